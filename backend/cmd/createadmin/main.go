@@ -0,0 +1,62 @@
+// Command createadmin bootstraps an administrator account.
+//
+//	go run ./cmd/createadmin -email admin@example.com -name "Site Admin"
+//
+// The password is read from -password or ADMIN_PASSWORD. When neither is set a
+// temporary password is generated, printed once, and must be changed on first login.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	appAuth "medapp/internal/auth"
+	"medapp/internal/db"
+	"medapp/internal/models"
+)
+
+func main() {
+	email := flag.String("email", "", "admin email address")
+	name := flag.String("name", "Administrator", "admin full name")
+	password := flag.String("password", os.Getenv("ADMIN_PASSWORD"), "admin password")
+	flag.Parse()
+
+	if strings.TrimSpace(*email) == "" {
+		log.Fatal("-email is required")
+	}
+
+	generated := false
+	if *password == "" {
+		temporary, err := appAuth.GenerateTemporaryPassword()
+		if err != nil {
+			log.Fatal("Failed to generate password: ", err)
+		}
+		*password = temporary
+		generated = true
+	} else if len(*password) < 6 {
+		log.Fatal("password must be at least 6 characters")
+	}
+
+	db.ConnectDB()
+
+	user, err := appAuth.NewService().CreateUser(&appAuth.RegisterPayload{
+		User: &models.User{
+			FullName:          *name,
+			Email:             strings.ToLower(*email),
+			Role:              models.RoleAdmin,
+			MustResetPassword: generated,
+		},
+		Password: *password,
+	})
+	if err != nil {
+		log.Fatal("Failed to create admin: ", err)
+	}
+
+	fmt.Printf("Created admin %s (id %d)\n", user.Email, user.ID)
+	if generated {
+		fmt.Printf("Temporary password: %s\n", *password)
+	}
+}
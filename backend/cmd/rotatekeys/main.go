@@ -0,0 +1,98 @@
+// Command rotatekeys re-encrypts sensitive columns under the active master key.
+//
+//	go run ./cmd/rotatekeys              # re-wrap data keys from retired master keys
+//	go run ./cmd/rotatekeys -rotate-kms  # add a new local KMS key first
+//	go run ./cmd/rotatekeys -full        # also replace every data key
+//
+// Rows written before encryption was enabled are encrypted on the way, and
+// blind indexes are rebuilt afterwards.
+//
+// After -rotate-kms, send SIGHUP to the running API (or restart it) so that it
+// wraps new values under the new key; until then it keeps using the old one,
+// and such values are picked up by the next run of this command. The API
+// reads values under the new key either way, as it rereads the key file when
+// it meets a key it does not know.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"medapp/internal/db"
+	"medapp/internal/fieldcrypt"
+	"medapp/internal/models"
+)
+
+// encryptedColumns lists every column tagged serializer:encrypted.
+var encryptedColumns = []fieldcrypt.Column{
+	{Table: "patient_profiles", Column: "allergies"},
+	{Table: "patient_profiles", Column: "chronic_conditions"},
+	{Table: "patient_profiles", Column: "emergency_contact"},
+	{Table: "appointments", Column: "notes"},
+	{Table: "patient_medical_infos", Column: "gender"},
+	{Table: "patient_medical_infos", Column: "age_group"},
+	{Table: "patient_medical_info_revisions", Column: "gender"},
+	{Table: "patient_medical_info_revisions", Column: "age_group"},
+	{Table: "patient_problems", Column: "notes"},
+	{Table: "hl7_messages", Column: "raw"},
+	{Table: "referrals", Column: "reason"},
+	{Table: "referrals", Column: "notes"},
+	{Table: "triage_records", Column: "input"},
+	{Table: "triage_records", Column: "output"},
+	{Table: "triage_records", Column: "prediction"},
+	{Table: "triage_records", Column: "feedback_note"},
+	{Table: "risk_assessments", Column: "features"},
+	{Table: "risk_assessments", Column: "result"},
+}
+
+func main() {
+	rotateKMS := flag.Bool("rotate-kms", false, "generate a new local KMS master key before re-encrypting")
+	full := flag.Bool("full", false, "re-encrypt values under fresh data keys instead of only re-wrapping them")
+	flag.Parse()
+
+	db.ConnectDB()
+
+	if *rotateKMS {
+		provider, err := fieldcrypt.Provider()
+		if err != nil {
+			log.Fatal(err)
+		}
+		kms, ok := provider.(*fieldcrypt.LocalKMS)
+		if !ok {
+			log.Fatal("-rotate-kms only applies to the local KMS; update FIELD_ENCRYPTION_KEYS and FIELD_ENCRYPTION_ACTIVE_KEY instead")
+		}
+		keyID, err := kms.Rotate()
+		if err != nil {
+			log.Fatal("Failed to rotate master key: ", err)
+		}
+		fmt.Printf("New active master key: %s\n", keyID)
+		fmt.Println("Send SIGHUP to the running API (or restart it) so that new values use this key.")
+	}
+
+	results, err := fieldcrypt.RotateColumns(db.DB, encryptedColumns, *full)
+	for _, r := range results {
+		fmt.Printf("%s.%s: %d scanned, %d updated\n", r.Column.Table, r.Column.Column, r.Scanned, r.Updated)
+	}
+	if err != nil {
+		log.Fatal("Rotation failed: ", err)
+	}
+
+	if err := rebuildBlindIndexes(); err != nil {
+		log.Fatal("Failed to rebuild blind indexes: ", err)
+	}
+}
+
+func rebuildBlindIndexes() error {
+	var infos []models.PatientMedicalInfo
+	if err := db.DB.Find(&infos).Error; err != nil {
+		return err
+	}
+	for i := range infos {
+		if err := db.DB.Model(&infos[i]).Select("GenderIndex", "AgeGroupIndex").Updates(&infos[i]).Error; err != nil {
+			return fmt.Errorf("medical info %d: %w", infos[i].ID, err)
+		}
+	}
+	fmt.Printf("patient_medical_infos: %d blind indexes rebuilt\n", len(infos))
+	return nil
+}
@@ -2,9 +2,16 @@ package main
 
 import (
 	"log"
+	"medapp/internal/adt"
 	"medapp/internal/api"
 	"medapp/internal/db"
+	"medapp/internal/duplicates"
+	"medapp/internal/erasure"
+	"medapp/internal/fieldcrypt"
+	"medapp/internal/mljobs"
+	"medapp/internal/patientsearch"
 	"os"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -17,8 +24,8 @@ func main() {
 	corsConfig := cors.Config{
 		AllowOrigins:     []string{"http://localhost:3000", "http://localhost:5173", "http://localhost:8081", "http://127.0.0.1:3000", "http://127.0.0.1:5173"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With"},
-		ExposeHeaders:    []string{"Content-Length", "Content-Type"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With", "If-Match"},
+		ExposeHeaders:    []string{"Content-Length", "Content-Type", "ETag", "X-Next-Cursor"},
 		AllowCredentials: true,
 		MaxAge:           12 * 60 * 60, // 12 hours
 	}
@@ -32,6 +39,14 @@ func main() {
 
 	r.Use(cors.New(corsConfig))
 	db.ConnectDB()
+	fieldcrypt.ReloadOnHangup()
+	patientsearch.Setup(db.DB)
+	erasure.StartPurger(24 * time.Hour)
+	duplicates.StartScanner(24 * time.Hour)
+	mljobs.Start()
+	if addr := os.Getenv("HL7_MLLP_ADDR"); addr != "" {
+		adt.Start(addr)
+	}
 	api.RegisterRoutes(r)
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -0,0 +1,99 @@
+// Package access decides whether a user may see or change a patient's record.
+package access
+
+import (
+	"errors"
+	"time"
+
+	"medapp/internal/db"
+	"medapp/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNoConsent = errors.New("patient has not granted consent for this access")
+	ErrForbidden = errors.New("forbidden")
+)
+
+// Check returns nil when user may access the given scope of the patient's record.
+// Patients may always access their own record and admins any record; doctors
+// need an active consent covering the scope.
+func Check(user *models.User, patientID uint, scope string) error {
+	switch user.Role {
+	case models.RoleAdmin:
+		return nil
+	case models.RolePatient:
+		if user.ID == patientID {
+			return nil
+		}
+		return ErrForbidden
+	case models.RoleDoctor:
+		_, err := ActiveConsent(user.ID, patientID, scope)
+		return err
+	}
+	return ErrForbidden
+}
+
+// ActiveConsent returns the doctor's consent for the patient if it is active
+// and covers scope, or ErrNoConsent.
+func ActiveConsent(doctorID, patientID uint, scope string) (*models.PatientConsent, error) {
+	var consents []models.PatientConsent
+	if err := activeConsents().
+		Where("doctor_id = ? AND patient_id = ?", doctorID, patientID).
+		Find(&consents).Error; err != nil {
+		return nil, err
+	}
+	for i := range consents {
+		if consents[i].HasScope(scope) {
+			return &consents[i], nil
+		}
+	}
+	return nil, ErrNoConsent
+}
+
+// ConsentedPatientIDs lists patients that granted the doctor an active consent covering scope.
+func ConsentedPatientIDs(doctorID uint, scope string) ([]uint, error) {
+	var consents []models.PatientConsent
+	if err := activeConsents().Where("doctor_id = ?", doctorID).Find(&consents).Error; err != nil {
+		return nil, err
+	}
+	seen := make(map[uint]bool, len(consents))
+	ids := make([]uint, 0, len(consents))
+	for _, consent := range consents {
+		if consent.HasScope(scope) && !seen[consent.PatientID] {
+			seen[consent.PatientID] = true
+			ids = append(ids, consent.PatientID)
+		}
+	}
+	return ids, nil
+}
+
+// ConsentScopes maps patient IDs to the union of scopes the doctor currently holds.
+func ConsentScopes(doctorID uint, patientIDs []uint) (map[uint]map[string]bool, error) {
+	scopes := make(map[uint]map[string]bool, len(patientIDs))
+	if len(patientIDs) == 0 {
+		return scopes, nil
+	}
+	var consents []models.PatientConsent
+	if err := activeConsents().
+		Where("doctor_id = ? AND patient_id IN ?", doctorID, patientIDs).
+		Find(&consents).Error; err != nil {
+		return nil, err
+	}
+	for _, consent := range consents {
+		if scopes[consent.PatientID] == nil {
+			scopes[consent.PatientID] = map[string]bool{}
+		}
+		for _, scope := range consent.ScopeList() {
+			scopes[consent.PatientID][scope] = true
+		}
+	}
+	return scopes, nil
+}
+
+func activeConsents() *gorm.DB {
+	return db.DB.Model(&models.PatientConsent{}).
+		Where("status = ?", models.ConsentActive).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now())
+}
@@ -0,0 +1,135 @@
+package access
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"medapp/internal/db"
+	"medapp/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotAssigned is returned for writes by doctors outside the patient's care team.
+var ErrNotAssigned = errors.New("patient is not assigned to this doctor")
+
+// Grant describes how an access check was satisfied.
+type Grant struct {
+	// Emergency is set when access relies on a break-the-glass override.
+	Emergency *models.EmergencyAccess
+}
+
+// Authorize is Check with a fallback to an active emergency override.
+func Authorize(user *models.User, patientID uint, scope string) (*Grant, error) {
+	err := Check(user, patientID, scope)
+	if err == nil {
+		return &Grant{}, nil
+	}
+	if !errors.Is(err, ErrNoConsent) {
+		return nil, err
+	}
+	return emergencyGrant(user, patientID, err)
+}
+
+// AuthorizeWrite additionally requires doctors to be assigned to the patient,
+// unless they hold an active emergency override.
+func AuthorizeWrite(user *models.User, patientID uint, scope string) (*Grant, error) {
+	if user.Role != models.RoleDoctor {
+		return Authorize(user, patientID, scope)
+	}
+
+	var assigned int64
+	if err := db.DB.Model(&models.DoctorPatient{}).
+		Where("doctor_id = ? AND patient_id = ?", user.ID, patientID).
+		Count(&assigned).Error; err != nil {
+		return nil, err
+	}
+	if assigned == 0 {
+		return emergencyGrant(user, patientID, ErrNotAssigned)
+	}
+	return Authorize(user, patientID, scope)
+}
+
+// ActiveEmergency returns the doctor's unexpired override for the patient, if any.
+func ActiveEmergency(doctorID, patientID uint) (*models.EmergencyAccess, error) {
+	var grant models.EmergencyAccess
+	err := db.DB.
+		Where("doctor_id = ? AND patient_id = ? AND ended_at IS NULL AND expires_at > ?", doctorID, patientID, time.Now()).
+		Order("expires_at DESC").
+		First(&grant).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+// Granted reports for each patient whether the user may see the given scope
+// of their record, counting active emergency overrides as Authorize does.
+func Granted(user *models.User, patientIDs []uint, scope string) (map[uint]bool, error) {
+	granted := make(map[uint]bool, len(patientIDs))
+	switch user.Role {
+	case models.RoleAdmin:
+		for _, id := range patientIDs {
+			granted[id] = true
+		}
+		return granted, nil
+	case models.RolePatient:
+		for _, id := range patientIDs {
+			if id == user.ID {
+				granted[id] = true
+			}
+		}
+		return granted, nil
+	}
+	if user.Role != models.RoleDoctor || len(patientIDs) == 0 {
+		return granted, nil
+	}
+
+	scopes, err := ConsentScopes(user.ID, patientIDs)
+	if err != nil {
+		return nil, err
+	}
+	for id, held := range scopes {
+		if held[scope] {
+			granted[id] = true
+		}
+	}
+	var overridden []uint
+	if err := db.DB.Model(&models.EmergencyAccess{}).
+		Where("doctor_id = ? AND patient_id IN ? AND ended_at IS NULL AND expires_at > ?", user.ID, patientIDs, time.Now()).
+		Pluck("patient_id", &overridden).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range overridden {
+		granted[id] = true
+	}
+	return granted, nil
+}
+
+// EmergencyTTL is how long a break-the-glass override lasts, from EMERGENCY_ACCESS_TTL.
+func EmergencyTTL() time.Duration {
+	if val := os.Getenv("EMERGENCY_ACCESS_TTL"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil && d > 0 {
+			return d
+		}
+	}
+	return time.Hour
+}
+
+func emergencyGrant(user *models.User, patientID uint, denied error) (*Grant, error) {
+	if user.Role != models.RoleDoctor {
+		return nil, denied
+	}
+	grant, err := ActiveEmergency(user.ID, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if grant == nil {
+		return nil, denied
+	}
+	return &Grant{Emergency: grant}, nil
+}
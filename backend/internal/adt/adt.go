@@ -0,0 +1,310 @@
+// Package adt applies HL7 v2 ADT registration (A04) and update (A08) messages
+// received over MLLP to patient accounts, keeping every message for replay.
+package adt
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"medapp/internal/audit"
+	"medapp/internal/db"
+	"medapp/internal/hl7"
+	"medapp/internal/models"
+	"medapp/internal/registry"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnsupportedMessage = errors.New("only ADT^A04 and ADT^A08 messages are supported")
+	ErrNotReplayable      = errors.New("only rejected messages can be replayed")
+)
+
+// identifierPrefix namespaces PID-3 identifiers by their assigning authority.
+const identifierPrefix = "urn:hl7:"
+
+// rejection is an error the sender has to fix; it is acknowledged with AR
+// rather than AE, which invites a retry.
+type rejection struct{ err error }
+
+func (r rejection) Error() string { return r.err.Error() }
+func (r rejection) Unwrap() error { return r.err }
+
+// Start serves MLLP connections on addr in the background. Only senders
+// listed in HL7_ALLOWED_SENDERS are accepted; without any the listener is
+// not started. HL7_MAX_CONNECTIONS caps the connections served at once.
+func Start(addr string) *hl7.Server {
+	senders, err := parseSenders(os.Getenv("HL7_ALLOWED_SENDERS"))
+	if err != nil {
+		log.Printf("adt: MLLP listener not started: HL7_ALLOWED_SENDERS: %v", err)
+		return nil
+	}
+	if len(senders) == 0 {
+		log.Printf("adt: MLLP listener not started: HL7_ALLOWED_SENDERS is empty")
+		return nil
+	}
+
+	server := &hl7.Server{
+		Addr: addr,
+		Handler: func(raw []byte, remoteAddr string) []byte {
+			return process(senders, raw, remoteAddr)
+		},
+	}
+	if value := os.Getenv("HL7_MAX_CONNECTIONS"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			server.MaxConns = n
+		} else {
+			log.Printf("adt: invalid HL7_MAX_CONNECTIONS %q, using the default", value)
+		}
+	}
+	go func() {
+		log.Printf("adt: listening for HL7 messages on %s", addr)
+		if err := server.ListenAndServe(); err != nil {
+			log.Printf("adt: MLLP listener stopped: %v", err)
+		}
+	}()
+	return server
+}
+
+// process applies one raw message and returns the acknowledgement for the
+// sender. Messages from senders that are not allowed are refused without
+// being stored.
+func process(senders []sender, raw []byte, remoteAddr string) []byte {
+	msg, err := hl7.Parse(raw)
+	facility := ""
+	if err == nil {
+		facility = msg.SendingFacility()
+	}
+	if !allowed(senders, facility, remoteAddr) {
+		log.Printf("adt: refused message from %s (sending facility %q): sender not allowed", remoteAddr, facility)
+		return hl7.Ack(msg, hl7.AckReject, "sender not allowed")
+	}
+
+	now := time.Now()
+	record := &models.HL7Message{
+		RemoteAddr:    remoteAddr,
+		Raw:           string(raw),
+		Attempts:      1,
+		LastAttemptAt: now,
+	}
+	if err != nil {
+		err = rejection{err}
+		record.Status = models.HL7MessageRejected
+		record.Error = err.Error()
+	} else {
+		record.MessageType, record.Event = msg.Type()
+		record.ControlID = msg.ControlID()
+		record.SendingApp = msg.SendingApplication()
+		record.SendingFacility = facility
+		err = apply(msg, record, remoteAddr)
+	}
+
+	if err := db.DB.Create(record).Error; err != nil {
+		log.Printf("adt: failed to store message %q from %s: %v", record.ControlID, remoteAddr, err)
+		return hl7.Ack(msg, hl7.AckError, "failed to store message")
+	}
+	if err != nil {
+		log.Printf("adt: rejected message %q from %s: %v", record.ControlID, remoteAddr, err)
+		return hl7.Ack(msg, ackCode(err), err.Error())
+	}
+	return hl7.Ack(msg, hl7.AckAccept, "")
+}
+
+// Replay applies a stored rejected message again on behalf of remoteAddr.
+func Replay(id uint, remoteAddr string) (*models.HL7Message, error) {
+	var record models.HL7Message
+	if err := db.DB.First(&record, id).Error; err != nil {
+		return nil, err
+	}
+	if record.Status != models.HL7MessageRejected {
+		return nil, ErrNotReplayable
+	}
+
+	record.Attempts++
+	record.LastAttemptAt = time.Now()
+	msg, err := hl7.Parse([]byte(record.Raw))
+	if err != nil {
+		record.Status = models.HL7MessageRejected
+		record.Error = err.Error()
+	} else {
+		apply(msg, &record, remoteAddr)
+	}
+
+	if err := db.DB.Model(&record).
+		Select("Status", "Error", "PatientID", "Attempts", "LastAttemptAt").
+		Updates(&record).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// apply updates the patient from msg and records the outcome on record.
+func apply(msg *hl7.Message, record *models.HL7Message, remoteAddr string) error {
+	err := applyADT(msg, record)
+	source := "hl7:" + record.SendingApp
+	detail := fmt.Sprintf("hl7 %s^%s %s", record.MessageType, record.Event, record.ControlID)
+	if err != nil {
+		record.Status = models.HL7MessageRejected
+		record.Error = err.Error()
+		audit.LogSystem(source, remoteAddr, audit.Entry{
+			Action:    audit.ActionUpdate,
+			Resource:  "patient_record",
+			PatientID: record.PatientID,
+			Outcome:   audit.OutcomeError,
+			Detail:    detail,
+		})
+		return err
+	}
+
+	record.Status = models.HL7MessageAccepted
+	record.Error = ""
+	action := audit.ActionUpdate
+	if record.Event == "A04" {
+		action = audit.ActionCreate
+	}
+	audit.LogSystem(source, remoteAddr, audit.Entry{
+		Action:    action,
+		Resource:  "patient_record",
+		PatientID: record.PatientID,
+		Detail:    detail,
+	})
+	return nil
+}
+
+func applyADT(msg *hl7.Message, record *models.HL7Message) error {
+	code, event := msg.Type()
+	if code != "ADT" || (event != "A04" && event != "A08") {
+		return rejection{ErrUnsupportedMessage}
+	}
+	data, err := hl7.ParsePID(msg)
+	if err != nil {
+		return rejection{err}
+	}
+
+	candidate := &registry.Candidate{
+		FullName:  data.FullName(),
+		Email:     data.Email,
+		Phone:     data.Phone,
+		BirthDate: data.BirthDate,
+	}
+	if data.Gender != "unknown" {
+		candidate.Gender = data.Gender
+	}
+	senderSystem := identifierPrefix + senderAuthority(record)
+	for _, identifier := range data.Identifiers {
+		authority := identifier.Authority
+		if authority == "" {
+			authority = senderAuthority(record)
+		}
+		candidate.Identifiers = append(candidate.Identifiers, registry.Identifier{
+			System: identifierPrefix + authority,
+			Value:  identifier.Value,
+		})
+	}
+
+	var patientID uint
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		// Looked up before matching, which links the message identifiers
+		// to whichever patient it finds.
+		var linked []uint
+		if event == "A08" {
+			if linked, err = linkedPatients(tx, candidate, senderSystem); err != nil {
+				return err
+			}
+		}
+		user, created, err := registry.MatchOrCreatePatient(tx, candidate)
+		if err != nil {
+			if errors.Is(err, registry.ErrInsufficientData) || errors.Is(err, registry.ErrIdentifierConflict) {
+				return rejection{err}
+			}
+			return err
+		}
+		patientID = user.ID
+		if event == "A08" && !created && slices.Contains(linked, user.ID) {
+			return overwrite(tx, user, candidate)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	record.PatientID = &patientID
+	return nil
+}
+
+// senderAuthority is the assigning authority of the identifiers the sender
+// issues itself: its facility, or its application when MSH-4 is empty.
+func senderAuthority(record *models.HL7Message) string {
+	if record.SendingFacility != "" {
+		return record.SendingFacility
+	}
+	return record.SendingApp
+}
+
+// linkedPatients returns the patients already holding one of the
+// candidate's identifiers issued under system.
+func linkedPatients(tx *gorm.DB, candidate *registry.Candidate, system string) ([]uint, error) {
+	var values []string
+	for _, identifier := range candidate.Identifiers {
+		if identifier.System == system {
+			values = append(values, identifier.Value)
+		}
+	}
+	var ids []uint
+	if len(values) == 0 {
+		return ids, nil
+	}
+	err := tx.Model(&models.PatientIdentifier{}).
+		Where("system = ? AND value IN ?", system, values).
+		Pluck("patient_id", &ids).Error
+	return ids, err
+}
+
+// overwrite applies an A08 update. Unlike registration, which only fills
+// empty fields, the sending system is treated as authoritative for the
+// demographics it sends; fields it leaves empty are kept. It only applies
+// to patients the sender already knows under its own identifier; any other
+// match is updated like a registration.
+func overwrite(tx *gorm.DB, user *models.User, candidate *registry.Candidate) error {
+	updates := map[string]interface{}{}
+	if name := strings.TrimSpace(candidate.FullName); name != "" && name != user.FullName {
+		updates["full_name"] = name
+	}
+	if candidate.Phone != "" && candidate.Phone != user.Phone {
+		updates["phone"] = candidate.Phone
+	}
+	if len(updates) > 0 {
+		if err := tx.Model(user).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+
+	profile := user.PatientProfile
+	var fields []string
+	if candidate.BirthDate != nil {
+		profile.DateOfBirth = candidate.BirthDate
+		fields = append(fields, "DateOfBirth")
+	}
+	if candidate.Gender != "" {
+		profile.Gender = candidate.Gender
+		fields = append(fields, "Gender")
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return tx.Model(profile).Select(fields).Updates(profile).Error
+}
+
+func ackCode(err error) string {
+	var r rejection
+	if errors.As(err, &r) {
+		return hl7.AckReject
+	}
+	return hl7.AckError
+}
@@ -0,0 +1,85 @@
+package adt
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// sender is one HL7_ALLOWED_SENDERS entry. An entry names a sending
+// facility (MSH-4), a peer address or CIDR range, or both as
+// FACILITY@ADDRESS; every part it names has to match.
+type sender struct {
+	facility string
+	network  *net.IPNet
+}
+
+// parseSenders reads a comma-separated HL7_ALLOWED_SENDERS value.
+func parseSenders(value string) ([]sender, error) {
+	var senders []sender
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		var s sender
+		facility, address, hasAddress := strings.Cut(entry, "@")
+		if !hasAddress && isAddress(entry) {
+			facility, address, hasAddress = "", entry, true
+		}
+		s.facility = strings.TrimSpace(facility)
+		if hasAddress {
+			network, err := parseNetwork(strings.TrimSpace(address))
+			if err != nil {
+				return nil, fmt.Errorf("invalid sender %q: %w", entry, err)
+			}
+			s.network = network
+		}
+		if s.facility == "" && s.network == nil {
+			return nil, fmt.Errorf("invalid sender %q", entry)
+		}
+		senders = append(senders, s)
+	}
+	return senders, nil
+}
+
+func isAddress(value string) bool {
+	if net.ParseIP(value) != nil {
+		return true
+	}
+	_, _, err := net.ParseCIDR(value)
+	return err == nil
+}
+
+// parseNetwork reads an IP address as a single-host network, or a CIDR range.
+func parseNetwork(value string) (*net.IPNet, error) {
+	if ip := net.ParseIP(value); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, network, err := net.ParseCIDR(value)
+	return network, err
+}
+
+// allowed reports whether a message from facility, received from
+// remoteAddr (host:port), matches one of the senders.
+func allowed(senders []sender, facility, remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	for _, s := range senders {
+		if s.facility != "" && !strings.EqualFold(s.facility, facility) {
+			continue
+		}
+		if s.network != nil && (ip == nil || !s.network.Contains(ip)) {
+			continue
+		}
+		return true
+	}
+	return false
+}
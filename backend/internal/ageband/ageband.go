@@ -0,0 +1,205 @@
+// Package ageband derives a patient's age and age band from their date of
+// birth. Bands are configured with AGE_BANDS; a manually recorded band is
+// only used for patients whose date of birth is unknown.
+package ageband
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSpec matches the age groups doctors picked by hand before bands were derived.
+const DefaultSpec = "0-18,19-35,36-50,51-65,65+:66-"
+
+// Where an age band came from.
+const (
+	SourceDateOfBirth = "dateOfBirth"
+	SourceManual      = "manual"
+)
+
+// Band is an inclusive range of ages in whole years. Max is -1 for an open-ended band.
+type Band struct {
+	Label string `json:"label"`
+	Min   int    `json:"min"`
+	Max   int    `json:"max"`
+}
+
+// Contains reports whether age falls in the band.
+func (b Band) Contains(age int) bool {
+	return age >= b.Min && (b.Max < 0 || age <= b.Max)
+}
+
+// Within reports whether every age in the band lies in [min, max]; max < 0 is unbounded.
+func (b Band) Within(min, max int) bool {
+	if b.Min < min {
+		return false
+	}
+	if max < 0 {
+		return true
+	}
+	return b.Max >= 0 && b.Max <= max
+}
+
+// Parse reads a comma-separated band list. Each band is "min-max", "min-" or
+// "min+" for an open upper end, optionally prefixed by "label:"; the range
+// itself is the label otherwise. Bands must be in ascending order and must
+// not overlap.
+func Parse(spec string) ([]Band, error) {
+	var bands []Band
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		label, rng, hasLabel := strings.Cut(item, ":")
+		if !hasLabel {
+			rng = label
+		}
+		label, rng = strings.TrimSpace(label), strings.TrimSpace(rng)
+
+		band := Band{Label: label, Max: -1}
+		minText, maxText, hasDash := strings.Cut(strings.TrimSuffix(rng, "+"), "-")
+		if !hasDash && !strings.HasSuffix(rng, "+") {
+			return nil, fmt.Errorf("age band %q must be min-max, min- or min+", item)
+		}
+		var err error
+		if band.Min, err = strconv.Atoi(strings.TrimSpace(minText)); err != nil || band.Min < 0 {
+			return nil, fmt.Errorf("age band %q has an invalid lower bound", item)
+		}
+		if maxText = strings.TrimSpace(maxText); maxText != "" {
+			if band.Max, err = strconv.Atoi(maxText); err != nil || band.Max < band.Min {
+				return nil, fmt.Errorf("age band %q has an invalid upper bound", item)
+			}
+		}
+		if n := len(bands); n > 0 {
+			previous := bands[n-1]
+			if previous.Max < 0 || band.Min <= previous.Max {
+				return nil, fmt.Errorf("age band %q overlaps %q", item, previous.Label)
+			}
+		}
+		for _, b := range bands {
+			if b.Label == band.Label {
+				return nil, fmt.Errorf("age band label %q is used twice", band.Label)
+			}
+		}
+		bands = append(bands, band)
+	}
+	if len(bands) == 0 {
+		return nil, errors.New("no age bands given")
+	}
+	return bands, nil
+}
+
+var (
+	loadOnce sync.Once
+	loaded   []Band
+)
+
+// Bands returns the configured bands, from AGE_BANDS or DefaultSpec.
+func Bands() []Band {
+	loadOnce.Do(func() {
+		spec := os.Getenv("AGE_BANDS")
+		if spec != "" {
+			bands, err := Parse(spec)
+			if err == nil {
+				loaded = bands
+				return
+			}
+			log.Printf("ageband: ignoring invalid AGE_BANDS: %v", err)
+		}
+		loaded, _ = Parse(DefaultSpec)
+	})
+	return loaded
+}
+
+// Lookup returns the configured band with the given label.
+func Lookup(label string) (Band, bool) {
+	for _, b := range Bands() {
+		if b.Label == label {
+			return b, true
+		}
+	}
+	return Band{}, false
+}
+
+// For returns the label of the band containing age, or "" when none does.
+func For(age int) string {
+	for _, b := range Bands() {
+		if b.Contains(age) {
+			return b.Label
+		}
+	}
+	return ""
+}
+
+// Age is the age in whole years on now of someone born on dob.
+func Age(dob, now time.Time) int {
+	years := now.Year() - dob.Year()
+	if now.Month() < dob.Month() || (now.Month() == dob.Month() && now.Day() < dob.Day()) {
+		years--
+	}
+	if years < 0 {
+		return 0
+	}
+	return years
+}
+
+// Result is a patient's derived age and band.
+type Result struct {
+	Age    *int   `json:"age"` // nil when the date of birth is unknown
+	Band   string `json:"ageGroup"`
+	Source string `json:"ageGroupSource,omitempty"`
+}
+
+// Resolve derives the age and band from dob when it is known and falls back
+// to the manually recorded band otherwise.
+func Resolve(dob *time.Time, manual string, now time.Time) Result {
+	if dob != nil && !dob.IsZero() {
+		age := Age(*dob, now)
+		return Result{Age: &age, Band: For(age), Source: SourceDateOfBirth}
+	}
+	if manual == "" {
+		return Result{}
+	}
+	return Result{Band: manual, Source: SourceManual}
+}
+
+// InRange reports whether a resolved age lies in [min, max], where max < 0 is
+// unbounded. Without an exact age, the whole manual band must lie in the range.
+func (r Result) InRange(min, max int) bool {
+	if r.Age != nil {
+		return *r.Age >= min && (max < 0 || *r.Age <= max)
+	}
+	band, ok := Lookup(r.Band)
+	return ok && band.Within(min, max)
+}
+
+// BirthDates converts an age range to dates of birth: someone aged between
+// min and max on now was born on or before latest and, when max >= 0, after
+// earliest. Dates are at midnight UTC.
+func BirthDates(min, max int, now time.Time) (latest time.Time, earliest *time.Time) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	latest = today.AddDate(-min, 0, 0)
+	if max >= 0 {
+		e := today.AddDate(-(max + 1), 0, 0)
+		earliest = &e
+	}
+	return latest, earliest
+}
+
+// Labels returns the labels of the configured bands lying wholly in [min, max].
+func Labels(min, max int) []string {
+	var labels []string
+	for _, b := range Bands() {
+		if b.Within(min, max) {
+			labels = append(labels, b.Label)
+		}
+	}
+	return labels
+}
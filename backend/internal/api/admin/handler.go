@@ -0,0 +1,255 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"medapp/internal/api/middleware"
+	"medapp/internal/audit"
+	appAuth "medapp/internal/auth"
+	"medapp/internal/db"
+	"medapp/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+var service = appAuth.NewService()
+
+type changeRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+func RegisterRoutes(r *gin.RouterGroup) {
+	r.Use(middleware.AuthRequired(), middleware.RequireRole(models.RoleAdmin))
+	r.GET("/users", listUsers)
+	r.GET("/users/:id", getUser)
+	r.PUT("/users/:id/role", changeRole)
+	r.POST("/users/:id/deactivate", deactivateUser)
+	r.POST("/users/:id/reactivate", reactivateUser)
+	r.POST("/users/:id/reset-password", resetPassword)
+}
+
+func listUsers(c *gin.Context) {
+	page, pageSize := parsePagination(c)
+
+	query := db.DB.Model(&models.User{})
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		like := "%" + strings.ToLower(q) + "%"
+		query = query.Where("LOWER(full_name) LIKE ? OR LOWER(email) LIKE ? OR phone LIKE ?", like, like, like)
+	}
+	if role := c.Query("role"); role != "" {
+		query = query.Where("role = ?", strings.ToLower(role))
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", strings.ToLower(status))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count users"})
+		return
+	}
+
+	var users []models.User
+	if err := query.
+		Order("created_at DESC, id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load users"})
+		return
+	}
+
+	items := make([]gin.H, 0, len(users))
+	for i := range users {
+		items = append(items, toUserResponse(&users[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":    items,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+}
+
+func getUser(c *gin.Context) {
+	user, ok := loadUser(c)
+	if !ok {
+		return
+	}
+
+	response := toUserResponse(user)
+	if user.DoctorProfile != nil {
+		response["doctorProfile"] = user.DoctorProfile
+	}
+	if user.PatientProfile != nil {
+		response["patientProfile"] = user.PatientProfile
+		audit.Log(c, middleware.CurrentUser(c), audit.Entry{
+			Action:     audit.ActionRead,
+			Resource:   "patient_profile",
+			ResourceID: audit.ID(user.ID),
+			PatientID:  audit.PatientRef(user.ID),
+			Detail:     "admin user detail",
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func changeRole(c *gin.Context) {
+	admin := middleware.CurrentUser(c)
+	user, ok := loadUser(c)
+	if !ok {
+		return
+	}
+
+	var req changeRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role := models.Role(strings.ToLower(req.Role))
+	switch role {
+	case models.RoleDoctor, models.RolePatient, models.RoleAdmin:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be doctor, patient or admin"})
+		return
+	}
+
+	if user.ID == admin.ID && role != models.RoleAdmin {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "admins cannot demote themselves"})
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("role", role).Error; err != nil {
+			return err
+		}
+		// Role-specific handlers expect the matching profile to exist.
+		if role == models.RoleDoctor && user.DoctorProfile == nil {
+			user.DoctorProfile = &models.DoctorProfile{UserID: user.ID}
+			if err := tx.Create(user.DoctorProfile).Error; err != nil {
+				return err
+			}
+		}
+		if role == models.RolePatient && user.PatientProfile == nil {
+			user.PatientProfile = &models.PatientProfile{UserID: user.ID}
+			if err := tx.Create(user.PatientProfile).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to change role"})
+		return
+	}
+
+	user.Role = role
+	c.JSON(http.StatusOK, toUserResponse(user))
+}
+
+func deactivateUser(c *gin.Context) {
+	admin := middleware.CurrentUser(c)
+	user, ok := loadUser(c)
+	if !ok {
+		return
+	}
+	if user.ID == admin.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "admins cannot deactivate themselves"})
+		return
+	}
+	setStatus(c, user, models.UserStatusDeactivated)
+}
+
+func reactivateUser(c *gin.Context) {
+	user, ok := loadUser(c)
+	if !ok {
+		return
+	}
+	setStatus(c, user, models.UserStatusActive)
+}
+
+func resetPassword(c *gin.Context) {
+	user, ok := loadUser(c)
+	if !ok {
+		return
+	}
+
+	temporary, err := service.ResetPassword(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user":              toUserResponse(user),
+		"temporaryPassword": temporary,
+	})
+}
+
+func setStatus(c *gin.Context, user *models.User, status string) {
+	if err := db.DB.Model(user).Update("status", status).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update status"})
+		return
+	}
+	user.Status = status
+	c.JSON(http.StatusOK, toUserResponse(user))
+}
+
+func loadUser(c *gin.Context) (*models.User, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return nil, false
+	}
+
+	var user models.User
+	if err := db.DB.Preload("DoctorProfile").Preload("PatientProfile").First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load user"})
+		return nil, false
+	}
+	return &user, true
+}
+
+func parsePagination(c *gin.Context) (int, int) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", strconv.Itoa(defaultPageSize)))
+	if err != nil || pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	return page, pageSize
+}
+
+func toUserResponse(user *models.User) gin.H {
+	return gin.H{
+		"id":                user.ID,
+		"email":             user.Email,
+		"fullName":          user.FullName,
+		"phone":             user.Phone,
+		"role":              user.Role,
+		"status":            user.Status,
+		"mustResetPassword": user.MustResetPassword,
+		"createdAt":         user.CreatedAt,
+		"updatedAt":         user.UpdatedAt,
+	}
+}
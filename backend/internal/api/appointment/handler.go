@@ -8,7 +8,9 @@ import (
 	"time"
 
 	"medapp/internal/api/middleware"
+	"medapp/internal/audit"
 	"medapp/internal/db"
+	"medapp/internal/fieldcrypt"
 	"medapp/internal/models"
 
 	"github.com/gin-gonic/gin"
@@ -22,6 +24,7 @@ type createAppointmentRequest struct {
 	ScheduledAt string `json:"scheduledAt" binding:"required"`
 	DurationMin int    `json:"durationMin"`
 	Reason      string `json:"reason"`
+	TriageID    *uint  `json:"triageId"` // one of the patient's symptom checks, shown to the doctor
 }
 
 type updateAppointmentRequest struct {
@@ -54,7 +57,7 @@ func listAppointments(c *gin.Context) {
 	}
 
 	status := c.Query("status")
-	query := db.DB.Preload("Doctor").Preload("Patient").Order("scheduled_at DESC")
+	query := db.DB.Preload("Doctor").Preload("Patient").Preload("Triage").Order("scheduled_at DESC")
 
 	switch user.Role {
 	case models.RoleDoctor:
@@ -75,10 +78,13 @@ func listAppointments(c *gin.Context) {
 		return
 	}
 
+	entries := make([]audit.Entry, 0, len(appointments))
 	responses := make([]gin.H, 0, len(appointments))
 	for _, appt := range appointments {
+		entries = append(entries, appointmentEntry(audit.ActionRead, audit.OutcomeSuccess, &appt))
 		responses = append(responses, toAppointmentResponse(&appt))
 	}
+	audit.LogMany(c, user, entries)
 
 	c.JSON(http.StatusOK, responses)
 }
@@ -102,6 +108,23 @@ func createAppointment(c *gin.Context) {
 		return
 	}
 
+	var doctorProfile models.DoctorProfile
+	if err := db.DB.
+		Joins("JOIN users ON users.id = doctor_profiles.user_id").
+		Where("doctor_profiles.user_id = ? AND users.role = ? AND users.status = ?", req.DoctorID, models.RoleDoctor, models.UserStatusActive).
+		First(&doctorProfile).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "doctor not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check doctor"})
+		return
+	}
+	if doctorProfile.VerificationStatus != models.VerificationVerified {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "doctor is not accepting appointments"})
+		return
+	}
+
 	appointment := models.Appointment{
 		DoctorID:    req.DoctorID,
 		PatientID:   user.ID,
@@ -110,11 +133,28 @@ func createAppointment(c *gin.Context) {
 		Reason:      req.Reason,
 		Status:      models.AppointmentPending,
 	}
+	if req.TriageID != nil {
+		var triage models.TriageRecord
+		if err := db.DB.Where("id = ? AND patient_id = ?", *req.TriageID, user.ID).First(&triage).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "symptom check not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load symptom check"})
+			return
+		}
+		appointment.TriageID = &triage.ID
+		if strings.TrimSpace(appointment.Reason) == "" && triage.Prediction != "" {
+			appointment.Reason = "Symptom check: " + triage.Prediction
+		}
+	}
 
 	if err := db.DB.Create(&appointment).Error; err != nil {
+		audit.Log(c, user, appointmentEntry(audit.ActionCreate, audit.OutcomeError, &appointment))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create appointment"})
 		return
 	}
+	audit.Log(c, user, appointmentEntry(audit.ActionCreate, audit.OutcomeSuccess, &appointment))
 
 	if err := preloadAppointment(&appointment); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load appointment"})
@@ -133,7 +173,7 @@ func updateAppointment(c *gin.Context) {
 
 	appointment, err := getAppointmentForUser(c.Param("id"), user)
 	if err != nil {
-		handleAppointmentError(c, err)
+		handleAppointmentError(c, user, err)
 		return
 	}
 
@@ -159,7 +199,13 @@ func updateAppointment(c *gin.Context) {
 		updates["reason"] = *req.Reason
 	}
 	if req.Notes != nil && user.Role == models.RoleDoctor {
-		updates["notes"] = *req.Notes
+		notes, err := fieldcrypt.Encrypt(*req.Notes)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to encrypt notes"})
+			return
+		}
+		// Map updates skip the column serializer, so notes are stored pre-encrypted.
+		updates["notes"] = notes
 	}
 
 	if len(updates) == 0 {
@@ -168,9 +214,11 @@ func updateAppointment(c *gin.Context) {
 	}
 
 	if err := db.DB.Model(&appointment).Updates(updates).Error; err != nil {
+		audit.Log(c, user, appointmentEntry(audit.ActionUpdate, audit.OutcomeError, appointment))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update appointment"})
 		return
 	}
+	audit.Log(c, user, appointmentEntry(audit.ActionUpdate, audit.OutcomeSuccess, appointment))
 
 	if err := preloadAppointment(appointment); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load appointment"})
@@ -189,7 +237,7 @@ func updateStatus(c *gin.Context) {
 
 	appointment, err := getAppointmentForUser(c.Param("id"), user)
 	if err != nil {
-		handleAppointmentError(c, err)
+		handleAppointmentError(c, user, err)
 		return
 	}
 
@@ -228,13 +276,20 @@ func updateStatus(c *gin.Context) {
 		"status": status,
 	}
 	if req.Notes != nil {
-		updates["notes"] = *req.Notes
+		notes, err := fieldcrypt.Encrypt(*req.Notes)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to encrypt notes"})
+			return
+		}
+		updates["notes"] = notes
 	}
 
 	if err := db.DB.Model(appointment).Updates(updates).Error; err != nil {
+		audit.Log(c, user, appointmentEntry(audit.ActionUpdate, audit.OutcomeError, appointment))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update status"})
 		return
 	}
+	audit.Log(c, user, appointmentEntry(audit.ActionUpdate, audit.OutcomeSuccess, appointment))
 
 	if err := preloadAppointment(appointment); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load appointment"})
@@ -262,23 +317,51 @@ func getAppointmentForUser(idParam string, user *models.User) (*models.Appointme
 	switch user.Role {
 	case models.RoleDoctor:
 		if appointment.DoctorID != user.ID {
-			return nil, errPermissionDenied
+			return nil, &permissionError{appointment: &appointment}
 		}
 	case models.RolePatient:
 		if appointment.PatientID != user.ID {
-			return nil, errPermissionDenied
+			return nil, &permissionError{appointment: &appointment}
 		}
 	}
 
 	return &appointment, nil
 }
 
+// permissionError carries the appointment that was denied so it can be audited.
+type permissionError struct {
+	appointment *models.Appointment
+}
+
+func (e *permissionError) Error() string {
+	return errPermissionDenied.Error()
+}
+
+func (e *permissionError) Unwrap() error {
+	return errPermissionDenied
+}
+
+func appointmentEntry(action, outcome string, appt *models.Appointment) audit.Entry {
+	entry := audit.Entry{
+		Action:    action,
+		Resource:  "appointment",
+		PatientID: audit.PatientRef(appt.PatientID),
+		Outcome:   outcome,
+	}
+	if appt.ID != 0 {
+		entry.ResourceID = audit.ID(appt.ID)
+	}
+	return entry
+}
+
 func preloadAppointment(appointment *models.Appointment) error {
-	return db.DB.Preload("Doctor").Preload("Patient").First(appointment, appointment.ID).Error
+	return db.DB.Preload("Doctor").Preload("Patient").Preload("Triage").First(appointment, appointment.ID).Error
 }
 
-func handleAppointmentError(c *gin.Context, err error) {
-	if errors.Is(err, errPermissionDenied) {
+func handleAppointmentError(c *gin.Context, user *models.User, err error) {
+	var denied *permissionError
+	if errors.As(err, &denied) {
+		audit.Log(c, user, appointmentEntry(audit.ActionUpdate, audit.OutcomeDenied, denied.appointment))
 		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed"})
 		return
 	}
@@ -299,6 +382,7 @@ func toAppointmentResponse(appt *models.Appointment) gin.H {
 		"status":      appt.Status,
 		"reason":      appt.Reason,
 		"notes":       appt.Notes,
+		"triageId":    appt.TriageID,
 		"createdAt":   appt.CreatedAt,
 		"updatedAt":   appt.UpdatedAt,
 	}
@@ -316,5 +400,14 @@ func toAppointmentResponse(appt *models.Appointment) gin.H {
 			"fullName": appt.Patient.FullName,
 		}
 	}
+	if appt.Triage != nil {
+		response["triage"] = gin.H{
+			"id":         appt.Triage.ID,
+			"createdAt":  appt.Triage.CreatedAt,
+			"prediction": appt.Triage.Prediction,
+			"confidence": appt.Triage.Confidence,
+			"urgency":    appt.Triage.Urgency,
+		}
+	}
 	return response
 }
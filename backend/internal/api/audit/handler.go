@@ -0,0 +1,199 @@
+package audit
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"medapp/internal/api/middleware"
+	appAudit "medapp/internal/audit"
+	"medapp/internal/db"
+	"medapp/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// RegisterAdminRoutes exposes the full audit trail to admins.
+func RegisterAdminRoutes(r *gin.RouterGroup) {
+	r.Use(middleware.AuthRequired(), middleware.RequireRole(models.RoleAdmin))
+	r.GET("", queryAuditLog)
+	r.GET("/", queryAuditLog)
+	r.GET("/verify", verifyAuditLog)
+}
+
+// RegisterPatientRoutes lets patients see who accessed their record.
+func RegisterPatientRoutes(r *gin.RouterGroup) {
+	r.GET("/access-log", middleware.AuthRequired(), middleware.RequireRole(models.RolePatient), patientAccessLog)
+}
+
+func queryAuditLog(c *gin.Context) {
+	page, pageSize := parsePagination(c)
+
+	query := db.DB.Model(&models.AuditLog{})
+	for param, column := range map[string]string{
+		"actorId":   "actor_id",
+		"patientId": "patient_id",
+	} {
+		if value := c.Query(param); value != "" {
+			id, err := strconv.Atoi(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + param})
+				return
+			}
+			query = query.Where(column+" = ?", id)
+		}
+	}
+	for _, param := range []string{"action", "resource", "outcome"} {
+		if value := c.Query(param); value != "" {
+			query = query.Where(param+" = ?", value)
+		}
+	}
+	if value := c.Query("resourceId"); value != "" {
+		query = query.Where("resource_id = ?", value)
+	}
+	if c.Query("emergency") == "true" {
+		query = query.Where("emergency_access_id IS NOT NULL")
+	}
+
+	from, to, ok := parseRange(c)
+	if !ok {
+		return
+	}
+	if from != nil {
+		query = query.Where("created_at >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("created_at < ?", *to)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count audit entries"})
+		return
+	}
+
+	var entries []models.AuditLog
+	if err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load audit entries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":    entries,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+}
+
+func verifyAuditLog(c *gin.Context) {
+	result, err := appAudit.Verify()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify audit log"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func patientAccessLog(c *gin.Context) {
+	patient := middleware.CurrentUser(c)
+	if patient == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+
+	page, pageSize := parsePagination(c)
+	query := db.DB.Model(&models.AuditLog{}).
+		Where("patient_id = ? AND actor_id <> ? AND outcome = ?", patient.ID, patient.ID, appAudit.OutcomeSuccess)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count access entries"})
+		return
+	}
+
+	var entries []models.AuditLog
+	if err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load access entries"})
+		return
+	}
+
+	actorIDs := make([]uint, 0, len(entries))
+	for _, e := range entries {
+		actorIDs = append(actorIDs, e.ActorID)
+	}
+	var actors []models.User
+	if len(actorIDs) > 0 {
+		if err := db.DB.Where("id IN ?", actorIDs).Find(&actors).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load actors"})
+			return
+		}
+	}
+	names := make(map[uint]string, len(actors))
+	for _, a := range actors {
+		names[a.ID] = a.FullName
+	}
+
+	items := make([]gin.H, 0, len(entries))
+	for _, e := range entries {
+		items = append(items, gin.H{
+			"id":         e.ID,
+			"accessedAt": e.CreatedAt,
+			"action":     e.Action,
+			"resource":   e.Resource,
+			"emergency":  e.EmergencyAccessID != nil,
+			"actor": gin.H{
+				"id":       e.ActorID,
+				"fullName": names[e.ActorID],
+				"role":     e.ActorRole,
+			},
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":    items,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+}
+
+func parseRange(c *gin.Context) (*time.Time, *time.Time, bool) {
+	var from, to *time.Time
+	for _, p := range []struct {
+		name string
+		dest **time.Time
+	}{{"from", &from}, {"to", &to}} {
+		value := c.Query(p.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + p.name})
+			return nil, nil, false
+		}
+		*p.dest = &parsed
+	}
+	return from, to, true
+}
+
+func parsePagination(c *gin.Context) (int, int) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", strconv.Itoa(defaultPageSize)))
+	if err != nil || pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	return page, pageSize
+}
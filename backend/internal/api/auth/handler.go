@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 	"time"
@@ -43,6 +44,11 @@ type PatientProfileInput struct {
 	EmergencyContact  string     `json:"emergencyContact"`
 }
 
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+	NewPassword     string `json:"newPassword" binding:"required,min=6"`
+}
+
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
@@ -53,7 +59,8 @@ var service = appAuth.NewService()
 func RegisterRoutes(r *gin.RouterGroup) {
 	r.POST("/register", registerHandler)
 	r.POST("/login", loginHandler)
-	r.GET("/me", middleware.AuthRequired(), meHandler)
+	r.GET("/me", middleware.AuthRequiredAllowReset(), meHandler)
+	r.POST("/password", middleware.AuthRequiredAllowReset(), changePasswordHandler)
 }
 
 func registerHandler(c *gin.Context) {
@@ -99,6 +106,8 @@ func registerHandler(c *gin.Context) {
 			Bio:             req.DoctorProfile.Bio,
 			AvatarURL:       req.DoctorProfile.AvatarURL,
 			ConsultationFee: req.DoctorProfile.ConsultationFee,
+			// Doctors get patient access only after an admin reviews their license.
+			VerificationStatus: models.VerificationUnverified,
 		}
 	}
 
@@ -137,6 +146,10 @@ func loginHandler(c *gin.Context) {
 	}
 
 	res, err := service.Authenticate(strings.ToLower(req.Email), req.Password)
+	if errors.Is(err, appAuth.ErrAccountDeactivated) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
@@ -145,6 +158,28 @@ func loginHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, res)
 }
 
+func changePasswordHandler(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := service.ChangePassword(user, req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	sanitizeUser(res.User)
+	c.JSON(http.StatusOK, res)
+}
+
 func meHandler(c *gin.Context) {
 	user := middleware.CurrentUser(c)
 	if user == nil {
@@ -0,0 +1,378 @@
+package consent
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"medapp/internal/api/middleware"
+	"medapp/internal/audit"
+	"medapp/internal/db"
+	"medapp/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const maxConsentDays = 365
+
+type requestConsentRequest struct {
+	PatientID    uint     `json:"patientId"`
+	PatientEmail string   `json:"patientEmail"`
+	Scopes       []string `json:"scopes" binding:"required,min=1"`
+	Reason       string   `json:"reason" binding:"required"`
+	DurationDays int      `json:"durationDays"`
+}
+
+type approveConsentRequest struct {
+	Scopes       []string `json:"scopes"`       // optional subset of the requested scopes
+	DurationDays *int     `json:"durationDays"` // optional, may only shorten the request
+	Note         string   `json:"note"`
+}
+
+type respondConsentRequest struct {
+	Note string `json:"note"`
+}
+
+// RegisterRoutes exposes consent requests to doctors.
+func RegisterRoutes(r *gin.RouterGroup) {
+	r.Use(middleware.AuthRequired(), middleware.RequireRole(models.RoleDoctor), middleware.RequireVerifiedDoctor())
+	r.GET("", listDoctorConsents)
+	r.GET("/", listDoctorConsents)
+	r.POST("", requestConsent)
+	r.POST("/", requestConsent)
+}
+
+// RegisterPatientRoutes lets patients answer and revoke consents.
+func RegisterPatientRoutes(r *gin.RouterGroup) {
+	g := r.Group("/consents")
+	g.Use(middleware.AuthRequired(), middleware.RequireRole(models.RolePatient))
+	g.GET("", listPatientConsents)
+	g.GET("/", listPatientConsents)
+	g.POST("/:id/approve", approveConsent)
+	g.POST("/:id/deny", denyConsent)
+	g.POST("/:id/revoke", revokeConsent)
+}
+
+func requestConsent(c *gin.Context) {
+	doctor := middleware.CurrentUser(c)
+	if doctor == nil || doctor.Role != models.RoleDoctor {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only doctors can request consent"})
+		return
+	}
+
+	var req requestConsentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.DurationDays < 0 || req.DurationDays > maxConsentDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "durationDays must be between 0 and 365"})
+		return
+	}
+
+	query := db.DB.Where("role = ?", models.RolePatient)
+	switch {
+	case req.PatientID != 0:
+		query = query.Where("id = ?", req.PatientID)
+	case req.PatientEmail != "":
+		query = query.Where("email = ?", strings.ToLower(strings.TrimSpace(req.PatientEmail)))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "patientId or patientEmail is required"})
+		return
+	}
+	var patient models.User
+	if err := query.First(&patient).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "patient not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check patient"})
+		return
+	}
+
+	var open models.PatientConsent
+	err = db.DB.
+		Where("doctor_id = ? AND patient_id = ? AND status IN ?", doctor.ID, patient.ID, []models.ConsentStatus{models.ConsentPending, models.ConsentActive}).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		First(&open).Error
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "an open consent already exists for this patient", "consent": toConsentResponse(&open)})
+		return
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check existing consent"})
+		return
+	}
+
+	consent := models.PatientConsent{
+		PatientID:    patient.ID,
+		DoctorID:     doctor.ID,
+		Scopes:       strings.Join(scopes, ","),
+		Status:       models.ConsentPending,
+		Reason:       strings.TrimSpace(req.Reason),
+		DurationDays: req.DurationDays,
+	}
+	if err := db.DB.Create(&consent).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to request consent"})
+		return
+	}
+	logConsent(c, doctor, audit.ActionCreate, &consent)
+
+	c.JSON(http.StatusCreated, toConsentResponse(&consent))
+}
+
+func listDoctorConsents(c *gin.Context) {
+	doctor := middleware.CurrentUser(c)
+	if doctor == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	listConsents(c, db.DB.Preload("Patient").Where("doctor_id = ?", doctor.ID))
+}
+
+func listPatientConsents(c *gin.Context) {
+	patient := middleware.CurrentUser(c)
+	if patient == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	listConsents(c, db.DB.Preload("Doctor").Preload("Doctor.DoctorProfile").Where("patient_id = ?", patient.ID))
+}
+
+func listConsents(c *gin.Context, query *gorm.DB) {
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", strings.ToLower(status))
+	}
+
+	var consents []models.PatientConsent
+	if err := query.Order("created_at DESC").Find(&consents).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load consents"})
+		return
+	}
+
+	results := make([]gin.H, 0, len(consents))
+	for i := range consents {
+		results = append(results, toConsentResponse(&consents[i]))
+	}
+	c.JSON(http.StatusOK, results)
+}
+
+func approveConsent(c *gin.Context) {
+	patient := middleware.CurrentUser(c)
+	consent, ok := loadPatientConsent(c, patient)
+	if !ok {
+		return
+	}
+
+	var req approveConsentRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if consent.Status != models.ConsentPending {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "only pending consents can be approved"})
+		return
+	}
+
+	scopes := consent.ScopeList()
+	if len(req.Scopes) > 0 {
+		narrowed, err := normalizeScopes(req.Scopes)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		for _, scope := range narrowed {
+			if !consent.HasScope(scope) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "cannot grant scope that was not requested: " + scope})
+				return
+			}
+		}
+		scopes = narrowed
+	}
+
+	days := consent.DurationDays
+	if req.DurationDays != nil {
+		if *req.DurationDays <= 0 || (days > 0 && *req.DurationDays > days) || *req.DurationDays > maxConsentDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "durationDays may only shorten the requested duration"})
+			return
+		}
+		days = *req.DurationDays
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":        models.ConsentActive,
+		"scopes":        strings.Join(scopes, ","),
+		"response_note": strings.TrimSpace(req.Note),
+		"responded_at":  now,
+		"expires_at":    nil,
+	}
+	if days > 0 {
+		updates["expires_at"] = now.AddDate(0, 0, days)
+	}
+	respond(c, patient, consent, updates)
+}
+
+func denyConsent(c *gin.Context) {
+	patient := middleware.CurrentUser(c)
+	consent, ok := loadPatientConsent(c, patient)
+	if !ok {
+		return
+	}
+	if consent.Status != models.ConsentPending {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "only pending consents can be denied"})
+		return
+	}
+
+	var req respondConsentRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	respond(c, patient, consent, map[string]interface{}{
+		"status":        models.ConsentDenied,
+		"response_note": strings.TrimSpace(req.Note),
+		"responded_at":  time.Now(),
+	})
+}
+
+func revokeConsent(c *gin.Context) {
+	patient := middleware.CurrentUser(c)
+	consent, ok := loadPatientConsent(c, patient)
+	if !ok {
+		return
+	}
+	if consent.Status != models.ConsentActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "only active consents can be revoked"})
+		return
+	}
+
+	respond(c, patient, consent, map[string]interface{}{
+		"status":     models.ConsentRevoked,
+		"revoked_at": time.Now(),
+	})
+}
+
+func respond(c *gin.Context, patient *models.User, consent *models.PatientConsent, updates map[string]interface{}) {
+	if err := db.DB.Model(consent).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update consent"})
+		return
+	}
+	if err := db.DB.Preload("Doctor").First(consent, consent.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load consent"})
+		return
+	}
+	logConsent(c, patient, audit.ActionUpdate, consent)
+	c.JSON(http.StatusOK, toConsentResponse(consent))
+}
+
+func loadPatientConsent(c *gin.Context, patient *models.User) (*models.PatientConsent, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid consent id"})
+		return nil, false
+	}
+
+	var consent models.PatientConsent
+	if err := db.DB.Where("id = ? AND patient_id = ?", id, patient.ID).First(&consent).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "consent not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load consent"})
+		return nil, false
+	}
+	return &consent, true
+}
+
+func normalizeScopes(scopes []string) ([]string, error) {
+	seen := map[string]bool{}
+	result := make([]string, 0, len(scopes))
+	for _, raw := range scopes {
+		scope := strings.ToLower(strings.TrimSpace(raw))
+		valid := false
+		for _, known := range models.ConsentScopes {
+			if scope == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, errors.New("unknown consent scope: " + raw)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			result = append(result, scope)
+		}
+	}
+	if len(result) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+	return result, nil
+}
+
+func logConsent(c *gin.Context, actor *models.User, action string, consent *models.PatientConsent) {
+	audit.Log(c, actor, audit.Entry{
+		Action:     action,
+		Resource:   "consent",
+		ResourceID: audit.ID(consent.ID),
+		PatientID:  audit.PatientRef(consent.PatientID),
+		Detail:     string(consent.Status) + " " + consent.Scopes,
+	})
+}
+
+func toConsentResponse(consent *models.PatientConsent) gin.H {
+	status := consent.Status
+	if status == models.ConsentActive && !consent.IsActive(time.Now()) {
+		status = "expired"
+	}
+
+	response := gin.H{
+		"id":           consent.ID,
+		"patientId":    consent.PatientID,
+		"doctorId":     consent.DoctorID,
+		"scopes":       consent.ScopeList(),
+		"status":       status,
+		"reason":       consent.Reason,
+		"responseNote": consent.ResponseNote,
+		"durationDays": consent.DurationDays,
+		"respondedAt":  consent.RespondedAt,
+		"expiresAt":    consent.ExpiresAt,
+		"revokedAt":    consent.RevokedAt,
+		"createdAt":    consent.CreatedAt,
+	}
+	if consent.Patient != nil {
+		response["patient"] = gin.H{
+			"id":       consent.Patient.ID,
+			"fullName": consent.Patient.FullName,
+			"email":    consent.Patient.Email,
+		}
+	}
+	if consent.Doctor != nil {
+		doctor := gin.H{
+			"id":       consent.Doctor.ID,
+			"fullName": consent.Doctor.FullName,
+		}
+		if consent.Doctor.DoctorProfile != nil {
+			doctor["speciality"] = consent.Doctor.DoctorProfile.Speciality
+			doctor["clinicName"] = consent.Doctor.DoctorProfile.ClinicName
+		}
+		response["doctor"] = doctor
+	}
+	return response
+}
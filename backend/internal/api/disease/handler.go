@@ -0,0 +1,389 @@
+package disease
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"medapp/internal/api/middleware"
+	"medapp/internal/audit"
+	"medapp/internal/db"
+	"medapp/internal/icd10"
+	"medapp/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+	maxImportSize   = 64 << 20
+)
+
+type diseaseRequest struct {
+	Name        *string `json:"name"`
+	Category    *string `json:"category"`
+	Description *string `json:"description"`
+	Code        *string `json:"code"`
+	CodeSystem  *string `json:"codeSystem"`
+	SnomedCode  *string `json:"snomedCode"`
+	ParentID    *uint   `json:"parentId"`
+}
+
+// RegisterAdminRoutes lets admins maintain the disease catalog.
+func RegisterAdminRoutes(r *gin.RouterGroup) {
+	r.Use(middleware.AuthRequired(), middleware.RequireRole(models.RoleAdmin))
+	r.GET("", listDiseases)
+	r.GET("/", listDiseases)
+	r.POST("", createDisease)
+	r.POST("/", createDisease)
+	r.POST("/import", importDiseases)
+	r.GET("/translations/export", exportTranslations)
+	r.POST("/translations/import", importTranslations)
+	r.GET("/:id", getDisease)
+	r.PUT("/:id", updateDisease)
+	r.POST("/:id/retire", retireDisease)
+	r.POST("/:id/restore", restoreDisease)
+	r.GET("/:id/translations", listTranslations)
+	r.PUT("/:id/translations/:lang", saveTranslation)
+	r.DELETE("/:id/translations/:lang", deleteTranslation)
+}
+
+func listDiseases(c *gin.Context) {
+	page, pageSize := parsePagination(c)
+
+	query := db.DB.Model(&models.Disease{})
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		like := strings.ToLower(q) + "%"
+		query = query.Where("LOWER(code) LIKE ? OR LOWER(name) LIKE ?", like, "%"+like)
+	}
+	if system := c.Query("codeSystem"); system != "" {
+		query = query.Where("code_system = ?", system)
+	}
+	switch c.Query("retired") {
+	case "true":
+		query = query.Where("retired = ?", true)
+	case "false":
+		query = query.Where("retired = ?", false)
+	}
+	if parent := c.Query("parentId"); parent != "" {
+		query = query.Where("parent_id = ?", parent)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count diseases"})
+		return
+	}
+	var diseases []models.Disease
+	if err := query.Order("code ASC, name ASC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&diseases).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load diseases"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":    diseases,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+}
+
+func getDisease(c *gin.Context) {
+	disease, ok := loadDisease(c)
+	if !ok {
+		return
+	}
+	var children []models.Disease
+	if err := db.DB.Where("parent_id = ?", disease.ID).Order("code ASC").Find(&children).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load child codes"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"disease": disease, "children": children})
+}
+
+func createDisease(c *gin.Context) {
+	var req diseaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Name == nil || strings.TrimSpace(*req.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	if req.Code == nil || icd10.NormalizeCode(*req.Code) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+		return
+	}
+
+	disease := models.Disease{CodeSystem: models.CodeSystemICD10}
+	if !applyRequest(c, &disease, &req) || !checkCodeFree(c, &disease) {
+		return
+	}
+	if err := db.DB.Create(&disease).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create disease"})
+		return
+	}
+	logChange(c, audit.ActionCreate, &disease, "")
+	c.JSON(http.StatusCreated, disease)
+}
+
+func updateDisease(c *gin.Context) {
+	disease, ok := loadDisease(c)
+	if !ok {
+		return
+	}
+	var req diseaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name cannot be empty"})
+		return
+	}
+	if req.Code != nil && icd10.NormalizeCode(*req.Code) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code cannot be empty"})
+		return
+	}
+	if !applyRequest(c, disease, &req) || !checkCodeFree(c, disease) {
+		return
+	}
+
+	if err := db.DB.Model(disease).
+		Select("Name", "Category", "Description", "Code", "CodeSystem", "SnomedCode", "ParentID").
+		Updates(disease).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update disease"})
+		return
+	}
+	logChange(c, audit.ActionUpdate, disease, "")
+	c.JSON(http.StatusOK, disease)
+}
+
+// applyRequest copies the fields present in req onto disease.
+func applyRequest(c *gin.Context, disease *models.Disease, req *diseaseRequest) bool {
+	if req.Name != nil {
+		disease.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Category != nil {
+		disease.Category = strings.TrimSpace(*req.Category)
+	}
+	if req.Description != nil {
+		disease.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.Code != nil {
+		disease.Code = icd10.NormalizeCode(*req.Code)
+	}
+	if req.CodeSystem != nil {
+		switch *req.CodeSystem {
+		case models.CodeSystemICD10, models.CodeSystemICD10CM:
+			disease.CodeSystem = *req.CodeSystem
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "codeSystem must be ICD-10 or ICD-10-CM"})
+			return false
+		}
+	}
+	if req.SnomedCode != nil {
+		disease.SnomedCode = strings.TrimSpace(*req.SnomedCode)
+	}
+	if req.ParentID != nil {
+		if *req.ParentID == 0 {
+			disease.ParentID = nil
+		} else {
+			if disease.ID != 0 && *req.ParentID == disease.ID {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "a disease cannot be its own parent"})
+				return false
+			}
+			var parent models.Disease
+			if err := db.DB.First(&parent, *req.ParentID).Error; err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "parent disease not found"})
+				return false
+			}
+			// Walk up from the new parent so the hierarchy cannot loop back to this disease.
+			for ancestor := parent.ParentID; ancestor != nil && disease.ID != 0; {
+				if *ancestor == disease.ID {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "parent would create a cycle"})
+					return false
+				}
+				var next models.Disease
+				if err := db.DB.Select("id", "parent_id").First(&next, *ancestor).Error; err != nil {
+					break
+				}
+				ancestor = next.ParentID
+			}
+			disease.ParentID = &parent.ID
+		}
+	}
+	if len(disease.Code) > 16 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is too long"})
+		return false
+	}
+	return true
+}
+
+func retireDisease(c *gin.Context) {
+	setRetired(c, true)
+}
+
+func restoreDisease(c *gin.Context) {
+	setRetired(c, false)
+}
+
+// setRetired retires or restores a code. Retired codes stay on existing
+// medical records but can no longer be chosen for new ones.
+func setRetired(c *gin.Context, retired bool) {
+	disease, ok := loadDisease(c)
+	if !ok {
+		return
+	}
+	var retiredAt *time.Time
+	if retired {
+		now := time.Now()
+		retiredAt = &now
+	}
+	if err := db.DB.Model(disease).Updates(map[string]interface{}{
+		"retired":    retired,
+		"retired_at": retiredAt,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update disease"})
+		return
+	}
+	disease.Retired = retired
+	disease.RetiredAt = retiredAt
+	detail := "restored"
+	if retired {
+		detail = "retired"
+	}
+	logChange(c, audit.ActionUpdate, disease, detail)
+	c.JSON(http.StatusOK, disease)
+}
+
+// importDiseases loads an uploaded ICD-10 CSV or ClaML file into the catalog.
+func importDiseases(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	defer file.Close()
+
+	format := strings.ToLower(c.Query("format"))
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(header.Filename)) {
+		case ".xml", ".claml":
+			format = "claml"
+		default:
+			format = "csv"
+		}
+	}
+
+	var entries []icd10.Entry
+	switch format {
+	case "csv":
+		entries, err = icd10.ParseCSV(file)
+	case "claml":
+		entries, err = icd10.ParseClaML(file, c.DefaultQuery("lang", "en"))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or claml"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to parse file: " + err.Error()})
+		return
+	}
+
+	opts := icd10.Options{
+		CodeSystem:    c.DefaultQuery("codeSystem", models.CodeSystemICD10),
+		RetireMissing: c.Query("retireMissing") == "true",
+	}
+	if opts.CodeSystem != models.CodeSystemICD10 && opts.CodeSystem != models.CodeSystemICD10CM {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "codeSystem must be ICD-10 or ICD-10-CM"})
+		return
+	}
+
+	var result *icd10.Result
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = icd10.Import(tx, entries, opts)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to import diseases"})
+		return
+	}
+
+	audit.Log(c, middleware.CurrentUser(c), audit.Entry{
+		Action:   audit.ActionCreate,
+		Resource: "disease",
+		Detail: "import " + header.Filename + ": " + strconv.Itoa(result.Created) + " created, " +
+			strconv.Itoa(result.Updated) + " updated, " + strconv.Itoa(result.Retired) + " retired",
+	})
+	c.JSON(http.StatusOK, result)
+}
+
+func loadDisease(c *gin.Context) (*models.Disease, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid disease id"})
+		return nil, false
+	}
+	var disease models.Disease
+	if err := db.DB.First(&disease, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "disease not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load disease"})
+		return nil, false
+	}
+	return &disease, true
+}
+
+func logChange(c *gin.Context, action string, disease *models.Disease, detail string) {
+	audit.Log(c, middleware.CurrentUser(c), audit.Entry{
+		Action:     action,
+		Resource:   "disease",
+		ResourceID: audit.ID(disease.ID),
+		Detail:     strings.TrimSpace(disease.Code + " " + detail),
+	})
+}
+
+// checkCodeFree rejects a code already used by another disease of the same system.
+func checkCodeFree(c *gin.Context, disease *models.Disease) bool {
+	if disease.Code == "" {
+		return true
+	}
+	var count int64
+	if err := db.DB.Model(&models.Disease{}).
+		Where("code_system = ? AND code = ? AND id <> ?", disease.CodeSystem, disease.Code, disease.ID).
+		Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check code"})
+		return false
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "code already exists in this code system"})
+		return false
+	}
+	return true
+}
+
+func parsePagination(c *gin.Context) (int, int) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", strconv.Itoa(defaultPageSize)))
+	if err != nil || pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	return page, pageSize
+}
@@ -0,0 +1,168 @@
+package disease
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"medapp/internal/api/middleware"
+	"medapp/internal/audit"
+	"medapp/internal/db"
+	"medapp/internal/i18n"
+	"medapp/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type translationRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+func listTranslations(c *gin.Context) {
+	disease, ok := loadDisease(c)
+	if !ok {
+		return
+	}
+	var translations []models.DiseaseTranslation
+	if err := db.DB.Where("disease_id = ?", disease.ID).Order("lang ASC").Find(&translations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load translations"})
+		return
+	}
+	c.JSON(http.StatusOK, translations)
+}
+
+func saveTranslation(c *gin.Context) {
+	disease, ok := loadDisease(c)
+	if !ok {
+		return
+	}
+	lang := i18n.Normalize(c.Param("lang"))
+	if lang == "" || lang == i18n.English {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.ErrUnsupportedLanguage.Error()})
+		return
+	}
+	var req translationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	translation := models.DiseaseTranslation{
+		DiseaseID:   disease.ID,
+		Lang:        lang,
+		Name:        strings.TrimSpace(req.Name),
+		Description: strings.TrimSpace(req.Description),
+	}
+	if err := i18n.Save(db.DB, &translation); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save translation"})
+		return
+	}
+	logChange(c, audit.ActionUpdate, disease, "translation "+lang)
+	c.JSON(http.StatusOK, translation)
+}
+
+func deleteTranslation(c *gin.Context) {
+	disease, ok := loadDisease(c)
+	if !ok {
+		return
+	}
+	result := db.DB.Where("disease_id = ? AND lang = ?", disease.ID, c.Param("lang")).Delete(&models.DiseaseTranslation{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete translation"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "translation not found"})
+		return
+	}
+	logChange(c, audit.ActionDelete, disease, "translation "+c.Param("lang"))
+	c.JSON(http.StatusOK, gin.H{"message": "translation deleted"})
+}
+
+// exportTranslations downloads a file for translators: every active disease
+// with its English text and the current translation into lang, if any.
+func exportTranslations(c *gin.Context) {
+	lang := i18n.Normalize(c.Query("lang"))
+	rows, err := i18n.ExportRows(db.DB, lang)
+	if err != nil {
+		if errors.Is(err, i18n.ErrUnsupportedLanguage) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to export translations"})
+		return
+	}
+
+	filename := "disease-translations-" + lang
+	switch c.DefaultQuery("format", "csv") {
+	case "json":
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.json"`)
+		c.JSON(http.StatusOK, rows)
+	case "csv":
+		var buf bytes.Buffer
+		if err := i18n.WriteCSV(&buf, rows); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to export translations"})
+			return
+		}
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.csv"`)
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or json"})
+	}
+}
+
+// importTranslations loads a translator's CSV or JSON file. Rows left without
+// a name are skipped; rows that cannot be matched are reported back.
+func importTranslations(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	defer file.Close()
+
+	format := strings.ToLower(c.Query("format"))
+	if format == "" {
+		format = "csv"
+		if strings.ToLower(filepath.Ext(header.Filename)) == ".json" {
+			format = "json"
+		}
+	}
+	var rows []i18n.TranslationRow
+	switch format {
+	case "csv":
+		rows, err = i18n.ParseCSV(file)
+	case "json":
+		rows, err = i18n.ParseJSON(file)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or json"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to parse file: " + err.Error()})
+		return
+	}
+
+	var result *i18n.ImportResult
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = i18n.ImportRows(tx, rows, c.Query("lang"))
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to import translations"})
+		return
+	}
+
+	audit.Log(c, middleware.CurrentUser(c), audit.Entry{
+		Action:   audit.ActionUpdate,
+		Resource: "disease",
+		Detail:   "translation import " + header.Filename,
+	})
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,198 @@
+package document
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"medapp/internal/access"
+	"medapp/internal/api/middleware"
+	"medapp/internal/audit"
+	"medapp/internal/db"
+	"medapp/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DocumentDir keeps patient documents out of the publicly served uploads directory.
+const DocumentDir = "./private/documents"
+
+const maxDocumentSize = 20 << 20
+
+// RegisterRoutes mounts document routes for a patient, expecting an :id path parameter.
+func RegisterRoutes(r *gin.RouterGroup) {
+	r.Use(middleware.AuthRequired(), middleware.RequireRole(models.RoleDoctor), middleware.RequireVerifiedDoctor())
+	r.GET("", listPatientDocuments)
+	r.POST("", uploadPatientDocument)
+	r.GET("/:docId", downloadPatientDocument)
+}
+
+// RegisterPatientRoutes lets patients manage their own documents.
+func RegisterPatientRoutes(r *gin.RouterGroup) {
+	g := r.Group("/documents")
+	g.Use(middleware.AuthRequired(), middleware.RequireRole(models.RolePatient))
+	g.GET("", listOwnDocuments)
+	g.POST("", uploadOwnDocument)
+	g.GET("/:docId", downloadOwnDocument)
+}
+
+func listPatientDocuments(c *gin.Context) {
+	user, patientID, ok := authorize(c, audit.ActionRead, access.Authorize)
+	if !ok {
+		return
+	}
+	list(c, user, patientID)
+}
+
+func uploadPatientDocument(c *gin.Context) {
+	user, patientID, ok := authorize(c, audit.ActionCreate, access.AuthorizeWrite)
+	if !ok {
+		return
+	}
+	upload(c, user, patientID)
+}
+
+func downloadPatientDocument(c *gin.Context) {
+	user, patientID, ok := authorize(c, audit.ActionRead, access.Authorize)
+	if !ok {
+		return
+	}
+	download(c, user, patientID)
+}
+
+func listOwnDocuments(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	list(c, user, user.ID)
+}
+
+func uploadOwnDocument(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	upload(c, user, user.ID)
+}
+
+func downloadOwnDocument(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	download(c, user, user.ID)
+}
+
+func authorize(c *gin.Context, action string, check func(*models.User, uint, string) (*access.Grant, error)) (*models.User, uint, bool) {
+	user := middleware.CurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return nil, 0, false
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid patient id"})
+		return nil, 0, false
+	}
+	patientID := uint(id)
+
+	grant, err := check(user, patientID, models.ScopeDocuments)
+	if err != nil {
+		if errors.Is(err, access.ErrNoConsent) || errors.Is(err, access.ErrNotAssigned) || errors.Is(err, access.ErrForbidden) {
+			audit.Log(c, user, audit.Entry{
+				Action:    action,
+				Resource:  "patient_document",
+				PatientID: audit.PatientRef(patientID),
+				Outcome:   audit.OutcomeDenied,
+			})
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return nil, 0, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check access"})
+		return nil, 0, false
+	}
+	if grant.Emergency != nil {
+		audit.MarkEmergency(c, grant.Emergency.ID)
+	}
+	return user, patientID, true
+}
+
+func list(c *gin.Context, user *models.User, patientID uint) {
+	var documents []models.PatientDocument
+	if err := db.DB.Where("patient_id = ?", patientID).Order("created_at DESC").Find(&documents).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load documents"})
+		return
+	}
+	audit.Log(c, user, audit.Entry{
+		Action:    audit.ActionRead,
+		Resource:  "patient_document",
+		PatientID: audit.PatientRef(patientID),
+		Detail:    "document list",
+	})
+	c.JSON(http.StatusOK, documents)
+}
+
+func upload(c *gin.Context, user *models.User, patientID uint) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "document file is required"})
+		return
+	}
+	if file.Size > maxDocumentSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "document is too large"})
+		return
+	}
+
+	dir := filepath.Join(DocumentDir, strconv.Itoa(int(patientID)))
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create document directory"})
+		return
+	}
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	destination := filepath.Join(dir, fmt.Sprintf("%d%s", time.Now().UnixNano(), ext))
+	if err := c.SaveUploadedFile(file, destination); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save document"})
+		return
+	}
+
+	title := strings.TrimSpace(c.PostForm("title"))
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(file.Filename), filepath.Ext(file.Filename))
+	}
+
+	document := models.PatientDocument{
+		PatientID:    patientID,
+		UploadedByID: user.ID,
+		Title:        title,
+		FileName:     filepath.Base(file.Filename),
+		ContentType:  file.Header.Get("Content-Type"),
+		Size:         file.Size,
+		StoragePath:  destination,
+	}
+	if err := db.DB.Create(&document).Error; err != nil {
+		os.Remove(destination)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store document metadata"})
+		return
+	}
+	audit.Log(c, user, audit.Entry{
+		Action:     audit.ActionCreate,
+		Resource:   "patient_document",
+		ResourceID: audit.ID(document.ID),
+		PatientID:  audit.PatientRef(patientID),
+	})
+
+	c.JSON(http.StatusCreated, document)
+}
+
+func download(c *gin.Context, user *models.User, patientID uint) {
+	var document models.PatientDocument
+	if err := db.DB.Where("id = ? AND patient_id = ?", c.Param("docId"), patientID).First(&document).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return
+	}
+	audit.Log(c, user, audit.Entry{
+		Action:     audit.ActionRead,
+		Resource:   "patient_document",
+		ResourceID: audit.ID(document.ID),
+		PatientID:  audit.PatientRef(patientID),
+	})
+	c.FileAttachment(document.StoragePath, document.FileName)
+}
@@ -0,0 +1,195 @@
+package duplicate
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"medapp/internal/api/middleware"
+	"medapp/internal/audit"
+	"medapp/internal/db"
+	"medapp/internal/duplicates"
+	"medapp/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type mergeRequest struct {
+	SurvivorID uint   `json:"survivorId"` // which of the pair to keep; defaults to the older record
+	Note       string `json:"note"`
+}
+
+type reviewRequest struct {
+	Note string `json:"note"`
+}
+
+// RegisterAdminRoutes gives admins the duplicate patient review queue.
+func RegisterAdminRoutes(r *gin.RouterGroup) {
+	r.Use(middleware.AuthRequired(), middleware.RequireRole(models.RoleAdmin))
+	r.GET("", listDuplicates)
+	r.GET("/", listDuplicates)
+	r.POST("/scan", scanDuplicates)
+	r.GET("/redirects/:patientId", getRedirect)
+	r.POST("/:id/merge", mergeDuplicate)
+	r.POST("/:id/dismiss", dismissDuplicate)
+}
+
+// listDuplicates lists candidate pairs, most likely first. status defaults to pending.
+func listDuplicates(c *gin.Context) {
+	status := c.DefaultQuery("status", string(models.DuplicatePending))
+	var duplicates []models.PatientDuplicate
+	if err := db.DB.Preload("Patient.PatientProfile").Preload("Other.PatientProfile").
+		Where("status = ?", status).
+		Order("score DESC, id ASC").
+		Find(&duplicates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load duplicates"})
+		return
+	}
+	c.JSON(http.StatusOK, duplicates)
+}
+
+// scanDuplicates runs the duplicate scan now instead of waiting for the next scheduled run.
+func scanDuplicates(c *gin.Context) {
+	var added int
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		added, err = duplicates.Scan(tx)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to scan for duplicates"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"added": added})
+}
+
+func getRedirect(c *gin.Context) {
+	var redirect models.PatientMergeRedirect
+	if err := db.DB.Where("from_id = ?", c.Param("patientId")).First(&redirect).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "patient was not merged"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load redirect"})
+		return
+	}
+	c.JSON(http.StatusOK, redirect)
+}
+
+func mergeDuplicate(c *gin.Context) {
+	admin := middleware.CurrentUser(c)
+	duplicate, ok := loadPendingDuplicate(c)
+	if !ok {
+		return
+	}
+
+	var req mergeRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	survivorID, mergedID := duplicate.PatientID, duplicate.OtherID
+	switch req.SurvivorID {
+	case 0, duplicate.PatientID:
+	case duplicate.OtherID:
+		survivorID, mergedID = mergedID, survivorID
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "survivorId must be one of the pair"})
+		return
+	}
+
+	now := time.Now()
+	var redirect *models.PatientMergeRedirect
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if redirect, err = duplicates.Merge(tx, survivorID, mergedID, admin.ID, &duplicate.ID); err != nil {
+			return err
+		}
+		return tx.Model(duplicate).Updates(map[string]interface{}{
+			"status":         models.DuplicateMerged,
+			"review_note":    strings.TrimSpace(req.Note),
+			"reviewed_by_id": admin.ID,
+			"reviewed_at":    now,
+		}).Error
+	})
+	if err != nil {
+		audit.Log(c, admin, mergeEntry(survivorID, mergedID, audit.OutcomeError))
+		if errors.Is(err, duplicates.ErrNotMergeable) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to merge patients"})
+		return
+	}
+	audit.Log(c, admin, mergeEntry(survivorID, mergedID, audit.OutcomeSuccess))
+
+	if err := db.DB.Preload("Patient.PatientProfile").Preload("Other.PatientProfile").First(duplicate, duplicate.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load duplicate"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"duplicate": duplicate, "redirect": redirect})
+}
+
+func dismissDuplicate(c *gin.Context) {
+	admin := middleware.CurrentUser(c)
+	duplicate, ok := loadPendingDuplicate(c)
+	if !ok {
+		return
+	}
+
+	var req reviewRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := db.DB.Model(duplicate).Updates(map[string]interface{}{
+		"status":         models.DuplicateDismissed,
+		"review_note":    strings.TrimSpace(req.Note),
+		"reviewed_by_id": admin.ID,
+		"reviewed_at":    time.Now(),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to dismiss duplicate"})
+		return
+	}
+
+	if err := db.DB.Preload("Patient.PatientProfile").Preload("Other.PatientProfile").First(duplicate, duplicate.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load duplicate"})
+		return
+	}
+	c.JSON(http.StatusOK, duplicate)
+}
+
+func loadPendingDuplicate(c *gin.Context) (*models.PatientDuplicate, bool) {
+	var duplicate models.PatientDuplicate
+	if err := db.DB.First(&duplicate, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "duplicate not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load duplicate"})
+		return nil, false
+	}
+	if duplicate.Status != models.DuplicatePending {
+		c.JSON(http.StatusConflict, gin.H{"error": "duplicate is not pending"})
+		return nil, false
+	}
+	return &duplicate, true
+}
+
+func mergeEntry(survivorID, mergedID uint, outcome string) audit.Entry {
+	return audit.Entry{
+		Action:     audit.ActionUpdate,
+		Resource:   "patient_account",
+		ResourceID: audit.ID(mergedID),
+		PatientID:  audit.PatientRef(survivorID),
+		Outcome:    outcome,
+		Detail:     fmt.Sprintf("merged patient %d into %d", mergedID, survivorID),
+	}
+}
@@ -0,0 +1,307 @@
+package emergency
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"medapp/internal/access"
+	"medapp/internal/api/middleware"
+	"medapp/internal/audit"
+	"medapp/internal/db"
+	"medapp/internal/models"
+	"medapp/internal/notify"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const minReasonLength = 20
+
+type startRequest struct {
+	Reason          string `json:"reason" binding:"required"`
+	DurationMinutes int    `json:"durationMinutes"` // optional, capped by EMERGENCY_ACCESS_TTL
+}
+
+type reviewRequest struct {
+	Status string `json:"status" binding:"required"` // approved or flagged
+	Note   string `json:"note"`
+}
+
+// RegisterRoutes mounts break-the-glass routes for a patient, expecting an :id path parameter.
+func RegisterRoutes(r *gin.RouterGroup) {
+	r.Use(middleware.AuthRequired(), middleware.RequireRole(models.RoleDoctor), middleware.RequireVerifiedDoctor())
+	r.GET("", getActiveAccess)
+	r.POST("", startAccess)
+	r.DELETE("", endAccess)
+}
+
+// RegisterAdminRoutes exposes overrides for after-the-fact review.
+func RegisterAdminRoutes(r *gin.RouterGroup) {
+	r.Use(middleware.AuthRequired(), middleware.RequireRole(models.RoleAdmin))
+	r.GET("", listAccesses)
+	r.GET("/", listAccesses)
+	r.GET("/:accessId", getAccess)
+	r.POST("/:accessId/review", reviewAccess)
+}
+
+func startAccess(c *gin.Context) {
+	doctor := middleware.CurrentUser(c)
+	if doctor == nil || doctor.Role != models.RoleDoctor {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only doctors can request emergency access"})
+		return
+	}
+	patient, ok := loadPatient(c)
+	if !ok {
+		return
+	}
+
+	var req startRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if len(reason) < minReasonLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("reason must be at least %d characters", minReasonLength)})
+		return
+	}
+
+	existing, err := access.ActiveEmergency(doctor.ID, patient.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check emergency access"})
+		return
+	}
+	if existing != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "emergency access is already active", "emergencyAccess": existing})
+		return
+	}
+
+	ttl := access.EmergencyTTL()
+	if req.DurationMinutes > 0 {
+		if requested := time.Duration(req.DurationMinutes) * time.Minute; requested < ttl {
+			ttl = requested
+		}
+	}
+
+	grant := models.EmergencyAccess{
+		DoctorID:     doctor.ID,
+		PatientID:    patient.ID,
+		Reason:       reason,
+		ExpiresAt:    time.Now().Add(ttl),
+		ReviewStatus: models.EmergencyReviewPending,
+	}
+	if err := db.DB.Create(&grant).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to grant emergency access"})
+		return
+	}
+
+	audit.MarkEmergency(c, grant.ID)
+	audit.Log(c, doctor, audit.Entry{
+		Action:     audit.ActionCreate,
+		Resource:   "emergency_access",
+		ResourceID: audit.ID(grant.ID),
+		PatientID:  audit.PatientRef(patient.ID),
+		Detail:     reason,
+	})
+
+	notify.Send([]uint{patient.ID}, "emergency_access",
+		"Emergency access to your record",
+		fmt.Sprintf("Dr. %s opened your record in an emergency until %s. Reason: %s", doctor.FullName, grant.ExpiresAt.Format(time.RFC1123), reason))
+	notify.Admins("emergency_access",
+		"Emergency access requires review",
+		fmt.Sprintf("Dr. %s (id %d) used break-the-glass access to patient %d. Reason: %s", doctor.FullName, doctor.ID, patient.ID, reason))
+
+	c.JSON(http.StatusCreated, grant)
+}
+
+func getActiveAccess(c *gin.Context) {
+	doctor := middleware.CurrentUser(c)
+	patient, ok := loadPatient(c)
+	if !ok {
+		return
+	}
+
+	grant, err := access.ActiveEmergency(doctor.ID, patient.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check emergency access"})
+		return
+	}
+	if grant == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no active emergency access"})
+		return
+	}
+	c.JSON(http.StatusOK, grant)
+}
+
+func endAccess(c *gin.Context) {
+	doctor := middleware.CurrentUser(c)
+	patient, ok := loadPatient(c)
+	if !ok {
+		return
+	}
+
+	grant, err := access.ActiveEmergency(doctor.ID, patient.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check emergency access"})
+		return
+	}
+	if grant == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no active emergency access"})
+		return
+	}
+
+	now := time.Now()
+	if err := db.DB.Model(grant).Update("ended_at", now).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to end emergency access"})
+		return
+	}
+	grant.EndedAt = &now
+	c.JSON(http.StatusOK, grant)
+}
+
+func listAccesses(c *gin.Context) {
+	query := db.DB.Preload("Doctor").Preload("Patient").Order("created_at DESC")
+	if status := c.Query("reviewStatus"); status != "" {
+		query = query.Where("review_status = ?", status)
+	}
+
+	var grants []models.EmergencyAccess
+	if err := query.Find(&grants).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load emergency accesses"})
+		return
+	}
+
+	results := make([]gin.H, 0, len(grants))
+	for i := range grants {
+		results = append(results, toAccessResponse(&grants[i]))
+	}
+	c.JSON(http.StatusOK, results)
+}
+
+func getAccess(c *gin.Context) {
+	grant, ok := loadAccess(c)
+	if !ok {
+		return
+	}
+
+	var entries []models.AuditLog
+	if err := db.DB.Where("emergency_access_id = ?", grant.ID).Order("id ASC").Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load audit entries"})
+		return
+	}
+
+	response := toAccessResponse(grant)
+	response["auditEntries"] = entries
+	c.JSON(http.StatusOK, response)
+}
+
+func reviewAccess(c *gin.Context) {
+	admin := middleware.CurrentUser(c)
+	grant, ok := loadAccess(c)
+	if !ok {
+		return
+	}
+
+	var req reviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	status := models.EmergencyReviewStatus(strings.ToLower(req.Status))
+	if status != models.EmergencyReviewApproved && status != models.EmergencyReviewFlagged {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be approved or flagged"})
+		return
+	}
+	if status == models.EmergencyReviewFlagged && strings.TrimSpace(req.Note) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a note is required when flagging an access"})
+		return
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"review_status":  status,
+		"review_note":    strings.TrimSpace(req.Note),
+		"reviewed_by_id": admin.ID,
+		"reviewed_at":    now,
+	}
+	// Reviewing an access that is still running ends it when it is flagged.
+	if status == models.EmergencyReviewFlagged && grant.IsActive(now) {
+		updates["ended_at"] = now
+	}
+	if err := db.DB.Model(grant).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record review"})
+		return
+	}
+	if err := db.DB.Preload("Doctor").Preload("Patient").First(grant, grant.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load emergency access"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toAccessResponse(grant))
+}
+
+func loadPatient(c *gin.Context) (*models.User, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid patient id"})
+		return nil, false
+	}
+
+	var patient models.User
+	if err := db.DB.Where("id = ? AND role = ?", id, models.RolePatient).First(&patient).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "patient not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check patient"})
+		return nil, false
+	}
+	return &patient, true
+}
+
+func loadAccess(c *gin.Context) (*models.EmergencyAccess, bool) {
+	var grant models.EmergencyAccess
+	if err := db.DB.Preload("Doctor").Preload("Patient").First(&grant, c.Param("accessId")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "emergency access not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load emergency access"})
+		return nil, false
+	}
+	return &grant, true
+}
+
+func toAccessResponse(grant *models.EmergencyAccess) gin.H {
+	response := gin.H{
+		"id":           grant.ID,
+		"doctorId":     grant.DoctorID,
+		"patientId":    grant.PatientID,
+		"reason":       grant.Reason,
+		"createdAt":    grant.CreatedAt,
+		"expiresAt":    grant.ExpiresAt,
+		"endedAt":      grant.EndedAt,
+		"active":       grant.IsActive(time.Now()),
+		"reviewStatus": grant.ReviewStatus,
+		"reviewNote":   grant.ReviewNote,
+		"reviewedById": grant.ReviewedByID,
+		"reviewedAt":   grant.ReviewedAt,
+	}
+	if grant.Doctor != nil {
+		response["doctor"] = gin.H{
+			"id":       grant.Doctor.ID,
+			"fullName": grant.Doctor.FullName,
+		}
+	}
+	if grant.Patient != nil {
+		response["patient"] = gin.H{
+			"id":       grant.Patient.ID,
+			"fullName": grant.Patient.FullName,
+		}
+	}
+	return response
+}
@@ -0,0 +1,312 @@
+// Package fhir serves a FHIR R4 facade over patients, practitioners,
+// appointments and conditions for partner systems, and imports transfer bundles.
+package fhir
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"medapp/internal/access"
+	"medapp/internal/api/middleware"
+	"medapp/internal/audit"
+	appFHIR "medapp/internal/fhir"
+	"medapp/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	contentType     = "application/fhir+json; charset=utf-8"
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// RegisterRoutes mounts the FHIR endpoints. Only the CapabilityStatement is public;
+// everything else needs a bearer token, and importing needs an admin or verified doctor.
+func RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/metadata", capabilityStatement)
+
+	g := r.Group("")
+	g.Use(middleware.OptionalAuth(), requireUser())
+	g.POST("", importBundle)
+	g.POST("/", importBundle)
+	g.GET("/Patient", searchPatients)
+	g.GET("/Patient/:id", readPatient)
+	g.GET("/Practitioner", searchPractitioners)
+	g.GET("/Practitioner/:id", readPractitioner)
+	g.GET("/Appointment", searchAppointments)
+	g.GET("/Appointment/:id", readAppointment)
+	g.GET("/Condition", searchConditions)
+	g.GET("/Condition/:id", readCondition)
+}
+
+// requireUser reports missing or invalid credentials as an OperationOutcome
+// instead of the plain error body used by the rest of the API.
+func requireUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if middleware.CurrentUser(c) == nil {
+			outcome(c, http.StatusUnauthorized, "login", "a valid bearer token is required")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func capabilityStatement(c *gin.Context) {
+	read := []appFHIR.CapabilityInteraction{{Code: "read"}, {Code: "search-type"}}
+	id := appFHIR.CapabilitySearchParam{Name: "_id", Type: "token"}
+	count := appFHIR.CapabilitySearchParam{Name: "_count", Type: "number", Documentation: "page size, at most 100"}
+	patient := appFHIR.CapabilitySearchParam{Name: "patient", Type: "reference"}
+
+	statement := appFHIR.NewCapabilityStatement(baseURL(c), []appFHIR.CapabilityResource{
+		{Type: "Patient", Interaction: read, SearchParam: []appFHIR.CapabilitySearchParam{id, count}},
+		{Type: "Practitioner", Interaction: read, SearchParam: []appFHIR.CapabilitySearchParam{id, count}},
+		{Type: "Appointment", Interaction: read, SearchParam: []appFHIR.CapabilitySearchParam{
+			id, patient, count,
+			{Name: "practitioner", Type: "reference"},
+			{Name: "date", Type: "date", Documentation: "supports eq, ne, lt, le, gt, ge, sa and eb prefixes"},
+			{Name: "status", Type: "token"},
+		}},
+		{Type: "Condition", Interaction: read, SearchParam: []appFHIR.CapabilitySearchParam{
+			id, patient, count,
+			{Name: "recorded-date", Type: "date"},
+			{Name: "code", Type: "token", Documentation: "ICD-10, ICD-10-CM or SNOMED CT code, optionally as system|code"},
+			{Name: "clinical-status", Type: "token", Documentation: "active or remission; resolved problems are not exposed as Conditions"},
+		}},
+		{Type: "AllergyIntolerance", Interaction: []appFHIR.CapabilityInteraction{{Code: "create"}}},
+		{Type: "Observation", Interaction: []appFHIR.CapabilityInteraction{{Code: "create"}}},
+	})
+	// Bundles of Patient, Condition, AllergyIntolerance and Observation entries can be imported.
+	statement.Rest[0].Interaction = []appFHIR.CapabilityInteraction{{Code: "transaction"}, {Code: "batch"}}
+	respond(c, http.StatusOK, statement)
+}
+
+// respond writes a FHIR resource with the FHIR JSON media type.
+func respond(c *gin.Context, status int, resource interface{}) {
+	body, err := json.Marshal(resource)
+	if err != nil {
+		outcome(c, http.StatusInternalServerError, "exception", "failed to encode resource")
+		return
+	}
+	c.Data(status, contentType, body)
+}
+
+func outcome(c *gin.Context, status int, code, diagnostics string) {
+	body, _ := json.Marshal(appFHIR.NewOperationOutcome(code, diagnostics))
+	c.Data(status, contentType, body)
+}
+
+// accessDenied maps access errors onto OperationOutcomes.
+func accessDenied(c *gin.Context, err error) {
+	if errors.Is(err, access.ErrNoConsent) || errors.Is(err, access.ErrForbidden) || errors.Is(err, access.ErrNotAssigned) {
+		outcome(c, http.StatusForbidden, "forbidden", err.Error())
+		return
+	}
+	outcome(c, http.StatusInternalServerError, "exception", "failed to check access")
+}
+
+// baseURL is the absolute URL of the FHIR endpoint, FHIR_BASE_URL when set.
+func baseURL(c *gin.Context) string {
+	if base := os.Getenv("FHIR_BASE_URL"); base != "" {
+		return strings.TrimSuffix(base, "/")
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if forwarded := c.GetHeader("X-Forwarded-Proto"); forwarded != "" {
+		scheme = forwarded
+	}
+	return scheme + "://" + c.Request.Host + "/fhir"
+}
+
+// page holds the paging parameters of a search.
+type page struct {
+	count  int
+	offset int
+}
+
+func parsePage(c *gin.Context) (page, bool) {
+	p := page{count: defaultPageSize}
+	if value := c.Query("_count"); value != "" {
+		count, err := strconv.Atoi(value)
+		if err != nil || count < 0 {
+			outcome(c, http.StatusBadRequest, "invalid", "_count must be a non-negative number")
+			return p, false
+		}
+		p.count = count
+	}
+	if p.count > maxPageSize {
+		p.count = maxPageSize
+	}
+	if value := c.Query("_offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			outcome(c, http.StatusBadRequest, "invalid", "_offset must be a non-negative number")
+			return p, false
+		}
+		p.offset = offset
+	}
+	return p, true
+}
+
+// searchset builds a paged search Bundle with self, first, previous and next links.
+func searchset(c *gin.Context, resourceType string, p page, total int64, resources []appFHIR.Resource) appFHIR.Bundle {
+	base := baseURL(c)
+	bundle := appFHIR.NewBundle("searchset", base, resources)
+	count := int(total)
+	bundle.Total = &count
+
+	link := func(relation string, offset int) appFHIR.BundleLink {
+		query := url.Values{}
+		for key, values := range c.Request.URL.Query() {
+			query[key] = values
+		}
+		query.Set("_count", strconv.Itoa(p.count))
+		query.Set("_offset", strconv.Itoa(offset))
+		return appFHIR.BundleLink{Relation: relation, URL: base + "/" + resourceType + "?" + query.Encode()}
+	}
+
+	bundle.Link = append(bundle.Link, link("self", p.offset), link("first", 0))
+	if p.offset > 0 {
+		previous := p.offset - p.count
+		if previous < 0 {
+			previous = 0
+		}
+		bundle.Link = append(bundle.Link, link("previous", previous))
+	}
+	if p.count > 0 && int64(p.offset+p.count) < total {
+		bundle.Link = append(bundle.Link, link("next", p.offset+p.count))
+	}
+	return bundle
+}
+
+// visiblePatients lists the patients whose scope the user may read; all is
+// true for admins, who may read every patient.
+func visiblePatients(user *models.User, scope string) (ids []uint, all bool, err error) {
+	switch user.Role {
+	case models.RoleAdmin:
+		return nil, true, nil
+	case models.RolePatient:
+		return []uint{user.ID}, false, nil
+	case models.RoleDoctor:
+		if user.DoctorProfile == nil || user.DoctorProfile.VerificationStatus != models.VerificationVerified {
+			return []uint{}, false, nil
+		}
+		ids, err := access.ConsentedPatientIDs(user.ID, scope)
+		return ids, false, err
+	}
+	return []uint{}, false, nil
+}
+
+// authorizeRead checks access to one patient's record and marks emergency use.
+func authorizeRead(c *gin.Context, user *models.User, patientID uint, scope, resource string) bool {
+	if user.Role == models.RoleDoctor && (user.DoctorProfile == nil || user.DoctorProfile.VerificationStatus != models.VerificationVerified) {
+		outcome(c, http.StatusForbidden, "forbidden", "doctor license is not verified")
+		return false
+	}
+	grant, err := access.Authorize(user, patientID, scope)
+	if err != nil {
+		if !errors.Is(err, access.ErrNoConsent) && !errors.Is(err, access.ErrForbidden) {
+			accessDenied(c, err)
+			return false
+		}
+		audit.Log(c, user, audit.Entry{
+			Action:    audit.ActionRead,
+			Resource:  resource,
+			PatientID: audit.PatientRef(patientID),
+			Outcome:   audit.OutcomeDenied,
+			Detail:    "fhir",
+		})
+		accessDenied(c, err)
+		return false
+	}
+	if grant.Emergency != nil {
+		audit.MarkEmergency(c, grant.Emergency.ID)
+	}
+	return true
+}
+
+// logReads records one audit entry per patient whose data was returned.
+func logReads(c *gin.Context, user *models.User, resource string, patientIDs []uint) {
+	seen := make(map[uint]bool, len(patientIDs))
+	entries := make([]audit.Entry, 0, len(patientIDs))
+	for _, id := range patientIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		entries = append(entries, audit.Entry{
+			Action:    audit.ActionRead,
+			Resource:  resource,
+			PatientID: audit.PatientRef(id),
+			Detail:    "fhir",
+		})
+	}
+	if len(entries) > 0 {
+		audit.LogMany(c, user, entries)
+	}
+}
+
+// parseIDs reads the _id parameter as numeric ids; ok is false after an error response.
+func parseIDs(c *gin.Context) (ids []uint, present bool, ok bool) {
+	value := c.Query("_id")
+	if value == "" {
+		return nil, false, true
+	}
+	for _, v := range appFHIR.SplitValues(value) {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			outcome(c, http.StatusBadRequest, "invalid", "invalid _id "+strconv.Quote(v))
+			return nil, true, false
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, true, true
+}
+
+// parseReferences reads a reference parameter such as patient=Patient/12.
+func parseReferences(c *gin.Context, param, resourceType string) (ids []uint, present bool, ok bool) {
+	value := c.Query(param)
+	if value == "" {
+		return nil, false, true
+	}
+	for _, v := range appFHIR.SplitValues(value) {
+		id, err := appFHIR.ParseReference(v, resourceType)
+		if err != nil {
+			outcome(c, http.StatusBadRequest, "invalid", err.Error())
+			return nil, true, false
+		}
+		ids = append(ids, id)
+	}
+	return ids, true, true
+}
+
+// parseDates reads every occurrence of a date parameter; all must match.
+func parseDates(c *gin.Context, param string) ([]appFHIR.DateFilter, bool) {
+	var filters []appFHIR.DateFilter
+	for _, value := range c.QueryArray(param) {
+		filter, err := appFHIR.ParseDate(value)
+		if err != nil {
+			outcome(c, http.StatusBadRequest, "invalid", err.Error())
+			return nil, false
+		}
+		filters = append(filters, filter)
+	}
+	return filters, true
+}
+
+func parsePathID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		outcome(c, http.StatusNotFound, "not-found", "resource not found")
+		return 0, false
+	}
+	return uint(id), true
+}
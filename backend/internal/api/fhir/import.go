@@ -0,0 +1,775 @@
+package fhir
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"medapp/internal/access"
+	"medapp/internal/ageband"
+	"medapp/internal/api/middleware"
+	"medapp/internal/audit"
+	"medapp/internal/db"
+	appFHIR "medapp/internal/fhir"
+	"medapp/internal/icd10"
+	"medapp/internal/medicalhistory"
+	"medapp/internal/models"
+	"medapp/internal/problemlist"
+	"medapp/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const loincSystem = "http://loinc.org"
+
+// LOINC codes of the observations that map onto medapp fields.
+const (
+	loincBloodGroup = "882-1"
+	loincSexAtBirth = "76689-9"
+	loincSex        = "46098-0"
+	loincAge        = "30525-0"
+)
+
+// entryError is a failure to import one bundle entry.
+type entryError struct {
+	status int
+	code   string
+	msg    string
+}
+
+func (e *entryError) Error() string { return e.msg }
+
+func invalid(format string, args ...interface{}) error {
+	return &entryError{http.StatusBadRequest, "invalid", fmt.Sprintf(format, args...)}
+}
+
+func forbidden(msg string) error {
+	return &entryError{http.StatusForbidden, "forbidden", msg}
+}
+
+// importer carries state across the entries of one bundle.
+type importer struct {
+	c        *gin.Context
+	user     *models.User
+	bundle   *appFHIR.RawBundle
+	patients map[string]*models.User // bundle references (fullUrl, Patient/<id>) to matched patients
+	created  map[uint]bool           // patients registered by this import
+	results  []appFHIR.BundleEntry
+	audits   [][]audit.Entry
+}
+
+func importBundle(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	if user.Role != models.RoleAdmin && (user.Role != models.RoleDoctor ||
+		user.DoctorProfile == nil || user.DoctorProfile.VerificationStatus != models.VerificationVerified) {
+		outcome(c, http.StatusForbidden, "forbidden", "only admins and verified doctors can import records")
+		return
+	}
+
+	var bundle appFHIR.RawBundle
+	if err := json.NewDecoder(c.Request.Body).Decode(&bundle); err != nil {
+		outcome(c, http.StatusBadRequest, "structure", "request body is not a valid JSON Bundle")
+		return
+	}
+	if bundle.ResourceType != "Bundle" {
+		outcome(c, http.StatusBadRequest, "invalid", "expected a Bundle resource")
+		return
+	}
+	if bundle.Type != "transaction" && bundle.Type != "batch" && bundle.Type != "collection" {
+		outcome(c, http.StatusBadRequest, "not-supported", "bundle type must be transaction, batch or collection")
+		return
+	}
+
+	imp := &importer{
+		c:        c,
+		user:     user,
+		bundle:   &bundle,
+		patients: map[string]*models.User{},
+		created:  map[uint]bool{},
+		results:  make([]appFHIR.BundleEntry, len(bundle.Entry)),
+		audits:   make([][]audit.Entry, len(bundle.Entry)),
+	}
+
+	// Patients go first so later entries can reference them by fullUrl.
+	order := make([]int, 0, len(bundle.Entry))
+	for i, entry := range bundle.Entry {
+		if appFHIR.ResourceTypeOf(entry.Resource) == "Patient" {
+			order = append(order, i)
+		}
+	}
+	for i, entry := range bundle.Entry {
+		if appFHIR.ResourceTypeOf(entry.Resource) != "Patient" {
+			order = append(order, i)
+		}
+	}
+
+	responseType := "batch-response"
+	if bundle.Type == "transaction" {
+		responseType = "transaction-response"
+		failed := -1
+		err := db.DB.Transaction(func(tx *gorm.DB) error {
+			for _, i := range order {
+				if err := imp.importEntry(tx, i); err != nil {
+					failed = i
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			status, code := entryStatus(err)
+			outcome(c, status, code, fmt.Sprintf("entry %d: %s; no entries were imported", failed, entryMessage(err)))
+			return
+		}
+		for i := range imp.audits {
+			imp.logEntry(i)
+		}
+	} else {
+		for _, i := range order {
+			err := db.DB.Transaction(func(tx *gorm.DB) error {
+				return imp.importEntry(tx, i)
+			})
+			if err != nil {
+				status, code := entryStatus(err)
+				issue := appFHIR.NewOperationOutcome(code, entryMessage(err))
+				imp.results[i] = appFHIR.BundleEntry{Response: &appFHIR.BundleResponse{
+					Status:  fmt.Sprintf("%d %s", status, http.StatusText(status)),
+					Outcome: &issue,
+				}}
+				continue
+			}
+			imp.logEntry(i)
+		}
+	}
+
+	respond(c, http.StatusOK, appFHIR.Bundle{
+		ResourceType: "Bundle",
+		Type:         responseType,
+		Timestamp:    time.Now().UTC().Format(time.RFC3339),
+		Entry:        imp.results,
+	})
+}
+
+func entryStatus(err error) (int, string) {
+	var entryErr *entryError
+	if errors.As(err, &entryErr) {
+		return entryErr.status, entryErr.code
+	}
+	return http.StatusInternalServerError, "exception"
+}
+
+func entryMessage(err error) string {
+	var entryErr *entryError
+	if errors.As(err, &entryErr) {
+		return entryErr.msg
+	}
+	return "failed to store entry"
+}
+
+func (imp *importer) logEntry(i int) {
+	if len(imp.audits[i]) > 0 {
+		audit.LogMany(imp.c, imp.user, imp.audits[i])
+	}
+}
+
+// record stores the response for entry i along with the audit entries to write once it is committed.
+func (imp *importer) record(i, status int, location string, patientID uint, action, warning string) {
+	response := &appFHIR.BundleResponse{
+		Status:   fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Location: location,
+	}
+	if warning != "" {
+		issue := appFHIR.NewOperationOutcome("informational", warning)
+		issue.Issue[0].Severity = "warning"
+		response.Outcome = &issue
+	}
+	imp.results[i] = appFHIR.BundleEntry{Response: response}
+	if action != "" {
+		imp.audits[i] = append(imp.audits[i], audit.Entry{
+			Action:     action,
+			Resource:   "patient_record",
+			ResourceID: audit.ID(patientID),
+			PatientID:  audit.PatientRef(patientID),
+			Detail:     "fhir import: " + appFHIR.ResourceTypeOf(imp.bundle.Entry[i].Resource),
+		})
+	}
+}
+
+func (imp *importer) importEntry(tx *gorm.DB, i int) error {
+	entry := imp.bundle.Entry[i]
+	imp.audits[i] = nil
+	if entry.Request != nil {
+		if method := strings.ToUpper(entry.Request.Method); method != "POST" && method != "PUT" {
+			return &entryError{http.StatusBadRequest, "not-supported", "only POST and PUT entries can be imported"}
+		}
+	}
+
+	switch resourceType := appFHIR.ResourceTypeOf(entry.Resource); resourceType {
+	case "Patient":
+		return imp.importPatient(tx, i)
+	case "Condition":
+		return imp.importCondition(tx, i)
+	case "AllergyIntolerance":
+		return imp.importAllergy(tx, i)
+	case "Observation":
+		return imp.importObservation(tx, i)
+	case "":
+		return invalid("entry has no resource")
+	default:
+		return &entryError{http.StatusBadRequest, "not-supported", resourceType + " resources cannot be imported"}
+	}
+}
+
+func (imp *importer) importPatient(tx *gorm.DB, i int) error {
+	entry := imp.bundle.Entry[i]
+	var resource appFHIR.Patient
+	if err := json.Unmarshal(entry.Resource, &resource); err != nil {
+		return invalid("malformed Patient: %v", err)
+	}
+
+	candidate, err := patientCandidate(&resource)
+	if err != nil {
+		return err
+	}
+
+	existing, err := registry.Match(tx, candidate)
+	if err != nil {
+		return registryError(err)
+	}
+	if existing != nil && !imp.canWrite(existing.ID, models.ScopeDemographics) {
+		return forbidden("a matching patient already exists and you have no write access to their record")
+	}
+
+	patient, created, err := registry.MatchOrCreatePatient(tx, candidate)
+	if err != nil {
+		return registryError(err)
+	}
+	if created {
+		imp.created[patient.ID] = true
+		if imp.user.Role == models.RoleDoctor {
+			if err := tx.Create(&models.DoctorPatient{DoctorID: imp.user.ID, PatientID: patient.ID}).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	if entry.FullURL != "" {
+		imp.patients[entry.FullURL] = patient
+	}
+	if resource.ID != "" {
+		imp.patients["Patient/"+resource.ID] = patient
+	}
+
+	location := "Patient/" + appFHIR.ResourceID(patient.ID)
+	if created {
+		imp.record(i, http.StatusCreated, location, patient.ID, audit.ActionCreate, "")
+	} else {
+		imp.record(i, http.StatusOK, location, patient.ID, audit.ActionUpdate, "matched an existing patient")
+	}
+	return nil
+}
+
+func registryError(err error) error {
+	switch {
+	case errors.Is(err, registry.ErrAmbiguousMatch), errors.Is(err, registry.ErrIdentifierConflict):
+		return &entryError{http.StatusConflict, "conflict", err.Error()}
+	case errors.Is(err, registry.ErrNotPatient), errors.Is(err, registry.ErrInsufficientData):
+		return invalid("%s", err.Error())
+	}
+	return err
+}
+
+func patientCandidate(resource *appFHIR.Patient) (*registry.Candidate, error) {
+	candidate := &registry.Candidate{}
+	if len(resource.Name) > 0 {
+		name := resource.Name[0]
+		candidate.FullName = strings.TrimSpace(name.Text)
+		if candidate.FullName == "" {
+			candidate.FullName = strings.TrimSpace(strings.Join(append(append([]string{}, name.Given...), name.Family), " "))
+		}
+	}
+	for _, point := range resource.Telecom {
+		switch point.System {
+		case "email":
+			if candidate.Email == "" {
+				candidate.Email = point.Value
+			}
+		case "phone":
+			if candidate.Phone == "" {
+				candidate.Phone = point.Value
+			}
+		}
+	}
+	if resource.BirthDate != "" {
+		birthDate, err := resourceDate(resource.BirthDate)
+		if err != nil {
+			return nil, invalid("birthDate must be a date (YYYY, YYYY-MM or YYYY-MM-DD)")
+		}
+		candidate.BirthDate = &birthDate
+	}
+	if resource.Gender != "" && resource.Gender != "unknown" {
+		candidate.Gender = resource.Gender
+	}
+	if len(resource.Contact) > 0 {
+		contact := resource.Contact[0]
+		parts := []string{}
+		if contact.Name != nil {
+			name := contact.Name.Text
+			if name == "" {
+				name = strings.TrimSpace(strings.Join(append(append([]string{}, contact.Name.Given...), contact.Name.Family), " "))
+			}
+			if name != "" {
+				parts = append(parts, name)
+			}
+		}
+		for _, point := range contact.Telecom {
+			parts = append(parts, point.Value)
+		}
+		candidate.EmergencyContact = strings.Join(parts, ", ")
+	}
+	for _, identifier := range resource.Identifier {
+		if identifier.System != "" && identifier.Value != "" {
+			candidate.Identifiers = append(candidate.Identifiers, registry.Identifier{System: identifier.System, Value: identifier.Value})
+		}
+	}
+	return candidate, nil
+}
+
+// canWrite reports whether the importer may add data to an existing patient's record.
+func (imp *importer) canWrite(patientID uint, scope string) bool {
+	if imp.user.Role == models.RoleAdmin || imp.created[patientID] {
+		return true
+	}
+	grant, err := access.AuthorizeWrite(imp.user, patientID, scope)
+	if err != nil {
+		return false
+	}
+	if grant.Emergency != nil {
+		audit.MarkEmergency(imp.c, grant.Emergency.ID)
+	}
+	return true
+}
+
+// subject resolves a patient reference to a patient from this bundle or an existing record.
+func (imp *importer) subject(tx *gorm.DB, ref appFHIR.Reference, scope string) (*models.User, error) {
+	if ref.Reference == "" {
+		return nil, invalid("resource has no patient reference")
+	}
+	if patient, ok := imp.patients[ref.Reference]; ok {
+		return patient, nil
+	}
+
+	id, err := appFHIR.ParseReference(ref.Reference, "Patient")
+	if err != nil {
+		return nil, invalid("unresolved patient reference %q", ref.Reference)
+	}
+	var patient models.User
+	if err := tx.Preload("PatientProfile").Where("id = ? AND role = ?", id, models.RolePatient).First(&patient).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &entryError{http.StatusNotFound, "not-found", "patient " + strconv.Itoa(int(id)) + " not found"}
+		}
+		return nil, err
+	}
+	if !imp.canWrite(patient.ID, scope) {
+		return nil, forbidden(fmt.Sprintf("no write access to patient %d", patient.ID))
+	}
+	return &patient, nil
+}
+
+func (imp *importer) importCondition(tx *gorm.DB, i int) error {
+	var resource appFHIR.Condition
+	if err := json.Unmarshal(imp.bundle.Entry[i].Resource, &resource); err != nil {
+		return invalid("malformed Condition: %v", err)
+	}
+	patient, err := imp.subject(tx, resource.Subject, models.ScopeConditions)
+	if err != nil {
+		return err
+	}
+
+	disease, err := matchDisease(tx, &resource.Code)
+	if err != nil {
+		return err
+	}
+	if disease == nil {
+		imp.record(i, http.StatusOK, "", 0, "", fmt.Sprintf("condition %q does not match any known disease and was not imported", conceptText(&resource.Code)))
+		return nil
+	}
+
+	onset, err := conditionDate(resource.OnsetDateTime, "onsetDateTime")
+	if err != nil {
+		return err
+	}
+	abatement, err := conditionDate(resource.AbatementDateTime, "abatementDateTime")
+	if err != nil {
+		return err
+	}
+	problem := models.PatientProblem{
+		PatientID:    patient.ID,
+		DiseaseID:    disease.ID,
+		Status:       appFHIR.ProblemStatus(&resource),
+		Severity:     appFHIR.ProblemSeverity(&resource),
+		OnsetDate:    onset,
+		ResolvedDate: abatement,
+	}
+
+	// Past conditions go on the problem list only; they are not current diagnoses.
+	if problem.Status == models.ProblemResolved {
+		var count int64
+		query := tx.Model(&models.PatientProblem{}).Where("patient_id = ? AND disease_id = ? AND status = ?", patient.ID, disease.ID, models.ProblemResolved)
+		if abatement != nil {
+			query = query.Where("resolved_date = ?", *abatement)
+		}
+		if err := query.Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			imp.record(i, http.StatusOK, "", patient.ID, "", "resolved condition is already recorded")
+			return nil
+		}
+		if err := problemlist.Record(tx, &problem, imp.user.ID, "imported"); err != nil {
+			return conditionError(err)
+		}
+		imp.record(i, http.StatusCreated, "", patient.ID, audit.ActionCreate, "recorded as a resolved problem")
+		return nil
+	}
+
+	info, err := imp.medicalInfo(tx, patient.ID)
+	if err != nil {
+		return err
+	}
+	for _, d := range info.Diseases {
+		if d.ID == disease.ID {
+			location := "Condition/" + appFHIR.ConditionID(info.ID, disease.ID)
+			imp.record(i, http.StatusOK, location, patient.ID, "", "condition is already recorded")
+			return nil
+		}
+	}
+	info.Diseases = append(info.Diseases, *disease)
+	if err := medicalhistory.Save(tx, info, imp.user.ID); err != nil {
+		return err
+	}
+
+	// Saving opened an active problem for the disease; fill in what the Condition says about it.
+	var current models.PatientProblem
+	if err := tx.Where("patient_id = ? AND disease_id = ? AND status <> ?", patient.ID, disease.ID, models.ProblemResolved).
+		First(&current).Error; err != nil {
+		return err
+	}
+	updated := current
+	updated.Status = problem.Status
+	updated.Severity = problem.Severity
+	updated.OnsetDate = problem.OnsetDate
+	if err := problemlist.Update(tx, &current, &updated, imp.user.ID); err != nil {
+		return conditionError(err)
+	}
+
+	location := "Condition/" + appFHIR.ConditionID(info.ID, disease.ID)
+	imp.record(i, http.StatusCreated, location, patient.ID, audit.ActionUpdate, "")
+	return nil
+}
+
+// conditionDate reads the date part of a FHIR dateTime; empty values are no date.
+func conditionDate(value, field string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	d, err := resourceDate(value)
+	if err != nil {
+		return nil, invalid("%s must be a date", field)
+	}
+	return &d, nil
+}
+
+// resourceDate reads a FHIR date or dateTime of any precision (YYYY,
+// YYYY-MM, YYYY-MM-DD or a timestamp) as the first day of the period it
+// covers. Timestamps keep the calendar date of their own offset.
+func resourceDate(value string) (time.Time, error) {
+	if value == "" || value[0] < '0' || value[0] > '9' {
+		// ParseDate would otherwise accept search prefixes such as "ge2020".
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	filter, err := appFHIR.ParseDate(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	y, m, d := filter.Start.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC), nil
+}
+
+// conditionError reports problem list validation failures as invalid entries.
+func conditionError(err error) error {
+	if errors.Is(err, problemlist.ErrInvalidDates) {
+		return invalid("%s", err.Error())
+	}
+	return err
+}
+
+// matchDisease finds the catalog disease for a condition code: by ICD-10 or
+// SNOMED CT coding, by local disease id, and finally by name against the
+// coding displays and text. Retired codes still match so that historical
+// diagnoses can be imported.
+func matchDisease(tx *gorm.DB, code *appFHIR.CodeableConcept) (*models.Disease, error) {
+	find := func(query *gorm.DB) (*models.Disease, error) {
+		var disease models.Disease
+		err := query.First(&disease).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &disease, nil
+	}
+
+	var names []string
+	for _, coding := range code.Coding {
+		var query *gorm.DB
+		switch {
+		case appFHIR.CatalogCodeSystem(coding.System) != "":
+			query = tx.Where("code_system = ? AND code = ?", appFHIR.CatalogCodeSystem(coding.System), icd10.NormalizeCode(coding.Code))
+		case coding.System == appFHIR.SnomedSystem && coding.Code != "":
+			query = tx.Where("snomed_code = ?", coding.Code).Order("retired ASC, id ASC")
+		case coding.System == appFHIR.DiseaseSystem:
+			if id, err := strconv.ParseUint(coding.Code, 10, 64); err == nil {
+				query = tx.Where("id = ?", id)
+			}
+		}
+		if query != nil {
+			disease, err := find(query)
+			if err != nil || disease != nil {
+				return disease, err
+			}
+		}
+		if coding.Display != "" {
+			names = append(names, strings.ToLower(coding.Display))
+		}
+	}
+	if code.Text != "" {
+		names = append(names, strings.ToLower(code.Text))
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	return find(tx.Where("LOWER(name) IN ?", names).Order("retired ASC, id ASC"))
+}
+
+func conceptText(code *appFHIR.CodeableConcept) string {
+	if code == nil {
+		return ""
+	}
+	if code.Text != "" {
+		return code.Text
+	}
+	for _, coding := range code.Coding {
+		if coding.Display != "" {
+			return coding.Display
+		}
+		if coding.Code != "" {
+			return coding.Code
+		}
+	}
+	return ""
+}
+
+// medicalInfo loads the patient's medical info with its diseases. A patient
+// without one gets an unsaved record authored by the importer, which
+// medicalhistory.Save creates.
+func (imp *importer) medicalInfo(tx *gorm.DB, patientID uint) (*models.PatientMedicalInfo, error) {
+	var info models.PatientMedicalInfo
+	err := tx.Preload("Diseases").Where("patient_id = ?", patientID).First(&info).Error
+	if err == nil {
+		return &info, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return &models.PatientMedicalInfo{PatientID: patientID, DoctorID: imp.user.ID}, nil
+}
+
+func (imp *importer) importAllergy(tx *gorm.DB, i int) error {
+	var resource appFHIR.AllergyIntolerance
+	if err := json.Unmarshal(imp.bundle.Entry[i].Resource, &resource); err != nil {
+		return invalid("malformed AllergyIntolerance: %v", err)
+	}
+	patient, err := imp.subject(tx, resource.Patient, models.ScopeConditions)
+	if err != nil {
+		return err
+	}
+
+	allergy := conceptText(resource.Code)
+	if allergy == "" {
+		return invalid("AllergyIntolerance has no code")
+	}
+	if resource.Criticality == "high" {
+		allergy += " (high criticality)"
+	}
+
+	profile, err := ensureProfile(tx, patient)
+	if err != nil {
+		return err
+	}
+	location := "Patient/" + appFHIR.ResourceID(patient.ID)
+	for _, line := range strings.Split(profile.Allergies, "\n") {
+		if strings.EqualFold(strings.TrimSpace(line), allergy) {
+			imp.record(i, http.StatusOK, location, patient.ID, "", "allergy is already recorded")
+			return nil
+		}
+	}
+	if strings.TrimSpace(profile.Allergies) == "" {
+		profile.Allergies = allergy
+	} else {
+		profile.Allergies += "\n" + allergy
+	}
+	if err := tx.Model(profile).Select("Allergies").Updates(profile).Error; err != nil {
+		return err
+	}
+	imp.record(i, http.StatusOK, location, patient.ID, audit.ActionUpdate, "")
+	return nil
+}
+
+func ensureProfile(tx *gorm.DB, patient *models.User) (*models.PatientProfile, error) {
+	if patient.PatientProfile != nil {
+		return patient.PatientProfile, nil
+	}
+	var profile models.PatientProfile
+	err := tx.Where("user_id = ?", patient.ID).First(&profile).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		profile = models.PatientProfile{UserID: patient.ID}
+		err = tx.Create(&profile).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	patient.PatientProfile = &profile
+	return &profile, nil
+}
+
+func (imp *importer) importObservation(tx *gorm.DB, i int) error {
+	var resource appFHIR.Observation
+	if err := json.Unmarshal(imp.bundle.Entry[i].Resource, &resource); err != nil {
+		return invalid("malformed Observation: %v", err)
+	}
+	patient, err := imp.subject(tx, resource.Subject, models.ScopeConditions)
+	if err != nil {
+		return err
+	}
+	location := "Patient/" + appFHIR.ResourceID(patient.ID)
+
+	code := ""
+	for _, coding := range resource.Code.Coding {
+		if coding.System == loincSystem {
+			code = coding.Code
+			break
+		}
+	}
+
+	switch code {
+	case loincBloodGroup:
+		value := observationText(&resource)
+		if value == "" {
+			return invalid("blood group observation has no value")
+		}
+		group, ok := bloodGroup(value)
+		if !ok {
+			return invalid("blood group %q is not recognised", value)
+		}
+		profile, err := ensureProfile(tx, patient)
+		if err != nil {
+			return err
+		}
+		profile.BloodType = group
+		if err := tx.Model(profile).Select("BloodType").Updates(profile).Error; err != nil {
+			return err
+		}
+	case loincSex, loincSexAtBirth:
+		value := observationText(&resource)
+		if value == "" {
+			return invalid("sex observation has no value")
+		}
+		info, err := imp.medicalInfo(tx, patient.ID)
+		if err != nil {
+			return err
+		}
+		info.Gender = appFHIR.Gender(value)
+		if err := medicalhistory.Save(tx, info, imp.user.ID); err != nil {
+			return err
+		}
+	case loincAge:
+		if resource.ValueQuantity == nil || resource.ValueQuantity.Value == nil {
+			return invalid("age observation needs a valueQuantity in years")
+		}
+		band := ageband.For(int(*resource.ValueQuantity.Value))
+		if *resource.ValueQuantity.Value < 0 || band == "" {
+			return invalid("age %v is outside the configured age bands", *resource.ValueQuantity.Value)
+		}
+		info, err := imp.medicalInfo(tx, patient.ID)
+		if err != nil {
+			return err
+		}
+		info.AgeGroup = band
+		if err := medicalhistory.Save(tx, info, imp.user.ID); err != nil {
+			return err
+		}
+	default:
+		imp.record(i, http.StatusOK, location, patient.ID, "",
+			fmt.Sprintf("observation %q is not supported and was not imported", conceptText(&resource.Code)))
+		return nil
+	}
+
+	imp.record(i, http.StatusOK, location, patient.ID, audit.ActionUpdate, "")
+	return nil
+}
+
+// bloodGroupPattern matches the common spellings of an ABO/Rh(D) group:
+// "A+", "AB neg", "Group O Rh(D) positive", "0 Rh-", "A(II) Rh+", "(IV)".
+var bloodGroupPattern = regexp.MustCompile(
+	`^(?:(?:BLOOD\s+)?(?:GROUP|TYPE)\s+)?(AB|A|B|O|0)?\s*(?:\((I|II|III|IV)\))?\s*` +
+		`(?:RH\s*(?:\(D\))?\s*)?(\+|-|POS|POSITIVE|NEG|NEGATIVE)?$`)
+
+// aboRoman maps the Roman-numeral ABO notation onto the letter groups.
+var aboRoman = map[string]string{"I": "O", "II": "A", "III": "B", "IV": "AB"}
+
+// bloodGroup normalises a blood group value to the form stored on the
+// profile ("A", "AB+", "O-", ...). Values that do not name an ABO group,
+// or name two different ones, are rejected rather than stored verbatim.
+func bloodGroup(value string) (string, bool) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	value = strings.NewReplacer("\u2212", "-", "\u2013", "-", "\u207A", "+", "\u207B", "-").Replace(value)
+	m := bloodGroupPattern.FindStringSubmatch(value)
+	if m == nil {
+		return "", false
+	}
+	abo := m[1]
+	if abo == "0" {
+		abo = "O"
+	}
+	if roman := aboRoman[m[2]]; roman != "" {
+		if abo != "" && abo != roman {
+			return "", false
+		}
+		abo = roman
+	}
+	if abo == "" {
+		return "", false
+	}
+	switch m[3] {
+	case "+", "POS", "POSITIVE":
+		return abo + "+", true
+	case "-", "NEG", "NEGATIVE":
+		return abo + "-", true
+	}
+	return abo, true
+}
+
+func observationText(resource *appFHIR.Observation) string {
+	if resource.ValueString != "" {
+		return strings.TrimSpace(resource.ValueString)
+	}
+	return strings.TrimSpace(conceptText(resource.ValueCodeableConcept))
+}
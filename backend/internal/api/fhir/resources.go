@@ -0,0 +1,455 @@
+package fhir
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"medapp/internal/api/middleware"
+	"medapp/internal/db"
+	appFHIR "medapp/internal/fhir"
+	"medapp/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func searchPatients(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	p, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	ids, hasIDs, ok := parseIDs(c)
+	if !ok {
+		return
+	}
+
+	visible, all, err := visiblePatients(user, models.ScopeDemographics)
+	if err != nil {
+		outcome(c, http.StatusInternalServerError, "exception", "failed to check access")
+		return
+	}
+
+	query := db.DB.Model(&models.User{}).Where("role = ?", models.RolePatient)
+	if !all {
+		query = query.Where("id IN ?", visible)
+	}
+	if hasIDs {
+		query = query.Where("id IN ?", ids)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		outcome(c, http.StatusInternalServerError, "exception", "failed to count patients")
+		return
+	}
+	var patients []models.User
+	if err := query.Preload("PatientProfile").Order("id ASC").Offset(p.offset).Limit(p.count).Find(&patients).Error; err != nil {
+		outcome(c, http.StatusInternalServerError, "exception", "failed to load patients")
+		return
+	}
+
+	resources := make([]appFHIR.Resource, 0, len(patients))
+	patientIDs := make([]uint, 0, len(patients))
+	for i := range patients {
+		resources = append(resources, appFHIR.NewPatient(&patients[i]))
+		patientIDs = append(patientIDs, patients[i].ID)
+	}
+	logReads(c, user, "patient_profile", patientIDs)
+	respond(c, http.StatusOK, searchset(c, "Patient", p, total, resources))
+}
+
+func readPatient(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	id, ok := parsePathID(c)
+	if !ok {
+		return
+	}
+	if !authorizeRead(c, user, id, models.ScopeDemographics, "patient_profile") {
+		return
+	}
+
+	var patient models.User
+	if err := db.DB.Preload("PatientProfile").Where("id = ? AND role = ?", id, models.RolePatient).First(&patient).Error; err != nil {
+		notFoundOrError(c, err, "Patient")
+		return
+	}
+	logReads(c, user, "patient_profile", []uint{patient.ID})
+	respond(c, http.StatusOK, appFHIR.NewPatient(&patient))
+}
+
+// practitioners limits doctors to verified, active accounts for everyone but admins.
+func practitioners(user *models.User) *gorm.DB {
+	query := db.DB.Model(&models.User{}).Where("users.role = ?", models.RoleDoctor)
+	if user.Role != models.RoleAdmin {
+		query = query.Joins("JOIN doctor_profiles ON doctor_profiles.user_id = users.id").
+			Where("users.status = ? AND doctor_profiles.verification_status = ?", models.UserStatusActive, models.VerificationVerified)
+	}
+	return query
+}
+
+func searchPractitioners(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	p, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	ids, hasIDs, ok := parseIDs(c)
+	if !ok {
+		return
+	}
+
+	query := practitioners(user)
+	if hasIDs {
+		query = query.Where("users.id IN ?", ids)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		outcome(c, http.StatusInternalServerError, "exception", "failed to count practitioners")
+		return
+	}
+	var doctors []models.User
+	if err := query.Preload("DoctorProfile").Order("users.id ASC").Offset(p.offset).Limit(p.count).Find(&doctors).Error; err != nil {
+		outcome(c, http.StatusInternalServerError, "exception", "failed to load practitioners")
+		return
+	}
+
+	resources := make([]appFHIR.Resource, 0, len(doctors))
+	for i := range doctors {
+		resources = append(resources, appFHIR.NewPractitioner(&doctors[i]))
+	}
+	respond(c, http.StatusOK, searchset(c, "Practitioner", p, total, resources))
+}
+
+func readPractitioner(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	id, ok := parsePathID(c)
+	if !ok {
+		return
+	}
+
+	var doctor models.User
+	if err := practitioners(user).Preload("DoctorProfile").Where("users.id = ?", id).First(&doctor).Error; err != nil {
+		notFoundOrError(c, err, "Practitioner")
+		return
+	}
+	respond(c, http.StatusOK, appFHIR.NewPractitioner(&doctor))
+}
+
+// appointmentStatuses maps FHIR appointment statuses back to medapp statuses.
+var appointmentStatuses = map[string]models.AppointmentStatus{
+	"proposed":  models.AppointmentPending,
+	"pending":   models.AppointmentPending,
+	"booked":    models.AppointmentConfirmed,
+	"fulfilled": models.AppointmentCompleted,
+	"cancelled": models.AppointmentCancelled,
+}
+
+// appointments limits appointments to those the user takes part in, or all for admins.
+func appointments(user *models.User) *gorm.DB {
+	query := db.DB.Model(&models.Appointment{})
+	switch user.Role {
+	case models.RoleAdmin:
+	case models.RoleDoctor:
+		query = query.Where("doctor_id = ?", user.ID)
+	default:
+		query = query.Where("patient_id = ?", user.ID)
+	}
+	return query
+}
+
+func searchAppointments(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	p, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	ids, hasIDs, ok := parseIDs(c)
+	if !ok {
+		return
+	}
+	patientIDs, hasPatients, ok := parseReferences(c, "patient", "Patient")
+	if !ok {
+		return
+	}
+	doctorIDs, hasDoctors, ok := parseReferences(c, "practitioner", "Practitioner")
+	if !ok {
+		return
+	}
+	dates, ok := parseDates(c, "date")
+	if !ok {
+		return
+	}
+
+	query := appointments(user)
+	if hasIDs {
+		query = query.Where("id IN ?", ids)
+	}
+	if hasPatients {
+		query = query.Where("patient_id IN ?", patientIDs)
+	}
+	if hasDoctors {
+		query = query.Where("doctor_id IN ?", doctorIDs)
+	}
+	for _, filter := range dates {
+		clause, args := filter.Clause("scheduled_at")
+		query = query.Where(clause, args...)
+	}
+	if value := c.Query("status"); value != "" {
+		statuses := []models.AppointmentStatus{}
+		for _, code := range appFHIR.SplitValues(value) {
+			if status, known := appointmentStatuses[strings.ToLower(code)]; known {
+				statuses = append(statuses, status)
+			}
+		}
+		query = query.Where("status IN ?", statuses)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		outcome(c, http.StatusInternalServerError, "exception", "failed to count appointments")
+		return
+	}
+	var appts []models.Appointment
+	if err := query.Preload("Doctor").Preload("Patient").Order("scheduled_at ASC, id ASC").
+		Offset(p.offset).Limit(p.count).Find(&appts).Error; err != nil {
+		outcome(c, http.StatusInternalServerError, "exception", "failed to load appointments")
+		return
+	}
+
+	resources := make([]appFHIR.Resource, 0, len(appts))
+	readPatients := make([]uint, 0, len(appts))
+	for i := range appts {
+		resources = append(resources, appFHIR.NewAppointment(&appts[i]))
+		readPatients = append(readPatients, appts[i].PatientID)
+	}
+	logReads(c, user, "appointment", readPatients)
+	respond(c, http.StatusOK, searchset(c, "Appointment", p, total, resources))
+}
+
+func readAppointment(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	id, ok := parsePathID(c)
+	if !ok {
+		return
+	}
+
+	var appt models.Appointment
+	if err := appointments(user).Preload("Doctor").Preload("Patient").Where("id = ?", id).First(&appt).Error; err != nil {
+		notFoundOrError(c, err, "Appointment")
+		return
+	}
+	logReads(c, user, "appointment", []uint{appt.PatientID})
+	respond(c, http.StatusOK, appFHIR.NewAppointment(&appt))
+}
+
+// conditionRow is one disease recorded on a patient's medical info, with its
+// problem list entry when there is one.
+type conditionRow struct {
+	InfoID        uint
+	PatientID     uint
+	DoctorID      uint
+	UpdatedAt     time.Time
+	DiseaseID     uint
+	Name          string
+	Category      string
+	Code          string
+	CodeSystem    string
+	SnomedCode    string
+	ProblemStatus string
+	Severity      string
+	OnsetDate     *time.Time
+	DiagnosedByID *uint
+}
+
+func (r *conditionRow) resource() appFHIR.Condition {
+	info := models.PatientMedicalInfo{ID: r.InfoID, PatientID: r.PatientID, DoctorID: r.DoctorID, UpdatedAt: r.UpdatedAt}
+	disease := models.Disease{
+		ID:         r.DiseaseID,
+		Name:       r.Name,
+		Category:   r.Category,
+		Code:       r.Code,
+		CodeSystem: r.CodeSystem,
+		SnomedCode: r.SnomedCode,
+	}
+	condition := appFHIR.NewCondition(&info, &disease)
+	if r.ProblemStatus != "" {
+		appFHIR.ApplyProblem(&condition, &models.PatientProblem{
+			Status:        models.ProblemStatus(r.ProblemStatus),
+			Severity:      models.ProblemSeverity(r.Severity),
+			OnsetDate:     r.OnsetDate,
+			DiagnosedByID: r.DiagnosedByID,
+		})
+	}
+	return condition
+}
+
+func conditions() *gorm.DB {
+	return db.DB.Table("patient_medical_info_diseases AS j").
+		Joins("JOIN patient_medical_infos AS pmi ON pmi.id = j.patient_medical_info_id").
+		Joins("JOIN diseases AS d ON d.id = j.disease_id").
+		Joins("LEFT JOIN patient_problems AS pp ON pp.patient_id = pmi.patient_id AND pp.disease_id = d.id AND pp.status <> ?", models.ProblemResolved)
+}
+
+const conditionColumns = "pmi.id AS info_id, pmi.patient_id, pmi.doctor_id, pmi.updated_at, d.id AS disease_id, d.name, d.category, d.code, d.code_system, d.snomed_code, " +
+	"pp.status AS problem_status, pp.severity, pp.onset_date, pp.diagnosed_by_id"
+
+func searchConditions(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	p, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	patientIDs, hasPatients, ok := parseReferences(c, "patient", "Patient")
+	if !ok {
+		return
+	}
+	dates, ok := parseDates(c, "recorded-date")
+	if !ok {
+		return
+	}
+
+	visible, all, err := visiblePatients(user, models.ScopeConditions)
+	if err != nil {
+		outcome(c, http.StatusInternalServerError, "exception", "failed to check access")
+		return
+	}
+
+	query := conditions()
+	if !all {
+		query = query.Where("pmi.patient_id IN ?", visible)
+	}
+	if hasPatients {
+		query = query.Where("pmi.patient_id IN ?", patientIDs)
+	}
+	if value := c.Query("_id"); value != "" {
+		pairs := []string{}
+		args := []interface{}{}
+		for _, id := range appFHIR.SplitValues(value) {
+			infoID, diseaseID, valid := parseConditionID(id)
+			if !valid {
+				outcome(c, http.StatusBadRequest, "invalid", "invalid _id "+strconv.Quote(id))
+				return
+			}
+			pairs = append(pairs, "(pmi.id = ? AND d.id = ?)")
+			args = append(args, infoID, diseaseID)
+		}
+		query = query.Where(strings.Join(pairs, " OR "), args...)
+	}
+	if value := c.Query("code"); value != "" {
+		clauses := []string{}
+		args := []interface{}{}
+		for _, token := range appFHIR.SplitValues(value) {
+			system, code, hasSystem := strings.Cut(token, "|")
+			if !hasSystem {
+				system, code = "", token
+			}
+			switch {
+			case !hasSystem:
+				clauses = append(clauses, "(d.code = ? OR d.snomed_code = ?)")
+				args = append(args, strings.ToUpper(code), code)
+			case system == appFHIR.DiseaseSystem:
+				clauses = append(clauses, "CAST(d.id AS TEXT) = ?")
+				args = append(args, code)
+			case system == appFHIR.SnomedSystem:
+				clauses = append(clauses, "d.snomed_code = ?")
+				args = append(args, code)
+			case appFHIR.CatalogCodeSystem(system) != "":
+				clauses = append(clauses, "(d.code_system = ? AND d.code = ?)")
+				args = append(args, appFHIR.CatalogCodeSystem(system), strings.ToUpper(code))
+			default:
+				outcome(c, http.StatusBadRequest, "not-supported", "unsupported code system "+strconv.Quote(system))
+				return
+			}
+		}
+		query = query.Where(strings.Join(clauses, " OR "), args...)
+	}
+	if value := c.Query("clinical-status"); value != "" {
+		statuses := appFHIR.SplitValues(value)
+		for i, status := range statuses {
+			if _, code, hasSystem := strings.Cut(status, "|"); hasSystem {
+				statuses[i] = code
+			}
+		}
+		query = query.Where("COALESCE(pp.status, ?) IN ?", models.ProblemActive, statuses)
+	}
+	for _, filter := range dates {
+		clause, args := filter.Clause("pmi.updated_at")
+		query = query.Where(clause, args...)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		outcome(c, http.StatusInternalServerError, "exception", "failed to count conditions")
+		return
+	}
+	var rows []conditionRow
+	if err := query.Select(conditionColumns).Order("pmi.id ASC, d.id ASC").
+		Offset(p.offset).Limit(p.count).Scan(&rows).Error; err != nil {
+		outcome(c, http.StatusInternalServerError, "exception", "failed to load conditions")
+		return
+	}
+
+	resources := make([]appFHIR.Resource, 0, len(rows))
+	readPatients := make([]uint, 0, len(rows))
+	for i := range rows {
+		resources = append(resources, rows[i].resource())
+		readPatients = append(readPatients, rows[i].PatientID)
+	}
+	logReads(c, user, "medical_info", readPatients)
+	respond(c, http.StatusOK, searchset(c, "Condition", p, total, resources))
+}
+
+func readCondition(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	infoID, diseaseID, valid := parseConditionID(c.Param("id"))
+	if !valid {
+		outcome(c, http.StatusNotFound, "not-found", "Condition not found")
+		return
+	}
+
+	var row conditionRow
+	result := conditions().Select(conditionColumns).Where("pmi.id = ? AND d.id = ?", infoID, diseaseID).Limit(1).Scan(&row)
+	if result.Error != nil {
+		outcome(c, http.StatusInternalServerError, "exception", "failed to load condition")
+		return
+	}
+	if result.RowsAffected == 0 {
+		outcome(c, http.StatusNotFound, "not-found", "Condition not found")
+		return
+	}
+	if !authorizeRead(c, user, row.PatientID, models.ScopeConditions, "medical_info") {
+		return
+	}
+	logReads(c, user, "medical_info", []uint{row.PatientID})
+	respond(c, http.StatusOK, row.resource())
+}
+
+// parseConditionID splits "<medical info id>-<disease id>".
+func parseConditionID(id string) (uint, uint, bool) {
+	parts := strings.SplitN(id, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	infoID, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	diseaseID, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return uint(infoID), uint(diseaseID), true
+}
+
+func notFoundOrError(c *gin.Context, err error, resourceType string) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		outcome(c, http.StatusNotFound, "not-found", resourceType+" not found")
+		return
+	}
+	outcome(c, http.StatusInternalServerError, "exception", "failed to load "+strings.ToLower(resourceType))
+}
@@ -0,0 +1,137 @@
+package hl7
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"medapp/internal/adt"
+	"medapp/internal/api/middleware"
+	"medapp/internal/audit"
+	"medapp/internal/db"
+	"medapp/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// RegisterAdminRoutes lets admins inspect received HL7 messages and replay rejected ones.
+func RegisterAdminRoutes(r *gin.RouterGroup) {
+	r.Use(middleware.AuthRequired(), middleware.RequireRole(models.RoleAdmin))
+	r.GET("", listMessages)
+	r.GET("/", listMessages)
+	r.GET("/:id", getMessage)
+	r.POST("/:id/replay", replayMessage)
+}
+
+func listMessages(c *gin.Context) {
+	page, pageSize := parsePagination(c)
+
+	query := db.DB.Model(&models.HL7Message{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if event := c.Query("event"); event != "" {
+		query = query.Where("event = ?", event)
+	}
+	if controlID := c.Query("controlId"); controlID != "" {
+		query = query.Where("control_id = ?", controlID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load hl7 messages"})
+		return
+	}
+
+	// The raw text holds patient data; it is only returned when a single message is opened.
+	var messages []models.HL7Message
+	if err := query.Omit("Raw").Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&messages).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load hl7 messages"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":    messages,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+}
+
+func getMessage(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid message id"})
+		return
+	}
+
+	var message models.HL7Message
+	if err := db.DB.First(&message, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "message not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load hl7 message"})
+		return
+	}
+
+	audit.Log(c, middleware.CurrentUser(c), audit.Entry{
+		Action:     audit.ActionRead,
+		Resource:   "hl7_message",
+		ResourceID: strconv.FormatUint(uint64(message.ID), 10),
+		PatientID:  message.PatientID,
+	})
+	c.JSON(http.StatusOK, message)
+}
+
+func replayMessage(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid message id"})
+		return
+	}
+
+	message, err := adt.Replay(uint(id), c.ClientIP())
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "message not found"})
+		case errors.Is(err, adt.ErrNotReplayable):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to replay hl7 message"})
+		}
+		return
+	}
+
+	audit.Log(c, middleware.CurrentUser(c), audit.Entry{
+		Action:     audit.ActionUpdate,
+		Resource:   "hl7_message",
+		ResourceID: strconv.FormatUint(uint64(message.ID), 10),
+		PatientID:  message.PatientID,
+		Detail:     "replay: " + string(message.Status),
+	})
+	message.Raw = ""
+	c.JSON(http.StatusOK, message)
+}
+
+func parsePagination(c *gin.Context) (int, int) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", strconv.Itoa(defaultPageSize)))
+	if err != nil || pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	return page, pageSize
+}
@@ -14,34 +14,76 @@ import (
 const userContextKey = "currentUser"
 
 func AuthRequired() gin.HandlerFunc {
+	return authenticate(false)
+}
+
+// AuthRequiredAllowReset authenticates like AuthRequired but lets through users
+// who still have to replace an admin-issued temporary password.
+func AuthRequiredAllowReset() gin.HandlerFunc {
+	return authenticate(true)
+}
+
+func authenticate(allowPendingReset bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		header := c.GetHeader("Authorization")
-		if header == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authorization header missing"})
+		user, status, message := resolveUser(c.GetHeader("Authorization"), allowPendingReset)
+		if user == nil {
+			c.AbortWithStatusJSON(status, gin.H{"error": message})
 			return
 		}
 
-		parts := strings.SplitN(header, " ", 2)
-		if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization header"})
-			return
-		}
+		c.Set(userContextKey, user)
+		c.Next()
+	}
+}
 
-		claims, err := auth.ParseToken(parts[1])
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
-			return
+// OptionalAuth sets the current user when a valid token is presented and
+// otherwise lets the request through anonymously.
+func OptionalAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if header := c.GetHeader("Authorization"); header != "" {
+			if user, _, _ := resolveUser(header, false); user != nil {
+				c.Set(userContextKey, user)
+			}
 		}
+		c.Next()
+	}
+}
 
-		var user models.User
-		if err := db.DB.Preload("DoctorProfile").Preload("PatientProfile").First(&user, claims.UserID).Error; err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
-			return
-		}
+// resolveUser validates the Authorization header and loads its user. On
+// failure it returns the status and message to respond with.
+func resolveUser(header string, allowPendingReset bool) (*models.User, int, string) {
+	if header == "" {
+		return nil, http.StatusUnauthorized, "authorization header missing"
+	}
 
-		c.Set(userContextKey, &user)
-		c.Next()
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return nil, http.StatusUnauthorized, "invalid authorization header"
+	}
+
+	claims, err := auth.ParseToken(parts[1])
+	if err != nil {
+		return nil, http.StatusUnauthorized, "invalid or expired token"
 	}
+
+	var user models.User
+	if err := db.DB.Preload("DoctorProfile").Preload("PatientProfile").First(&user, claims.UserID).Error; err != nil {
+		return nil, http.StatusUnauthorized, "user not found"
+	}
+
+	if user.Status != models.UserStatusActive {
+		return nil, http.StatusForbidden, "account is deactivated"
+	}
+
+	if user.PasswordChangedAt != nil && claims.IssuedAt != nil && claims.IssuedAt.Time.Before(*user.PasswordChangedAt) {
+		return nil, http.StatusUnauthorized, "invalid or expired token"
+	}
+
+	if user.MustResetPassword && !allowPendingReset {
+		return nil, http.StatusForbidden, "password reset required"
+	}
+
+	return &user, 0, ""
 }
 
 func CurrentUser(c *gin.Context) *models.User {
@@ -67,3 +109,19 @@ func RequireRole(role models.Role) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// RequireVerifiedDoctor blocks doctors whose license has not been approved by an admin.
+func RequireVerifiedDoctor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := CurrentUser(c)
+		if user == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+			return
+		}
+		if user.Role == models.RoleDoctor && (user.DoctorProfile == nil || user.DoctorProfile.VerificationStatus != models.VerificationVerified) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "doctor license is not verified"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -1,43 +1,248 @@
 package ml
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"time"
 
 	"medapp/internal/api/middleware"
+	"medapp/internal/db"
 	"medapp/internal/mlclient"
+	"medapp/internal/models"
+	"medapp/internal/symptomcheck"
 
 	"github.com/gin-gonic/gin"
 )
 
+// symptomsRequest is the version 1 request, answered by the original model.
 type symptomsRequest struct {
 	Fever    bool `json:"fever"`
 	Cough    bool `json:"cough"`
 	Headache bool `json:"headache"`
 }
 
-var client = mlclient.New()
-
 func RegisterRoutes(r *gin.RouterGroup) {
 	r.Use(middleware.AuthRequired())
+	r.GET("/symptoms/catalog", getSymptomCatalog)
 	r.POST("/symptoms", predictSymptoms)
+	r.GET("/triage", listTriage)
+	r.GET("/triage/:id", getTriage)
+	r.GET("/triage/:id/recommendations", getTriageRecommendations)
+	r.POST("/triage/:id/feedback", middleware.RequireRole(models.RoleDoctor), recordFeedback)
+	r.GET("/risk/:patientId", middleware.RequireRole(models.RoleDoctor), getRiskAssessment)
+
+	jobs := r.Group("/jobs", middleware.RequireRole(models.RoleDoctor))
+	jobs.POST("", createJob)
+	jobs.GET("", listJobs)
+	jobs.GET("/:id", getJob)
+	jobs.GET("/:id/results", getJobResults)
+	jobs.POST("/:id/cancel", cancelJob)
+}
+
+// RegisterAdminRoutes exports the doctors' verdicts on predictions for model
+// training and reports on the ML service.
+func RegisterAdminRoutes(r *gin.RouterGroup) {
+	r.Use(middleware.AuthRequired(), middleware.RequireRole(models.RoleAdmin))
+	r.GET("/triage/feedback", exportFeedback)
+	r.GET("/metrics", getMetrics)
+}
+
+// getSymptomCatalog lists the symptoms a version 2 request may report.
+func getSymptomCatalog(c *gin.Context) {
+	var symptoms []models.Symptom
+	if err := db.DB.Where("retired = ?", false).Order("sort_order ASC, name ASC").Find(&symptoms).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load symptoms"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"version":         mlclient.SymptomsSchemaVersion,
+		"symptoms":        symptoms,
+		"severities":      symptomcheck.Severities,
+		"maxSymptoms":     symptomcheck.MaxSymptoms,
+		"maxDurationDays": symptomcheck.MaxDurationDays,
+	})
 }
 
+// predictSymptoms answers a version 1 request (three booleans, no "version")
+// with the original prediction, and a version 2 request with a differential.
 func predictSymptoms(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read request"})
+		return
+	}
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(body, &header); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	switch header.Version {
+	case 0, 1:
+		predictSymptomsV1(c, body)
+	case mlclient.SymptomsSchemaVersion:
+		predictSymptomsV2(c, body)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported schema version"})
+	}
+}
+
+func predictSymptomsV1(c *gin.Context, body []byte) {
 	var req symptomsRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := json.Unmarshal(body, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	resp, err := client.PredictSymptoms(mlclient.SymptomsPayload{
+	resp, err := mlclient.Shared().PredictSymptoms(c.Request.Context(), mlclient.SymptomsPayload{
 		Fever:    req.Fever,
 		Cough:    req.Cough,
 		Headache: req.Headache,
 	})
 	if err != nil {
-		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		respondMLError(c, err, "symptom checker is temporarily unavailable")
 		return
 	}
 
-	c.JSON(http.StatusOK, resp)
+	modelVersion := legacyModelVersion
+	if resp.Fallback {
+		modelVersion = mlclient.FallbackModelVersion
+	}
+	record, err := recordTriage(middleware.CurrentUser(c), 1, modelVersion, req, resp, resp.Prediction, resp.Confidence, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save symptom check"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"prediction": resp.Prediction,
+		"confidence": resp.Confidence,
+		"fallback":   resp.Fallback,
+		"triageId":   record.ID,
+	})
+}
+
+func predictSymptomsV2(c *gin.Context, body []byte) {
+	user := middleware.CurrentUser(c)
+	var req symptomcheck.Request
+	if err := json.Unmarshal(body, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	symptoms, err := symptomcheck.Validate(db.DB, &req)
+	if err != nil {
+		var verr *symptomcheck.ValidationError
+		if errors.As(err, &verr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": verr.Error(), "problems": verr.Problems})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check symptoms"})
+		return
+	}
+
+	// Only patients have a record to draw on; anyone else gets a context-free answer
+	patient := mlclient.PatientContext{ChronicConditions: []string{}}
+	if user.Role == models.RolePatient {
+		if patient, err = symptomcheck.PatientContext(db.DB, user.ID, time.Now()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load patient record"})
+			return
+		}
+	}
+
+	payload := mlclient.SymptomsV2Payload{Version: mlclient.SymptomsSchemaVersion, Symptoms: symptoms, Patient: patient}
+	resp, err := mlclient.Shared().PredictSymptomsV2(c.Request.Context(), payload)
+	if err != nil {
+		respondMLError(c, err, "symptom checker is temporarily unavailable")
+		return
+	}
+
+	prediction, confidence := "", 0.0
+	if len(resp.Differential) > 0 {
+		prediction, confidence = resp.Differential[0].Condition, resp.Differential[0].Probability
+	}
+	record, err := recordTriage(user, mlclient.SymptomsSchemaVersion, resp.ModelVersion, payload, resp, prediction, confidence, resp.Urgency)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save symptom check"})
+		return
+	}
+
+	diseases, err := diseasesByCode(resp.Differential)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load diseases"})
+		return
+	}
+	differential := make([]gin.H, 0, len(resp.Differential))
+	for i, d := range resp.Differential {
+		item := gin.H{
+			"rank":        i + 1,
+			"condition":   d.Condition,
+			"code":        d.Code,
+			"probability": d.Probability,
+		}
+		if disease, ok := diseases[d.Code]; ok {
+			item["diseaseId"] = disease.ID
+			item["diseaseName"] = disease.Name
+		}
+		differential = append(differential, item)
+	}
+
+	recommendations, ok := recommendDoctors(c, resp.Differential, req.City, 0)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"triageId":        record.ID,
+		"version":         mlclient.SymptomsSchemaVersion,
+		"modelVersion":    resp.ModelVersion,
+		"fallback":        resp.Fallback,
+		"differential":    differential,
+		"urgency":         resp.Urgency,
+		"advice":          resp.Advice,
+		"redFlags":        resp.RedFlags,
+		"symptoms":        symptoms,
+		"patient":         patient,
+		"recommendations": recommendations,
+	})
+}
+
+// respondMLError reports a failed ML call: 503 while the service is down
+// (and no fallback answered), 502 when it rejected the request.
+func respondMLError(c *gin.Context, err error, unavailable string) {
+	switch {
+	case errors.Is(err, mlclient.ErrUnavailable), errors.Is(err, mlclient.ErrCircuitOpen), errors.Is(err, context.DeadlineExceeded):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": unavailable})
+	default:
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+	}
+}
+
+// getMetrics reports ML service latency, failures and circuit breaker state.
+func getMetrics(c *gin.Context) {
+	c.JSON(http.StatusOK, mlclient.Shared().Metrics())
+}
+
+// diseasesByCode finds the catalog diseases for the ICD-10 codes in the differential.
+func diseasesByCode(differential []mlclient.Differential) (map[string]models.Disease, error) {
+	codes := make([]string, 0, len(differential))
+	for _, d := range differential {
+		if d.Code != "" {
+			codes = append(codes, d.Code)
+		}
+	}
+	found := make(map[string]models.Disease, len(codes))
+	if len(codes) == 0 {
+		return found, nil
+	}
+	var diseases []models.Disease
+	if err := db.DB.Where("code_system = ? AND code IN ?", models.CodeSystemICD10, codes).Find(&diseases).Error; err != nil {
+		return nil, err
+	}
+	for _, d := range diseases {
+		found[d.Code] = d
+	}
+	return found, nil
 }
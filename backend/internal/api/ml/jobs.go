@@ -0,0 +1,254 @@
+package ml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"medapp/internal/api/middleware"
+	"medapp/internal/audit"
+	"medapp/internal/db"
+	"medapp/internal/mljobs"
+	"medapp/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxListedFailures caps the failed patients shown when polling a job.
+const maxListedFailures = 100
+
+type jobRequest struct {
+	Kind       string `json:"kind"`       // defaults to risk
+	PatientIDs []uint `json:"patientIds"` // defaults to the doctor's whole panel
+	Refresh    bool   `json:"refresh"`
+}
+
+// createJob queues batch scoring of the doctor's patients.
+func createJob(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	var req jobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Kind == "" {
+		req.Kind = models.MLJobKindRisk
+	}
+
+	var patientIDs []uint
+	if len(req.PatientIDs) == 0 {
+		if err := db.DB.Model(&models.DoctorPatient{}).
+			Joins("JOIN users ON users.id = doctor_patients.patient_id").
+			Where("doctor_patients.doctor_id = ? AND users.status = ?", user.ID, models.UserStatusActive).
+			Distinct().Order("doctor_patients.patient_id").
+			Pluck("doctor_patients.patient_id", &patientIDs).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load patients"})
+			return
+		}
+	} else {
+		seen := make(map[uint]bool, len(req.PatientIDs))
+		for _, id := range req.PatientIDs {
+			if !seen[id] {
+				seen[id] = true
+				patientIDs = append(patientIDs, id)
+			}
+		}
+		if len(patientIDs) <= mljobs.MaxPatients {
+			var found int64
+			if err := db.DB.Model(&models.User{}).
+				Where("id IN ? AND role = ? AND status = ?", patientIDs, models.RolePatient, models.UserStatusActive).
+				Count(&found).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load patients"})
+				return
+			}
+			if int(found) != len(patientIDs) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "patientIds must all be active patients"})
+				return
+			}
+		}
+	}
+
+	job, err := mljobs.Submit(user, req.Kind, patientIDs, req.Refresh)
+	if err != nil {
+		switch {
+		case errors.Is(err, mljobs.ErrUnknownKind), errors.Is(err, mljobs.ErrNoPatients), errors.Is(err, mljobs.ErrTooManyItems):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, mljobs.ErrTooManyActive):
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create job"})
+		}
+		return
+	}
+	audit.Log(c, user, audit.Entry{
+		Action:     audit.ActionCreate,
+		Resource:   "ml_job",
+		ResourceID: audit.ID(job.ID),
+		Detail:     fmt.Sprintf("%s scoring of %d patients", job.Kind, job.Total),
+	})
+	c.JSON(http.StatusAccepted, jobResponse(job))
+}
+
+// listJobs lists the doctor's jobs, newest first.
+func listJobs(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	var jobs []models.MLJob
+	if err := db.DB.Where("created_by_id = ?", user.ID).Order("created_at DESC, id DESC").Limit(maxHistoryLimit).Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load jobs"})
+		return
+	}
+	results := make([]gin.H, 0, len(jobs))
+	for i := range jobs {
+		results = append(results, jobResponse(&jobs[i]))
+	}
+	c.JSON(http.StatusOK, results)
+}
+
+// getJob reports a job's progress and which patients could not be scored.
+func getJob(c *gin.Context) {
+	job, ok := loadJob(c)
+	if !ok {
+		return
+	}
+	var failed []models.MLJobItem
+	if err := db.DB.Where("job_id = ? AND status = ?", job.ID, models.MLJobItemFailed).
+		Order("id").Limit(maxListedFailures).Find(&failed).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load job"})
+		return
+	}
+	failures := make([]gin.H, 0, len(failed))
+	for _, item := range failed {
+		failures = append(failures, gin.H{"patientId": item.PatientID, "error": item.Error, "attempts": item.Attempts})
+	}
+	resp := jobResponse(job)
+	resp["failures"] = failures
+	c.JSON(http.StatusOK, resp)
+}
+
+// getJobResults downloads the scores found so far as JSON or CSV.
+func getJobResults(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	job, ok := loadJob(c)
+	if !ok {
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or json"})
+		return
+	}
+	results, err := mljobs.Results(db.DB, job.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load job results"})
+		return
+	}
+
+	filename := fmt.Sprintf("ml-job-%d-results", job.ID)
+	var buf bytes.Buffer
+	if format == "csv" {
+		if err := mljobs.WriteCSV(&buf, results); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to export job results"})
+			return
+		}
+	}
+
+	entries := make([]audit.Entry, 0, len(results))
+	for _, r := range results {
+		if r.AssessmentID == nil {
+			continue
+		}
+		entries = append(entries, audit.Entry{
+			Action:     audit.ActionRead,
+			Resource:   "risk_assessment",
+			ResourceID: audit.ID(*r.AssessmentID),
+			PatientID:  audit.PatientRef(r.PatientID),
+			Detail:     "ml job " + audit.ID(job.ID),
+		})
+	}
+	audit.LogMany(c, user, entries)
+
+	if format == "csv" {
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.csv"`)
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`.json"`)
+	resp := jobResponse(job)
+	resp["results"] = results
+	c.JSON(http.StatusOK, resp)
+}
+
+// cancelJob stops a job; patients already scored keep their results.
+func cancelJob(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	job, ok := loadJob(c)
+	if !ok {
+		return
+	}
+	if err := mljobs.Cancel(job.ID); err != nil {
+		if errors.Is(err, mljobs.ErrFinished) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cancel job"})
+		return
+	}
+	audit.Log(c, user, audit.Entry{
+		Action:     audit.ActionUpdate,
+		Resource:   "ml_job",
+		ResourceID: audit.ID(job.ID),
+		Detail:     "cancelled",
+	})
+	if err := db.DB.First(job, job.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load job"})
+		return
+	}
+	c.JSON(http.StatusOK, jobResponse(job))
+}
+
+// loadJob loads the job in the URL if the signed-in doctor created it.
+func loadJob(c *gin.Context) (*models.MLJob, bool) {
+	user := middleware.CurrentUser(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+		return nil, false
+	}
+	var job models.MLJob
+	if err := db.DB.Where("id = ? AND created_by_id = ?", id, user.ID).First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load job"})
+		return nil, false
+	}
+	return &job, true
+}
+
+func jobResponse(job *models.MLJob) gin.H {
+	done := job.Succeeded + job.Failed + job.Cancelled
+	progress := 0.0
+	if job.Total > 0 {
+		progress = float64(done*1000/job.Total) / 1000
+	}
+	return gin.H{
+		"id":              job.ID,
+		"kind":            job.Kind,
+		"status":          job.Status,
+		"refresh":         job.Refresh,
+		"total":           job.Total,
+		"succeeded":       job.Succeeded,
+		"failed":          job.Failed,
+		"cancelled":       job.Cancelled,
+		"pending":         job.Total - done,
+		"progress":        progress,
+		"cancelRequested": job.CancelRequested,
+		"createdAt":       job.CreatedAt,
+		"startedAt":       job.StartedAt,
+		"finishedAt":      job.FinishedAt,
+	}
+}
@@ -0,0 +1,81 @@
+package ml
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"medapp/internal/api/middleware"
+	"medapp/internal/audit"
+	"medapp/internal/db"
+	"medapp/internal/mlclient"
+	"medapp/internal/recommend"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getTriageRecommendations suggests doctors for a stored symptom check.
+func getTriageRecommendations(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	record, ok := loadTriage(c, user, audit.ActionRead)
+	if !ok {
+		return
+	}
+
+	var differential []mlclient.Differential
+	if record.SchemaVersion == mlclient.SymptomsSchemaVersion {
+		var output mlclient.DifferentialResponse
+		if err := json.Unmarshal([]byte(record.Output), &output); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read symptom check"})
+			return
+		}
+		differential = output.Differential
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	results, ok := recommendDoctors(c, differential, c.Query("city"), limit)
+	if !ok {
+		return
+	}
+	if !ownTriage(record, user) {
+		audit.Log(c, user, triageEntry(audit.ActionRead, audit.OutcomeSuccess, record, "recommendations"))
+	}
+	c.JSON(http.StatusOK, gin.H{"triageId": record.ID, "urgency": record.Urgency, "recommendations": results})
+}
+
+// recommendDoctors ranks doctors for the differential and renders them.
+func recommendDoctors(c *gin.Context, differential []mlclient.Differential, city string, limit int) ([]gin.H, bool) {
+	recommendations, err := recommend.Recommend(db.DB, recommend.Input{
+		Differential: differential,
+		City:         strings.TrimSpace(city),
+		Limit:        limit,
+		Now:          time.Now(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to recommend doctors"})
+		return nil, false
+	}
+	results := make([]gin.H, 0, len(recommendations))
+	for _, r := range recommendations {
+		profile := r.Doctor.DoctorProfile
+		results = append(results, gin.H{
+			"doctor": gin.H{
+				"id":              r.Doctor.ID,
+				"fullName":        r.Doctor.FullName,
+				"speciality":      profile.Speciality,
+				"experienceYears": profile.Experience,
+				"clinicName":      profile.ClinicName,
+				"city":            profile.City,
+				"avatarUrl":       profile.AvatarURL,
+				"consultationFee": profile.ConsultationFee,
+			},
+			"score":        r.Score,
+			"speciality":   r.Speciality,
+			"condition":    r.Condition,
+			"cityMatch":    r.CityMatch,
+			"nextFreeSlot": r.NextFreeSlot,
+		})
+	}
+	return results, true
+}
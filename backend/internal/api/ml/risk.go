@@ -0,0 +1,101 @@
+package ml
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"medapp/internal/access"
+	"medapp/internal/api/middleware"
+	"medapp/internal/audit"
+	"medapp/internal/db"
+	"medapp/internal/mlclient"
+	"medapp/internal/models"
+	"medapp/internal/risk"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// riskScopes are the consents a risk assessment reads: demographics for age,
+// gender and blood type, conditions for the problem list.
+var riskScopes = []string{models.ScopeDemographics, models.ScopeConditions}
+
+// getRiskAssessment scores a patient's chronic disease risk. The last
+// assessment is returned while the record is unchanged; ?refresh=true scores
+// it again, e.g. after a model update.
+func getRiskAssessment(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	id, err := strconv.ParseUint(c.Param("patientId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid patient id"})
+		return
+	}
+	patientID := uint(id)
+
+	var patient models.User
+	if err := db.DB.Where("id = ? AND role = ?", patientID, models.RolePatient).First(&patient).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "patient not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load patient"})
+		return
+	}
+	for _, scope := range riskScopes {
+		grant, err := access.Authorize(user, patientID, scope)
+		if err != nil {
+			if errors.Is(err, access.ErrNoConsent) || errors.Is(err, access.ErrNotAssigned) || errors.Is(err, access.ErrForbidden) {
+				audit.Log(c, user, audit.Entry{
+					Action:    audit.ActionRead,
+					Resource:  "risk_assessment",
+					PatientID: audit.PatientRef(patientID),
+					Outcome:   audit.OutcomeDenied,
+					Detail:    err.Error() + " (" + scope + ")",
+				})
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check access"})
+			return
+		}
+		if grant.Emergency != nil {
+			audit.MarkEmergency(c, grant.Emergency.ID)
+		}
+	}
+
+	refresh, _ := strconv.ParseBool(c.Query("refresh"))
+	assessment, err := risk.Assess(c.Request.Context(), db.DB, mlclient.Shared(), patientID, &user.ID, refresh)
+	if err != nil {
+		var status *mlclient.StatusError
+		if errors.Is(err, mlclient.ErrUnavailable) || errors.Is(err, mlclient.ErrCircuitOpen) || errors.Is(err, context.DeadlineExceeded) || errors.As(err, &status) {
+			respondMLError(c, err, "risk scoring is temporarily unavailable")
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to assess risk"})
+		return
+	}
+
+	detail := "model " + assessment.Record.ModelVersion
+	if assessment.Cached {
+		detail += ", cached"
+	}
+	audit.Log(c, user, audit.Entry{
+		Action:     audit.ActionRead,
+		Resource:   "risk_assessment",
+		ResourceID: audit.ID(assessment.Record.ID),
+		PatientID:  audit.PatientRef(patientID),
+		Outcome:    audit.OutcomeSuccess,
+		Detail:     detail,
+	})
+	c.JSON(http.StatusOK, gin.H{
+		"id":           assessment.Record.ID,
+		"patientId":    patientID,
+		"modelVersion": assessment.Record.ModelVersion,
+		"assessedAt":   assessment.Record.CreatedAt,
+		"cached":       assessment.Cached,
+		"features":     assessment.Features,
+		"risks":        assessment.Result.Risks,
+	})
+}
@@ -0,0 +1,307 @@
+package ml
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"medapp/internal/access"
+	"medapp/internal/api/middleware"
+	"medapp/internal/audit"
+	"medapp/internal/db"
+	"medapp/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// legacyModelVersion names the model behind /predict/, which does not report one.
+const legacyModelVersion = "logistic-1"
+
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+)
+
+type feedbackRequest struct {
+	Matched     *bool  `json:"matched" binding:"required"`
+	DiagnosisID *uint  `json:"diagnosisId"` // the final diagnosis
+	Note        string `json:"note"`
+}
+
+// recordTriage keeps a symptom check. input and output are stored as JSON.
+func recordTriage(user *models.User, schemaVersion int, modelVersion string, input, output interface{}, prediction string, confidence float64, urgency string) (*models.TriageRecord, error) {
+	inputJSON, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	outputJSON, err := json.Marshal(output)
+	if err != nil {
+		return nil, err
+	}
+	record := models.TriageRecord{
+		UserID:        user.ID,
+		SchemaVersion: schemaVersion,
+		ModelVersion:  modelVersion,
+		Input:         string(inputJSON),
+		Output:        string(outputJSON),
+		Prediction:    prediction,
+		Confidence:    confidence,
+		Urgency:       urgency,
+	}
+	if user.Role == models.RolePatient {
+		record.PatientID = &user.ID
+	}
+	if err := db.DB.Create(&record).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// listTriage lists the signed-in user's own symptom checks, newest first.
+func listTriage(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultHistoryLimit)))
+	if err != nil || limit < 1 {
+		limit = defaultHistoryLimit
+	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+	query := db.DB.Where("user_id = ? OR patient_id = ?", user.ID, user.ID).Order("created_at DESC, id DESC").Limit(limit)
+	if before := c.Query("before"); before != "" {
+		id, err := strconv.ParseUint(before, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid before"})
+			return
+		}
+		query = query.Where("id < ?", id)
+	}
+
+	var records []models.TriageRecord
+	if err := query.Find(&records).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load symptom checks"})
+		return
+	}
+	results := make([]gin.H, 0, len(records))
+	for i := range records {
+		results = append(results, toTriageResponse(&records[i], false))
+	}
+	c.JSON(http.StatusOK, results)
+}
+
+func getTriage(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	record, ok := loadTriage(c, user, audit.ActionRead)
+	if !ok {
+		return
+	}
+	if !ownTriage(record, user) {
+		audit.Log(c, user, triageEntry(audit.ActionRead, audit.OutcomeSuccess, record, ""))
+	}
+	c.JSON(http.StatusOK, toTriageResponse(record, true))
+}
+
+// recordFeedback lets the treating doctor say whether the prediction matched
+// the final diagnosis.
+func recordFeedback(c *gin.Context) {
+	doctor := middleware.CurrentUser(c)
+	if doctor.Role != models.RoleDoctor {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the treating doctor can review a symptom check"})
+		return
+	}
+	record, ok := loadTriage(c, doctor, audit.ActionUpdate)
+	if !ok {
+		return
+	}
+	if record.PatientID == nil || *record.PatientID == doctor.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "only a patient's own symptom check can be reviewed"})
+		return
+	}
+	var req feedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.DiagnosisID != nil {
+		var count int64
+		if err := db.DB.Model(&models.Disease{}).Where("id = ?", *req.DiagnosisID).Count(&count).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check diagnosis"})
+			return
+		}
+		if count == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "diagnosis not found"})
+			return
+		}
+	}
+
+	now := time.Now()
+	record.FeedbackMatched = req.Matched
+	record.FeedbackDiagnosisID = req.DiagnosisID
+	record.FeedbackNote = strings.TrimSpace(req.Note)
+	record.FeedbackByID = &doctor.ID
+	record.FeedbackAt = &now
+	if err := db.DB.Model(record).
+		Select("FeedbackMatched", "FeedbackDiagnosisID", "FeedbackNote", "FeedbackByID", "FeedbackAt").
+		Updates(record).Error; err != nil {
+		audit.Log(c, doctor, triageEntry(audit.ActionUpdate, audit.OutcomeError, record, "feedback"))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record feedback"})
+		return
+	}
+	audit.Log(c, doctor, triageEntry(audit.ActionUpdate, audit.OutcomeSuccess, record, "feedback"))
+
+	if err := db.DB.Preload("FeedbackDiagnosis").First(record, record.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load symptom check"})
+		return
+	}
+	c.JSON(http.StatusOK, toTriageResponse(record, true))
+}
+
+// exportFeedback lists reviewed checks without who they belong to: the model
+// input and output, and the doctor's verdict.
+func exportFeedback(c *gin.Context) {
+	query := db.DB.Preload("FeedbackDiagnosis").Where("feedback_at IS NOT NULL").Order("id ASC")
+	if version := c.Query("modelVersion"); version != "" {
+		query = query.Where("model_version = ?", version)
+	}
+	if since := c.Query("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid since"})
+			return
+		}
+		query = query.Where("feedback_at >= ?", t)
+	}
+
+	var records []models.TriageRecord
+	if err := query.Find(&records).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load feedback"})
+		return
+	}
+	results := make([]gin.H, 0, len(records))
+	for _, r := range records {
+		item := gin.H{
+			"id":            r.ID,
+			"schemaVersion": r.SchemaVersion,
+			"modelVersion":  r.ModelVersion,
+			"input":         json.RawMessage(r.Input),
+			"output":        json.RawMessage(r.Output),
+			"prediction":    r.Prediction,
+			"confidence":    r.Confidence,
+			"matched":       r.FeedbackMatched,
+		}
+		if r.FeedbackDiagnosis != nil {
+			item["diagnosisCode"] = r.FeedbackDiagnosis.Code
+			item["diagnosisName"] = r.FeedbackDiagnosis.Name
+		}
+		results = append(results, item)
+	}
+	audit.Log(c, middleware.CurrentUser(c), audit.Entry{
+		Action:   audit.ActionRead,
+		Resource: "triage_feedback",
+		Detail:   "exported " + strconv.Itoa(len(results)) + " labeled checks",
+	})
+	c.JSON(http.StatusOK, results)
+}
+
+// loadTriage loads a symptom check the user may see: their own, or, for a
+// doctor, a patient's check attached to one of the doctor's appointments or
+// covered by the patient's consent. Admins see every check.
+func loadTriage(c *gin.Context, user *models.User, action string) (*models.TriageRecord, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid symptom check id"})
+		return nil, false
+	}
+	var record models.TriageRecord
+	if err := db.DB.Preload("FeedbackDiagnosis").First(&record, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "symptom check not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load symptom check"})
+		return nil, false
+	}
+	if (ownTriage(&record, user) && action == audit.ActionRead) || user.Role == models.RoleAdmin {
+		return &record, true
+	}
+
+	allowed := false
+	if user.Role == models.RoleDoctor && record.PatientID != nil {
+		var attached int64
+		if err := db.DB.Model(&models.Appointment{}).
+			Where("triage_id = ? AND doctor_id = ?", record.ID, user.ID).
+			Count(&attached).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check access"})
+			return nil, false
+		}
+		allowed = attached > 0
+		if !allowed {
+			switch err := access.Check(user, *record.PatientID, models.ScopeConditions); {
+			case err == nil:
+				allowed = true
+			case !errors.Is(err, access.ErrNoConsent) && !errors.Is(err, access.ErrForbidden):
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check access"})
+				return nil, false
+			}
+		}
+	}
+	if !allowed {
+		if record.PatientID != nil {
+			audit.Log(c, user, triageEntry(action, audit.OutcomeDenied, &record, ""))
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "symptom check not found"})
+		return nil, false
+	}
+	return &record, true
+}
+
+// ownTriage reports whether the user ran the check or it is about them; checks
+// of a merged patient account belong to the surviving account.
+func ownTriage(record *models.TriageRecord, user *models.User) bool {
+	return record.UserID == user.ID || (record.PatientID != nil && *record.PatientID == user.ID)
+}
+
+func triageEntry(action, outcome string, record *models.TriageRecord, detail string) audit.Entry {
+	entry := audit.Entry{
+		Action:     action,
+		Resource:   "triage_record",
+		ResourceID: audit.ID(record.ID),
+		Outcome:    outcome,
+		Detail:     detail,
+	}
+	if record.PatientID != nil {
+		entry.PatientID = audit.PatientRef(*record.PatientID)
+	}
+	return entry
+}
+
+// toTriageResponse renders a check; detail adds the model input and output.
+func toTriageResponse(r *models.TriageRecord, detail bool) gin.H {
+	response := gin.H{
+		"id":                  r.ID,
+		"createdAt":           r.CreatedAt,
+		"userId":              r.UserID,
+		"patientId":           r.PatientID,
+		"schemaVersion":       r.SchemaVersion,
+		"modelVersion":        r.ModelVersion,
+		"prediction":          r.Prediction,
+		"confidence":          r.Confidence,
+		"urgency":             r.Urgency,
+		"feedbackMatched":     r.FeedbackMatched,
+		"feedbackDiagnosisId": r.FeedbackDiagnosisID,
+		"feedbackNote":        r.FeedbackNote,
+		"feedbackAt":          r.FeedbackAt,
+	}
+	if r.FeedbackDiagnosis != nil {
+		response["feedbackDiagnosis"] = gin.H{"id": r.FeedbackDiagnosis.ID, "code": r.FeedbackDiagnosis.Code, "name": r.FeedbackDiagnosis.Name}
+	}
+	if detail {
+		response["input"] = json.RawMessage(r.Input)
+		response["output"] = json.RawMessage(r.Output)
+	}
+	return response
+}
@@ -0,0 +1,58 @@
+package notification
+
+import (
+	"net/http"
+	"time"
+
+	"medapp/internal/api/middleware"
+	"medapp/internal/db"
+	"medapp/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes mounts the current user's notifications.
+func RegisterRoutes(r *gin.RouterGroup) {
+	g := r.Group("/notifications")
+	g.Use(middleware.AuthRequired())
+	g.GET("", listNotifications)
+	g.GET("/", listNotifications)
+	g.POST("/:id/read", markRead)
+}
+
+func listNotifications(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+
+	query := db.DB.Where("user_id = ?", user.ID).Order("created_at DESC").Limit(100)
+	if c.Query("unread") == "true" {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var notifications []models.Notification
+	if err := query.Find(&notifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load notifications"})
+		return
+	}
+	c.JSON(http.StatusOK, notifications)
+}
+
+func markRead(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+
+	result := db.DB.Model(&models.Notification{}).
+		Where("id = ? AND user_id = ? AND read_at IS NULL", c.Param("id"), user.ID).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update notification"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"updated": result.RowsAffected})
+}
@@ -0,0 +1,167 @@
+package patient
+
+import (
+	"net/http"
+	"strconv"
+
+	"medapp/internal/ageband"
+	"medapp/internal/api/middleware"
+	"medapp/internal/audit"
+	"medapp/internal/db"
+	"medapp/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ageFilter narrows patients by derived age: minAge and maxAge in years, or
+// the label of one configured ageGroup.
+type ageFilter struct {
+	active bool
+	min    int
+	max    int // -1 when unbounded
+	band   string
+}
+
+func parseAgeFilter(c *gin.Context) (ageFilter, bool) {
+	filter := ageFilter{max: -1}
+	for _, param := range []struct {
+		name   string
+		target *int
+	}{{"minAge", &filter.min}, {"maxAge", &filter.max}} {
+		value := c.Query(param.name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + param.name})
+			return filter, false
+		}
+		*param.target = n
+		filter.active = true
+	}
+	if filter.max >= 0 && filter.max < filter.min {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "maxAge must not be less than minAge"})
+		return filter, false
+	}
+	if label := c.Query("ageGroup"); label != "" {
+		if _, ok := ageband.Lookup(label); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown age group"})
+			return filter, false
+		}
+		filter.band = label
+		filter.active = true
+	}
+	return filter, true
+}
+
+// matches reports whether a patient's derived age passes the filter. Patients
+// with neither a date of birth nor a recorded age group never match an active filter.
+func (f ageFilter) matches(age ageband.Result) bool {
+	if !f.active {
+		return true
+	}
+	if f.band != "" && age.Band != f.band {
+		return false
+	}
+	if f.min > 0 || f.max >= 0 {
+		return age.InRange(f.min, f.max)
+	}
+	return age.Band != ""
+}
+
+// listAgeBands returns the configured age bands.
+func listAgeBands(c *gin.Context) {
+	c.JSON(http.StatusOK, ageband.Bands())
+}
+
+// ageStats counts the doctor's visible patients per age band, taking the
+// same filter and age filters as the patient list.
+func ageStats(c *gin.Context) {
+	doctor := middleware.CurrentUser(c)
+	if doctor == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	filter, ok := parseAgeFilter(c)
+	if !ok {
+		return
+	}
+	patientIDs, ok := visiblePatientIDs(c, doctor)
+	if !ok {
+		return
+	}
+
+	var profiles []models.PatientProfile
+	var infos []models.PatientMedicalInfo
+	if len(patientIDs) > 0 {
+		if err := db.DB.Select("user_id", "date_of_birth").Where("user_id IN ?", patientIDs).Find(&profiles).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load patients"})
+			return
+		}
+		if err := db.DB.Select("id", "patient_id", "age_group").Where("patient_id IN ?", patientIDs).Find(&infos).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load medical info"})
+			return
+		}
+	}
+	scopes, err := patientScopes(doctor, patientIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load consents"})
+		return
+	}
+	profileOf := make(map[uint]*models.PatientProfile, len(profiles))
+	for i := range profiles {
+		profileOf[profiles[i].UserID] = &profiles[i]
+	}
+	infoOf := make(map[uint]*models.PatientMedicalInfo, len(infos))
+	for i := range infos {
+		if scopes[infos[i].PatientID][models.ScopeConditions] {
+			infoOf[infos[i].PatientID] = &infos[i]
+		}
+	}
+
+	bands := ageband.Bands()
+	counts := make(map[string]int, len(bands))
+	total, fromDOB, fromManual, unknown := 0, 0, 0, 0
+	for _, id := range patientIDs {
+		age := resolveAge(profileOf[id], infoOf[id])
+		if !filter.matches(age) {
+			continue
+		}
+		total++
+		switch age.Source {
+		case ageband.SourceDateOfBirth:
+			fromDOB++
+		case ageband.SourceManual:
+			fromManual++
+		}
+		if age.Band == "" {
+			unknown++
+			continue
+		}
+		counts[age.Band]++
+	}
+
+	results := make([]gin.H, 0, len(bands)+1)
+	for _, band := range bands {
+		results = append(results, gin.H{"label": band.Label, "min": band.Min, "max": band.Max, "count": counts[band.Label]})
+		delete(counts, band.Label)
+	}
+	// Age groups recorded by hand before the bands were changed
+	for label, count := range counts {
+		results = append(results, gin.H{"label": label, "count": count, "legacy": true})
+	}
+
+	audit.Log(c, doctor, audit.Entry{
+		Action:   audit.ActionRead,
+		Resource: "patient_statistics",
+		Detail:   "age bands",
+	})
+	c.JSON(http.StatusOK, gin.H{
+		"total":           total,
+		"bands":           results,
+		"unknown":         unknown,
+		"fromDateOfBirth": fromDOB,
+		"fromManual":      fromManual,
+	})
+}
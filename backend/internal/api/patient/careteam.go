@@ -0,0 +1,323 @@
+package patient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"medapp/internal/access"
+	"medapp/internal/api/middleware"
+	"medapp/internal/audit"
+	"medapp/internal/db"
+	"medapp/internal/models"
+	"medapp/internal/notify"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type updateCareTeamRequest struct {
+	IsPrimary *bool   `json:"isPrimary"`
+	CareRole  *string `json:"careRole"`
+}
+
+// RegisterPatientRoutes lets patients see the doctors treating them.
+func RegisterPatientRoutes(r *gin.RouterGroup) {
+	r.GET("/doctors", middleware.AuthRequired(), middleware.RequireRole(models.RolePatient), listMyDoctors)
+}
+
+func patientParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid patient id"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func validCareRole(role string) bool {
+	for _, r := range models.CareRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// errPrimaryHeld is returned when the patient already has a primary
+// physician and the user may not take the flag from them.
+var errPrimaryHeld = errors.New("only the primary physician or an admin can hand over the primary role")
+
+// mayChangePrimary reports whether user may set (primary) or clear the
+// primary flag on doctorID's assignment while heldBy holds it (0 for
+// nobody). Admins always may. Otherwise a vacant flag may be claimed, and
+// only its holder may hand it over or step down: nobody takes it from them.
+func mayChangePrimary(user *models.User, doctorID, heldBy uint, primary bool) bool {
+	if user.Role == models.RoleAdmin {
+		return true
+	}
+	if primary {
+		return heldBy == 0 || heldBy == doctorID || heldBy == user.ID
+	}
+	return heldBy != doctorID || heldBy == user.ID
+}
+
+// setPrimary sets or clears the primary flag on the assignment on behalf of
+// user, taking it from whoever held it before. It returns the doctor who
+// lost the flag, or 0 when nobody did.
+func setPrimary(tx *gorm.DB, user *models.User, assignment *models.DoctorPatient, primary bool) (uint, error) {
+	var holder models.DoctorPatient
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("patient_id = ? AND is_primary", assignment.PatientID).
+		First(&holder).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+	heldBy := holder.DoctorID
+	if !mayChangePrimary(user, assignment.DoctorID, heldBy, primary) {
+		return 0, errPrimaryHeld
+	}
+
+	if !primary {
+		assignment.IsPrimary = false
+		if heldBy != assignment.DoctorID {
+			return 0, nil
+		}
+		return heldBy, tx.Model(assignment).Update("is_primary", false).Error
+	}
+	assignment.IsPrimary = true
+	if heldBy == assignment.DoctorID {
+		return 0, nil
+	}
+	if heldBy != 0 {
+		if err := tx.Model(&holder).Update("is_primary", false).Error; err != nil {
+			return 0, err
+		}
+	}
+	return heldBy, tx.Model(assignment).Update("is_primary", true).Error
+}
+
+// notifyPrimaryLost tells a doctor that user took away their primary role,
+// unless they gave it up themselves.
+func notifyPrimaryLost(user *models.User, doctorID, patientID uint) {
+	if doctorID == 0 || doctorID == user.ID {
+		return
+	}
+	notify.Send([]uint{doctorID}, "care_team", "Primary physician changed",
+		fmt.Sprintf("You are no longer the primary physician of patient #%d; the care team was changed by %s.", patientID, user.FullName))
+}
+
+// loadCareTeam returns the patient's assignments with the doctors' profiles,
+// primary physician first.
+func loadCareTeam(patientID uint) ([]models.DoctorPatient, error) {
+	var team []models.DoctorPatient
+	err := db.DB.Preload("Doctor.DoctorProfile").
+		Where("patient_id = ?", patientID).
+		Order("is_primary DESC, created_at ASC").
+		Find(&team).Error
+	return team, err
+}
+
+func toCareTeamResponse(a *models.DoctorPatient) gin.H {
+	doctor := gin.H{}
+	if a.Doctor != nil {
+		doctor = gin.H{"id": a.Doctor.ID, "fullName": a.Doctor.FullName, "email": a.Doctor.Email, "phone": a.Doctor.Phone}
+		if a.Doctor.DoctorProfile != nil {
+			doctor["speciality"] = a.Doctor.DoctorProfile.Speciality
+			doctor["clinicName"] = a.Doctor.DoctorProfile.ClinicName
+			doctor["avatarUrl"] = a.Doctor.DoctorProfile.AvatarURL
+		}
+	}
+	return gin.H{
+		"id":        a.ID,
+		"doctorId":  a.DoctorID,
+		"patientId": a.PatientID,
+		"isPrimary": a.IsPrimary,
+		"careRole":  a.CareRole,
+		"since":     a.CreatedAt,
+		"updatedAt": a.UpdatedAt,
+		"doctor":    doctor,
+	}
+}
+
+// getCareTeam lists the doctors assigned to a patient.
+func getCareTeam(c *gin.Context) {
+	doctor := middleware.CurrentUser(c)
+	patientID, ok := patientParam(c)
+	if !ok {
+		return
+	}
+	if _, ok := checkAccess(c, doctor, patientID, models.ScopeDemographics, audit.ActionRead, "care_team", access.Authorize); !ok {
+		return
+	}
+
+	team, err := loadCareTeam(patientID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load care team"})
+		return
+	}
+	audit.Log(c, doctor, audit.Entry{
+		Action:    audit.ActionRead,
+		Resource:  "care_team",
+		PatientID: audit.PatientRef(patientID),
+	})
+
+	results := make([]gin.H, 0, len(team))
+	for i := range team {
+		results = append(results, toCareTeamResponse(&team[i]))
+	}
+	c.JSON(http.StatusOK, results)
+}
+
+// loadManagedAssignment loads the assignment of doctorId to the patient when
+// the current user may change it: the doctor themselves, the patient's
+// primary physician, or an admin.
+func loadManagedAssignment(c *gin.Context, action string) (*models.DoctorPatient, bool) {
+	user := middleware.CurrentUser(c)
+	patientID, ok := patientParam(c)
+	if !ok {
+		return nil, false
+	}
+	doctorID, err := strconv.Atoi(c.Param("doctorId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid doctor id"})
+		return nil, false
+	}
+
+	var assignment models.DoctorPatient
+	if err := db.DB.Where("patient_id = ? AND doctor_id = ?", patientID, doctorID).First(&assignment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "doctor is not on this patient's care team"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load assignment"})
+		return nil, false
+	}
+	if user.Role == models.RoleAdmin || user.ID == assignment.DoctorID {
+		return &assignment, true
+	}
+	var primary int64
+	if err := db.DB.Model(&models.DoctorPatient{}).
+		Where("patient_id = ? AND doctor_id = ? AND is_primary", patientID, user.ID).
+		Count(&primary).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check care team"})
+		return nil, false
+	}
+	if primary == 0 {
+		audit.Log(c, user, audit.Entry{
+			Action:     action,
+			Resource:   "doctor_patient",
+			ResourceID: audit.ID(assignment.ID),
+			PatientID:  audit.PatientRef(patientID),
+			Outcome:    audit.OutcomeDenied,
+			Detail:     "not the doctor or the primary physician",
+		})
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the doctor, the primary physician or an admin can change this assignment"})
+		return nil, false
+	}
+	return &assignment, true
+}
+
+// updateCareTeamMember changes a doctor's care role or primary flag. Doctors
+// editing their own assignment may only change the role or step down; see
+// mayChangePrimary.
+func updateCareTeamMember(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	assignment, ok := loadManagedAssignment(c, audit.ActionUpdate)
+	if !ok {
+		return
+	}
+
+	var req updateCareTeamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.CareRole != nil && !validCareRole(*req.CareRole) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid care role"})
+		return
+	}
+
+	var lostBy uint
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if req.CareRole != nil {
+			if err := tx.Model(assignment).Update("care_role", *req.CareRole).Error; err != nil {
+				return err
+			}
+		}
+		if req.IsPrimary != nil {
+			var err error
+			lostBy, err = setPrimary(tx, user, assignment, *req.IsPrimary)
+			return err
+		}
+		return nil
+	})
+	entry := audit.Entry{
+		Action:     audit.ActionUpdate,
+		Resource:   "doctor_patient",
+		ResourceID: audit.ID(assignment.ID),
+		PatientID:  audit.PatientRef(assignment.PatientID),
+	}
+	if errors.Is(err, errPrimaryHeld) {
+		entry.Outcome = audit.OutcomeDenied
+		entry.Detail = "primary physician held by another doctor"
+		audit.Log(c, user, entry)
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		entry.Outcome = audit.OutcomeError
+		audit.Log(c, user, entry)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update assignment"})
+		return
+	}
+	audit.Log(c, user, entry)
+	notifyPrimaryLost(user, lostBy, assignment.PatientID)
+
+	if err := db.DB.Preload("Doctor.DoctorProfile").First(assignment, assignment.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load assignment"})
+		return
+	}
+	c.JSON(http.StatusOK, toCareTeamResponse(assignment))
+}
+
+// unassignPatient removes a doctor from the patient's care team.
+func unassignPatient(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	assignment, ok := loadManagedAssignment(c, audit.ActionDelete)
+	if !ok {
+		return
+	}
+
+	entry := audit.Entry{
+		Action:     audit.ActionDelete,
+		Resource:   "doctor_patient",
+		ResourceID: audit.ID(assignment.ID),
+		PatientID:  audit.PatientRef(assignment.PatientID),
+	}
+	if err := db.DB.Delete(assignment).Error; err != nil {
+		entry.Outcome = audit.OutcomeError
+		audit.Log(c, user, entry)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unassign patient"})
+		return
+	}
+	audit.Log(c, user, entry)
+	c.JSON(http.StatusOK, gin.H{"message": "patient unassigned"})
+}
+
+// listMyDoctors lists the signed-in patient's care team.
+func listMyDoctors(c *gin.Context) {
+	patient := middleware.CurrentUser(c)
+	team, err := loadCareTeam(patient.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load doctors"})
+		return
+	}
+	results := make([]gin.H, 0, len(team))
+	for i := range team {
+		results = append(results, toCareTeamResponse(&team[i]))
+	}
+	c.JSON(http.StatusOK, results)
+}
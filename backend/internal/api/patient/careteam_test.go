@@ -0,0 +1,40 @@
+package patient
+
+import (
+	"testing"
+
+	"medapp/internal/models"
+)
+
+func TestMayChangePrimary(t *testing.T) {
+	const primary, other, newcomer = 1, 2, 3
+	doctor := func(id uint) *models.User { return &models.User{ID: id, Role: models.RoleDoctor} }
+	admin := &models.User{ID: 99, Role: models.RoleAdmin}
+
+	tests := []struct {
+		name     string
+		user     *models.User
+		doctorID uint
+		heldBy   uint
+		set      bool
+		want     bool
+	}{
+		{"doctor takes the flag from the primary", doctor(other), other, primary, true, false},
+		{"newly assigned doctor takes the flag", doctor(newcomer), newcomer, primary, true, false},
+		{"doctor clears the primary's flag", doctor(other), primary, primary, false, false},
+		{"primary hands the flag over", doctor(primary), other, primary, true, true},
+		{"primary steps down", doctor(primary), primary, primary, false, true},
+		{"primary keeps the flag", doctor(primary), primary, primary, true, true},
+		{"doctor claims a vacant flag", doctor(other), other, 0, true, true},
+		{"doctor clears a flag they do not hold", doctor(other), other, primary, false, true},
+		{"admin reassigns the flag", admin, other, primary, true, true},
+		{"admin clears the flag", admin, primary, primary, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mayChangePrimary(tt.user, tt.doctorID, tt.heldBy, tt.set); got != tt.want {
+				t.Errorf("mayChangePrimary = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
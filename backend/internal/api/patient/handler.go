@@ -1,28 +1,63 @@
 package patient
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"medapp/internal/access"
+	"medapp/internal/ageband"
 	"medapp/internal/api/middleware"
+	"medapp/internal/audit"
 	"medapp/internal/db"
+	"medapp/internal/duplicates"
+	"medapp/internal/i18n"
+	"medapp/internal/icd10"
+	"medapp/internal/medicalhistory"
 	"medapp/internal/models"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultTypeaheadLimit = 20
+	maxTypeaheadLimit     = 500
 )
 
 func RegisterRoutes(r *gin.RouterGroup) {
-	r.Use(middleware.AuthRequired(), middleware.RequireRole(models.RoleDoctor))
+	r.Use(middleware.AuthRequired(), middleware.RequireRole(models.RoleDoctor), middleware.RequireVerifiedDoctor())
 	r.POST("/assign", assignPatient)
 	r.POST("/:id/medical-info", updateMedicalInfo)
 	r.GET("/", listPatients)
 	r.GET("/diseases", listDiseases)
+	r.GET("/age-bands", listAgeBands)
+	r.GET("/stats/age", ageStats)
+	r.GET("/search", searchPatients)
+	r.GET("/:id", getPatient)
+	r.GET("/:id/care-team", getCareTeam)
+	r.PATCH("/:id/care-team/:doctorId", updateCareTeamMember)
+	r.DELETE("/:id/care-team/:doctorId", unassignPatient)
+	r.GET("/:id/medical-info/history", getMedicalInfoHistory)
+	r.GET("/:id/medical-info/history/:version", getMedicalInfoRevision)
+	r.GET("/:id/medical-info/diff", diffMedicalInfo)
+	r.GET("/:id/problems", listProblems)
+	r.POST("/:id/problems", createProblem)
+	r.GET("/:id/problems/timeline", problemTimeline)
+	r.GET("/:id/problems/:problemId", getProblem)
+	r.PATCH("/:id/problems/:problemId", updateProblem)
 }
 
 // Assign patient to doctor
 type assignPatientRequest struct {
-	PatientID uint `json:"patientId" binding:"required"`
+	PatientID uint   `json:"patientId" binding:"required"`
+	IsPrimary bool   `json:"isPrimary"`
+	CareRole  string `json:"careRole"` // defaults to attending
 }
 
 func assignPatient(c *gin.Context) {
@@ -37,6 +72,13 @@ func assignPatient(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if req.CareRole == "" {
+		req.CareRole = models.CareRoleAttending
+	}
+	if !validCareRole(req.CareRole) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid care role"})
+		return
+	}
 
 	// Check if patient exists
 	var patient models.User
@@ -49,6 +91,11 @@ func assignPatient(c *gin.Context) {
 		return
 	}
 
+	// Assignments are long-lived, so they need real consent rather than an emergency override.
+	if _, ok := checkAccess(c, doctor, req.PatientID, models.ScopeDemographics, audit.ActionCreate, "doctor_patient", consentOnly); !ok {
+		return
+	}
+
 	// Check if already assigned
 	var existing models.DoctorPatient
 	if err := db.DB.Where("doctor_id = ? AND patient_id = ?", doctor.ID, req.PatientID).First(&existing).Error; err != nil {
@@ -67,12 +114,47 @@ func assignPatient(c *gin.Context) {
 	assignment := models.DoctorPatient{
 		DoctorID:  doctor.ID,
 		PatientID: req.PatientID,
+		CareRole:  req.CareRole,
 	}
 
-	if err := db.DB.Create(&assignment).Error; err != nil {
+	var lostBy uint
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&assignment).Error; err != nil {
+			return err
+		}
+		if req.IsPrimary {
+			var err error
+			lostBy, err = setPrimary(tx, doctor, &assignment, true)
+			return err
+		}
+		return nil
+	}); errors.Is(err, errPrimaryHeld) {
+		audit.Log(c, doctor, audit.Entry{
+			Action:    audit.ActionCreate,
+			Resource:  "doctor_patient",
+			PatientID: audit.PatientRef(req.PatientID),
+			Outcome:   audit.OutcomeDenied,
+			Detail:    "primary physician held by another doctor",
+		})
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		audit.Log(c, doctor, audit.Entry{
+			Action:    audit.ActionCreate,
+			Resource:  "doctor_patient",
+			PatientID: audit.PatientRef(req.PatientID),
+			Outcome:   audit.OutcomeError,
+		})
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to assign patient"})
 		return
 	}
+	audit.Log(c, doctor, audit.Entry{
+		Action:     audit.ActionCreate,
+		Resource:   "doctor_patient",
+		ResourceID: audit.ID(assignment.ID),
+		PatientID:  audit.PatientRef(req.PatientID),
+	})
+	notifyPrimaryLost(doctor, lostBy, req.PatientID)
 
 	if err := db.DB.Preload("Patient").Preload("Doctor").First(&assignment, assignment.ID).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load assignment"})
@@ -83,6 +165,8 @@ func assignPatient(c *gin.Context) {
 		"id":        assignment.ID,
 		"doctorId":  assignment.DoctorID,
 		"patientId": assignment.PatientID,
+		"isPrimary": assignment.IsPrimary,
+		"careRole":  assignment.CareRole,
 		"patient": gin.H{
 			"id":       assignment.Patient.ID,
 			"fullName": assignment.Patient.FullName,
@@ -94,9 +178,28 @@ func assignPatient(c *gin.Context) {
 
 // Update patient medical info
 type updateMedicalInfoRequest struct {
-	Gender    string  `json:"gender" binding:"required"`
-	AgeGroup  string  `json:"ageGroup" binding:"required"`
+	Gender     string `json:"gender" binding:"required"`
+	AgeGroup   string `json:"ageGroup"` // only used when the date of birth is unknown
 	DiseaseIDs []uint `json:"diseaseIds"`
+	// Version is the version the edit was based on; If-Match may carry it instead.
+	Version *int `json:"version"`
+}
+
+// expectedVersion returns the version the client last saw, from the request
+// body or an If-Match header holding the ETag of an earlier response.
+func expectedVersion(c *gin.Context, req *updateMedicalInfoRequest) (int, bool) {
+	if req.Version != nil {
+		return *req.Version, true
+	}
+	tag := strings.TrimPrefix(strings.TrimSpace(c.GetHeader("If-Match")), "W/")
+	if v, err := strconv.Atoi(strings.Trim(tag, `"`)); err == nil {
+		return v, true
+	}
+	return 0, false
+}
+
+func versionTag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
 }
 
 func updateMedicalInfo(c *gin.Context) {
@@ -129,6 +232,29 @@ func updateMedicalInfo(c *gin.Context) {
 		return
 	}
 
+	if _, ok := checkAccess(c, doctor, uint(patientID), models.ScopeConditions, audit.ActionUpdate, "medical_info", access.AuthorizeWrite); !ok {
+		return
+	}
+
+	profile, err := loadProfile(uint(patientID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load patient profile"})
+		return
+	}
+
+	logWrite := func(action, outcome string, resourceID uint) {
+		entry := audit.Entry{
+			Action:    action,
+			Resource:  "medical_info",
+			PatientID: audit.PatientRef(uint(patientID)),
+			Outcome:   outcome,
+		}
+		if resourceID != 0 {
+			entry.ResourceID = audit.ID(resourceID)
+		}
+		audit.Log(c, doctor, entry)
+	}
+
 	// Load diseases
 	var diseases []models.Disease
 	if len(req.DiseaseIDs) > 0 {
@@ -138,45 +264,83 @@ func updateMedicalInfo(c *gin.Context) {
 		}
 	}
 
-	// Create or update medical info
+	// Retired codes may stay on a record that already has them but cannot be newly added
+	var retiredIDs []uint
+	for _, d := range diseases {
+		if d.Retired {
+			retiredIDs = append(retiredIDs, d.ID)
+		}
+	}
+	if len(retiredIDs) > 0 {
+		var kept int64
+		if err := db.DB.Table("patient_medical_info_diseases AS j").
+			Joins("JOIN patient_medical_infos AS pmi ON pmi.id = j.patient_medical_info_id").
+			Where("pmi.patient_id = ? AND j.disease_id IN ?", patientID, retiredIDs).
+			Count(&kept).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check diseases"})
+			return
+		}
+		if int(kept) < len(retiredIDs) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "retired diseases cannot be added"})
+			return
+		}
+	}
+
+	// Create or update medical info. Edits must name the version they were based
+	// on so a concurrent edit by another doctor is reported instead of lost.
+	action := audit.ActionUpdate
 	var medicalInfo models.PatientMedicalInfo
 	if err := db.DB.Where("patient_id = ?", patientID).First(&medicalInfo).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			// Create new
-			medicalInfo = models.PatientMedicalInfo{
-				PatientID: uint(patientID),
-				DoctorID:  doctor.ID,
-				Gender:    req.Gender,
-				AgeGroup:  req.AgeGroup,
-			}
-			if err := db.DB.Create(&medicalInfo).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create medical info"})
-				return
-			}
-		} else {
+		if err != gorm.ErrRecordNotFound {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check medical info"})
 			return
 		}
+		action = audit.ActionCreate
+		medicalInfo = models.PatientMedicalInfo{PatientID: uint(patientID)}
 	} else {
-		// Update existing
-		medicalInfo.DoctorID = doctor.ID
-		medicalInfo.Gender = req.Gender
-		medicalInfo.AgeGroup = req.AgeGroup
-		if err := db.DB.Model(&medicalInfo).Updates(map[string]interface{}{
-			"doctor_id": doctor.ID,
-			"gender":    req.Gender,
-			"age_group": req.AgeGroup,
-		}).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update medical info"})
+		version, ok := expectedVersion(c, &req)
+		if !ok {
+			c.JSON(http.StatusPreconditionRequired, gin.H{"error": "version is required to update medical info", "currentVersion": medicalInfo.Version})
+			return
+		}
+		if version != medicalInfo.Version {
+			logWrite(action, audit.OutcomeDenied, medicalInfo.ID)
+			c.JSON(http.StatusConflict, gin.H{"error": "medical info was changed by another user", "currentVersion": medicalInfo.Version})
 			return
 		}
 	}
+	// The age group follows the date of birth when it is known; a manual choice
+	// must be one of the configured bands, or the value already on record.
+	if profile != nil && profile.DateOfBirth != nil {
+		req.AgeGroup = ageband.Resolve(profile.DateOfBirth, "", time.Now()).Band
+	} else if req.AgeGroup == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ageGroup is required when the patient's date of birth is unknown"})
+		return
+	} else if _, known := ageband.Lookup(req.AgeGroup); !known && req.AgeGroup != medicalInfo.AgeGroup {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown age group"})
+		return
+	}
+	medicalInfo.DoctorID = doctor.ID
+	medicalInfo.Gender = req.Gender
+	medicalInfo.AgeGroup = req.AgeGroup
+	medicalInfo.Diseases = diseases
 
-	// Update diseases association
-	if err := db.DB.Model(&medicalInfo).Association("Diseases").Replace(diseases); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update diseases"})
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		return medicalhistory.Save(tx, &medicalInfo, doctor.ID)
+	})
+	if errors.Is(err, medicalhistory.ErrVersionConflict) {
+		var current models.PatientMedicalInfo
+		db.DB.Select("id", "version").Where("patient_id = ?", patientID).First(&current)
+		logWrite(action, audit.OutcomeDenied, current.ID)
+		c.JSON(http.StatusConflict, gin.H{"error": "medical info was changed by another user", "currentVersion": current.Version})
+		return
+	}
+	if err != nil {
+		logWrite(action, audit.OutcomeError, medicalInfo.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save medical info"})
 		return
 	}
+	logWrite(action, audit.OutcomeSuccess, medicalInfo.ID)
 
 	// Reload with associations
 	if err := db.DB.Preload("Diseases").Preload("Patient").Preload("Doctor").First(&medicalInfo, medicalInfo.ID).Error; err != nil {
@@ -184,7 +348,9 @@ func updateMedicalInfo(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, toMedicalInfoResponse(&medicalInfo))
+	localizeDiseases(c, medicalInfo.Diseases)
+	c.Header("ETag", versionTag(medicalInfo.Version))
+	c.JSON(http.StatusOK, toMedicalInfoResponse(&medicalInfo, profile))
 }
 
 // List patients with filter
@@ -195,23 +361,15 @@ func listPatients(c *gin.Context) {
 		return
 	}
 
-	filter := c.Query("filter") // "all" or "my" (default to "my")
-
-	var patientIDs []uint
-	if filter == "all" {
-		// Get all patients
-		if err := db.DB.Model(&models.User{}).Where("role = ?", models.RolePatient).Pluck("id", &patientIDs).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load patients"})
-			return
-		}
-	} else {
-		// Get only doctor's patients
-		if err := db.DB.Model(&models.DoctorPatient{}).Where("doctor_id = ?", doctor.ID).Pluck("patient_id", &patientIDs).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load assignments"})
-			return
-		}
+	ageFilter, ok := parseAgeFilter(c)
+	if !ok {
+		return
 	}
 
+	patientIDs, ok := visiblePatientIDs(c, doctor)
+	if !ok {
+		return
+	}
 	if len(patientIDs) == 0 {
 		c.JSON(http.StatusOK, []gin.H{})
 		return
@@ -230,6 +388,64 @@ func listPatients(c *gin.Context) {
 		return
 	}
 
+	scopes, err := patientScopes(doctor, patientIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load consents"})
+		return
+	}
+
+	// Ages are derived from the date of birth, so the age filters run here rather
+	// than in SQL. The recorded age group is part of the conditions scope.
+	ages := make(map[uint]ageband.Result, len(patients))
+	matched := patients[:0]
+	for _, p := range patients {
+		info := p.MedicalInfo
+		if !scopes[p.ID][models.ScopeConditions] {
+			info = nil
+		}
+		age := resolveAge(p.PatientProfile, info)
+		if !ageFilter.matches(age) {
+			continue
+		}
+		ages[p.ID] = age
+		matched = append(matched, p)
+	}
+	patients = matched
+	patientIDs = patientIDs[:0]
+	for _, p := range patients {
+		patientIDs = append(patientIDs, p.ID)
+	}
+
+	entries := make([]audit.Entry, 0, len(patients))
+	for _, p := range patients {
+		entries = append(entries, audit.Entry{
+			Action:     audit.ActionRead,
+			Resource:   "patient_record",
+			ResourceID: audit.ID(p.ID),
+			PatientID:  audit.PatientRef(p.ID),
+			Detail:     "patient list",
+		})
+	}
+	audit.LogMany(c, doctor, entries)
+
+	c.JSON(http.StatusOK, patientListItems(c, patients, scopes, ages))
+}
+
+// patientListItems renders patients for the patient list and search. Medical
+// info is only included for patients whose conditions scope the doctor holds.
+func patientListItems(c *gin.Context, patients []models.User, scopes map[uint]map[string]bool, ages map[uint]ageband.Result) []gin.H {
+	var shown []models.Disease
+	for _, p := range patients {
+		if p.MedicalInfo != nil && scopes[p.ID][models.ScopeConditions] {
+			shown = append(shown, p.MedicalInfo.Diseases...)
+		}
+	}
+	localizeDiseases(c, shown)
+	translated := make(map[uint]models.Disease, len(shown))
+	for _, d := range shown {
+		translated[d.ID] = d
+	}
+
 	results := make([]gin.H, 0, len(patients))
 	for _, p := range patients {
 		patientData := gin.H{
@@ -237,11 +453,13 @@ func listPatients(c *gin.Context) {
 			"fullName": p.FullName,
 			"email":    p.Email,
 			"phone":    p.Phone,
+			"age":      ages[p.ID].Age,
 		}
 
-		if p.MedicalInfo != nil {
+		if p.MedicalInfo != nil && scopes[p.ID][models.ScopeConditions] {
 			diseases := make([]gin.H, 0, len(p.MedicalInfo.Diseases))
 			for _, d := range p.MedicalInfo.Diseases {
+				d = translated[d.ID]
 				diseases = append(diseases, gin.H{
 					"id":       d.ID,
 					"name":     d.Name,
@@ -249,11 +467,13 @@ func listPatients(c *gin.Context) {
 				})
 			}
 			patientData["medicalInfo"] = gin.H{
-				"id":        p.MedicalInfo.ID,
-				"gender":    p.MedicalInfo.Gender,
-				"ageGroup":  p.MedicalInfo.AgeGroup,
-				"diseases":  diseases,
-				"updatedAt": p.MedicalInfo.UpdatedAt,
+				"id":             p.MedicalInfo.ID,
+				"gender":         p.MedicalInfo.Gender,
+				"ageGroup":       ages[p.ID].Band,
+				"ageGroupSource": ages[p.ID].Source,
+				"diseases":       diseases,
+				"version":        p.MedicalInfo.Version,
+				"updatedAt":      p.MedicalInfo.UpdatedAt,
 				"doctor": gin.H{
 					"id":       p.MedicalInfo.Doctor.ID,
 					"fullName": p.MedicalInfo.Doctor.FullName,
@@ -263,17 +483,255 @@ func listPatients(c *gin.Context) {
 
 		results = append(results, patientData)
 	}
+	return results
+}
 
-	c.JSON(http.StatusOK, results)
+// visiblePatientIDs lists the patients the doctor may see. Doctors only ever
+// see patients who granted them consent; filter=all lists every consenting
+// patient and filter=my (the default) narrows that to the doctor's assignments.
+func visiblePatientIDs(c *gin.Context, doctor *models.User) ([]uint, bool) {
+	var patientIDs []uint
+	if doctor.Role == models.RoleAdmin {
+		if err := db.DB.Model(&models.User{}).Where("role = ?", models.RolePatient).Pluck("id", &patientIDs).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load patients"})
+			return nil, false
+		}
+		return patientIDs, true
+	}
+
+	consented, err := access.ConsentedPatientIDs(doctor.ID, models.ScopeDemographics)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load consents"})
+		return nil, false
+	}
+	if c.Query("filter") == "all" || len(consented) == 0 {
+		return consented, true
+	}
+	if err := db.DB.Model(&models.DoctorPatient{}).
+		Where("doctor_id = ? AND patient_id IN ?", doctor.ID, consented).
+		Pluck("patient_id", &patientIDs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load assignments"})
+		return nil, false
+	}
+	return patientIDs, true
+}
+
+// Get a single patient's record, limited to the scopes the doctor holds
+func getPatient(c *gin.Context) {
+	doctor := middleware.CurrentUser(c)
+	if doctor == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid patient id"})
+		return
+	}
+	patientID := uint(id)
+
+	// A record merged into another points doctors who may see the survivor to it
+	if survivorID, merged, err := duplicates.Resolve(db.DB, patientID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load patient"})
+		return
+	} else if merged {
+		if _, err := access.Authorize(doctor, survivorID, models.ScopeDemographics); err == nil {
+			c.Header("Location", fmt.Sprintf("%s/%d", strings.TrimSuffix(c.Request.URL.Path, "/"+c.Param("id")), survivorID))
+			c.JSON(http.StatusMovedPermanently, gin.H{"error": "patient record was merged", "mergedInto": survivorID})
+			return
+		}
+	}
+
+	grant, ok := checkAccess(c, doctor, patientID, models.ScopeDemographics, audit.ActionRead, "patient_record", access.Authorize)
+	if !ok {
+		return
+	}
+
+	var patient models.User
+	if err := db.DB.
+		Preload("PatientProfile").
+		Preload("MedicalInfo").
+		Preload("MedicalInfo.Diseases").
+		Preload("MedicalInfo.Doctor").
+		Where("id = ? AND role = ?", patientID, models.RolePatient).
+		First(&patient).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "patient not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load patient"})
+		return
+	}
+
+	scopes, err := patientScopes(doctor, []uint{patientID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load consents"})
+		return
+	}
+	if grant.Emergency != nil {
+		scopes[patientID] = allScopes()
+	}
+
+	response := gin.H{
+		"id":             patient.ID,
+		"fullName":       patient.FullName,
+		"email":          patient.Email,
+		"phone":          patient.Phone,
+		"patientProfile": toProfileResponse(patient.PatientProfile, scopes[patientID][models.ScopeConditions]),
+		"consentScopes":  scopeList(scopes[patientID]),
+	}
+	if grant.Emergency != nil {
+		response["emergencyAccess"] = gin.H{
+			"id":        grant.Emergency.ID,
+			"expiresAt": grant.Emergency.ExpiresAt,
+		}
+	}
+	if patient.MedicalInfo != nil && scopes[patientID][models.ScopeConditions] {
+		localizeDiseases(c, patient.MedicalInfo.Diseases)
+		response["medicalInfo"] = toMedicalInfoResponse(patient.MedicalInfo, patient.PatientProfile)
+	}
+
+	audit.Log(c, doctor, audit.Entry{
+		Action:     audit.ActionRead,
+		Resource:   "patient_record",
+		ResourceID: audit.ID(patientID),
+		PatientID:  audit.PatientRef(patientID),
+	})
+
+	c.JSON(http.StatusOK, response)
+}
+
+// toProfileResponse renders a patient profile. Allergies and chronic
+// conditions are condition data, left out unless conditions is set.
+func toProfileResponse(profile *models.PatientProfile, conditions bool) interface{} {
+	if profile == nil || conditions {
+		return profile
+	}
+	return gin.H{
+		"id":               profile.ID,
+		"createdAt":        profile.CreatedAt,
+		"updatedAt":        profile.UpdatedAt,
+		"userId":           profile.UserID,
+		"dateOfBirth":      profile.DateOfBirth,
+		"gender":           profile.Gender,
+		"bloodType":        profile.BloodType,
+		"emergencyContact": profile.EmergencyContact,
+	}
+}
+
+type authorizer func(user *models.User, patientID uint, scope string) (*access.Grant, error)
+
+func consentOnly(user *models.User, patientID uint, scope string) (*access.Grant, error) {
+	if err := access.Check(user, patientID, scope); err != nil {
+		return nil, err
+	}
+	return &access.Grant{}, nil
+}
+
+// checkAccess runs authorize and writes the denial response and audit entry.
+// Access granted through an emergency override flags the request's audit entries.
+func checkAccess(c *gin.Context, user *models.User, patientID uint, scope, action, resource string, authorize authorizer) (*access.Grant, bool) {
+	grant, err := authorize(user, patientID, scope)
+	if err == nil {
+		if grant.Emergency != nil {
+			audit.MarkEmergency(c, grant.Emergency.ID)
+		}
+		return grant, true
+	}
+	if errors.Is(err, access.ErrNoConsent) || errors.Is(err, access.ErrNotAssigned) || errors.Is(err, access.ErrForbidden) {
+		audit.Log(c, user, audit.Entry{
+			Action:    action,
+			Resource:  resource,
+			PatientID: audit.PatientRef(patientID),
+			Outcome:   audit.OutcomeDenied,
+			Detail:    err.Error() + " (" + scope + ")",
+		})
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check access"})
+	return nil, false
 }
 
-// List all diseases
+// patientScopes returns the consent scopes held per patient; admins hold all of them.
+func patientScopes(user *models.User, patientIDs []uint) (map[uint]map[string]bool, error) {
+	if user.Role != models.RoleAdmin {
+		return access.ConsentScopes(user.ID, patientIDs)
+	}
+	scopes := make(map[uint]map[string]bool, len(patientIDs))
+	for _, id := range patientIDs {
+		scopes[id] = allScopes()
+	}
+	return scopes, nil
+}
+
+func allScopes() map[string]bool {
+	scopes := make(map[string]bool, len(models.ConsentScopes))
+	for _, scope := range models.ConsentScopes {
+		scopes[scope] = true
+	}
+	return scopes
+}
+
+func scopeList(scopes map[string]bool) []string {
+	result := make([]string, 0, len(scopes))
+	for _, scope := range models.ConsentScopes {
+		if scopes[scope] {
+			result = append(result, scope)
+		}
+	}
+	return result
+}
+
+// List diseases in the request language. With q, codes and names (in any
+// language) starting with q are returned best match first (exact code, code
+// prefix, name prefix, word prefix in name).
 func listDiseases(c *gin.Context) {
+	query := db.DB.Model(&models.Disease{})
+	if c.Query("includeRetired") != "true" {
+		query = query.Where("retired = ?", false)
+	}
+	if parent := c.Query("parentId"); parent != "" {
+		query = query.Where("parent_id = ?", parent)
+	}
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category = ?", category)
+	}
+
+	limit := 0
+	if q := strings.ToLower(strings.TrimSpace(c.Query("q"))); q != "" {
+		code := strings.ToLower(icd10.NormalizeCode(q))
+		translated := db.DB.Model(&models.DiseaseTranslation{}).Select("disease_id").
+			Where("LOWER(name) LIKE ? OR LOWER(name) LIKE ?", q+"%", "% "+q+"%")
+		query = query.Where("LOWER(code) LIKE ? OR LOWER(name) LIKE ? OR LOWER(name) LIKE ? OR id IN (?)", code+"%", q+"%", "% "+q+"%", translated).
+			Order(clause.OrderBy{Expression: clause.Expr{
+				SQL:  "CASE WHEN LOWER(code) = ? THEN 0 WHEN LOWER(code) LIKE ? THEN 1 WHEN LOWER(name) LIKE ? THEN 2 ELSE 3 END",
+				Vars: []interface{}{code, code + "%", q + "%"},
+			}}).
+			Order("code ASC, name ASC")
+		limit = defaultTypeaheadLimit
+	} else {
+		query = query.Order("category ASC, name ASC")
+	}
+	if value := c.Query("limit"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			limit = n
+		}
+	}
+	if limit > maxTypeaheadLimit {
+		limit = maxTypeaheadLimit
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
 	var diseases []models.Disease
-	if err := db.DB.Order("category ASC, name ASC").Find(&diseases).Error; err != nil {
+	if err := query.Find(&diseases).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load diseases"})
 		return
 	}
+	localizeDiseases(c, diseases)
 
 	results := make([]gin.H, 0, len(diseases))
 	for _, d := range diseases {
@@ -282,19 +740,65 @@ func listDiseases(c *gin.Context) {
 			"name":        d.Name,
 			"category":    d.Category,
 			"description": d.Description,
+			"code":        d.Code,
+			"codeSystem":  d.CodeSystem,
+			"snomedCode":  d.SnomedCode,
+			"parentId":    d.ParentID,
+			"retired":     d.Retired,
 		})
 	}
 
 	c.JSON(http.StatusOK, results)
 }
 
-func toMedicalInfoResponse(info *models.PatientMedicalInfo) gin.H {
+// localizeDiseases translates disease names into the request language. The
+// English catalog text is kept if translations cannot be loaded.
+func localizeDiseases(c *gin.Context, diseases []models.Disease) {
+	ptrs := make([]*models.Disease, 0, len(diseases))
+	for i := range diseases {
+		ptrs = append(ptrs, &diseases[i])
+	}
+	if err := i18n.LocalizeDiseases(i18n.Language(c), ptrs...); err != nil {
+		log.Printf("patient: failed to localize diseases: %v", err)
+	}
+}
+
+// loadProfile returns the patient's profile, or nil if they have none.
+func loadProfile(patientID uint) (*models.PatientProfile, error) {
+	var profile models.PatientProfile
+	err := db.DB.Where("user_id = ?", patientID).First(&profile).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// resolveAge derives the patient's age and age group from their date of
+// birth, falling back to the age group recorded on their medical info.
+func resolveAge(profile *models.PatientProfile, info *models.PatientMedicalInfo) ageband.Result {
+	var dob *time.Time
+	if profile != nil {
+		dob = profile.DateOfBirth
+	}
+	manual := ""
+	if info != nil {
+		manual = info.AgeGroup
+	}
+	return ageband.Resolve(dob, manual, time.Now())
+}
+
+func toMedicalInfoResponse(info *models.PatientMedicalInfo, profile *models.PatientProfile) gin.H {
 	diseases := make([]gin.H, 0, len(info.Diseases))
 	for _, d := range info.Diseases {
 		diseases = append(diseases, gin.H{
 			"id":       d.ID,
 			"name":     d.Name,
 			"category": d.Category,
+			"code":     d.Code,
+			"retired":  d.Retired,
 		})
 	}
 
@@ -303,11 +807,15 @@ func toMedicalInfoResponse(info *models.PatientMedicalInfo) gin.H {
 		"patientId": info.PatientID,
 		"doctorId":  info.DoctorID,
 		"gender":    info.Gender,
-		"ageGroup":  info.AgeGroup,
 		"diseases":  diseases,
+		"version":   info.Version,
 		"createdAt": info.CreatedAt,
 		"updatedAt": info.UpdatedAt,
 	}
+	age := resolveAge(profile, info)
+	response["age"] = age.Age
+	response["ageGroup"] = age.Band
+	response["ageGroupSource"] = age.Source
 
 	if info.Patient != nil {
 		response["patient"] = gin.H{
@@ -325,4 +833,3 @@ func toMedicalInfoResponse(info *models.PatientMedicalInfo) gin.H {
 
 	return response
 }
-
@@ -0,0 +1,202 @@
+package patient
+
+import (
+	"net/http"
+	"strconv"
+
+	"medapp/internal/access"
+	"medapp/internal/api/middleware"
+	"medapp/internal/audit"
+	"medapp/internal/db"
+	"medapp/internal/medicalhistory"
+	"medapp/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loadHistory checks access and loads the patient's medical info revisions,
+// oldest first. It writes the error response and returns false on failure.
+func loadHistory(c *gin.Context) (uint, []models.PatientMedicalInfoRevision, bool) {
+	doctor := middleware.CurrentUser(c)
+	if doctor == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return 0, nil, false
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid patient id"})
+		return 0, nil, false
+	}
+	patientID := uint(id)
+
+	if _, ok := checkAccess(c, doctor, patientID, models.ScopeConditions, audit.ActionRead, "medical_info", access.Authorize); !ok {
+		return 0, nil, false
+	}
+
+	var revisions []models.PatientMedicalInfoRevision
+	if err := db.DB.Preload("Author").Where("patient_id = ?", patientID).Order("version ASC").Find(&revisions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load medical info history"})
+		return 0, nil, false
+	}
+	if len(revisions) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "medical info not found"})
+		return 0, nil, false
+	}
+
+	audit.Log(c, doctor, audit.Entry{
+		Action:     audit.ActionRead,
+		Resource:   "medical_info",
+		ResourceID: audit.ID(revisions[0].MedicalInfoID),
+		PatientID:  audit.PatientRef(patientID),
+		Detail:     "history",
+	})
+	return patientID, revisions, true
+}
+
+func findRevision(revisions []models.PatientMedicalInfoRevision, version int) *models.PatientMedicalInfoRevision {
+	for i := range revisions {
+		if revisions[i].Version == version {
+			return &revisions[i]
+		}
+	}
+	return nil
+}
+
+func toRevisionResponse(revision *models.PatientMedicalInfoRevision) gin.H {
+	response := gin.H{
+		"version":   revision.Version,
+		"gender":    revision.Gender,
+		"ageGroup":  revision.AgeGroup,
+		"diseases":  revision.Diseases,
+		"createdAt": revision.CreatedAt,
+		"author":    nil,
+	}
+	if revision.Author != nil {
+		response["author"] = gin.H{
+			"id":       revision.Author.ID,
+			"fullName": revision.Author.FullName,
+		}
+	}
+	return response
+}
+
+// getMedicalInfoHistory lists every revision, newest first, each with what it
+// changed, and attributes each current diagnosis to the doctor who added it.
+func getMedicalInfoHistory(c *gin.Context) {
+	patientID, revisions, ok := loadHistory(c)
+	if !ok {
+		return
+	}
+
+	items := make([]gin.H, 0, len(revisions))
+	for i := len(revisions) - 1; i >= 0; i-- {
+		var previous *models.PatientMedicalInfoRevision
+		if i > 0 {
+			previous = &revisions[i-1]
+		}
+		item := toRevisionResponse(&revisions[i])
+		item["changes"] = medicalhistory.Diff(previous, &revisions[i])
+		items = append(items, item)
+	}
+
+	authors := make(map[uint]*models.User, len(revisions))
+	for _, revision := range revisions {
+		if revision.Author != nil {
+			authors[revision.Author.ID] = revision.Author
+		}
+	}
+	diagnoses := make([]gin.H, 0)
+	for _, d := range medicalhistory.Diagnoses(revisions) {
+		item := gin.H{
+			"disease": d.Disease,
+			"version": d.Version,
+			"addedAt": d.AddedAt,
+			"addedBy": nil,
+		}
+		if d.AuthorID != nil && authors[*d.AuthorID] != nil {
+			item["addedBy"] = gin.H{"id": *d.AuthorID, "fullName": authors[*d.AuthorID].FullName}
+		}
+		diagnoses = append(diagnoses, item)
+	}
+
+	current := revisions[len(revisions)-1].Version
+	c.Header("ETag", versionTag(current))
+	c.JSON(http.StatusOK, gin.H{
+		"patientId":      patientID,
+		"currentVersion": current,
+		"revisions":      items,
+		"diagnoses":      diagnoses,
+	})
+}
+
+func getMedicalInfoRevision(c *gin.Context) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid version"})
+		return
+	}
+	_, revisions, ok := loadHistory(c)
+	if !ok {
+		return
+	}
+	revision := findRevision(revisions, version)
+	if revision == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "revision not found"})
+		return
+	}
+	c.JSON(http.StatusOK, toRevisionResponse(revision))
+}
+
+// diffMedicalInfo compares two revisions. to defaults to the current version
+// and from to the one before it.
+func diffMedicalInfo(c *gin.Context) {
+	parseVersion := func(name string) (int, bool) {
+		value := c.Query(name)
+		if value == "" {
+			return 0, true
+		}
+		v, err := strconv.Atoi(value)
+		if err != nil || v < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name + " version"})
+			return 0, false
+		}
+		return v, true
+	}
+	from, ok := parseVersion("from")
+	if !ok {
+		return
+	}
+	to, ok := parseVersion("to")
+	if !ok {
+		return
+	}
+
+	_, revisions, ok := loadHistory(c)
+	if !ok {
+		return
+	}
+	if to == 0 {
+		to = revisions[len(revisions)-1].Version
+	}
+	if from == 0 {
+		from = to - 1
+	}
+	if from >= to {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+
+	toRevision := findRevision(revisions, to)
+	if toRevision == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "revision not found"})
+		return
+	}
+	var fromRevision *models.PatientMedicalInfoRevision
+	if from > 0 {
+		if fromRevision = findRevision(revisions, from); fromRevision == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "revision not found"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, medicalhistory.Diff(fromRevision, toRevision))
+}
@@ -0,0 +1,530 @@
+package patient
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"medapp/internal/access"
+	"medapp/internal/api/middleware"
+	"medapp/internal/audit"
+	"medapp/internal/db"
+	"medapp/internal/medicalhistory"
+	"medapp/internal/models"
+	"medapp/internal/problemlist"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type createProblemRequest struct {
+	DiseaseID     uint                   `json:"diseaseId" binding:"required"`
+	Status        models.ProblemStatus   `json:"status"`
+	Severity      models.ProblemSeverity `json:"severity"`
+	OnsetDate     string                 `json:"onsetDate"`
+	ResolvedDate  string                 `json:"resolvedDate"`
+	DiagnosedByID *uint                  `json:"diagnosedById"`
+	Notes         string                 `json:"notes"`
+}
+
+// updateProblemRequest changes only the fields present; an empty date clears it.
+type updateProblemRequest struct {
+	Status       *models.ProblemStatus   `json:"status"`
+	Severity     *models.ProblemSeverity `json:"severity"`
+	OnsetDate    *string                 `json:"onsetDate"`
+	ResolvedDate *string                 `json:"resolvedDate"`
+	Notes        *string                 `json:"notes"`
+}
+
+// parseDate reads a YYYY-MM-DD date; an empty string is no date.
+func parseDate(value string) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	d, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, errors.New("dates must be formatted as YYYY-MM-DD")
+	}
+	return &d, nil
+}
+
+// problemAccess checks the patient id parameter and the doctor's access to the
+// patient's conditions. It writes the error response and returns false on failure.
+func problemAccess(c *gin.Context, action string, authorize authorizer) (*models.User, uint, bool) {
+	doctor := middleware.CurrentUser(c)
+	if doctor == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return nil, 0, false
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid patient id"})
+		return nil, 0, false
+	}
+	patientID := uint(id)
+
+	var patient models.User
+	if err := db.DB.Select("id").Where("id = ? AND role = ?", patientID, models.RolePatient).First(&patient).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "patient not found"})
+			return nil, 0, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check patient"})
+		return nil, 0, false
+	}
+	if _, ok := checkAccess(c, doctor, patientID, models.ScopeConditions, action, "problem", authorize); !ok {
+		return nil, 0, false
+	}
+	return doctor, patientID, true
+}
+
+func loadProblem(c *gin.Context, patientID uint) (*models.PatientProblem, bool) {
+	problemID, err := strconv.Atoi(c.Param("problemId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid problem id"})
+		return nil, false
+	}
+	var problem models.PatientProblem
+	if err := db.DB.Preload("Disease").Preload("DiagnosedBy").
+		Where("id = ? AND patient_id = ?", problemID, patientID).First(&problem).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "problem not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load problem"})
+		return nil, false
+	}
+	return &problem, true
+}
+
+// problemError writes the response for an error from saving a problem.
+func problemError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, problemlist.ErrAlreadyOpen):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, problemlist.ErrInvalidStatus), errors.Is(err, problemlist.ErrInvalidSeverity), errors.Is(err, problemlist.ErrInvalidDates):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, medicalhistory.ErrVersionConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "medical info was changed by another user"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save problem"})
+	}
+}
+
+// syncMedicalInfo lists the diseases of the patient's unresolved problems on
+// their medical info, so both views agree after a problem list change.
+func syncMedicalInfo(tx *gorm.DB, patientID, authorID uint) error {
+	diseases, err := problemlist.OpenDiseases(tx, patientID)
+	if err != nil {
+		return err
+	}
+	return medicalhistory.SetDiseases(tx, patientID, diseases, authorID)
+}
+
+func toProblemResponse(p *models.PatientProblem) gin.H {
+	response := gin.H{
+		"id":           p.ID,
+		"patientId":    p.PatientID,
+		"diseaseId":    p.DiseaseID,
+		"status":       p.Status,
+		"severity":     p.Severity,
+		"onsetDate":    formatDate(p.OnsetDate),
+		"resolvedDate": formatDate(p.ResolvedDate),
+		"notes":        p.Notes,
+		"diagnosedBy":  nil,
+		"createdAt":    p.CreatedAt,
+		"updatedAt":    p.UpdatedAt,
+	}
+	if p.Disease != nil {
+		response["disease"] = gin.H{
+			"id":       p.Disease.ID,
+			"name":     p.Disease.Name,
+			"category": p.Disease.Category,
+			"code":     p.Disease.Code,
+			"retired":  p.Disease.Retired,
+		}
+	}
+	if p.DiagnosedBy != nil {
+		response["diagnosedBy"] = gin.H{
+			"id":       p.DiagnosedBy.ID,
+			"fullName": p.DiagnosedBy.FullName,
+		}
+	}
+	return response
+}
+
+func formatDate(d *time.Time) interface{} {
+	if d == nil {
+		return nil
+	}
+	return d.Format("2006-01-02")
+}
+
+// localizeProblems translates the preloaded diseases of problems.
+func localizeProblems(c *gin.Context, problems []models.PatientProblem) {
+	diseases := make([]models.Disease, 0, len(problems))
+	for _, p := range problems {
+		if p.Disease != nil {
+			diseases = append(diseases, *p.Disease)
+		}
+	}
+	localizeDiseases(c, diseases)
+	translated := make(map[uint]models.Disease, len(diseases))
+	for _, d := range diseases {
+		translated[d.ID] = d
+	}
+	for i := range problems {
+		if problems[i].Disease != nil {
+			d := translated[problems[i].Disease.ID]
+			problems[i].Disease = &d
+		}
+	}
+}
+
+// listProblems returns the patient's problem list, unresolved problems first.
+// Filters: status and severity (comma-separated), diseaseId, category, and an
+// onset date range with onsetFrom and onsetTo.
+func listProblems(c *gin.Context) {
+	doctor, patientID, ok := problemAccess(c, audit.ActionRead, access.Authorize)
+	if !ok {
+		return
+	}
+
+	query := db.DB.Model(&models.PatientProblem{}).Where("patient_problems.patient_id = ?", patientID)
+	if value := c.Query("status"); value != "" {
+		statuses := strings.Split(value, ",")
+		for _, s := range statuses {
+			if !problemlist.ValidStatus(models.ProblemStatus(s)) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": problemlist.ErrInvalidStatus.Error()})
+				return
+			}
+		}
+		query = query.Where("patient_problems.status IN ?", statuses)
+	}
+	if value := c.Query("severity"); value != "" {
+		severities := strings.Split(value, ",")
+		for _, s := range severities {
+			if s == "" || !problemlist.ValidSeverity(models.ProblemSeverity(s)) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": problemlist.ErrInvalidSeverity.Error()})
+				return
+			}
+		}
+		query = query.Where("patient_problems.severity IN ?", severities)
+	}
+	if value := c.Query("diseaseId"); value != "" {
+		diseaseID, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid disease id"})
+			return
+		}
+		query = query.Where("patient_problems.disease_id = ?", diseaseID)
+	}
+	if category := c.Query("category"); category != "" {
+		query = query.Joins("JOIN diseases ON diseases.id = patient_problems.disease_id").Where("diseases.category = ?", category)
+	}
+	for param, clause := range map[string]string{
+		"onsetFrom": "patient_problems.onset_date >= ?",
+		"onsetTo":   "patient_problems.onset_date <= ?",
+	} {
+		d, err := parseDate(c.Query(param))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if d != nil {
+			query = query.Where(clause, *d)
+		}
+	}
+
+	var problems []models.PatientProblem
+	if err := query.Preload("Disease").Preload("DiagnosedBy").
+		Order("CASE patient_problems.status WHEN 'active' THEN 0 WHEN 'remission' THEN 1 ELSE 2 END").
+		Order("patient_problems.onset_date DESC NULLS LAST, patient_problems.id DESC").
+		Find(&problems).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load problems"})
+		return
+	}
+	localizeProblems(c, problems)
+
+	audit.Log(c, doctor, audit.Entry{
+		Action:    audit.ActionRead,
+		Resource:  "problem",
+		PatientID: audit.PatientRef(patientID),
+	})
+
+	results := make([]gin.H, 0, len(problems))
+	for i := range problems {
+		results = append(results, toProblemResponse(&problems[i]))
+	}
+	c.JSON(http.StatusOK, results)
+}
+
+func createProblem(c *gin.Context) {
+	doctor, patientID, ok := problemAccess(c, audit.ActionCreate, access.AuthorizeWrite)
+	if !ok {
+		return
+	}
+	var req createProblemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	onset, err := parseDate(req.OnsetDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resolved, err := parseDate(req.ResolvedDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var disease models.Disease
+	if err := db.DB.First(&disease, req.DiseaseID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid disease id"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check disease"})
+		return
+	}
+	// Resolved historical diagnoses may use retired codes; current ones may not
+	if disease.Retired && req.Status != models.ProblemResolved {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "retired diseases cannot be added"})
+		return
+	}
+	if req.DiagnosedByID != nil {
+		var count int64
+		if err := db.DB.Model(&models.User{}).Where("id = ? AND role = ?", *req.DiagnosedByID, models.RoleDoctor).Count(&count).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check doctor"})
+			return
+		}
+		if count == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "diagnosing doctor not found"})
+			return
+		}
+	}
+
+	problem := models.PatientProblem{
+		PatientID:     patientID,
+		DiseaseID:     disease.ID,
+		Status:        req.Status,
+		Severity:      req.Severity,
+		OnsetDate:     onset,
+		ResolvedDate:  resolved,
+		DiagnosedByID: req.DiagnosedByID,
+		Notes:         strings.TrimSpace(req.Notes),
+	}
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := problemlist.Record(tx, &problem, doctor.ID, ""); err != nil {
+			return err
+		}
+		return syncMedicalInfo(tx, patientID, doctor.ID)
+	})
+	if err != nil {
+		problemError(c, err)
+		return
+	}
+	audit.Log(c, doctor, audit.Entry{
+		Action:     audit.ActionCreate,
+		Resource:   "problem",
+		ResourceID: audit.ID(problem.ID),
+		PatientID:  audit.PatientRef(patientID),
+	})
+
+	if err := db.DB.Preload("Disease").Preload("DiagnosedBy").First(&problem, problem.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load problem"})
+		return
+	}
+	problems := []models.PatientProblem{problem}
+	localizeProblems(c, problems)
+	c.JSON(http.StatusCreated, toProblemResponse(&problems[0]))
+}
+
+// getProblem returns a problem with every change made to it.
+func getProblem(c *gin.Context) {
+	doctor, patientID, ok := problemAccess(c, audit.ActionRead, access.Authorize)
+	if !ok {
+		return
+	}
+	problem, ok := loadProblem(c, patientID)
+	if !ok {
+		return
+	}
+	var events []models.PatientProblemEvent
+	if err := db.DB.Preload("Author").Where("problem_id = ?", problem.ID).Order("created_at ASC, id ASC").Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load problem history"})
+		return
+	}
+	problems := []models.PatientProblem{*problem}
+	localizeProblems(c, problems)
+
+	audit.Log(c, doctor, audit.Entry{
+		Action:     audit.ActionRead,
+		Resource:   "problem",
+		ResourceID: audit.ID(problem.ID),
+		PatientID:  audit.PatientRef(patientID),
+	})
+
+	history := make([]gin.H, 0, len(events))
+	for _, e := range events {
+		history = append(history, eventResponse(e.Type, e.CreatedAt, e.FromStatus, e.ToStatus, e.Detail, e.Author))
+	}
+	response := toProblemResponse(&problems[0])
+	response["history"] = history
+	c.JSON(http.StatusOK, response)
+}
+
+func eventResponse(kind string, date time.Time, from, to models.ProblemStatus, detail string, author *models.User) gin.H {
+	response := gin.H{
+		"type":   kind,
+		"date":   date,
+		"author": nil,
+	}
+	if from != "" {
+		response["fromStatus"] = from
+	}
+	if to != "" {
+		response["toStatus"] = to
+	}
+	if detail != "" {
+		response["detail"] = detail
+	}
+	if author != nil {
+		response["author"] = gin.H{"id": author.ID, "fullName": author.FullName}
+	}
+	return response
+}
+
+func updateProblem(c *gin.Context) {
+	doctor, patientID, ok := problemAccess(c, audit.ActionUpdate, access.AuthorizeWrite)
+	if !ok {
+		return
+	}
+	problem, ok := loadProblem(c, patientID)
+	if !ok {
+		return
+	}
+	var req updateProblemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	after := *problem
+	if req.Status != nil {
+		after.Status = *req.Status
+	}
+	if req.Severity != nil {
+		after.Severity = *req.Severity
+	}
+	if req.Notes != nil {
+		after.Notes = strings.TrimSpace(*req.Notes)
+	}
+	for _, field := range []struct {
+		value  *string
+		target **time.Time
+	}{{req.OnsetDate, &after.OnsetDate}, {req.ResolvedDate, &after.ResolvedDate}} {
+		if field.value == nil {
+			continue
+		}
+		d, err := parseDate(*field.value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		*field.target = d
+	}
+	if problem.Disease != nil && problem.Disease.Retired && !problemlist.Open(problem.Status) && problemlist.Open(after.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "retired diseases cannot be added"})
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := problemlist.Update(tx, problem, &after, doctor.ID); err != nil {
+			return err
+		}
+		return syncMedicalInfo(tx, patientID, doctor.ID)
+	})
+	if err != nil {
+		audit.Log(c, doctor, audit.Entry{
+			Action:     audit.ActionUpdate,
+			Resource:   "problem",
+			ResourceID: audit.ID(problem.ID),
+			PatientID:  audit.PatientRef(patientID),
+			Outcome:    audit.OutcomeError,
+		})
+		problemError(c, err)
+		return
+	}
+	audit.Log(c, doctor, audit.Entry{
+		Action:     audit.ActionUpdate,
+		Resource:   "problem",
+		ResourceID: audit.ID(problem.ID),
+		PatientID:  audit.PatientRef(patientID),
+	})
+
+	problems := []models.PatientProblem{after}
+	localizeProblems(c, problems)
+	c.JSON(http.StatusOK, toProblemResponse(&problems[0]))
+}
+
+// problemTimeline lists the patient's problems as dated entries, oldest
+// first: onsets and resolutions alongside when each problem was recorded or
+// changed. from and to limit the dates.
+func problemTimeline(c *gin.Context) {
+	doctor, patientID, ok := problemAccess(c, audit.ActionRead, access.Authorize)
+	if !ok {
+		return
+	}
+	from, err := parseDate(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	to, err := parseDate(c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var problems []models.PatientProblem
+	if err := db.DB.Preload("Disease").Where("patient_id = ?", patientID).Find(&problems).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load problems"})
+		return
+	}
+	var events []models.PatientProblemEvent
+	if err := db.DB.Preload("Author").Where("patient_id = ?", patientID).Order("created_at ASC, id ASC").Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load problem history"})
+		return
+	}
+	localizeProblems(c, problems)
+
+	audit.Log(c, doctor, audit.Entry{
+		Action:    audit.ActionRead,
+		Resource:  "problem",
+		PatientID: audit.PatientRef(patientID),
+		Detail:    "timeline",
+	})
+
+	entries := make([]gin.H, 0)
+	for _, e := range problemlist.Timeline(problems, events) {
+		if from != nil && e.Date.Before(*from) {
+			continue
+		}
+		if to != nil && !e.Date.Before(to.AddDate(0, 0, 1)) {
+			continue
+		}
+		entry := eventResponse(e.Kind, e.Date, e.FromStatus, e.ToStatus, e.Detail, e.Author)
+		entry["problemId"] = e.ProblemID
+		if e.Disease != nil {
+			entry["disease"] = gin.H{"id": e.Disease.ID, "name": e.Disease.Name, "code": e.Disease.Code}
+		}
+		entries = append(entries, entry)
+	}
+	c.JSON(http.StatusOK, entries)
+}
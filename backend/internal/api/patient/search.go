@@ -0,0 +1,99 @@
+package patient
+
+import (
+	"errors"
+	"net/http"
+
+	"medapp/internal/ageband"
+	"medapp/internal/api/middleware"
+	"medapp/internal/audit"
+	"medapp/internal/db"
+	"medapp/internal/models"
+	"medapp/internal/patientsearch"
+
+	"github.com/gin-gonic/gin"
+)
+
+// searchPatients finds patients by name, contact details, disease, age,
+// gender and blood type. It takes the same filter as the patient list and
+// returns one page at a time; nextCursor fetches the following page.
+func searchPatients(c *gin.Context) {
+	doctor := middleware.CurrentUser(c)
+	if doctor == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	query, err := patientsearch.FromRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	visibility, err := patientsearch.VisibilityFor(doctor, doctor.Role != models.RoleAdmin && c.Query("filter") != "all")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load consents"})
+		return
+	}
+	page, err := patientsearch.Search(db.DB, query, visibility)
+	if err != nil {
+		if errors.Is(err, patientsearch.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search patients"})
+		return
+	}
+
+	patientIDs := make([]uint, 0, len(page.Patients))
+	for _, p := range page.Patients {
+		patientIDs = append(patientIDs, p.ID)
+	}
+	scopes, err := patientScopes(doctor, patientIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load consents"})
+		return
+	}
+	var infos []models.PatientMedicalInfo
+	if len(patientIDs) > 0 {
+		if err := db.DB.Preload("Diseases").Preload("Doctor").Where("patient_id IN ?", patientIDs).Find(&infos).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load medical info"})
+			return
+		}
+	}
+	infoOf := make(map[uint]*models.PatientMedicalInfo, len(infos))
+	for i := range infos {
+		infoOf[infos[i].PatientID] = &infos[i]
+	}
+	ages := make(map[uint]ageband.Result, len(page.Patients))
+	for i := range page.Patients {
+		p := &page.Patients[i]
+		p.MedicalInfo = infoOf[p.ID]
+		info := p.MedicalInfo
+		if !scopes[p.ID][models.ScopeConditions] {
+			info = nil
+		}
+		ages[p.ID] = resolveAge(p.PatientProfile, info)
+	}
+
+	entries := make([]audit.Entry, 0, len(page.Patients))
+	for _, p := range page.Patients {
+		entries = append(entries, audit.Entry{
+			Action:     audit.ActionRead,
+			Resource:   "patient_record",
+			ResourceID: audit.ID(p.ID),
+			PatientID:  audit.PatientRef(p.ID),
+			Detail:     "patient search",
+		})
+	}
+	audit.LogMany(c, doctor, entries)
+
+	items := patientListItems(c, page.Patients, scopes, ages)
+	for i, p := range page.Patients {
+		if score, ok := page.Scores[p.ID]; ok {
+			items[i]["score"] = score
+		}
+	}
+	if page.NextCursor != "" {
+		c.Header("X-Next-Cursor", page.NextCursor)
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "nextCursor": page.NextCursor})
+}
@@ -0,0 +1,250 @@
+package privacy
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"medapp/internal/api/middleware"
+	"medapp/internal/audit"
+	"medapp/internal/db"
+	"medapp/internal/fhir"
+	"medapp/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// patientExport is everything held about a patient, loaded before the archive is written.
+type patientExport struct {
+	user         *models.User
+	medicalInfo  *models.PatientMedicalInfo
+	history      []models.PatientMedicalInfoRevision
+	problems     []models.PatientProblem
+	appointments []models.Appointment
+	documents    []models.PatientDocument
+	videoViews   []models.VideoView
+	consents     []models.PatientConsent
+}
+
+func exportData(c *gin.Context) {
+	patient := middleware.CurrentUser(c)
+	if patient == nil || patient.Role != models.RolePatient {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only patients can export their data"})
+		return
+	}
+
+	data, err := loadExport(patient)
+	if err != nil {
+		audit.Log(c, patient, exportEntry(patient, audit.OutcomeError))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to collect patient data"})
+		return
+	}
+
+	filename := fmt.Sprintf("medapp-export-%d-%s.zip", patient.ID, time.Now().Format("20060102"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	if err := writeArchive(c.Writer, data); err != nil {
+		// Headers are already sent, so the truncated archive is the only signal the client gets.
+		log.Printf("privacy: export for user %d failed: %v", patient.ID, err)
+		audit.Log(c, patient, exportEntry(patient, audit.OutcomeError))
+		return
+	}
+	audit.Log(c, patient, exportEntry(patient, audit.OutcomeSuccess))
+}
+
+func loadExport(patient *models.User) (*patientExport, error) {
+	data := &patientExport{user: patient}
+
+	var info models.PatientMedicalInfo
+	err := db.DB.Preload("Diseases").Preload("Doctor").Where("patient_id = ?", patient.ID).First(&info).Error
+	if err == nil {
+		data.medicalInfo = &info
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err := db.DB.Where("patient_id = ?", patient.ID).Order("version ASC").Find(&data.history).Error; err != nil {
+		return nil, err
+	}
+	if err := db.DB.Preload("Disease").Where("patient_id = ?", patient.ID).Order("created_at ASC").Find(&data.problems).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.DB.Preload("Doctor.DoctorProfile").Where("patient_id = ?", patient.ID).
+		Order("scheduled_at ASC").Find(&data.appointments).Error; err != nil {
+		return nil, err
+	}
+	if err := db.DB.Where("patient_id = ?", patient.ID).Order("created_at ASC").Find(&data.documents).Error; err != nil {
+		return nil, err
+	}
+	if err := db.DB.Preload("Video").Where("user_id = ?", patient.ID).Order("created_at ASC").Find(&data.videoViews).Error; err != nil {
+		return nil, err
+	}
+	if err := db.DB.Preload("Doctor").Where("patient_id = ?", patient.ID).Order("created_at ASC").Find(&data.consents).Error; err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func writeArchive(w io.Writer, data *patientExport) error {
+	archive := zip.NewWriter(w)
+
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"profile.json", profileJSON(data.user)},
+		{"medical_info.json", data.medicalInfo},
+		{"medical_info_history.json", data.history},
+		{"problems.json", data.problems},
+		{"appointments.json", appointmentsJSON(data.appointments)},
+		{"documents.json", data.documents},
+		{"videos_watched.json", videoViewsJSON(data.videoViews)},
+		{"consents.json", consentsJSON(data.consents)},
+		{"fhir_bundle.json", fhirBundle(data)},
+	}
+	for _, file := range files {
+		if err := writeJSON(archive, file.name, file.content); err != nil {
+			return err
+		}
+	}
+
+	for _, document := range data.documents {
+		if err := copyFile(archive, fmt.Sprintf("documents/%d-%s", document.ID, document.FileName), document.StoragePath); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+func writeJSON(archive *zip.Writer, name string, content interface{}) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(content)
+}
+
+func copyFile(archive *zip.Writer, name, path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		// A missing file should not make the rest of the export unavailable.
+		log.Printf("privacy: skipping document %s: %v", path, err)
+		return nil
+	}
+	defer src.Close()
+
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, src)
+	return err
+}
+
+func profileJSON(user *models.User) gin.H {
+	return gin.H{
+		"id":             user.ID,
+		"createdAt":      user.CreatedAt,
+		"email":          user.Email,
+		"fullName":       user.FullName,
+		"phone":          user.Phone,
+		"role":           user.Role,
+		"status":         user.Status,
+		"patientProfile": user.PatientProfile,
+	}
+}
+
+func appointmentsJSON(appointments []models.Appointment) []gin.H {
+	items := make([]gin.H, 0, len(appointments))
+	for _, appt := range appointments {
+		item := gin.H{
+			"id":          appt.ID,
+			"createdAt":   appt.CreatedAt,
+			"scheduledAt": appt.ScheduledAt,
+			"durationMin": appt.DurationMin,
+			"status":      appt.Status,
+			"reason":      appt.Reason,
+			"notes":       appt.Notes,
+		}
+		if appt.Doctor != nil {
+			item["doctor"] = gin.H{"id": appt.Doctor.ID, "fullName": appt.Doctor.FullName}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func videoViewsJSON(views []models.VideoView) []gin.H {
+	items := make([]gin.H, 0, len(views))
+	for _, view := range views {
+		item := gin.H{"videoId": view.VideoID, "viewedAt": view.CreatedAt}
+		if view.Video != nil {
+			item["title"] = view.Video.Title
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func consentsJSON(consents []models.PatientConsent) []gin.H {
+	items := make([]gin.H, 0, len(consents))
+	for _, consent := range consents {
+		item := gin.H{
+			"id":          consent.ID,
+			"createdAt":   consent.CreatedAt,
+			"status":      consent.Status,
+			"scopes":      consent.ScopeList(),
+			"reason":      consent.Reason,
+			"respondedAt": consent.RespondedAt,
+			"expiresAt":   consent.ExpiresAt,
+			"revokedAt":   consent.RevokedAt,
+		}
+		if consent.Doctor != nil {
+			item["doctor"] = gin.H{"id": consent.Doctor.ID, "fullName": consent.Doctor.FullName}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func fhirBundle(data *patientExport) fhir.Bundle {
+	resources := []fhir.Resource{fhir.NewPatient(data.user)}
+
+	seen := map[uint]bool{}
+	for i := range data.appointments {
+		if doctor := data.appointments[i].Doctor; doctor != nil && !seen[doctor.ID] {
+			seen[doctor.ID] = true
+			resources = append(resources, fhir.NewPractitioner(doctor))
+		}
+	}
+	for i := range data.appointments {
+		resources = append(resources, fhir.NewAppointment(&data.appointments[i]))
+	}
+	if data.medicalInfo != nil {
+		for _, condition := range fhir.NewConditions(data.medicalInfo) {
+			resources = append(resources, condition)
+		}
+	}
+	return fhir.NewBundle("collection", "", resources)
+}
+
+func exportEntry(patient *models.User, outcome string) audit.Entry {
+	return audit.Entry{
+		Action:    audit.ActionRead,
+		Resource:  "data_export",
+		PatientID: audit.PatientRef(patient.ID),
+		Outcome:   outcome,
+	}
+}
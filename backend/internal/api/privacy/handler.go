@@ -0,0 +1,240 @@
+package privacy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"medapp/internal/api/middleware"
+	"medapp/internal/audit"
+	"medapp/internal/db"
+	"medapp/internal/erasure"
+	"medapp/internal/models"
+	"medapp/internal/notify"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type erasureRequestBody struct {
+	Reason string `json:"reason"`
+}
+
+type reviewRequest struct {
+	Note string `json:"note"`
+}
+
+// RegisterPatientRoutes gives patients their data export and account erasure.
+func RegisterPatientRoutes(r *gin.RouterGroup) {
+	r.GET("/export", middleware.AuthRequired(), middleware.RequireRole(models.RolePatient), exportData)
+
+	g := r.Group("/erasure")
+	g.Use(middleware.AuthRequired(), middleware.RequireRole(models.RolePatient))
+	g.GET("", listOwnErasureRequests)
+	g.POST("", requestErasure)
+	g.DELETE("", cancelErasure)
+}
+
+// RegisterAdminRoutes lets admins review erasure requests.
+func RegisterAdminRoutes(r *gin.RouterGroup) {
+	r.Use(middleware.AuthRequired(), middleware.RequireRole(models.RoleAdmin))
+	r.GET("", listErasureRequests)
+	r.GET("/", listErasureRequests)
+	r.POST("/:id/approve", approveErasure)
+	r.POST("/:id/reject", rejectErasure)
+}
+
+func requestErasure(c *gin.Context) {
+	patient := middleware.CurrentUser(c)
+	if patient == nil || patient.Role != models.RolePatient {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only patients can request erasure"})
+		return
+	}
+
+	var req erasureRequestBody
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var existing int64
+	if err := db.DB.Model(&models.ErasureRequest{}).
+		Where("user_id = ? AND status = ?", patient.ID, models.ErasurePending).
+		Count(&existing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check erasure requests"})
+		return
+	}
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "an erasure request is already pending"})
+		return
+	}
+
+	request := models.ErasureRequest{
+		UserID: patient.ID,
+		Reason: strings.TrimSpace(req.Reason),
+		Status: models.ErasurePending,
+	}
+	if err := db.DB.Create(&request).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create erasure request"})
+		return
+	}
+	audit.Log(c, patient, audit.Entry{
+		Action:     audit.ActionCreate,
+		Resource:   "erasure_request",
+		ResourceID: audit.ID(request.ID),
+		PatientID:  audit.PatientRef(patient.ID),
+	})
+	notify.Admins("erasure_request", "Account erasure requested",
+		fmt.Sprintf("Patient %d asked for their account to be erased.", patient.ID))
+
+	c.JSON(http.StatusCreated, request)
+}
+
+func listOwnErasureRequests(c *gin.Context) {
+	patient := middleware.CurrentUser(c)
+	var requests []models.ErasureRequest
+	if err := db.DB.Where("user_id = ?", patient.ID).Order("created_at DESC").Find(&requests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load erasure requests"})
+		return
+	}
+	c.JSON(http.StatusOK, requests)
+}
+
+func cancelErasure(c *gin.Context) {
+	patient := middleware.CurrentUser(c)
+	var request models.ErasureRequest
+	if err := db.DB.Where("user_id = ? AND status = ?", patient.ID, models.ErasurePending).First(&request).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no pending erasure request"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load erasure request"})
+		return
+	}
+	if err := db.DB.Model(&request).Update("status", models.ErasureCancelled).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cancel erasure request"})
+		return
+	}
+	request.Status = models.ErasureCancelled
+	c.JSON(http.StatusOK, request)
+}
+
+func listErasureRequests(c *gin.Context) {
+	query := db.DB.Preload("User").Order("created_at DESC")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var requests []models.ErasureRequest
+	if err := query.Find(&requests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load erasure requests"})
+		return
+	}
+	c.JSON(http.StatusOK, requests)
+}
+
+func approveErasure(c *gin.Context) {
+	admin := middleware.CurrentUser(c)
+	request, ok := loadPendingRequest(c)
+	if !ok {
+		return
+	}
+
+	var req reviewRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	now := time.Now()
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := erasure.Anonymize(tx, request.UserID, now); err != nil {
+			return err
+		}
+		return tx.Model(request).Updates(map[string]interface{}{
+			"status":         models.ErasureCompleted,
+			"review_note":    strings.TrimSpace(req.Note),
+			"reviewed_by_id": admin.ID,
+			"reviewed_at":    now,
+			"completed_at":   now,
+			"retain_until":   now.Add(erasure.RetentionPeriod()),
+		}).Error
+	})
+	if err != nil {
+		audit.Log(c, admin, erasureEntry(request, audit.OutcomeError))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to erase account"})
+		return
+	}
+	audit.Log(c, admin, erasureEntry(request, audit.OutcomeSuccess))
+
+	if err := db.DB.Preload("User").First(request, request.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load erasure request"})
+		return
+	}
+	c.JSON(http.StatusOK, request)
+}
+
+func rejectErasure(c *gin.Context) {
+	admin := middleware.CurrentUser(c)
+	request, ok := loadPendingRequest(c)
+	if !ok {
+		return
+	}
+
+	var req reviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Note) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a note explaining the rejection is required"})
+		return
+	}
+
+	note := strings.TrimSpace(req.Note)
+	if err := db.DB.Model(request).Updates(map[string]interface{}{
+		"status":         models.ErasureRejected,
+		"review_note":    note,
+		"reviewed_by_id": admin.ID,
+		"reviewed_at":    time.Now(),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reject erasure request"})
+		return
+	}
+	notify.Send([]uint{request.UserID}, "erasure_request", "Erasure request rejected", note)
+
+	if err := db.DB.Preload("User").First(request, request.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load erasure request"})
+		return
+	}
+	c.JSON(http.StatusOK, request)
+}
+
+func loadPendingRequest(c *gin.Context) (*models.ErasureRequest, bool) {
+	var request models.ErasureRequest
+	if err := db.DB.First(&request, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "erasure request not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load erasure request"})
+		return nil, false
+	}
+	if request.Status != models.ErasurePending {
+		c.JSON(http.StatusConflict, gin.H{"error": "erasure request is not pending"})
+		return nil, false
+	}
+	return &request, true
+}
+
+func erasureEntry(request *models.ErasureRequest, outcome string) audit.Entry {
+	return audit.Entry{
+		Action:     audit.ActionDelete,
+		Resource:   "patient_account",
+		ResourceID: audit.ID(request.UserID),
+		PatientID:  audit.PatientRef(request.UserID),
+		Outcome:    outcome,
+		Detail:     fmt.Sprintf("erasure request %d", request.ID),
+	}
+}
@@ -0,0 +1,607 @@
+package referral
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"medapp/internal/access"
+	"medapp/internal/api/middleware"
+	"medapp/internal/audit"
+	"medapp/internal/db"
+	"medapp/internal/models"
+	"medapp/internal/notify"
+	"medapp/internal/scheduling"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// How far ahead a referral is booked, by urgency.
+var bookingWindow = map[models.ReferralUrgency]time.Duration{
+	models.UrgencyUrgent:  2 * 24 * time.Hour,
+	models.UrgencySoon:    14 * 24 * time.Hour,
+	models.UrgencyRoutine: 60 * 24 * time.Hour,
+}
+
+type createReferralRequest struct {
+	PatientID   uint   `json:"patientId" binding:"required"`
+	ToDoctorID  uint   `json:"toDoctorId"`
+	Speciality  string `json:"speciality"` // instead of toDoctorId, for any doctor of the speciality
+	Reason      string `json:"reason" binding:"required"`
+	Notes       string `json:"notes"`
+	Urgency     string `json:"urgency"` // routine (default), soon or urgent
+	DocumentIDs []uint `json:"documentIds"`
+	AutoBook    bool   `json:"autoBook"`
+}
+
+type respondRequest struct {
+	Note     string `json:"note"`
+	AutoBook *bool  `json:"autoBook"` // overrides the referrer's choice when accepting
+}
+
+// RegisterRoutes lets doctors send and answer referrals.
+func RegisterRoutes(r *gin.RouterGroup) {
+	r.Use(middleware.AuthRequired(), middleware.RequireRole(models.RoleDoctor), middleware.RequireVerifiedDoctor())
+	r.GET("", listReferrals)
+	r.GET("/", listReferrals)
+	r.POST("", createReferral)
+	r.POST("/", createReferral)
+	r.GET("/:id", getReferral)
+	r.POST("/:id/accept", acceptReferral)
+	r.POST("/:id/decline", declineReferral)
+	r.POST("/:id/cancel", cancelReferral)
+	r.POST("/:id/complete", completeReferral)
+}
+
+// RegisterPatientRoutes lets patients follow their referrals.
+func RegisterPatientRoutes(r *gin.RouterGroup) {
+	r.GET("/referrals", middleware.AuthRequired(), middleware.RequireRole(models.RolePatient), listOwnReferrals)
+}
+
+func createReferral(c *gin.Context) {
+	doctor := middleware.CurrentUser(c)
+	var req createReferralRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	speciality := strings.TrimSpace(req.Speciality)
+	if (req.ToDoctorID == 0) == (speciality == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "either toDoctorId or speciality is required"})
+		return
+	}
+	urgency := models.ReferralUrgency(req.Urgency)
+	if urgency == "" {
+		urgency = models.UrgencyRoutine
+	}
+	if _, ok := bookingWindow[urgency]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "urgency must be routine, soon or urgent"})
+		return
+	}
+	if req.ToDoctorID == doctor.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot refer a patient to yourself"})
+		return
+	}
+
+	var patient models.User
+	if err := db.DB.Where("id = ? AND role = ?", req.PatientID, models.RolePatient).First(&patient).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "patient not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check patient"})
+		return
+	}
+
+	// Referring is a lasting hand-over, so it needs real consent rather than an emergency override
+	scopes := []string{models.ScopeDemographics, models.ScopeConditions}
+	if len(req.DocumentIDs) > 0 {
+		scopes = append(scopes, models.ScopeDocuments)
+	}
+	for _, scope := range scopes {
+		if err := access.Check(doctor, patient.ID, scope); err != nil {
+			if errors.Is(err, access.ErrNoConsent) || errors.Is(err, access.ErrForbidden) {
+				audit.Log(c, doctor, audit.Entry{
+					Action:    audit.ActionCreate,
+					Resource:  "referral",
+					PatientID: audit.PatientRef(patient.ID),
+					Outcome:   audit.OutcomeDenied,
+					Detail:    err.Error() + " (" + scope + ")",
+				})
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check access"})
+			return
+		}
+	}
+
+	referral := models.Referral{
+		PatientID:    patient.ID,
+		FromDoctorID: doctor.ID,
+		Speciality:   speciality,
+		Reason:       strings.TrimSpace(req.Reason),
+		Notes:        strings.TrimSpace(req.Notes),
+		Urgency:      urgency,
+		Status:       models.ReferralPending,
+		AutoBook:     req.AutoBook,
+	}
+	var recipients []uint
+	if req.ToDoctorID != 0 {
+		receiver, err := loadVerifiedDoctor(req.ToDoctorID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "receiving doctor not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check receiving doctor"})
+			return
+		}
+		referral.ToDoctorID = &receiver.ID
+		referral.Speciality = receiver.DoctorProfile.Speciality
+		recipients = []uint{receiver.ID}
+	} else {
+		ids, err := specialists(speciality, doctor.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to find doctors"})
+			return
+		}
+		if len(ids) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no verified doctor has this speciality"})
+			return
+		}
+		recipients = ids
+	}
+
+	if len(req.DocumentIDs) > 0 {
+		if err := db.DB.Where("id IN ? AND patient_id = ?", req.DocumentIDs, patient.ID).Find(&referral.Documents).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load documents"})
+			return
+		}
+		if len(referral.Documents) != len(req.DocumentIDs) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "documents must belong to the patient"})
+			return
+		}
+	}
+
+	if err := db.DB.Create(&referral).Error; err != nil {
+		audit.Log(c, doctor, referralEntry(audit.ActionCreate, audit.OutcomeError, &referral))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create referral"})
+		return
+	}
+	audit.Log(c, doctor, referralEntry(audit.ActionCreate, audit.OutcomeSuccess, &referral))
+	notify.Send(recipients, "referral", "New referral",
+		fmt.Sprintf("Dr. %s referred a patient to you (%s).", doctor.FullName, referral.Urgency))
+
+	respondWithReferral(c, http.StatusCreated, referral.ID)
+}
+
+// listReferrals lists referrals the doctor received (box=received, the
+// default, including open ones for their speciality) or sent (box=sent).
+func listReferrals(c *gin.Context) {
+	doctor := middleware.CurrentUser(c)
+	query := db.DB.Preload("Patient").Preload("FromDoctor").Preload("ToDoctor").Preload("Documents").
+		Order("CASE urgency WHEN 'urgent' THEN 0 WHEN 'soon' THEN 1 ELSE 2 END, created_at DESC")
+	switch c.DefaultQuery("box", "received") {
+	case "received":
+		speciality, err := specialityOf(doctor.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load doctor profile"})
+			return
+		}
+		query = query.Where("to_doctor_id = ? OR (to_doctor_id IS NULL AND status = ? AND from_doctor_id <> ? AND LOWER(speciality) = LOWER(?) AND speciality <> '')",
+			doctor.ID, models.ReferralPending, doctor.ID, speciality)
+	case "sent":
+		query = query.Where("from_doctor_id = ?", doctor.ID)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "box must be received or sent"})
+		return
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var referrals []models.Referral
+	if err := query.Find(&referrals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load referrals"})
+		return
+	}
+	entries := make([]audit.Entry, 0, len(referrals))
+	results := make([]gin.H, 0, len(referrals))
+	for i := range referrals {
+		entries = append(entries, referralEntry(audit.ActionRead, audit.OutcomeSuccess, &referrals[i]))
+		results = append(results, toReferralResponse(&referrals[i]))
+	}
+	audit.LogMany(c, doctor, entries)
+	c.JSON(http.StatusOK, results)
+}
+
+func getReferral(c *gin.Context) {
+	doctor := middleware.CurrentUser(c)
+	referral, ok := loadReferral(c, doctor)
+	if !ok {
+		return
+	}
+	audit.Log(c, doctor, referralEntry(audit.ActionRead, audit.OutcomeSuccess, referral))
+	respondWithReferral(c, http.StatusOK, referral.ID)
+}
+
+// acceptReferral takes the patient on: the doctor joins the care team, asks
+// the patient for consent if they hold none, and the first free slot is
+// booked when the referral asks for it.
+func acceptReferral(c *gin.Context) {
+	doctor := middleware.CurrentUser(c)
+	referral, ok := loadPendingReferral(c, doctor, true)
+	if !ok {
+		return
+	}
+	var req respondRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	autoBook := referral.AutoBook
+	if req.AutoBook != nil {
+		autoBook = *req.AutoBook
+	}
+
+	now := time.Now()
+	consentRequested := false
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		// Only one doctor of a speciality can take an open referral
+		result := tx.Model(&models.Referral{}).
+			Where("id = ? AND status = ?", referral.ID, models.ReferralPending).
+			Updates(map[string]interface{}{
+				"status":        models.ReferralAccepted,
+				"to_doctor_id":  doctor.ID,
+				"response_note": strings.TrimSpace(req.Note),
+				"responded_at":  now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errNotPending
+		}
+
+		var assigned int64
+		if err := tx.Model(&models.DoctorPatient{}).Where("doctor_id = ? AND patient_id = ?", doctor.ID, referral.PatientID).Count(&assigned).Error; err != nil {
+			return err
+		}
+		if assigned == 0 {
+			if err := tx.Create(&models.DoctorPatient{DoctorID: doctor.ID, PatientID: referral.PatientID, CareRole: models.CareRoleSpecialist}).Error; err != nil {
+				return err
+			}
+		}
+
+		var open int64
+		if err := tx.Model(&models.PatientConsent{}).
+			Where("doctor_id = ? AND patient_id = ? AND status IN ?", doctor.ID, referral.PatientID, []models.ConsentStatus{models.ConsentPending, models.ConsentActive}).
+			Where("expires_at IS NULL OR expires_at > ?", now).
+			Count(&open).Error; err != nil {
+			return err
+		}
+		if open == 0 {
+			scopes := []string{models.ScopeDemographics, models.ScopeConditions}
+			if len(referral.Documents) > 0 {
+				scopes = append(scopes, models.ScopeDocuments)
+			}
+			consent := models.PatientConsent{
+				PatientID: referral.PatientID,
+				DoctorID:  doctor.ID,
+				Scopes:    strings.Join(scopes, ","),
+				Status:    models.ConsentPending,
+				Reason:    fmt.Sprintf("Referral from Dr. %s", referral.FromDoctor.FullName),
+			}
+			if err := tx.Create(&consent).Error; err != nil {
+				return err
+			}
+			consentRequested = true
+		}
+
+		if !autoBook {
+			return nil
+		}
+		slot, err := scheduling.NextFreeSlot(tx, doctor.ID, now, scheduling.SlotLength(), bookingWindow[referral.Urgency])
+		if err != nil {
+			return err
+		}
+		appointment := models.Appointment{
+			DoctorID:    doctor.ID,
+			PatientID:   referral.PatientID,
+			ScheduledAt: slot,
+			DurationMin: int(scheduling.SlotLength() / time.Minute),
+			Reason:      fmt.Sprintf("Referral from Dr. %s", referral.FromDoctor.FullName),
+			Status:      models.AppointmentPending,
+		}
+		if err := tx.Create(&appointment).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Referral{}).Where("id = ?", referral.ID).Update("appointment_id", appointment.ID).Error
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errNotPending):
+			c.JSON(http.StatusConflict, gin.H{"error": "referral is not pending"})
+		case errors.Is(err, scheduling.ErrNoFreeSlot):
+			c.JSON(http.StatusConflict, gin.H{"error": "no free slot to book; accept without autoBook and schedule manually"})
+		default:
+			audit.Log(c, doctor, referralEntry(audit.ActionUpdate, audit.OutcomeError, referral))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to accept referral"})
+		}
+		return
+	}
+	audit.Log(c, doctor, referralEntry(audit.ActionUpdate, audit.OutcomeSuccess, referral))
+
+	notify.Send([]uint{referral.FromDoctorID}, "referral", "Referral accepted",
+		fmt.Sprintf("Dr. %s accepted your referral.", doctor.FullName))
+	body := fmt.Sprintf("Dr. %s has taken on your referral.", doctor.FullName)
+	if consentRequested {
+		body += " Please review their request to access your record."
+	}
+	notify.Send([]uint{referral.PatientID}, "referral", "Referral accepted", body)
+
+	respondWithReferral(c, http.StatusOK, referral.ID)
+}
+
+func declineReferral(c *gin.Context) {
+	doctor := middleware.CurrentUser(c)
+	referral, ok := loadPendingReferral(c, doctor, true)
+	if !ok {
+		return
+	}
+	var req respondRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Note) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a note explaining the decline is required"})
+		return
+	}
+
+	// Open speciality referrals stay open for the other doctors of the speciality
+	if referral.ToDoctorID == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "open speciality referrals cannot be declined; leave them for another doctor"})
+		return
+	}
+	updates := map[string]interface{}{
+		"status":        models.ReferralDeclined,
+		"response_note": strings.TrimSpace(req.Note),
+		"responded_at":  time.Now(),
+	}
+	if !updateStatus(c, doctor, referral, updates, "failed to decline referral") {
+		return
+	}
+	notify.Send([]uint{referral.FromDoctorID}, "referral", "Referral declined",
+		fmt.Sprintf("Dr. %s declined your referral: %s", doctor.FullName, strings.TrimSpace(req.Note)))
+	respondWithReferral(c, http.StatusOK, referral.ID)
+}
+
+func cancelReferral(c *gin.Context) {
+	doctor := middleware.CurrentUser(c)
+	referral, ok := loadPendingReferral(c, doctor, false)
+	if !ok {
+		return
+	}
+	if !updateStatus(c, doctor, referral, map[string]interface{}{"status": models.ReferralCancelled}, "failed to cancel referral") {
+		return
+	}
+	if referral.ToDoctorID != nil {
+		notify.Send([]uint{*referral.ToDoctorID}, "referral", "Referral cancelled",
+			fmt.Sprintf("Dr. %s cancelled a referral.", doctor.FullName))
+	}
+	respondWithReferral(c, http.StatusOK, referral.ID)
+}
+
+// completeReferral marks an accepted referral as dealt with.
+func completeReferral(c *gin.Context) {
+	doctor := middleware.CurrentUser(c)
+	referral, ok := loadReferral(c, doctor)
+	if !ok {
+		return
+	}
+	if referral.ToDoctorID == nil || (*referral.ToDoctorID != doctor.ID && doctor.Role != models.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the receiving doctor can complete a referral"})
+		return
+	}
+	if referral.Status != models.ReferralAccepted {
+		c.JSON(http.StatusConflict, gin.H{"error": "referral is not accepted"})
+		return
+	}
+	if !updateStatus(c, doctor, referral, map[string]interface{}{"status": models.ReferralCompleted}, "failed to complete referral") {
+		return
+	}
+	notify.Send([]uint{referral.FromDoctorID}, "referral", "Referral completed",
+		fmt.Sprintf("Dr. %s completed your referral.", doctor.FullName))
+	respondWithReferral(c, http.StatusOK, referral.ID)
+}
+
+func listOwnReferrals(c *gin.Context) {
+	patient := middleware.CurrentUser(c)
+	var referrals []models.Referral
+	if err := db.DB.Preload("FromDoctor").Preload("ToDoctor").Preload("Appointment").
+		Where("patient_id = ?", patient.ID).
+		Order("created_at DESC").
+		Find(&referrals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load referrals"})
+		return
+	}
+	results := make([]gin.H, 0, len(referrals))
+	for i := range referrals {
+		results = append(results, toReferralResponse(&referrals[i]))
+	}
+	c.JSON(http.StatusOK, results)
+}
+
+var errNotPending = errors.New("referral is not pending")
+
+func updateStatus(c *gin.Context, user *models.User, referral *models.Referral, updates map[string]interface{}, failure string) bool {
+	result := db.DB.Model(&models.Referral{}).Where("id = ? AND status = ?", referral.ID, referral.Status).Updates(updates)
+	if result.Error != nil {
+		audit.Log(c, user, referralEntry(audit.ActionUpdate, audit.OutcomeError, referral))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": failure})
+		return false
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "referral was changed by someone else"})
+		return false
+	}
+	audit.Log(c, user, referralEntry(audit.ActionUpdate, audit.OutcomeSuccess, referral))
+	return true
+}
+
+func loadVerifiedDoctor(id uint) (*models.User, error) {
+	var doctor models.User
+	err := db.DB.Preload("DoctorProfile").
+		Joins("JOIN doctor_profiles ON doctor_profiles.user_id = users.id").
+		Where("users.id = ? AND users.role = ? AND users.status = ?", id, models.RoleDoctor, models.UserStatusActive).
+		Where("doctor_profiles.verification_status = ?", models.VerificationVerified).
+		First(&doctor).Error
+	return &doctor, err
+}
+
+// specialists lists verified doctors of the speciality other than exceptID.
+func specialists(speciality string, exceptID uint) ([]uint, error) {
+	var ids []uint
+	err := db.DB.Model(&models.User{}).
+		Joins("JOIN doctor_profiles ON doctor_profiles.user_id = users.id").
+		Where("users.role = ? AND users.status = ? AND users.id <> ?", models.RoleDoctor, models.UserStatusActive, exceptID).
+		Where("doctor_profiles.verification_status = ? AND LOWER(doctor_profiles.speciality) = LOWER(?)", models.VerificationVerified, speciality).
+		Pluck("users.id", &ids).Error
+	return ids, err
+}
+
+func specialityOf(doctorID uint) (string, error) {
+	var profile models.DoctorProfile
+	err := db.DB.Where("user_id = ?", doctorID).First(&profile).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	return profile.Speciality, err
+}
+
+// loadReferral loads the referral when the doctor sent it, received it, may
+// take it as an open referral for their speciality, or is an admin.
+func loadReferral(c *gin.Context, doctor *models.User) (*models.Referral, bool) {
+	var referral models.Referral
+	if err := db.DB.Preload("FromDoctor").Preload("Documents").First(&referral, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "referral not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load referral"})
+		return nil, false
+	}
+
+	allowed := doctor.Role == models.RoleAdmin || referral.FromDoctorID == doctor.ID ||
+		(referral.ToDoctorID != nil && *referral.ToDoctorID == doctor.ID)
+	if !allowed && referral.ToDoctorID == nil && referral.Status == models.ReferralPending {
+		speciality, err := specialityOf(doctor.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load doctor profile"})
+			return nil, false
+		}
+		allowed = speciality != "" && strings.EqualFold(speciality, referral.Speciality)
+	}
+	if !allowed {
+		audit.Log(c, doctor, referralEntry(audit.ActionRead, audit.OutcomeDenied, &referral))
+		c.JSON(http.StatusNotFound, gin.H{"error": "referral not found"})
+		return nil, false
+	}
+	return &referral, true
+}
+
+// loadPendingReferral loads a pending referral the doctor may answer
+// (receiving) or withdraw (as the referrer).
+func loadPendingReferral(c *gin.Context, doctor *models.User, receiving bool) (*models.Referral, bool) {
+	referral, ok := loadReferral(c, doctor)
+	if !ok {
+		return nil, false
+	}
+	if receiving && doctor.Role != models.RoleDoctor {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only a doctor can answer a referral"})
+		return nil, false
+	}
+	if receiving && referral.FromDoctorID == doctor.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "the referring doctor cannot answer their own referral"})
+		return nil, false
+	}
+	if !receiving && referral.FromDoctorID != doctor.ID && doctor.Role != models.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the referring doctor can cancel a referral"})
+		return nil, false
+	}
+	if referral.Status != models.ReferralPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "referral is not pending"})
+		return nil, false
+	}
+	return referral, true
+}
+
+func respondWithReferral(c *gin.Context, status int, id uint) {
+	var referral models.Referral
+	if err := db.DB.Preload("Patient").Preload("FromDoctor").Preload("ToDoctor").Preload("Documents").Preload("Appointment").
+		First(&referral, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load referral"})
+		return
+	}
+	c.JSON(status, toReferralResponse(&referral))
+}
+
+func referralEntry(action, outcome string, referral *models.Referral) audit.Entry {
+	entry := audit.Entry{
+		Action:    action,
+		Resource:  "referral",
+		PatientID: audit.PatientRef(referral.PatientID),
+		Outcome:   outcome,
+	}
+	if referral.ID != 0 {
+		entry.ResourceID = audit.ID(referral.ID)
+	}
+	return entry
+}
+
+func userSummary(u *models.User) gin.H {
+	if u == nil {
+		return nil
+	}
+	return gin.H{"id": u.ID, "fullName": u.FullName}
+}
+
+func toReferralResponse(r *models.Referral) gin.H {
+	documents := make([]gin.H, 0, len(r.Documents))
+	for _, d := range r.Documents {
+		documents = append(documents, gin.H{"id": d.ID, "title": d.Title, "fileName": d.FileName, "contentType": d.ContentType})
+	}
+	response := gin.H{
+		"id":            r.ID,
+		"createdAt":     r.CreatedAt,
+		"updatedAt":     r.UpdatedAt,
+		"patientId":     r.PatientID,
+		"fromDoctorId":  r.FromDoctorID,
+		"toDoctorId":    r.ToDoctorID,
+		"speciality":    r.Speciality,
+		"reason":        r.Reason,
+		"notes":         r.Notes,
+		"urgency":       r.Urgency,
+		"status":        r.Status,
+		"autoBook":      r.AutoBook,
+		"appointmentId": r.AppointmentID,
+		"responseNote":  r.ResponseNote,
+		"respondedAt":   r.RespondedAt,
+		"documents":     documents,
+		"patient":       userSummary(r.Patient),
+		"fromDoctor":    userSummary(r.FromDoctor),
+		"toDoctor":      userSummary(r.ToDoctor),
+	}
+	if r.Appointment != nil {
+		response["appointment"] = gin.H{
+			"id":          r.Appointment.ID,
+			"scheduledAt": r.Appointment.ScheduledAt,
+			"durationMin": r.Appointment.DurationMin,
+			"status":      r.Appointment.Status,
+		}
+	}
+	return response
+}
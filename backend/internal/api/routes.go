@@ -3,12 +3,26 @@ package api
 import (
 	"net/http"
 
+	"medapp/internal/api/admin"
 	"medapp/internal/api/appointment"
+	"medapp/internal/api/audit"
 	"medapp/internal/api/auth"
+	"medapp/internal/api/consent"
+	"medapp/internal/api/disease"
+	"medapp/internal/api/document"
+	"medapp/internal/api/duplicate"
+	"medapp/internal/api/emergency"
+	"medapp/internal/api/fhir"
+	"medapp/internal/api/hl7"
 	"medapp/internal/api/home"
 	"medapp/internal/api/ml"
+	"medapp/internal/api/notification"
 	"medapp/internal/api/patient"
+	"medapp/internal/api/privacy"
+	"medapp/internal/api/referral"
+	"medapp/internal/api/symptom"
 	"medapp/internal/api/user"
+	"medapp/internal/api/verification"
 	"medapp/internal/api/video"
 
 	"github.com/gin-gonic/gin"
@@ -22,11 +36,35 @@ func RegisterRoutes(r *gin.Engine) {
 		video.RegisterRoutes(api.Group("/videos"))
 		appointment.RegisterRoutes(api.Group("/appointments"))
 		ml.RegisterRoutes(api.Group("/ml"))
+		ml.RegisterAdminRoutes(api.Group("/admin/ml"))
 		user.RegisterRoutes(api.Group("/users"))
 		patient.RegisterRoutes(api.Group("/patients"))
+		admin.RegisterRoutes(api.Group("/admin"))
+		verification.RegisterRoutes(api.Group("/verification"))
+		verification.RegisterAdminRoutes(api.Group("/admin/doctors"))
+		audit.RegisterAdminRoutes(api.Group("/admin/audit"))
+		consent.RegisterRoutes(api.Group("/consents"))
+		document.RegisterRoutes(api.Group("/patients/:id/documents"))
+		emergency.RegisterRoutes(api.Group("/patients/:id/emergency-access"))
+		emergency.RegisterAdminRoutes(api.Group("/admin/emergency-access"))
+		privacy.RegisterAdminRoutes(api.Group("/admin/erasure-requests"))
+		hl7.RegisterAdminRoutes(api.Group("/admin/hl7/messages"))
+		disease.RegisterAdminRoutes(api.Group("/admin/diseases"))
+		symptom.RegisterAdminRoutes(api.Group("/admin/symptoms"))
+		duplicate.RegisterAdminRoutes(api.Group("/admin/patient-duplicates"))
+		referral.RegisterRoutes(api.Group("/referrals"))
+		me := api.Group("/me")
+		audit.RegisterPatientRoutes(me)
+		consent.RegisterPatientRoutes(me)
+		document.RegisterPatientRoutes(me)
+		notification.RegisterRoutes(me)
+		privacy.RegisterPatientRoutes(me)
+		patient.RegisterPatientRoutes(me)
+		referral.RegisterPatientRoutes(me)
 		api.GET("/status", func(c *gin.Context) {
 			c.JSON(http.StatusOK, gin.H{"message": "MedApp Backend Running"})
 		})
 	}
+	fhir.RegisterRoutes(r.Group("/fhir"))
 	r.Static("/uploads", "./uploads")
 }
@@ -0,0 +1,217 @@
+package symptom
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"medapp/internal/api/middleware"
+	"medapp/internal/audit"
+	"medapp/internal/db"
+	"medapp/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Codes are what the ML service matches on, so they are kept to snake_case.
+var codePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+type symptomRequest struct {
+	Code        *string `json:"code"`
+	Name        *string `json:"name"`
+	Category    *string `json:"category"`
+	Description *string `json:"description"`
+	SortOrder   *int    `json:"sortOrder"`
+}
+
+// RegisterAdminRoutes lets admins maintain the symptom catalog.
+func RegisterAdminRoutes(r *gin.RouterGroup) {
+	r.Use(middleware.AuthRequired(), middleware.RequireRole(models.RoleAdmin))
+	r.GET("", listSymptoms)
+	r.GET("/", listSymptoms)
+	r.POST("", createSymptom)
+	r.POST("/", createSymptom)
+	r.PUT("/:id", updateSymptom)
+	r.POST("/:id/retire", retireSymptom)
+	r.POST("/:id/restore", restoreSymptom)
+}
+
+func listSymptoms(c *gin.Context) {
+	query := db.DB.Model(&models.Symptom{})
+	switch c.Query("retired") {
+	case "true":
+		query = query.Where("retired = ?", true)
+	case "false":
+		query = query.Where("retired = ?", false)
+	}
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category = ?", category)
+	}
+	var symptoms []models.Symptom
+	if err := query.Order("sort_order ASC, name ASC").Find(&symptoms).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load symptoms"})
+		return
+	}
+	c.JSON(http.StatusOK, symptoms)
+}
+
+func createSymptom(c *gin.Context) {
+	var req symptomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Code == nil || req.Name == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code and name are required"})
+		return
+	}
+
+	var symptom models.Symptom
+	if !applyRequest(c, &symptom, &req) || !checkCodeFree(c, &symptom) {
+		return
+	}
+	if err := db.DB.Create(&symptom).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create symptom"})
+		return
+	}
+	logChange(c, audit.ActionCreate, &symptom, "")
+	c.JSON(http.StatusCreated, symptom)
+}
+
+func updateSymptom(c *gin.Context) {
+	symptom, ok := loadSymptom(c)
+	if !ok {
+		return
+	}
+	var req symptomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !applyRequest(c, symptom, &req) || !checkCodeFree(c, symptom) {
+		return
+	}
+
+	if err := db.DB.Model(symptom).
+		Select("Code", "Name", "Category", "Description", "SortOrder").
+		Updates(symptom).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update symptom"})
+		return
+	}
+	logChange(c, audit.ActionUpdate, symptom, "")
+	c.JSON(http.StatusOK, symptom)
+}
+
+// applyRequest copies the fields present in req onto symptom.
+func applyRequest(c *gin.Context, symptom *models.Symptom, req *symptomRequest) bool {
+	if req.Code != nil {
+		code := strings.ToLower(strings.TrimSpace(*req.Code))
+		if !codePattern.MatchString(code) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "code must be lowercase letters, digits and underscores"})
+			return false
+		}
+		symptom.Code = code
+	}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name cannot be empty"})
+			return false
+		}
+		symptom.Name = name
+	}
+	if req.Category != nil {
+		symptom.Category = strings.TrimSpace(*req.Category)
+	}
+	if req.Description != nil {
+		symptom.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.SortOrder != nil {
+		symptom.SortOrder = *req.SortOrder
+	}
+	return true
+}
+
+func retireSymptom(c *gin.Context) {
+	setRetired(c, true)
+}
+
+func restoreSymptom(c *gin.Context) {
+	setRetired(c, false)
+}
+
+// setRetired retires or restores a symptom. Retired symptoms are no longer
+// offered by the symptom checker.
+func setRetired(c *gin.Context, retired bool) {
+	symptom, ok := loadSymptom(c)
+	if !ok {
+		return
+	}
+	var retiredAt *time.Time
+	if retired {
+		now := time.Now()
+		retiredAt = &now
+	}
+	if err := db.DB.Model(symptom).Updates(map[string]interface{}{
+		"retired":    retired,
+		"retired_at": retiredAt,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update symptom"})
+		return
+	}
+	symptom.Retired = retired
+	symptom.RetiredAt = retiredAt
+	detail := "restored"
+	if retired {
+		detail = "retired"
+	}
+	logChange(c, audit.ActionUpdate, symptom, detail)
+	c.JSON(http.StatusOK, symptom)
+}
+
+func loadSymptom(c *gin.Context) (*models.Symptom, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid symptom id"})
+		return nil, false
+	}
+	var symptom models.Symptom
+	if err := db.DB.First(&symptom, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "symptom not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load symptom"})
+		return nil, false
+	}
+	return &symptom, true
+}
+
+func logChange(c *gin.Context, action string, symptom *models.Symptom, detail string) {
+	audit.Log(c, middleware.CurrentUser(c), audit.Entry{
+		Action:     action,
+		Resource:   "symptom",
+		ResourceID: audit.ID(symptom.ID),
+		Detail:     strings.TrimSpace(symptom.Code + " " + detail),
+	})
+}
+
+// checkCodeFree rejects a code already used by another symptom.
+func checkCodeFree(c *gin.Context, symptom *models.Symptom) bool {
+	var count int64
+	if err := db.DB.Model(&models.Symptom{}).
+		Where("code = ? AND id <> ?", symptom.Code, symptom.ID).
+		Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check code"})
+		return false
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "code already exists"})
+		return false
+	}
+	return true
+}
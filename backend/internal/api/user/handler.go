@@ -1,26 +1,33 @@
 package user
 
 import (
+	"errors"
 	"net/http"
 
+	"medapp/internal/access"
 	"medapp/internal/api/middleware"
+	"medapp/internal/audit"
 	"medapp/internal/db"
 	"medapp/internal/models"
+	"medapp/internal/patientsearch"
 
 	"github.com/gin-gonic/gin"
 )
 
 func RegisterRoutes(r *gin.RouterGroup) {
 	r.GET("/doctors", listDoctors)
-	r.GET("/patients", middleware.AuthRequired(), listPatients)
+	r.GET("/patients", middleware.AuthRequired(), middleware.RequireVerifiedDoctor(), listPatients)
+	r.GET("/patients/search", middleware.AuthRequired(), middleware.RequireVerifiedDoctor(), searchPatients)
 }
 
 func listDoctors(c *gin.Context) {
 	var doctors []models.User
 	if err := db.DB.
 		Preload("DoctorProfile").
-		Where("role = ?", models.RoleDoctor).
-		Order("full_name ASC").
+		Joins("JOIN doctor_profiles ON doctor_profiles.user_id = users.id").
+		Where("users.role = ? AND users.status = ?", models.RoleDoctor, models.UserStatusActive).
+		Where("doctor_profiles.verification_status = ?", models.VerificationVerified).
+		Order("users.full_name ASC").
 		Find(&doctors).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load doctors"})
 		return
@@ -60,35 +67,135 @@ func listPatients(c *gin.Context) {
 		return
 	}
 
+	query := db.DB.Preload("PatientProfile").Where("role = ?", models.RolePatient)
+	if user.Role == models.RoleDoctor {
+		consented, err := access.ConsentedPatientIDs(user.ID, models.ScopeDemographics)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load consents"})
+			return
+		}
+		if len(consented) == 0 {
+			c.JSON(http.StatusOK, []gin.H{})
+			return
+		}
+		query = query.Where("id IN ?", consented)
+	}
+
 	var patients []models.User
-	if err := db.DB.
-		Preload("PatientProfile").
-		Where("role = ?", models.RolePatient).
+	if err := query.
 		Order("full_name ASC").
 		Find(&patients).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load patients"})
 		return
 	}
 
+	entries := make([]audit.Entry, 0, len(patients))
+	for _, p := range patients {
+		entries = append(entries, audit.Entry{
+			Action:     audit.ActionRead,
+			Resource:   "patient_profile",
+			ResourceID: audit.ID(p.ID),
+			PatientID:  audit.PatientRef(p.ID),
+			Detail:     "user directory",
+		})
+	}
+	audit.LogMany(c, user, entries)
+
+	conditions, err := conditionsGranted(user, patients)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load consents"})
+		return
+	}
 	results := make([]gin.H, 0, len(patients))
 	for _, p := range patients {
-		profile := gin.H{}
-		if p.PatientProfile != nil {
-			profile = gin.H{
-				"gender":            p.PatientProfile.Gender,
-				"bloodType":         p.PatientProfile.BloodType,
-				"allergies":         p.PatientProfile.Allergies,
-				"chronicConditions": p.PatientProfile.ChronicConditions,
-			}
+		results = append(results, toPatientItem(p, conditions[p.ID]))
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+// conditionsGranted reports which of the patients' condition data the user may see.
+func conditionsGranted(user *models.User, patients []models.User) (map[uint]bool, error) {
+	ids := make([]uint, 0, len(patients))
+	for _, p := range patients {
+		ids = append(ids, p.ID)
+	}
+	return access.Granted(user, ids, models.ScopeConditions)
+}
+
+// toPatientItem renders a directory entry. Allergies and chronic conditions
+// are condition data and are only included when conditions is set.
+func toPatientItem(p models.User, conditions bool) gin.H {
+	profile := gin.H{}
+	if p.PatientProfile != nil {
+		profile = gin.H{
+			"gender":    p.PatientProfile.Gender,
+			"bloodType": p.PatientProfile.BloodType,
 		}
-		results = append(results, gin.H{
-			"id":             p.ID,
-			"fullName":       p.FullName,
-			"email":          p.Email,
-			"phone":          p.Phone,
-			"patientProfile": profile,
+		if conditions {
+			profile["allergies"] = p.PatientProfile.Allergies
+			profile["chronicConditions"] = p.PatientProfile.ChronicConditions
+		}
+	}
+	return gin.H{
+		"id":             p.ID,
+		"fullName":       p.FullName,
+		"email":          p.Email,
+		"phone":          p.Phone,
+		"patientProfile": profile,
+	}
+}
+
+// searchPatients searches the same patients as the directory listing, one
+// page at a time. See patientsearch.FromRequest for the parameters.
+func searchPatients(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	if user == nil || (user.Role != models.RoleDoctor && user.Role != models.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+
+	query, err := patientsearch.FromRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	visibility, err := patientsearch.VisibilityFor(user, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load consents"})
+		return
+	}
+	page, err := patientsearch.Search(db.DB, query, visibility)
+	if err != nil {
+		if errors.Is(err, patientsearch.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search patients"})
+		return
+	}
+
+	conditions, err := conditionsGranted(user, page.Patients)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load consents"})
+		return
+	}
+	entries := make([]audit.Entry, 0, len(page.Patients))
+	results := make([]gin.H, 0, len(page.Patients))
+	for _, p := range page.Patients {
+		entries = append(entries, audit.Entry{
+			Action:     audit.ActionRead,
+			Resource:   "patient_profile",
+			ResourceID: audit.ID(p.ID),
+			PatientID:  audit.PatientRef(p.ID),
+			Detail:     "user directory search",
 		})
+		results = append(results, toPatientItem(p, conditions[p.ID]))
 	}
+	audit.LogMany(c, user, entries)
 
-	c.JSON(http.StatusOK, results)
+	if page.NextCursor != "" {
+		c.Header("X-Next-Cursor", page.NextCursor)
+	}
+	c.JSON(http.StatusOK, gin.H{"items": results, "nextCursor": page.NextCursor})
 }
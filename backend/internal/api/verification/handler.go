@@ -0,0 +1,357 @@
+package verification
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"medapp/internal/api/middleware"
+	"medapp/internal/db"
+	"medapp/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// LicenseDir keeps license scans out of the publicly served uploads directory.
+const LicenseDir = "./private/licenses"
+
+const maxLicenseSize = 10 << 20
+
+var allowedLicenseExtensions = map[string]bool{
+	".pdf":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+}
+
+type decisionRequest struct {
+	Reason string `json:"reason"`
+}
+
+// RegisterRoutes exposes the doctor side of the workflow.
+func RegisterRoutes(r *gin.RouterGroup) {
+	r.Use(middleware.AuthRequired(), middleware.RequireRole(models.RoleDoctor))
+	r.GET("", getOwnVerification)
+	r.GET("/", getOwnVerification)
+	r.POST("/documents", uploadDocument)
+	r.GET("/documents/:docId", downloadOwnDocument)
+}
+
+// RegisterAdminRoutes exposes the review queue and decisions to admins.
+func RegisterAdminRoutes(r *gin.RouterGroup) {
+	r.Use(middleware.AuthRequired(), middleware.RequireRole(models.RoleAdmin))
+	r.GET("", listDoctorVerifications)
+	r.GET("/", listDoctorVerifications)
+	r.GET("/:id/verification", getDoctorVerification)
+	r.GET("/:id/documents/:docId", downloadDoctorDocument)
+	r.POST("/:id/approve", approveDoctor)
+	r.POST("/:id/reject", rejectDoctor)
+}
+
+func getOwnVerification(c *gin.Context) {
+	doctor := middleware.CurrentUser(c)
+	if doctor == nil || doctor.DoctorProfile == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "doctor profile missing"})
+		return
+	}
+
+	response, err := verificationResponse(doctor)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load verification"})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func uploadDocument(c *gin.Context) {
+	doctor := middleware.CurrentUser(c)
+	if doctor == nil || doctor.DoctorProfile == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "doctor profile missing"})
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "license file is required"})
+		return
+	}
+	if file.Size > maxLicenseSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "license file is too large"})
+		return
+	}
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	if !allowedLicenseExtensions[ext] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "license file must be a pdf, jpg or png"})
+		return
+	}
+
+	dir := filepath.Join(LicenseDir, strconv.Itoa(int(doctor.ID)))
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create license directory"})
+		return
+	}
+	destination := filepath.Join(dir, fmt.Sprintf("%d%s", time.Now().UnixNano(), ext))
+	if err := c.SaveUploadedFile(file, destination); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save license file"})
+		return
+	}
+
+	document := models.DoctorLicenseDocument{
+		DoctorID:    doctor.ID,
+		FileName:    filepath.Base(file.Filename),
+		ContentType: file.Header.Get("Content-Type"),
+		Size:        file.Size,
+		StoragePath: destination,
+	}
+
+	profile := doctor.DoctorProfile
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&document).Error; err != nil {
+			return err
+		}
+		// A new document puts unverified and rejected doctors back into the review queue.
+		if profile.VerificationStatus == models.VerificationVerified || profile.VerificationStatus == models.VerificationPending {
+			return nil
+		}
+		if err := tx.Model(profile).Update("verification_status", models.VerificationPending).Error; err != nil {
+			return err
+		}
+		profile.VerificationStatus = models.VerificationPending
+		return tx.Create(&models.DoctorVerificationEvent{
+			DoctorID: doctor.ID,
+			ActorID:  doctor.ID,
+			Status:   models.VerificationPending,
+			Reason:   "license document submitted",
+		}).Error
+	})
+	if err != nil {
+		os.Remove(destination)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store license document"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"document":           document,
+		"verificationStatus": profile.VerificationStatus,
+	})
+}
+
+func downloadOwnDocument(c *gin.Context) {
+	doctor := middleware.CurrentUser(c)
+	if doctor == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	serveDocument(c, doctor.ID)
+}
+
+func listDoctorVerifications(c *gin.Context) {
+	status := c.DefaultQuery("status", string(models.VerificationPending))
+
+	query := db.DB.
+		Preload("DoctorProfile").
+		Joins("JOIN doctor_profiles ON doctor_profiles.user_id = users.id").
+		Where("users.role = ?", models.RoleDoctor).
+		Order("doctor_profiles.updated_at ASC")
+	if status != "all" {
+		query = query.Where("doctor_profiles.verification_status = ?", status)
+	}
+
+	var doctors []models.User
+	if err := query.Find(&doctors).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load doctors"})
+		return
+	}
+
+	results := make([]gin.H, 0, len(doctors))
+	for _, d := range doctors {
+		results = append(results, gin.H{
+			"id":                 d.ID,
+			"fullName":           d.FullName,
+			"email":              d.Email,
+			"speciality":         d.DoctorProfile.Speciality,
+			"licenseNumber":      d.DoctorProfile.LicenseNumber,
+			"verificationStatus": d.DoctorProfile.VerificationStatus,
+			"updatedAt":          d.DoctorProfile.UpdatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+func getDoctorVerification(c *gin.Context) {
+	doctor, ok := loadDoctor(c)
+	if !ok {
+		return
+	}
+
+	response, err := verificationResponse(doctor)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load verification"})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func downloadDoctorDocument(c *gin.Context) {
+	doctor, ok := loadDoctor(c)
+	if !ok {
+		return
+	}
+	serveDocument(c, doctor.ID)
+}
+
+func approveDoctor(c *gin.Context) {
+	var req decisionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	decide(c, models.VerificationVerified, req.Reason)
+}
+
+func rejectDoctor(c *gin.Context) {
+	var req decisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a reason is required to reject a doctor"})
+		return
+	}
+	decide(c, models.VerificationRejected, req.Reason)
+}
+
+func decide(c *gin.Context, status models.VerificationStatus, reason string) {
+	admin := middleware.CurrentUser(c)
+	doctor, ok := loadDoctor(c)
+	if !ok {
+		return
+	}
+
+	profile := doctor.DoctorProfile
+	if status == models.VerificationVerified {
+		var documents int64
+		if err := db.DB.Model(&models.DoctorLicenseDocument{}).Where("doctor_id = ?", doctor.ID).Count(&documents).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check license documents"})
+			return
+		}
+		if documents == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "doctor has not uploaded any license documents"})
+			return
+		}
+	}
+
+	var verifiedAt *time.Time
+	if status == models.VerificationVerified {
+		now := time.Now()
+		verifiedAt = &now
+	}
+	updates := map[string]interface{}{"verification_status": status, "verified_at": verifiedAt}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(profile).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.DoctorVerificationEvent{
+			DoctorID: doctor.ID,
+			ActorID:  admin.ID,
+			Status:   status,
+			Reason:   strings.TrimSpace(reason),
+		}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record decision"})
+		return
+	}
+	profile.VerificationStatus = status
+	profile.VerifiedAt = verifiedAt
+
+	response, err := verificationResponse(doctor)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load verification"})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func loadDoctor(c *gin.Context) (*models.User, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid doctor id"})
+		return nil, false
+	}
+
+	var doctor models.User
+	if err := db.DB.Preload("DoctorProfile").Where("id = ? AND role = ?", id, models.RoleDoctor).First(&doctor).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "doctor not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load doctor"})
+		return nil, false
+	}
+	if doctor.DoctorProfile == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "doctor profile missing"})
+		return nil, false
+	}
+	return &doctor, true
+}
+
+func serveDocument(c *gin.Context, doctorID uint) {
+	var document models.DoctorLicenseDocument
+	if err := db.DB.Where("id = ? AND doctor_id = ?", c.Param("docId"), doctorID).First(&document).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return
+	}
+	c.FileAttachment(document.StoragePath, document.FileName)
+}
+
+func verificationResponse(doctor *models.User) (gin.H, error) {
+	var documents []models.DoctorLicenseDocument
+	if err := db.DB.Where("doctor_id = ?", doctor.ID).Order("created_at ASC").Find(&documents).Error; err != nil {
+		return nil, err
+	}
+
+	var events []models.DoctorVerificationEvent
+	if err := db.DB.Preload("Actor").Where("doctor_id = ?", doctor.ID).Order("created_at ASC").Find(&events).Error; err != nil {
+		return nil, err
+	}
+
+	history := make([]gin.H, 0, len(events))
+	for _, e := range events {
+		entry := gin.H{
+			"id":        e.ID,
+			"status":    e.Status,
+			"reason":    e.Reason,
+			"createdAt": e.CreatedAt,
+		}
+		if e.Actor != nil {
+			entry["actor"] = gin.H{
+				"id":       e.Actor.ID,
+				"fullName": e.Actor.FullName,
+				"role":     e.Actor.Role,
+			}
+		}
+		history = append(history, entry)
+	}
+
+	return gin.H{
+		"doctorId":           doctor.ID,
+		"fullName":           doctor.FullName,
+		"licenseNumber":      doctor.DoctorProfile.LicenseNumber,
+		"verificationStatus": doctor.DoctorProfile.VerificationStatus,
+		"verifiedAt":         doctor.DoctorProfile.VerifiedAt,
+		"documents":          documents,
+		"history":            history,
+	}, nil
+}
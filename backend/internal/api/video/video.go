@@ -2,6 +2,7 @@ package video
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -35,9 +36,9 @@ type videoResponse struct {
 func RegisterRoutes(r *gin.RouterGroup) {
 	r.GET("", listVideos)
 	r.GET("/", listVideos)
-	r.GET("/:id", getVideo)
+	r.GET("/:id", middleware.OptionalAuth(), getVideo)
 	authGroup := r.Group("")
-	authGroup.Use(middleware.AuthRequired(), middleware.RequireRole(models.RoleDoctor))
+	authGroup.Use(middleware.AuthRequired(), middleware.RequireRole(models.RoleDoctor), middleware.RequireVerifiedDoctor())
 	authGroup.POST("", uploadVideo)
 	authGroup.POST("/", uploadVideo)
 }
@@ -64,6 +65,13 @@ func getVideo(c *gin.Context) {
 		return
 	}
 
+	// Signed-in viewers get a watch history entry; it is included in their data export.
+	if user := middleware.CurrentUser(c); user != nil {
+		if err := db.DB.Create(&models.VideoView{UserID: user.ID, VideoID: video.ID}).Error; err != nil {
+			log.Printf("video: failed to record view of %d by %d: %v", video.ID, user.ID, err)
+		}
+	}
+
 	c.JSON(http.StatusOK, toVideoResponse(&video))
 }
 
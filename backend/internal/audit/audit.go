@@ -0,0 +1,242 @@
+// Package audit records access to patient health information in an
+// append-only, hash-chained table so that edits or deletions are detectable.
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"medapp/internal/db"
+	"medapp/internal/models"
+	"medapp/internal/textutil"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	ActionRead   = "read"
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeDenied  = "denied"
+	OutcomeError   = "error"
+)
+
+// EmergencyAccessKey is the gin context key under which handlers store the ID
+// of a break-the-glass override; entries logged for that request are flagged with it.
+const EmergencyAccessKey = "emergencyAccessID"
+
+// chainLockKey serialises writers so every entry links to its true predecessor.
+const chainLockKey = 7_301_028
+
+// Entry describes one access event. Actor, IP and user agent are filled in by Log.
+type Entry struct {
+	Action     string
+	Resource   string
+	ResourceID string
+	PatientID  *uint
+	Outcome    string
+	Detail     string
+}
+
+// MarkEmergency flags every entry logged for the rest of the request as made
+// under the given break-the-glass override.
+func MarkEmergency(c *gin.Context, emergencyAccessID uint) {
+	c.Set(EmergencyAccessKey, emergencyAccessID)
+}
+
+// Log records an entry for the current request. Failures are logged rather
+// than surfaced so that an audit outage does not take down clinical workflows.
+func Log(c *gin.Context, actor *models.User, entry Entry) {
+	LogMany(c, actor, []Entry{entry})
+}
+
+// LogMany records several entries for the current request in one chained write.
+func LogMany(c *gin.Context, actor *models.User, entries []Entry) {
+	if len(entries) == 0 {
+		return
+	}
+
+	records := make([]models.AuditLog, 0, len(entries))
+	for _, e := range entries {
+		record := models.AuditLog{
+			Action:     e.Action,
+			Resource:   e.Resource,
+			ResourceID: e.ResourceID,
+			PatientID:  e.PatientID,
+			IP:         c.ClientIP(),
+			UserAgent:  textutil.Truncate(c.Request.UserAgent(), 512),
+			Outcome:    e.Outcome,
+			Detail:     e.Detail,
+		}
+		if record.Outcome == "" {
+			record.Outcome = OutcomeSuccess
+		}
+		if actor != nil {
+			record.ActorID = actor.ID
+			record.ActorRole = string(actor.Role)
+		}
+		if value, ok := c.Get(EmergencyAccessKey); ok {
+			if id, ok := value.(uint); ok {
+				record.EmergencyAccessID = &id
+			}
+		}
+		records = append(records, record)
+	}
+
+	if err := Append(records); err != nil {
+		log.Printf("audit: failed to record %d entries for %s: %v", len(records), c.FullPath(), err)
+	}
+}
+
+// LogSystem records an entry made by a background integration rather than a
+// signed-in user, such as a message received from another system.
+func LogSystem(source, remoteAddr string, entry Entry) {
+	record := models.AuditLog{
+		ActorRole:  "system",
+		Action:     entry.Action,
+		Resource:   entry.Resource,
+		ResourceID: entry.ResourceID,
+		PatientID:  entry.PatientID,
+		IP:         textutil.Truncate(remoteAddr, 64),
+		UserAgent:  textutil.Truncate(source, 512),
+		Outcome:    entry.Outcome,
+		Detail:     entry.Detail,
+	}
+	if record.Outcome == "" {
+		record.Outcome = OutcomeSuccess
+	}
+	if err := Append([]models.AuditLog{record}); err != nil {
+		log.Printf("audit: failed to record %s entry from %s: %v", entry.Resource, source, err)
+	}
+}
+
+// Append chains and stores the given records in order.
+func Append(records []models.AuditLog) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", chainLockKey).Error; err != nil {
+			return err
+		}
+
+		var last models.AuditLog
+		if err := tx.Select("hash").Order("id DESC").Limit(1).Find(&last).Error; err != nil {
+			return err
+		}
+		prev := last.Hash
+
+		now := time.Now().UTC().Truncate(time.Microsecond)
+		for i := range records {
+			records[i].CreatedAt = now
+			records[i].PrevHash = prev
+			records[i].Hash = ComputeHash(&records[i])
+			prev = records[i].Hash
+		}
+		return tx.Create(&records).Error
+	})
+}
+
+// hashedFields is the canonical form of an entry. New fields must be added
+// with omitempty so hashes of existing entries stay stable.
+type hashedFields struct {
+	CreatedAt  string `json:"createdAt"`
+	ActorID    uint   `json:"actorId"`
+	ActorRole  string `json:"actorRole"`
+	Action     string `json:"action"`
+	Resource   string `json:"resource"`
+	ResourceID string `json:"resourceId"`
+	PatientID  *uint  `json:"patientId"`
+	IP         string `json:"ip"`
+	UserAgent  string `json:"userAgent"`
+	Outcome    string `json:"outcome"`
+	Detail     string `json:"detail"`
+	PrevHash   string `json:"prevHash"`
+
+	EmergencyAccessID *uint `json:"emergencyAccessId,omitempty"`
+}
+
+// ComputeHash returns the chain hash of a record given its PrevHash.
+func ComputeHash(record *models.AuditLog) string {
+	payload, _ := json.Marshal(hashedFields{
+		CreatedAt:  record.CreatedAt.UTC().Format(time.RFC3339Nano),
+		ActorID:    record.ActorID,
+		ActorRole:  record.ActorRole,
+		Action:     record.Action,
+		Resource:   record.Resource,
+		ResourceID: record.ResourceID,
+		PatientID:  record.PatientID,
+		IP:         record.IP,
+		UserAgent:  record.UserAgent,
+		Outcome:    record.Outcome,
+		Detail:     record.Detail,
+		PrevHash:   record.PrevHash,
+
+		EmergencyAccessID: record.EmergencyAccessID,
+	})
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyResult reports the outcome of walking the chain.
+type VerifyResult struct {
+	Valid    bool   `json:"valid"`
+	Checked  int    `json:"checked"`
+	BrokenAt *uint  `json:"brokenAt,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Verify recomputes every hash in insertion order and stops at the first
+// entry that was modified or whose predecessor is missing.
+func Verify() (*VerifyResult, error) {
+	const batchSize = 500
+
+	result := &VerifyResult{Valid: true}
+	prev := ""
+	var lastID uint
+	for {
+		var batch []models.AuditLog
+		if err := db.DB.Where("id > ?", lastID).Order("id ASC").Limit(batchSize).Find(&batch).Error; err != nil {
+			return nil, err
+		}
+		for i := range batch {
+			record := &batch[i]
+			result.Checked++
+			if record.PrevHash != prev {
+				return broken(result, record.ID, "previous hash does not match the preceding entry"), nil
+			}
+			if ComputeHash(record) != record.Hash {
+				return broken(result, record.ID, "entry contents do not match its hash"), nil
+			}
+			prev = record.Hash
+			lastID = record.ID
+		}
+		if len(batch) < batchSize {
+			return result, nil
+		}
+	}
+}
+
+func broken(result *VerifyResult, id uint, reason string) *VerifyResult {
+	result.Valid = false
+	result.BrokenAt = &id
+	result.Reason = reason
+	return result
+}
+
+// PatientRef is a small helper for the common *uint PatientID field.
+func PatientRef(id uint) *uint {
+	return &id
+}
+
+// ID formats a numeric resource id.
+func ID(id uint) string {
+	return fmt.Sprintf("%d", id)
+}
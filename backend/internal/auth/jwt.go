@@ -24,6 +24,11 @@ func jwtSecret() ([]byte, error) {
 }
 
 func GenerateToken(userID uint, role string, expiry time.Duration) (string, error) {
+	return GenerateTokenAt(userID, role, time.Now(), expiry)
+}
+
+// GenerateTokenAt issues a token dated issuedAt; it expires expiry after that.
+func GenerateTokenAt(userID uint, role string, issuedAt time.Time, expiry time.Duration) (string, error) {
 	secret, err := jwtSecret()
 	if err != nil {
 		return "", err
@@ -36,8 +41,8 @@ func GenerateToken(userID uint, role string, expiry time.Duration) (string, erro
 		UserID: userID,
 		Role:   role,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiry)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(issuedAt.Add(expiry)),
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
 		},
 	}
 
@@ -3,7 +3,6 @@ package auth
 import (
 	"errors"
 	"fmt"
-	"log"
 	"os"
 	"time"
 
@@ -13,6 +12,8 @@ import (
 	"gorm.io/gorm"
 )
 
+var ErrAccountDeactivated = errors.New("account is deactivated")
+
 type Service struct{}
 
 func NewService() *Service {
@@ -35,6 +36,15 @@ type RegisterPayload struct {
 }
 
 func (s *Service) Register(payload *RegisterPayload) (*AuthResult, error) {
+	user, err := s.CreateUser(payload)
+	if err != nil {
+		return nil, err
+	}
+	return s.newAuthResult(user)
+}
+
+// CreateUser persists a new account with its role profile without issuing a token.
+func (s *Service) CreateUser(payload *RegisterPayload) (*models.User, error) {
 	if payload == nil || payload.User == nil {
 		return nil, errors.New("invalid payload")
 	}
@@ -56,7 +66,9 @@ func (s *Service) Register(payload *RegisterPayload) (*AuthResult, error) {
 		return nil, fmt.Errorf("hash password: %w", err)
 	}
 	user.PasswordHash = hashed
-	log.Println("here")
+	if user.Status == "" {
+		user.Status = models.UserStatusActive
+	}
 	err = db.DB.Transaction(func(tx *gorm.DB) error {
 		if err := tx.Create(user).Error; err != nil {
 			return err
@@ -78,12 +90,10 @@ func (s *Service) Register(payload *RegisterPayload) (*AuthResult, error) {
 
 		return nil
 	})
-	log.Println("here2")
 	if err != nil {
 		return nil, fmt.Errorf("create user: %w", err)
 	}
-	log.Println("here3")
-	return s.newAuthResult(user)
+	return user, nil
 }
 
 func (s *Service) Authenticate(email, password string) (*AuthResult, error) {
@@ -96,17 +106,74 @@ func (s *Service) Authenticate(email, password string) (*AuthResult, error) {
 		return nil, errors.New("invalid email or password")
 	}
 
+	if user.Status != models.UserStatusActive {
+		return nil, ErrAccountDeactivated
+	}
+
 	return s.newAuthResult(&user)
 }
 
+// ChangePassword replaces the password of a user who knows the current one and
+// clears any pending forced reset. Previously issued tokens stop working.
+func (s *Service) ChangePassword(user *models.User, current, next string) (*AuthResult, error) {
+	if !CheckPasswordHash(user.PasswordHash, current) {
+		return nil, errors.New("current password is incorrect")
+	}
+	if current == next {
+		return nil, errors.New("new password must differ from the current one")
+	}
+	if err := s.setPassword(user, next, false); err != nil {
+		return nil, err
+	}
+	return s.newAuthResult(user)
+}
+
+// ResetPassword assigns a random temporary password and forces the user to pick
+// a new one on next login. The temporary password is returned once.
+func (s *Service) ResetPassword(user *models.User) (string, error) {
+	temporary, err := GenerateTemporaryPassword()
+	if err != nil {
+		return "", fmt.Errorf("generate password: %w", err)
+	}
+	if err := s.setPassword(user, temporary, true); err != nil {
+		return "", err
+	}
+	return temporary, nil
+}
+
+func (s *Service) setPassword(user *models.User, password string, mustReset bool) error {
+	hashed, err := HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("hash password: %w", err)
+	}
+	// Token timestamps have second precision, so round up: a token issued
+	// earlier in the same second as the change must not survive it.
+	changedAt := time.Now().Truncate(time.Second).Add(time.Second)
+	if err := db.DB.Model(user).Updates(map[string]interface{}{
+		"password_hash":       hashed,
+		"must_reset_password": mustReset,
+		"password_changed_at": changedAt,
+	}).Error; err != nil {
+		return fmt.Errorf("update password: %w", err)
+	}
+	user.PasswordHash = hashed
+	user.MustResetPassword = mustReset
+	user.PasswordChangedAt = &changedAt
+	return nil
+}
+
 func (s *Service) newAuthResult(user *models.User) (*AuthResult, error) {
-	log.Println("here5")
 	expires := tokenExpiry()
-	token, err := GenerateToken(user.ID, string(user.Role), expires)
+	// Right after a password change the change time is up to a second ahead;
+	// date the new token from it so that it is accepted.
+	issuedAt := time.Now()
+	if user.PasswordChangedAt != nil && issuedAt.Before(*user.PasswordChangedAt) {
+		issuedAt = *user.PasswordChangedAt
+	}
+	token, err := GenerateTokenAt(user.ID, string(user.Role), issuedAt, expires)
 	if err != nil {
 		return nil, err
 	}
-	log.Println("here4")
 	return &AuthResult{
 		User:      user,
 		Token:     token,
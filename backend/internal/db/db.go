@@ -6,10 +6,14 @@ import (
 	"os"
 	"time"
 
+	"medapp/internal/fieldcrypt"
+	"medapp/internal/medicalhistory"
 	"medapp/internal/models"
+	"medapp/internal/problemlist"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
@@ -42,6 +46,17 @@ func ConnectDB() {
 	sqlDB.SetMaxOpenConns(25)
 	sqlDB.SetConnMaxLifetime(5 * time.Minute)
 
+	// Encrypted columns need their keys before any model is read or written.
+	if err := fieldcrypt.Init(); err != nil {
+		log.Fatal("Failed to initialise field encryption: ", err)
+	}
+
+	// Disease names were unique before the catalog gained ICD-10 codes; the
+	// full classification repeats some names, so the constraint is dropped.
+	if err := db.Exec("DROP INDEX IF EXISTS idx_diseases_name").Error; err != nil {
+		log.Fatal("Failed to drop disease name index: ", err)
+	}
+
 	if err := db.AutoMigrate(
 		&models.User{},
 		&models.DoctorProfile{},
@@ -50,13 +65,59 @@ func ConnectDB() {
 		&models.Appointment{},
 		&models.DoctorPatient{},
 		&models.PatientMedicalInfo{},
+		&models.PatientMedicalInfoRevision{},
+		&models.PatientProblem{},
+		&models.PatientProblemEvent{},
 		&models.Disease{},
+		&models.DiseaseTranslation{},
+		&models.Symptom{},
+		&models.TriageRecord{},
+		&models.RiskAssessment{},
+		&models.MLJob{},
+		&models.MLJobItem{},
+		&models.DoctorLicenseDocument{},
+		&models.DoctorVerificationEvent{},
+		&models.AuditLog{},
+		&models.PatientConsent{},
+		&models.PatientDocument{},
+		&models.EmergencyAccess{},
+		&models.Notification{},
+		&models.VideoView{},
+		&models.ErasureRequest{},
+		&models.PatientIdentifier{},
+		&models.HL7Message{},
+		&models.PatientDuplicate{},
+		&models.PatientMergeRedirect{},
+		&models.Referral{},
 	); err != nil {
 		log.Fatal("AutoMigrate failed: ", err)
 	}
 
+	if err := protectAuditLog(db); err != nil {
+		log.Fatal("Failed to protect audit log: ", err)
+	}
+
+	// Assignments made before care teams had no update time
+	if err := db.Exec("UPDATE doctor_patients SET updated_at = created_at WHERE updated_at IS NULL").Error; err != nil {
+		log.Printf("Failed to backfill assignment update times: %v", err)
+	}
+
 	// Seed common diseases if they don't exist
 	seedDiseases(db)
+	seedDiseaseTranslations(db)
+	seedSymptoms(db)
+
+	// Medical info saved before revisions were kept gets its current state as the first revision
+	if count, err := medicalhistory.Backfill(db); err != nil {
+		log.Printf("Failed to backfill medical info history: %v", err)
+	} else if count > 0 {
+		log.Printf("Recorded history for %d existing medical info records", count)
+	}
+	if count, err := problemlist.Backfill(db); err != nil {
+		log.Printf("Failed to backfill problem lists: %v", err)
+	} else if count > 0 {
+		log.Printf("Started problem lists for %d patients", count)
+	}
 
 	DB = db
 	log.Println("PostgreSQL connected and models migrated")
@@ -70,38 +131,169 @@ func getEnv(key, fallback string) string {
 	return val
 }
 
+// protectAuditLog rejects UPDATE and DELETE on audit_logs at the database level.
+func protectAuditLog(db *gorm.DB) error {
+	statements := []string{
+		`CREATE OR REPLACE FUNCTION audit_logs_append_only() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'audit_logs is append-only';
+		END;
+		$$ LANGUAGE plpgsql`,
+		`DROP TRIGGER IF EXISTS audit_logs_append_only ON audit_logs`,
+		`CREATE TRIGGER audit_logs_append_only BEFORE UPDATE OR DELETE ON audit_logs
+		FOR EACH ROW EXECUTE FUNCTION audit_logs_append_only()`,
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func seedDiseases(db *gorm.DB) {
 	diseases := []models.Disease{
-		{Name: "Diabetes Type 1", Category: "Chronic", Description: "Autoimmune condition where the pancreas produces little or no insulin"},
-		{Name: "Diabetes Type 2", Category: "Chronic", Description: "Metabolic disorder characterized by high blood sugar"},
-		{Name: "Hypertension", Category: "Chronic", Description: "High blood pressure, a long-term medical condition"},
-		{Name: "Asthma", Category: "Chronic", Description: "Chronic inflammatory disease of the airways"},
-		{Name: "COPD", Category: "Chronic", Description: "Chronic Obstructive Pulmonary Disease"},
-		{Name: "Heart Disease", Category: "Chronic", Description: "Various conditions affecting the heart"},
-		{Name: "Arthritis", Category: "Chronic", Description: "Inflammation of one or more joints"},
-		{Name: "Osteoporosis", Category: "Chronic", Description: "Bone disease that occurs when bone mineral density decreases"},
-		{Name: "Chronic Kidney Disease", Category: "Chronic", Description: "Progressive loss of kidney function over time"},
-		{Name: "Depression", Category: "Mental Health", Description: "Mood disorder causing persistent sadness"},
-		{Name: "Anxiety Disorder", Category: "Mental Health", Description: "Mental health disorder characterized by excessive worry"},
-		{Name: "Epilepsy", Category: "Neurological", Description: "Central nervous system disorder causing seizures"},
-		{Name: "Migraine", Category: "Neurological", Description: "Recurrent headaches often accompanied by nausea"},
-		{Name: "Thyroid Disease", Category: "Endocrine", Description: "Disorders affecting the thyroid gland"},
-		{Name: "Obesity", Category: "Metabolic", Description: "Excessive body fat accumulation"},
-		{Name: "Anemia", Category: "Hematological", Description: "Condition with reduced red blood cells or hemoglobin"},
-		{Name: "Hepatitis", Category: "Infectious", Description: "Inflammation of the liver"},
-		{Name: "HIV/AIDS", Category: "Infectious", Description: "Viral infection affecting the immune system"},
-		{Name: "Tuberculosis", Category: "Infectious", Description: "Bacterial infection primarily affecting the lungs"},
-		{Name: "Cancer", Category: "Oncological", Description: "Group of diseases involving abnormal cell growth"},
+		{Name: "Diabetes Type 1", Category: "Chronic", Description: "Autoimmune condition where the pancreas produces little or no insulin", Code: "E10", SnomedCode: "46635009"},
+		{Name: "Diabetes Type 2", Category: "Chronic", Description: "Metabolic disorder characterized by high blood sugar", Code: "E11", SnomedCode: "44054006"},
+		{Name: "Hypertension", Category: "Chronic", Description: "High blood pressure, a long-term medical condition", Code: "I10", SnomedCode: "38341003"},
+		{Name: "Asthma", Category: "Chronic", Description: "Chronic inflammatory disease of the airways", Code: "J45", SnomedCode: "195967001"},
+		{Name: "COPD", Category: "Chronic", Description: "Chronic Obstructive Pulmonary Disease", Code: "J44", SnomedCode: "13645005"},
+		{Name: "Heart Disease", Category: "Chronic", Description: "Various conditions affecting the heart", Code: "I51.9", SnomedCode: "56265001"},
+		{Name: "Arthritis", Category: "Chronic", Description: "Inflammation of one or more joints", Code: "M13.9", SnomedCode: "3723001"},
+		{Name: "Osteoporosis", Category: "Chronic", Description: "Bone disease that occurs when bone mineral density decreases", Code: "M81", SnomedCode: "64859006"},
+		{Name: "Chronic Kidney Disease", Category: "Chronic", Description: "Progressive loss of kidney function over time", Code: "N18", SnomedCode: "709044004"},
+		{Name: "Depression", Category: "Mental Health", Description: "Mood disorder causing persistent sadness", Code: "F32", SnomedCode: "35489007"},
+		{Name: "Anxiety Disorder", Category: "Mental Health", Description: "Mental health disorder characterized by excessive worry", Code: "F41", SnomedCode: "197480006"},
+		{Name: "Epilepsy", Category: "Neurological", Description: "Central nervous system disorder causing seizures", Code: "G40", SnomedCode: "84757009"},
+		{Name: "Migraine", Category: "Neurological", Description: "Recurrent headaches often accompanied by nausea", Code: "G43", SnomedCode: "37796009"},
+		{Name: "Thyroid Disease", Category: "Endocrine", Description: "Disorders affecting the thyroid gland", Code: "E07.9", SnomedCode: "14304000"},
+		{Name: "Obesity", Category: "Metabolic", Description: "Excessive body fat accumulation", Code: "E66", SnomedCode: "414916001"},
+		{Name: "Anemia", Category: "Hematological", Description: "Condition with reduced red blood cells or hemoglobin", Code: "D64.9", SnomedCode: "271737000"},
+		{Name: "Hepatitis", Category: "Infectious", Description: "Inflammation of the liver", Code: "K75.9", SnomedCode: "128241005"},
+		{Name: "HIV/AIDS", Category: "Infectious", Description: "Viral infection affecting the immune system", Code: "B24", SnomedCode: "86406008"},
+		{Name: "Tuberculosis", Category: "Infectious", Description: "Bacterial infection primarily affecting the lungs", Code: "A16", SnomedCode: "56717001"},
+		{Name: "Cancer", Category: "Oncological", Description: "Group of diseases involving abnormal cell growth", Code: "C80", SnomedCode: "363346000"},
 	}
 
 	for _, disease := range diseases {
+		disease.CodeSystem = models.CodeSystemICD10
 		var existing models.Disease
-		if err := db.Where("name = ?", disease.Name).First(&existing).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				if err := db.Create(&disease).Error; err != nil {
-					log.Printf("Failed to seed disease %s: %v", disease.Name, err)
-				}
+		err := db.Where("code_system = ? AND code = ?", disease.CodeSystem, disease.Code).
+			Or("name = ? AND code = ''", disease.Name).
+			First(&existing).Error
+		if err == gorm.ErrRecordNotFound {
+			if err := db.Create(&disease).Error; err != nil {
+				log.Printf("Failed to seed disease %s: %v", disease.Name, err)
 			}
+			continue
+		}
+		if err != nil {
+			log.Printf("Failed to check disease %s: %v", disease.Name, err)
+			continue
+		}
+		// Diseases seeded before codes existed are matched by name and given their codes.
+		if existing.Code == "" {
+			if err := db.Model(&existing).Updates(map[string]interface{}{
+				"code":        disease.Code,
+				"code_system": disease.CodeSystem,
+				"snomed_code": disease.SnomedCode,
+			}).Error; err != nil {
+				log.Printf("Failed to code disease %s: %v", disease.Name, err)
+			}
+		}
+	}
+}
+
+// seedDiseaseTranslations adds Russian and Kazakh names for the seeded diseases.
+// Existing translations are left alone so translators' edits survive restarts.
+func seedDiseaseTranslations(db *gorm.DB) {
+	type translation struct {
+		code, lang, name, description string
+	}
+	translations := []translation{
+		{"E10", "ru", "Сахарный диабет 1 типа", "Аутоиммунное заболевание, при котором поджелудочная железа вырабатывает мало инсулина или не вырабатывает его совсем"},
+		{"E10", "kk", "1 типті қант диабеті", "Ұйқы безі инсулинді аз өндіретін немесе мүлдем өндірмейтін аутоиммундық ауру"},
+		{"E11", "ru", "Сахарный диабет 2 типа", "Нарушение обмена веществ, характеризующееся высоким уровнем сахара в крови"},
+		{"E11", "kk", "2 типті қант диабеті", "Қандағы қант деңгейінің жоғарылауымен сипатталатын зат алмасу бұзылысы"},
+		{"I10", "ru", "Артериальная гипертензия", "Длительно повышенное артериальное давление"},
+		{"I10", "kk", "Артериялық гипертензия", "Артериялық қысымның ұзақ уақыт бойы жоғары болуы"},
+		{"J45", "ru", "Бронхиальная астма", "Хроническое воспалительное заболевание дыхательных путей"},
+		{"J45", "kk", "Бронх демікпесі", "Тыныс жолдарының созылмалы қабыну ауруы"},
+		{"J44", "ru", "ХОБЛ", "Хроническая обструктивная болезнь лёгких"},
+		{"J44", "kk", "ӨСОА", "Өкпенің созылмалы обструктивті ауруы"},
+		{"I51.9", "ru", "Болезнь сердца", "Различные заболевания, поражающие сердце"},
+		{"I51.9", "kk", "Жүрек ауруы", "Жүректі зақымдайтын түрлі аурулар"},
+		{"M13.9", "ru", "Артрит", "Воспаление одного или нескольких суставов"},
+		{"M13.9", "kk", "Артрит", "Бір немесе бірнеше буынның қабынуы"},
+		{"M81", "ru", "Остеопороз", "Заболевание костей, возникающее при снижении минеральной плотности костной ткани"},
+		{"M81", "kk", "Остеопороз", "Сүйектің минералды тығыздығы төмендегенде пайда болатын ауру"},
+		{"N18", "ru", "Хроническая болезнь почек", "Прогрессирующее снижение функции почек"},
+		{"N18", "kk", "Бүйректің созылмалы ауруы", "Бүйрек қызметінің біртіндеп төмендеуі"},
+		{"F32", "ru", "Депрессия", "Расстройство настроения, вызывающее стойкое чувство печали"},
+		{"F32", "kk", "Депрессия", "Ұзаққа созылған мұңға әкелетін көңіл-күй бұзылысы"},
+		{"F41", "ru", "Тревожное расстройство", "Психическое расстройство, характеризующееся чрезмерным беспокойством"},
+		{"F41", "kk", "Мазасыздық бұзылысы", "Шамадан тыс уайыммен сипатталатын психикалық бұзылыс"},
+		{"G40", "ru", "Эпилепсия", "Заболевание центральной нервной системы, вызывающее судорожные приступы"},
+		{"G40", "kk", "Эпилепсия", "Құрысу ұстамаларын тудыратын орталық жүйке жүйесінің ауруы"},
+		{"G43", "ru", "Мигрень", "Повторяющиеся головные боли, часто сопровождающиеся тошнотой"},
+		{"G43", "kk", "Бас сақинасы", "Жиі жүрек айнуымен қатар жүретін қайталанатын бас ауруы"},
+		{"E07.9", "ru", "Заболевание щитовидной железы", "Нарушения работы щитовидной железы"},
+		{"E07.9", "kk", "Қалқанша без ауруы", "Қалқанша без қызметінің бұзылыстары"},
+		{"E66", "ru", "Ожирение", "Избыточное накопление жировой ткани"},
+		{"E66", "kk", "Семіздік", "Дене майының шамадан тыс жиналуы"},
+		{"D64.9", "ru", "Анемия", "Состояние со сниженным количеством эритроцитов или гемоглобина"},
+		{"D64.9", "kk", "Анемия", "Эритроциттер немесе гемоглобин мөлшері төмендеген жағдай"},
+		{"K75.9", "ru", "Гепатит", "Воспаление печени"},
+		{"K75.9", "kk", "Гепатит", "Бауырдың қабынуы"},
+		{"B24", "ru", "ВИЧ/СПИД", "Вирусная инфекция, поражающая иммунную систему"},
+		{"B24", "kk", "АИТВ/ЖИТС", "Иммундық жүйені зақымдайтын вирустық инфекция"},
+		{"A16", "ru", "Туберкулёз", "Бактериальная инфекция, преимущественно поражающая лёгкие"},
+		{"A16", "kk", "Туберкулез", "Негізінен өкпені зақымдайтын бактериялық инфекция"},
+		{"C80", "ru", "Рак", "Группа заболеваний, связанных с аномальным ростом клеток"},
+		{"C80", "kk", "Қатерлі ісік", "Жасушалардың қалыптан тыс өсуімен байланысты аурулар тобы"},
+	}
+
+	for _, t := range translations {
+		var disease models.Disease
+		if err := db.Select("id").Where("code_system = ? AND code = ?", models.CodeSystemICD10, t.code).First(&disease).Error; err != nil {
+			continue
+		}
+		record := models.DiseaseTranslation{DiseaseID: disease.ID, Lang: t.lang, Name: t.name, Description: t.description}
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&record).Error; err != nil {
+			log.Printf("Failed to seed %s translation of %s: %v", t.lang, t.code, err)
+		}
+	}
+}
+
+// seedSymptoms adds the starter symptom catalog. Existing entries are left
+// alone so admins' edits survive restarts.
+func seedSymptoms(db *gorm.DB) {
+	symptoms := []models.Symptom{
+		{Code: "fever", Name: "Fever", Category: "General", Description: "Body temperature above 38 °C"},
+		{Code: "fatigue", Name: "Fatigue", Category: "General", Description: "Unusual tiredness or lack of energy"},
+		{Code: "chills", Name: "Chills", Category: "General", Description: "Feeling cold with shivering"},
+		{Code: "cough", Name: "Cough", Category: "Respiratory"},
+		{Code: "sore_throat", Name: "Sore throat", Category: "Respiratory"},
+		{Code: "runny_nose", Name: "Runny or blocked nose", Category: "Respiratory"},
+		{Code: "shortness_of_breath", Name: "Shortness of breath", Category: "Respiratory", Description: "Difficulty breathing or breathlessness at rest or on light effort"},
+		{Code: "chest_pain", Name: "Chest pain", Category: "Cardiovascular", Description: "Pain, pressure or tightness in the chest"},
+		{Code: "palpitations", Name: "Palpitations", Category: "Cardiovascular", Description: "Noticeably fast, strong or irregular heartbeat"},
+		{Code: "headache", Name: "Headache", Category: "Neurological"},
+		{Code: "dizziness", Name: "Dizziness", Category: "Neurological"},
+		{Code: "loss_of_smell", Name: "Loss of smell or taste", Category: "Neurological"},
+		{Code: "nausea", Name: "Nausea or vomiting", Category: "Digestive"},
+		{Code: "diarrhea", Name: "Diarrhea", Category: "Digestive"},
+		{Code: "abdominal_pain", Name: "Abdominal pain", Category: "Digestive"},
+		{Code: "muscle_pain", Name: "Muscle aches", Category: "Musculoskeletal"},
+		{Code: "joint_pain", Name: "Joint pain", Category: "Musculoskeletal"},
+		{Code: "rash", Name: "Skin rash", Category: "Skin"},
+		{Code: "frequent_urination", Name: "Frequent urination", Category: "Urinary"},
+		{Code: "excessive_thirst", Name: "Excessive thirst", Category: "Endocrine"},
+	}
+	for i := range symptoms {
+		symptoms[i].SortOrder = (i + 1) * 10
+		if err := db.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "code"}}, DoNothing: true}).Create(&symptoms[i]).Error; err != nil {
+			log.Printf("Failed to seed symptom %s: %v", symptoms[i].Code, err)
 		}
 	}
 }
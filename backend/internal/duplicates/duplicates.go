@@ -0,0 +1,281 @@
+// Package duplicates finds patients who registered more than once and merges
+// their records. A background scan scores pairs of patients on name
+// similarity, date of birth and phone number; pairs above the threshold wait
+// for an admin, who either dismisses them or merges one record into the other.
+package duplicates
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"medapp/internal/db"
+	"medapp/internal/models"
+	"medapp/internal/notify"
+
+	"gorm.io/gorm"
+)
+
+const defaultThreshold = 0.6
+
+// Weights of the signals in a score. A differing date of birth halves it.
+const (
+	nameWeight  = 0.5
+	birthWeight = 0.3
+	phoneWeight = 0.2
+)
+
+// minPhoneDigits is the number of trailing digits compared, so that the same
+// number with and without a country code still matches.
+const minPhoneDigits = 7
+
+// Threshold is the lowest score queued for review, configured with
+// DUPLICATE_MATCH_THRESHOLD (default 0.6).
+func Threshold() float64 {
+	if value := os.Getenv("DUPLICATE_MATCH_THRESHOLD"); value != "" {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil && parsed > 0 && parsed <= 1 {
+			return parsed
+		}
+		log.Printf("duplicates: ignoring invalid DUPLICATE_MATCH_THRESHOLD %q", value)
+	}
+	return defaultThreshold
+}
+
+// Person holds the details compared between two patients.
+type Person struct {
+	ID        uint
+	FullName  string
+	Phone     string
+	BirthDate *time.Time
+}
+
+// Match is how alike two people are.
+type Match struct {
+	Score          float64
+	NameSimilarity float64
+	SameBirthDate  *bool // nil when either date is unknown
+	SamePhone      bool
+}
+
+// Compare scores a and b.
+func Compare(a, b Person) Match {
+	m := Match{NameSimilarity: Similarity(a.FullName, b.FullName)}
+	m.Score = nameWeight * m.NameSimilarity
+	if a.BirthDate != nil && b.BirthDate != nil {
+		same := a.BirthDate.Format("2006-01-02") == b.BirthDate.Format("2006-01-02")
+		m.SameBirthDate = &same
+		if same {
+			m.Score += birthWeight
+		}
+	}
+	if pa, pb := phoneKey(a.Phone), phoneKey(b.Phone); pa != "" && pa == pb {
+		m.SamePhone = true
+		m.Score += phoneWeight
+	}
+	if m.SameBirthDate != nil && !*m.SameBirthDate {
+		m.Score /= 2
+	}
+	return m
+}
+
+// Similarity compares two names the way pg_trgm does: the share of
+// three-letter groups, taken per word, that the names have in common.
+func Similarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	shared := 0
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+func trigrams(s string) map[string]bool {
+	set := map[string]bool{}
+	for _, word := range words(s) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+	return set
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 127)
+	})
+}
+
+func phoneKey(phone string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phone)
+	if len(digits) < minPhoneDigits {
+		return ""
+	}
+	return digits[len(digits)-minPhoneDigits:]
+}
+
+// blockKeys are the groups a person is compared within: only people sharing a
+// date of birth, a phone number or the start of a name word are scored.
+func blockKeys(p Person) []string {
+	var keys []string
+	if p.BirthDate != nil {
+		keys = append(keys, "dob:"+p.BirthDate.Format("2006-01-02"))
+	}
+	if phone := phoneKey(p.Phone); phone != "" {
+		keys = append(keys, "phone:"+phone)
+	}
+	for _, word := range words(p.FullName) {
+		runes := []rune(word)
+		if len(runes) > 3 {
+			runes = runes[:3]
+		}
+		keys = append(keys, "name:"+string(runes))
+	}
+	return keys
+}
+
+// Candidates returns the pairs among people scoring at least threshold,
+// keyed by the pair's ids with the lower first.
+func Candidates(people []Person, threshold float64) map[[2]uint]Match {
+	blocks := map[string][]int{}
+	for i, p := range people {
+		for _, key := range blockKeys(p) {
+			blocks[key] = append(blocks[key], i)
+		}
+	}
+	compared := map[[2]uint]bool{}
+	found := map[[2]uint]Match{}
+	for _, members := range blocks {
+		for i := 0; i < len(members); i++ {
+			for j := i + 1; j < len(members); j++ {
+				a, b := people[members[i]], people[members[j]]
+				pair := pairKey(a.ID, b.ID)
+				if compared[pair] {
+					continue
+				}
+				compared[pair] = true
+				if m := Compare(a, b); m.Score >= threshold {
+					found[pair] = m
+				}
+			}
+		}
+	}
+	return found
+}
+
+func pairKey(a, b uint) [2]uint {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]uint{a, b}
+}
+
+// Scan scores every active patient against the others and queues new
+// candidate pairs. Pending pairs that no longer score enough are dropped;
+// pairs an admin already dismissed stay dismissed. It returns the number of
+// newly queued pairs.
+func Scan(tx *gorm.DB) (int, error) {
+	var rows []struct {
+		ID          uint
+		FullName    string
+		Phone       string
+		DateOfBirth *time.Time
+	}
+	if err := tx.Table("users").
+		Select("users.id, users.full_name, users.phone, patient_profiles.date_of_birth").
+		Joins("LEFT JOIN patient_profiles ON patient_profiles.user_id = users.id").
+		Where("users.role = ? AND users.status = ?", models.RolePatient, models.UserStatusActive).
+		Scan(&rows).Error; err != nil {
+		return 0, err
+	}
+	people := make([]Person, 0, len(rows))
+	for _, row := range rows {
+		people = append(people, Person{ID: row.ID, FullName: row.FullName, Phone: row.Phone, BirthDate: row.DateOfBirth})
+	}
+	found := Candidates(people, Threshold())
+
+	var existing []models.PatientDuplicate
+	if err := tx.Find(&existing).Error; err != nil {
+		return 0, err
+	}
+	known := make(map[[2]uint]*models.PatientDuplicate, len(existing))
+	for i := range existing {
+		known[[2]uint{existing[i].PatientID, existing[i].OtherID}] = &existing[i]
+	}
+
+	added := 0
+	for pair, m := range found {
+		if current, ok := known[pair]; ok {
+			if current.Status != models.DuplicatePending {
+				continue
+			}
+			if err := tx.Model(current).Updates(map[string]interface{}{
+				"score":           m.Score,
+				"name_similarity": m.NameSimilarity,
+				"same_birth_date": m.SameBirthDate,
+				"same_phone":      m.SamePhone,
+			}).Error; err != nil {
+				return added, err
+			}
+			continue
+		}
+		duplicate := models.PatientDuplicate{
+			PatientID:      pair[0],
+			OtherID:        pair[1],
+			Score:          m.Score,
+			NameSimilarity: m.NameSimilarity,
+			SameBirthDate:  m.SameBirthDate,
+			SamePhone:      m.SamePhone,
+			Status:         models.DuplicatePending,
+		}
+		if err := tx.Create(&duplicate).Error; err != nil {
+			return added, err
+		}
+		added++
+	}
+
+	for pair, current := range known {
+		if _, ok := found[pair]; !ok && current.Status == models.DuplicatePending {
+			if err := tx.Delete(current).Error; err != nil {
+				return added, err
+			}
+		}
+	}
+	return added, nil
+}
+
+// StartScanner runs Scan now and then every interval in the background,
+// telling admins when new pairs need review.
+func StartScanner(interval time.Duration) {
+	go func() {
+		for {
+			var added int
+			err := db.DB.Transaction(func(tx *gorm.DB) error {
+				var err error
+				added, err = Scan(tx)
+				return err
+			})
+			if err != nil {
+				log.Printf("duplicates: scan failed: %v", err)
+			} else if added > 0 {
+				log.Printf("duplicates: found %d possible duplicate patients", added)
+				notify.Admins("patient_duplicate", "Possible duplicate patients",
+					fmt.Sprintf("%d pairs of patient records may belong to the same person.", added))
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
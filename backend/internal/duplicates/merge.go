@@ -0,0 +1,268 @@
+package duplicates
+
+import (
+	"errors"
+	"fmt"
+
+	"medapp/internal/medicalhistory"
+	"medapp/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSamePatient  = errors.New("a patient cannot be merged into themselves")
+	ErrNotMergeable = errors.New("both records must be active patients")
+)
+
+// patientTables hold rows that simply move to the surviving patient.
+var patientTables = []interface{}{
+	&models.Appointment{},
+	&models.PatientDocument{},
+	&models.PatientConsent{},
+	&models.EmergencyAccess{},
+	&models.PatientIdentifier{},
+	&models.HL7Message{},
+	&models.Referral{},
+	&models.TriageRecord{},
+	&models.RiskAssessment{},
+	&models.MLJobItem{},
+}
+
+// Merge moves the records of patient mergedID onto survivorID and leaves a
+// redirect for the old id. Doctor assignments, problems and medical info are
+// combined; profile details the survivor lacks are copied over. The merged
+// account is kept, unable to sign in, with the medical info history it had
+// when the survivor already had medical info of its own. Run it inside a
+// transaction.
+func Merge(tx *gorm.DB, survivorID, mergedID, adminID uint, duplicateID *uint) (*models.PatientMergeRedirect, error) {
+	if survivorID == mergedID {
+		return nil, ErrSamePatient
+	}
+	var patients []models.User
+	if err := tx.Preload("PatientProfile").
+		Where("id IN ? AND role = ? AND status = ?", []uint{survivorID, mergedID}, models.RolePatient, models.UserStatusActive).
+		Find(&patients).Error; err != nil {
+		return nil, err
+	}
+	if len(patients) != 2 {
+		return nil, ErrNotMergeable
+	}
+	survivor, merged := &patients[0], &patients[1]
+	if survivor.ID != survivorID {
+		survivor, merged = merged, survivor
+	}
+
+	for _, model := range patientTables {
+		if err := tx.Model(model).Where("patient_id = ?", mergedID).Update("patient_id", survivorID).Error; err != nil {
+			return nil, fmt.Errorf("move %T: %w", model, err)
+		}
+	}
+	if err := mergeAssignments(tx, survivorID, mergedID); err != nil {
+		return nil, err
+	}
+	if err := mergeProblems(tx, survivorID, mergedID); err != nil {
+		return nil, err
+	}
+	if err := mergeMedicalInfo(tx, survivorID, mergedID, adminID); err != nil {
+		return nil, err
+	}
+	if err := mergeProfile(tx, survivor, merged); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Model(&models.User{}).Where("id = ?", mergedID).Update("status", models.UserStatusMerged).Error; err != nil {
+		return nil, fmt.Errorf("close merged account: %w", err)
+	}
+
+	// Earlier merges into the merged record now lead straight to the survivor
+	if err := tx.Model(&models.PatientMergeRedirect{}).Where("to_id = ?", mergedID).Update("to_id", survivorID).Error; err != nil {
+		return nil, fmt.Errorf("update redirects: %w", err)
+	}
+	redirect := models.PatientMergeRedirect{FromID: mergedID, ToID: survivorID, MergedByID: adminID, DuplicateID: duplicateID}
+	if err := tx.Create(&redirect).Error; err != nil {
+		return nil, fmt.Errorf("create redirect: %w", err)
+	}
+
+	// Other candidate pairs with the merged record are rescored against the survivor on the next scan
+	query := tx.Where("(patient_id = ? OR other_id = ?) AND status = ?", mergedID, mergedID, models.DuplicatePending)
+	if duplicateID != nil {
+		query = query.Where("id <> ?", *duplicateID)
+	}
+	if err := query.Delete(&models.PatientDuplicate{}).Error; err != nil {
+		return nil, fmt.Errorf("drop stale duplicates: %w", err)
+	}
+	return &redirect, nil
+}
+
+// mergeAssignments moves doctor assignments, dropping those the survivor
+// already has with the same doctor.
+func mergeAssignments(tx *gorm.DB, survivorID, mergedID uint) error {
+	if err := tx.Where("patient_id = ? AND doctor_id IN (?)", mergedID,
+		tx.Model(&models.DoctorPatient{}).Select("doctor_id").Where("patient_id = ?", survivorID)).
+		Delete(&models.DoctorPatient{}).Error; err != nil {
+		return fmt.Errorf("drop shared assignments: %w", err)
+	}
+	// The survivor keeps their own primary physician if they have one
+	if err := tx.Model(&models.DoctorPatient{}).
+		Where("patient_id = ? AND is_primary AND EXISTS (SELECT 1 FROM doctor_patients p WHERE p.patient_id = ? AND p.is_primary)", mergedID, survivorID).
+		Update("is_primary", false).Error; err != nil {
+		return fmt.Errorf("keep primary physician: %w", err)
+	}
+	if err := tx.Model(&models.DoctorPatient{}).Where("patient_id = ?", mergedID).Update("patient_id", survivorID).Error; err != nil {
+		return fmt.Errorf("move assignments: %w", err)
+	}
+	return nil
+}
+
+// mergeProblems moves problems and their events. When both patients have an
+// unresolved problem for the same disease, the merged one's events are added
+// to the survivor's problem and the merged problem is removed.
+func mergeProblems(tx *gorm.DB, survivorID, mergedID uint) error {
+	var open []models.PatientProblem
+	if err := tx.Where("patient_id = ? AND status <> ?", survivorID, models.ProblemResolved).Find(&open).Error; err != nil {
+		return err
+	}
+	openFor := make(map[uint]uint, len(open))
+	for _, p := range open {
+		openFor[p.DiseaseID] = p.ID
+	}
+
+	var problems []models.PatientProblem
+	if err := tx.Where("patient_id = ?", mergedID).Find(&problems).Error; err != nil {
+		return err
+	}
+	for _, p := range problems {
+		target := p.ID
+		if existing, ok := openFor[p.DiseaseID]; ok && p.Status != models.ProblemResolved {
+			target = existing
+		}
+		if err := tx.Model(&models.PatientProblemEvent{}).Where("problem_id = ?", p.ID).
+			Updates(map[string]interface{}{"problem_id": target, "patient_id": survivorID}).Error; err != nil {
+			return fmt.Errorf("move problem history: %w", err)
+		}
+		if target != p.ID {
+			if err := tx.Delete(&models.PatientProblem{}, p.ID).Error; err != nil {
+				return fmt.Errorf("combine problems: %w", err)
+			}
+			continue
+		}
+		if err := tx.Model(&models.PatientProblem{}).Where("id = ?", p.ID).Update("patient_id", survivorID).Error; err != nil {
+			return fmt.Errorf("move problem: %w", err)
+		}
+	}
+	return nil
+}
+
+// mergeMedicalInfo moves the merged patient's medical info with its history
+// when the survivor has none. Otherwise the survivor's record gains the
+// merged diseases, and any gender or age group it lacks, as a new version.
+func mergeMedicalInfo(tx *gorm.DB, survivorID, mergedID, adminID uint) error {
+	var infos []models.PatientMedicalInfo
+	if err := tx.Preload("Diseases").Where("patient_id IN ?", []uint{survivorID, mergedID}).Find(&infos).Error; err != nil {
+		return err
+	}
+	var survivor, merged *models.PatientMedicalInfo
+	for i := range infos {
+		if infos[i].PatientID == survivorID {
+			survivor = &infos[i]
+		} else {
+			merged = &infos[i]
+		}
+	}
+	if merged == nil {
+		return nil
+	}
+	if survivor == nil {
+		if err := tx.Model(merged).Update("patient_id", survivorID).Error; err != nil {
+			return fmt.Errorf("move medical info: %w", err)
+		}
+		if err := tx.Model(&models.PatientMedicalInfoRevision{}).Where("medical_info_id = ?", merged.ID).
+			Update("patient_id", survivorID).Error; err != nil {
+			return fmt.Errorf("move medical info history: %w", err)
+		}
+		return nil
+	}
+
+	changed := false
+	if survivor.Gender == "" && merged.Gender != "" {
+		survivor.Gender = merged.Gender
+		changed = true
+	}
+	if survivor.AgeGroup == "" && merged.AgeGroup != "" {
+		survivor.AgeGroup = merged.AgeGroup
+		changed = true
+	}
+	listed := make(map[uint]bool, len(survivor.Diseases))
+	for _, d := range survivor.Diseases {
+		listed[d.ID] = true
+	}
+	for _, d := range merged.Diseases {
+		if !listed[d.ID] {
+			survivor.Diseases = append(survivor.Diseases, d)
+			listed[d.ID] = true
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	if err := medicalhistory.Save(tx, survivor, adminID); err != nil {
+		return fmt.Errorf("combine medical info: %w", err)
+	}
+	return nil
+}
+
+// mergeProfile copies contact and profile details the survivor lacks.
+func mergeProfile(tx *gorm.DB, survivor, merged *models.User) error {
+	if survivor.Phone == "" && merged.Phone != "" {
+		if err := tx.Model(survivor).Update("phone", merged.Phone).Error; err != nil {
+			return fmt.Errorf("copy phone: %w", err)
+		}
+	}
+	from := merged.PatientProfile
+	if from == nil {
+		return nil
+	}
+	if survivor.PatientProfile == nil {
+		return tx.Model(from).Update("user_id", survivor.ID).Error
+	}
+	to := survivor.PatientProfile
+	var fields []string
+	fill := func(field string, target *string, value string) {
+		if *target == "" && value != "" {
+			*target = value
+			fields = append(fields, field)
+		}
+	}
+	fill("Gender", &to.Gender, from.Gender)
+	fill("BloodType", &to.BloodType, from.BloodType)
+	fill("Allergies", &to.Allergies, from.Allergies)
+	fill("ChronicConditions", &to.ChronicConditions, from.ChronicConditions)
+	fill("EmergencyContact", &to.EmergencyContact, from.EmergencyContact)
+	if to.DateOfBirth == nil && from.DateOfBirth != nil {
+		to.DateOfBirth = from.DateOfBirth
+		fields = append(fields, "DateOfBirth")
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	if err := tx.Model(to).Select(fields).Updates(to).Error; err != nil {
+		return fmt.Errorf("copy profile: %w", err)
+	}
+	return nil
+}
+
+// Resolve follows merge redirects from patientID. It returns the id records
+// now live under and whether patientID was merged away.
+func Resolve(tx *gorm.DB, patientID uint) (uint, bool, error) {
+	var redirect models.PatientMergeRedirect
+	err := tx.Where("from_id = ?", patientID).First(&redirect).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return patientID, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return redirect.ToID, true, nil
+}
@@ -0,0 +1,238 @@
+// Package erasure anonymizes closed patient accounts and purges their clinical
+// records once the legal retention period has passed.
+package erasure
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"medapp/internal/auth"
+	"medapp/internal/db"
+	"medapp/internal/models"
+
+	"gorm.io/gorm"
+)
+
+const defaultRetentionDays = 3650
+
+// RetentionPeriod is how long clinical records outlive an erased account,
+// configured with CLINICAL_RETENTION_DAYS (default ten years).
+func RetentionPeriod() time.Duration {
+	days := defaultRetentionDays
+	if value := os.Getenv("CLINICAL_RETENTION_DAYS"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			days = parsed
+		} else {
+			log.Printf("erasure: ignoring invalid CLINICAL_RETENTION_DAYS %q", value)
+		}
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Anonymize removes the user's personal data while keeping the clinical
+// records (medical info, appointments, documents, audit trail) that must be
+// retained. The account can no longer sign in afterwards. Duplicate accounts
+// merged into the user are anonymized too.
+func Anonymize(tx *gorm.DB, userID uint, now time.Time) error {
+	merged, err := mergedInto(tx, userID)
+	if err != nil {
+		return err
+	}
+	for _, id := range merged {
+		if err := Anonymize(tx, id, now); err != nil {
+			return err
+		}
+	}
+
+	placeholder, err := auth.GenerateTemporaryPassword()
+	if err != nil {
+		return err
+	}
+	hash, err := auth.HashPassword(placeholder)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"full_name":           "Erased user",
+		"email":               fmt.Sprintf("erased-%d@erased.invalid", userID),
+		"phone":               "",
+		"password_hash":       hash,
+		"status":              models.UserStatusErased,
+		"must_reset_password": false,
+		"password_changed_at": now,
+	}).Error; err != nil {
+		return fmt.Errorf("anonymize user: %w", err)
+	}
+
+	if err := tx.Model(&models.PatientProfile{}).Where("user_id = ?", userID).
+		Update("emergency_contact", "").Error; err != nil {
+		return fmt.Errorf("clear emergency contact: %w", err)
+	}
+
+	for _, model := range []interface{}{&models.VideoView{}, &models.Notification{}} {
+		if err := tx.Where("user_id = ?", userID).Delete(model).Error; err != nil {
+			return fmt.Errorf("delete personal activity: %w", err)
+		}
+	}
+	if err := tx.Where("patient_id = ?", userID).Delete(&models.PatientIdentifier{}).Error; err != nil {
+		return fmt.Errorf("delete external identifiers: %w", err)
+	}
+
+	if err := tx.Where("patient_id = ?", userID).Delete(&models.DoctorPatient{}).Error; err != nil {
+		return fmt.Errorf("remove doctor assignments: %w", err)
+	}
+
+	if err := tx.Model(&models.PatientConsent{}).
+		Where("patient_id = ? AND status IN ?", userID, []models.ConsentStatus{models.ConsentPending, models.ConsentActive}).
+		Updates(map[string]interface{}{"status": models.ConsentRevoked, "revoked_at": now}).Error; err != nil {
+		return fmt.Errorf("revoke consents: %w", err)
+	}
+
+	if err := tx.Model(&models.EmergencyAccess{}).
+		Where("patient_id = ? AND ended_at IS NULL AND expires_at > ?", userID, now).
+		Update("ended_at", now).Error; err != nil {
+		return fmt.Errorf("end emergency access: %w", err)
+	}
+
+	if err := tx.Model(&models.Appointment{}).
+		Where("patient_id = ? AND scheduled_at > ? AND status IN ?", userID, now,
+			[]models.AppointmentStatus{models.AppointmentPending, models.AppointmentConfirmed}).
+		Update("status", models.AppointmentCancelled).Error; err != nil {
+		return fmt.Errorf("cancel upcoming appointments: %w", err)
+	}
+
+	return nil
+}
+
+// mergedInto lists the accounts merged into userID as duplicates.
+func mergedInto(tx *gorm.DB, userID uint) ([]uint, error) {
+	var ids []uint
+	if err := tx.Model(&models.PatientMergeRedirect{}).Where("to_id = ?", userID).Pluck("from_id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("load merged accounts: %w", err)
+	}
+	return ids, nil
+}
+
+// Purge deletes the retained clinical records of an erased patient, including
+// what remains on duplicate accounts merged into them. Audit entries are
+// append-only and are kept.
+func Purge(tx *gorm.DB, userID uint) ([]string, error) {
+	merged, err := mergedInto(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var mergedFiles []string
+	for _, id := range merged {
+		files, err := Purge(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		mergedFiles = append(mergedFiles, files...)
+	}
+
+	if err := tx.Where("patient_id = ?", userID).Delete(&models.PatientMedicalInfoRevision{}).Error; err != nil {
+		return nil, fmt.Errorf("delete medical info history: %w", err)
+	}
+	for _, model := range []interface{}{&models.PatientProblemEvent{}, &models.PatientProblem{}} {
+		if err := tx.Where("patient_id = ?", userID).Delete(model).Error; err != nil {
+			return nil, fmt.Errorf("delete problem list: %w", err)
+		}
+	}
+	var infos []models.PatientMedicalInfo
+	if err := tx.Where("patient_id = ?", userID).Find(&infos).Error; err != nil {
+		return nil, err
+	}
+	for i := range infos {
+		if err := tx.Select("Diseases").Delete(&infos[i]).Error; err != nil {
+			return nil, fmt.Errorf("delete medical info: %w", err)
+		}
+	}
+
+	var documents []models.PatientDocument
+	if err := tx.Where("patient_id = ?", userID).Find(&documents).Error; err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(documents)+len(mergedFiles))
+	files = append(files, mergedFiles...)
+	for _, document := range documents {
+		files = append(files, document.StoragePath)
+	}
+
+	// Referrals point at the documents and appointments below, so they go first
+	referrals := tx.Model(&models.Referral{}).Select("id").Where("patient_id = ?", userID)
+	if err := tx.Exec("DELETE FROM referral_documents WHERE referral_id IN (?)", referrals).Error; err != nil {
+		return nil, fmt.Errorf("delete referral documents: %w", err)
+	}
+	if err := tx.Where("patient_id = ?", userID).Delete(&models.Referral{}).Error; err != nil {
+		return nil, fmt.Errorf("delete referrals: %w", err)
+	}
+
+	for _, model := range []interface{}{
+		&models.PatientDocument{},
+		&models.Appointment{},
+		&models.PatientConsent{},
+		&models.HL7Message{},
+		&models.TriageRecord{},
+		&models.MLJobItem{},
+		&models.RiskAssessment{},
+	} {
+		if err := tx.Where("patient_id = ?", userID).Delete(model).Error; err != nil {
+			return nil, fmt.Errorf("delete clinical records: %w", err)
+		}
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&models.PatientProfile{}).Error; err != nil {
+		return nil, fmt.Errorf("delete patient profile: %w", err)
+	}
+	return files, nil
+}
+
+// PurgeExpired purges every erased account whose retention period ended before now.
+func PurgeExpired(now time.Time) (int, error) {
+	var requests []models.ErasureRequest
+	if err := db.DB.Where("status = ? AND purged_at IS NULL AND retain_until <= ?", models.ErasureCompleted, now).
+		Find(&requests).Error; err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for i := range requests {
+		request := &requests[i]
+		var files []string
+		err := db.DB.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if files, err = Purge(tx, request.UserID); err != nil {
+				return err
+			}
+			return tx.Model(request).Update("purged_at", now).Error
+		})
+		if err != nil {
+			return purged, fmt.Errorf("purge user %d: %w", request.UserID, err)
+		}
+		// Files go only after the rows are gone, so a failed purge never leaves dangling metadata.
+		for _, path := range files {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				log.Printf("erasure: failed to remove %s: %v", path, err)
+			}
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// StartPurger runs PurgeExpired now and then every interval in the background.
+func StartPurger(interval time.Duration) {
+	go func() {
+		for {
+			if count, err := PurgeExpired(time.Now()); err != nil {
+				log.Printf("erasure: purge failed: %v", err)
+			} else if count > 0 {
+				log.Printf("erasure: purged clinical records of %d erased accounts", count)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
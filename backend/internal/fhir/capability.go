@@ -0,0 +1,71 @@
+package fhir
+
+import "time"
+
+// Version is the FHIR release the facade implements.
+const Version = "4.0.1"
+
+type CapabilitySearchParam struct {
+	Name          string `json:"name"`
+	Type          string `json:"type"` // token, reference, date, string, number
+	Documentation string `json:"documentation,omitempty"`
+}
+
+type CapabilityInteraction struct {
+	Code string `json:"code"` // read, search-type, ...
+}
+
+type CapabilityResource struct {
+	Type        string                  `json:"type"`
+	Interaction []CapabilityInteraction `json:"interaction"`
+	SearchParam []CapabilitySearchParam `json:"searchParam,omitempty"`
+}
+
+type CapabilitySecurity struct {
+	Description string `json:"description,omitempty"`
+}
+
+type CapabilityRest struct {
+	Mode        string                  `json:"mode"`
+	Security    *CapabilitySecurity     `json:"security,omitempty"`
+	Resource    []CapabilityResource    `json:"resource"`
+	Interaction []CapabilityInteraction `json:"interaction,omitempty"` // system-level, e.g. transaction
+
+}
+
+type CapabilityImplementation struct {
+	Description string `json:"description"`
+	URL         string `json:"url,omitempty"`
+}
+
+type CapabilityStatement struct {
+	ResourceType   string                   `json:"resourceType"`
+	Status         string                   `json:"status"`
+	Date           string                   `json:"date"`
+	Kind           string                   `json:"kind"`
+	FhirVersion    string                   `json:"fhirVersion"`
+	Format         []string                 `json:"format"`
+	Implementation CapabilityImplementation `json:"implementation"`
+	Rest           []CapabilityRest         `json:"rest"`
+}
+
+// NewCapabilityStatement describes a server exposing resources at base.
+func NewCapabilityStatement(base string, resources []CapabilityResource) CapabilityStatement {
+	return CapabilityStatement{
+		ResourceType: "CapabilityStatement",
+		Status:       "active",
+		Date:         instant(time.Now()),
+		Kind:         "instance",
+		FhirVersion:  Version,
+		Format:       []string{"application/fhir+json", "json"},
+		Implementation: CapabilityImplementation{
+			Description: "MedApp FHIR API",
+			URL:         base,
+		},
+		Rest: []CapabilityRest{{
+			Mode:     "server",
+			Security: &CapabilitySecurity{Description: "Bearer token issued by /api/auth/login"},
+			Resource: resources,
+		}},
+	}
+}
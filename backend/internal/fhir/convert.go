@@ -0,0 +1,343 @@
+package fhir
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"medapp/internal/models"
+)
+
+const (
+	// IdentifierSystem qualifies medapp user ids in exported resources.
+	IdentifierSystem = "urn:medapp:user"
+	// LicenseSystem qualifies doctor license numbers.
+	LicenseSystem = "urn:medapp:license"
+	// DiseaseSystem codes conditions by the local disease catalog id.
+	DiseaseSystem = "urn:medapp:disease"
+	// ICD10System and ICD10CMSystem are the canonical URIs of the ICD-10 editions.
+	ICD10System   = "http://hl7.org/fhir/sid/icd-10"
+	ICD10CMSystem = "http://hl7.org/fhir/sid/icd-10-cm"
+	// SnomedSystem is the canonical URI of SNOMED CT.
+	SnomedSystem = "http://snomed.info/sct"
+)
+
+// CodeSystemURI maps a catalog code system to its FHIR URI.
+func CodeSystemURI(codeSystem string) string {
+	if codeSystem == models.CodeSystemICD10CM {
+		return ICD10CMSystem
+	}
+	return ICD10System
+}
+
+// CatalogCodeSystem maps a FHIR coding system to a catalog code system, or "".
+func CatalogCodeSystem(uri string) string {
+	switch uri {
+	case ICD10System:
+		return models.CodeSystemICD10
+	case ICD10CMSystem:
+		return models.CodeSystemICD10CM
+	}
+	return ""
+}
+
+// DiseaseConcept codes a catalog disease by ICD-10 and SNOMED CT when known,
+// followed by the local catalog id.
+func DiseaseConcept(disease *models.Disease) CodeableConcept {
+	var codings []Coding
+	if disease.Code != "" {
+		codings = append(codings, Coding{System: CodeSystemURI(disease.CodeSystem), Code: disease.Code, Display: disease.Name})
+	}
+	if disease.SnomedCode != "" {
+		codings = append(codings, Coding{System: SnomedSystem, Code: disease.SnomedCode})
+	}
+	codings = append(codings, Coding{System: DiseaseSystem, Code: ResourceID(disease.ID), Display: disease.Name})
+	return CodeableConcept{Coding: codings, Text: disease.Name}
+}
+
+func instant(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func meta(updated time.Time) *Meta {
+	if updated.IsZero() {
+		return nil
+	}
+	return &Meta{LastUpdated: instant(updated)}
+}
+
+func humanName(fullName string) []HumanName {
+	fullName = strings.TrimSpace(fullName)
+	if fullName == "" {
+		return nil
+	}
+	name := HumanName{Use: "official", Text: fullName}
+	if parts := strings.Fields(fullName); len(parts) > 1 {
+		name.Given = parts[:len(parts)-1]
+		name.Family = parts[len(parts)-1]
+	} else {
+		name.Family = fullName
+	}
+	return []HumanName{name}
+}
+
+func telecom(user *models.User) []ContactPoint {
+	var points []ContactPoint
+	if user.Phone != "" {
+		points = append(points, ContactPoint{System: "phone", Value: user.Phone})
+	}
+	if user.Email != "" {
+		points = append(points, ContactPoint{System: "email", Value: user.Email})
+	}
+	return points
+}
+
+// Gender maps the free-text gender stored on profiles to a FHIR administrative gender.
+func Gender(value string) string {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "":
+		return "unknown"
+	case "male", "m", "man":
+		return "male"
+	case "female", "f", "woman":
+		return "female"
+	default:
+		return "other"
+	}
+}
+
+// ResourceID formats a numeric id as a FHIR logical id.
+func ResourceID(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+// Ref builds a relative reference such as "Patient/12".
+func Ref(resourceType string, id uint, display string) Reference {
+	return Reference{Reference: resourceType + "/" + ResourceID(id), Display: display}
+}
+
+// NewPatient converts a patient account. PatientProfile should be preloaded.
+// Only demographics are mapped: allergies and chronic conditions fall under
+// the conditions scope, which reading a Patient does not require.
+func NewPatient(user *models.User) Patient {
+	active := user.Status == models.UserStatusActive
+	patient := Patient{
+		ResourceType: "Patient",
+		ID:           ResourceID(user.ID),
+		Meta:         meta(user.UpdatedAt),
+		Identifier:   []Identifier{{System: IdentifierSystem, Value: ResourceID(user.ID)}},
+		Active:       &active,
+		Name:         humanName(user.FullName),
+		Telecom:      telecom(user),
+		Gender:       "unknown",
+	}
+	if profile := user.PatientProfile; profile != nil {
+		patient.Gender = Gender(profile.Gender)
+		if profile.DateOfBirth != nil {
+			patient.BirthDate = profile.DateOfBirth.Format("2006-01-02")
+		}
+	}
+	return patient
+}
+
+// NewPractitioner converts a doctor account. DoctorProfile should be preloaded.
+func NewPractitioner(user *models.User) Practitioner {
+	active := user.Status == models.UserStatusActive
+	practitioner := Practitioner{
+		ResourceType: "Practitioner",
+		ID:           ResourceID(user.ID),
+		Meta:         meta(user.UpdatedAt),
+		Identifier:   []Identifier{{System: IdentifierSystem, Value: ResourceID(user.ID)}},
+		Active:       &active,
+		Name:         humanName(user.FullName),
+		Telecom:      telecom(user),
+	}
+	if profile := user.DoctorProfile; profile != nil && profile.Speciality != "" {
+		qualification := PractitionerQualification{Code: CodeableConcept{Text: profile.Speciality}}
+		if profile.LicenseNumber != "" {
+			qualification.Identifier = []Identifier{{System: LicenseSystem, Value: profile.LicenseNumber}}
+		}
+		practitioner.Qualification = []PractitionerQualification{qualification}
+	}
+	return practitioner
+}
+
+// AppointmentStatus maps medapp appointment states to FHIR appointment statuses.
+func AppointmentStatus(status models.AppointmentStatus) string {
+	switch status {
+	case models.AppointmentPending:
+		return "proposed"
+	case models.AppointmentConfirmed:
+		return "booked"
+	case models.AppointmentCompleted:
+		return "fulfilled"
+	case models.AppointmentCancelled:
+		return "cancelled"
+	}
+	return "proposed"
+}
+
+// NewAppointment converts an appointment. Doctor and Patient are used for display names when preloaded.
+func NewAppointment(appt *models.Appointment) Appointment {
+	doctorName, patientName := "", ""
+	if appt.Doctor != nil {
+		doctorName = appt.Doctor.FullName
+	}
+	if appt.Patient != nil {
+		patientName = appt.Patient.FullName
+	}
+
+	participantStatus := "accepted"
+	if appt.Status == models.AppointmentPending {
+		participantStatus = "needs-action"
+	} else if appt.Status == models.AppointmentCancelled {
+		participantStatus = "declined"
+	}
+
+	resource := Appointment{
+		ResourceType:    "Appointment",
+		ID:              ResourceID(appt.ID),
+		Meta:            meta(appt.UpdatedAt),
+		Status:          AppointmentStatus(appt.Status),
+		Description:     appt.Reason,
+		MinutesDuration: appt.DurationMin,
+		Comment:         appt.Notes,
+		Participant: []AppointmentParticipant{
+			{Actor: Ref("Practitioner", appt.DoctorID, doctorName), Status: participantStatus},
+			{Actor: Ref("Patient", appt.PatientID, patientName), Status: "accepted"},
+		},
+	}
+	if !appt.ScheduledAt.IsZero() {
+		resource.Start = instant(appt.ScheduledAt)
+		if appt.DurationMin > 0 {
+			resource.End = instant(appt.ScheduledAt.Add(time.Duration(appt.DurationMin) * time.Minute))
+		}
+	}
+	return resource
+}
+
+// NewConditions converts the diseases recorded in a patient's medical info,
+// one Condition per disease. Diseases should be preloaded.
+func NewConditions(info *models.PatientMedicalInfo) []Condition {
+	conditions := make([]Condition, 0, len(info.Diseases))
+	for i := range info.Diseases {
+		conditions = append(conditions, NewCondition(info, &info.Diseases[i]))
+	}
+	return conditions
+}
+
+// ConditionID is the logical id of the Condition for a disease in a medical info record.
+func ConditionID(infoID, diseaseID uint) string {
+	return fmt.Sprintf("%d-%d", infoID, diseaseID)
+}
+
+// NewCondition converts a single disease recorded in a patient's medical info.
+func NewCondition(info *models.PatientMedicalInfo, disease *models.Disease) Condition {
+	condition := Condition{
+		ResourceType: "Condition",
+		ID:           ConditionID(info.ID, disease.ID),
+		Meta:         meta(info.UpdatedAt),
+		ClinicalStatus: &CodeableConcept{Coding: []Coding{{
+			System: ConditionClinicalSystem,
+			Code:   "active",
+		}}},
+		Code:         DiseaseConcept(disease),
+		Subject:      Ref("Patient", info.PatientID, ""),
+		RecordedDate: instant(info.UpdatedAt),
+	}
+	if info.DoctorID != 0 {
+		asserter := Ref("Practitioner", info.DoctorID, "")
+		condition.Asserter = &asserter
+	}
+	if disease.Category != "" {
+		condition.Category = []CodeableConcept{{Text: disease.Category}}
+	}
+	return condition
+}
+
+// ConditionClinicalSystem is the code system of Condition.clinicalStatus.
+const ConditionClinicalSystem = "http://terminology.hl7.org/CodeSystem/condition-clinical"
+
+// SNOMED CT codes for problem severities.
+var severityCodes = map[models.ProblemSeverity]Coding{
+	models.SeverityMild:     {System: SnomedSystem, Code: "255604002", Display: "Mild"},
+	models.SeverityModerate: {System: SnomedSystem, Code: "6736007", Display: "Moderate"},
+	models.SeveritySevere:   {System: SnomedSystem, Code: "24484000", Display: "Severe"},
+}
+
+// ApplyProblem adds the status, severity, onset and resolution recorded on
+// the patient's problem list to a Condition.
+func ApplyProblem(condition *Condition, problem *models.PatientProblem) {
+	condition.ClinicalStatus = &CodeableConcept{Coding: []Coding{{System: ConditionClinicalSystem, Code: string(problem.Status)}}}
+	if coding, ok := severityCodes[problem.Severity]; ok {
+		condition.Severity = &CodeableConcept{Coding: []Coding{coding}}
+	}
+	if problem.OnsetDate != nil {
+		condition.OnsetDateTime = problem.OnsetDate.Format("2006-01-02")
+	}
+	if problem.ResolvedDate != nil {
+		condition.AbatementDateTime = problem.ResolvedDate.Format("2006-01-02")
+	}
+	if problem.DiagnosedByID != nil {
+		asserter := Ref("Practitioner", *problem.DiagnosedByID, "")
+		condition.Asserter = &asserter
+	}
+}
+
+// ProblemSeverity maps a Condition's severity coding to a problem severity, or "".
+func ProblemSeverity(condition *Condition) models.ProblemSeverity {
+	if condition.Severity == nil {
+		return ""
+	}
+	for _, coding := range condition.Severity.Coding {
+		for severity, known := range severityCodes {
+			if coding.System == known.System && coding.Code == known.Code {
+				return severity
+			}
+		}
+	}
+	switch severity := models.ProblemSeverity(strings.ToLower(condition.Severity.Text)); severity {
+	case models.SeverityMild, models.SeverityModerate, models.SeveritySevere:
+		return severity
+	}
+	return ""
+}
+
+// ProblemStatus maps a Condition's clinical status to a problem status.
+// Inactive, resolved and remission conditions are no longer current; others
+// are treated as active.
+func ProblemStatus(condition *Condition) models.ProblemStatus {
+	if condition.ClinicalStatus == nil {
+		return models.ProblemActive
+	}
+	for _, coding := range condition.ClinicalStatus.Coding {
+		switch coding.Code {
+		case "remission":
+			return models.ProblemRemission
+		case "resolved", "inactive":
+			return models.ProblemResolved
+		}
+	}
+	return models.ProblemActive
+}
+
+// NewBundle wraps resources in a bundle of the given type. When base is set,
+// entries get absolute full URLs under it.
+func NewBundle(bundleType, base string, resources []Resource) Bundle {
+	bundle := Bundle{
+		ResourceType: "Bundle",
+		Type:         bundleType,
+		Timestamp:    instant(time.Now()),
+		Entry:        make([]BundleEntry, 0, len(resources)),
+	}
+	for _, resource := range resources {
+		entry := BundleEntry{Resource: resource}
+		if base != "" {
+			resourceType, id := resource.Key()
+			entry.FullURL = strings.TrimSuffix(base, "/") + "/" + resourceType + "/" + id
+		}
+		bundle.Entry = append(bundle.Entry, entry)
+	}
+	return bundle
+}
@@ -0,0 +1,96 @@
+package fhir
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DateFilter is a parsed date search parameter such as "ge2024-01-01".
+// The value covers the half-open range [Start, End) implied by its precision.
+type DateFilter struct {
+	Prefix string
+	Start  time.Time
+	End    time.Time
+}
+
+var datePrefixes = []string{"eq", "ne", "lt", "le", "gt", "ge", "sa", "eb"}
+
+// ParseDate parses a date search value with an optional comparison prefix.
+// Supported precisions are year, month, day and full RFC3339 timestamps.
+func ParseDate(value string) (DateFilter, error) {
+	filter := DateFilter{Prefix: "eq"}
+	for _, prefix := range datePrefixes {
+		if strings.HasPrefix(value, prefix) {
+			filter.Prefix = prefix
+			value = value[len(prefix):]
+			break
+		}
+	}
+
+	layouts := []struct {
+		layout string
+		step   func(time.Time) time.Time
+	}{
+		{time.RFC3339, func(t time.Time) time.Time { return t.Add(time.Second) }},
+		{"2006-01-02T15:04:05", func(t time.Time) time.Time { return t.Add(time.Second) }},
+		{"2006-01-02", func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }},
+		{"2006-01", func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }},
+		{"2006", func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }},
+	}
+	for _, l := range layouts {
+		if parsed, err := time.Parse(l.layout, value); err == nil {
+			filter.Start = parsed
+			filter.End = l.step(parsed)
+			return filter, nil
+		}
+	}
+	return filter, fmt.Errorf("invalid date %q", value)
+}
+
+// Clause returns an SQL condition on column with its arguments.
+func (f DateFilter) Clause(column string) (string, []interface{}) {
+	switch f.Prefix {
+	case "ne":
+		return column + " < ? OR " + column + " >= ?", []interface{}{f.Start, f.End}
+	case "lt", "eb":
+		return column + " < ?", []interface{}{f.Start}
+	case "le":
+		return column + " < ?", []interface{}{f.End}
+	case "gt", "sa":
+		return column + " >= ?", []interface{}{f.End}
+	case "ge":
+		return column + " >= ?", []interface{}{f.Start}
+	}
+	return column + " >= ? AND " + column + " < ?", []interface{}{f.Start, f.End}
+}
+
+// ParseReference extracts the numeric id from "12", "Patient/12" or an absolute
+// URL ending in "Patient/12".
+func ParseReference(value, resourceType string) (uint, error) {
+	value = strings.TrimSpace(value)
+	if i := strings.LastIndex(value, "/"); i >= 0 {
+		if !strings.HasSuffix(value[:i], resourceType) {
+			return 0, fmt.Errorf("reference %q is not a %s", value, resourceType)
+		}
+		value = value[i+1:]
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || id == 0 {
+		return 0, errors.New("invalid reference " + strconv.Quote(value))
+	}
+	return uint(id), nil
+}
+
+// SplitValues splits a comma-separated list of alternatives, dropping blanks.
+func SplitValues(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,235 @@
+// Package fhir maps medapp records to a small subset of HL7 FHIR R4 resources.
+package fhir
+
+import "encoding/json"
+
+// Resource is implemented by every resource this package produces.
+type Resource interface {
+	// Key returns the resource type and logical id.
+	Key() (string, string)
+}
+
+type Meta struct {
+	LastUpdated string `json:"lastUpdated,omitempty"`
+}
+
+type Identifier struct {
+	System string `json:"system,omitempty"`
+	Value  string `json:"value"`
+}
+
+type HumanName struct {
+	Use    string   `json:"use,omitempty"`
+	Text   string   `json:"text,omitempty"`
+	Family string   `json:"family,omitempty"`
+	Given  []string `json:"given,omitempty"`
+}
+
+type ContactPoint struct {
+	System string `json:"system"` // phone, email
+	Value  string `json:"value"`
+	Use    string `json:"use,omitempty"`
+}
+
+type Reference struct {
+	Reference string `json:"reference,omitempty"`
+	Display   string `json:"display,omitempty"`
+}
+
+type Coding struct {
+	System  string `json:"system,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Display string `json:"display,omitempty"`
+}
+
+type CodeableConcept struct {
+	Coding []Coding `json:"coding,omitempty"`
+	Text   string   `json:"text,omitempty"`
+}
+
+type PatientContact struct {
+	Relationship []CodeableConcept `json:"relationship,omitempty"`
+	Name         *HumanName        `json:"name,omitempty"`
+	Telecom      []ContactPoint    `json:"telecom,omitempty"`
+}
+
+type Quantity struct {
+	Value  *float64 `json:"value,omitempty"`
+	Unit   string   `json:"unit,omitempty"`
+	System string   `json:"system,omitempty"`
+	Code   string   `json:"code,omitempty"`
+}
+
+type Annotation struct {
+	Text string `json:"text"`
+}
+
+type Patient struct {
+	ResourceType string           `json:"resourceType"`
+	ID           string           `json:"id,omitempty"`
+	Meta         *Meta            `json:"meta,omitempty"`
+	Identifier   []Identifier     `json:"identifier,omitempty"`
+	Active       *bool            `json:"active,omitempty"`
+	Name         []HumanName      `json:"name,omitempty"`
+	Telecom      []ContactPoint   `json:"telecom,omitempty"`
+	Gender       string           `json:"gender,omitempty"` // male, female, other, unknown
+	BirthDate    string           `json:"birthDate,omitempty"`
+	Contact      []PatientContact `json:"contact,omitempty"`
+}
+
+func (p Patient) Key() (string, string) { return p.ResourceType, p.ID }
+
+type PractitionerQualification struct {
+	Identifier []Identifier    `json:"identifier,omitempty"`
+	Code       CodeableConcept `json:"code"`
+}
+
+type Practitioner struct {
+	ResourceType  string                      `json:"resourceType"`
+	ID            string                      `json:"id,omitempty"`
+	Meta          *Meta                       `json:"meta,omitempty"`
+	Identifier    []Identifier                `json:"identifier,omitempty"`
+	Active        *bool                       `json:"active,omitempty"`
+	Name          []HumanName                 `json:"name,omitempty"`
+	Telecom       []ContactPoint              `json:"telecom,omitempty"`
+	Qualification []PractitionerQualification `json:"qualification,omitempty"`
+}
+
+func (p Practitioner) Key() (string, string) { return p.ResourceType, p.ID }
+
+type AppointmentParticipant struct {
+	Actor  Reference `json:"actor"`
+	Status string    `json:"status"` // accepted, declined, tentative, needs-action
+}
+
+type Appointment struct {
+	ResourceType    string                   `json:"resourceType"`
+	ID              string                   `json:"id,omitempty"`
+	Meta            *Meta                    `json:"meta,omitempty"`
+	Status          string                   `json:"status"`
+	Description     string                   `json:"description,omitempty"`
+	Start           string                   `json:"start,omitempty"`
+	End             string                   `json:"end,omitempty"`
+	MinutesDuration int                      `json:"minutesDuration,omitempty"`
+	Comment         string                   `json:"comment,omitempty"`
+	Participant     []AppointmentParticipant `json:"participant"`
+}
+
+func (a Appointment) Key() (string, string) { return a.ResourceType, a.ID }
+
+type Condition struct {
+	ResourceType      string            `json:"resourceType"`
+	ID                string            `json:"id,omitempty"`
+	Meta              *Meta             `json:"meta,omitempty"`
+	ClinicalStatus    *CodeableConcept  `json:"clinicalStatus,omitempty"`
+	Category          []CodeableConcept `json:"category,omitempty"`
+	Severity          *CodeableConcept  `json:"severity,omitempty"`
+	Code              CodeableConcept   `json:"code"`
+	Subject           Reference         `json:"subject"`
+	OnsetDateTime     string            `json:"onsetDateTime,omitempty"`
+	AbatementDateTime string            `json:"abatementDateTime,omitempty"`
+	Asserter          *Reference        `json:"asserter,omitempty"`
+	RecordedDate      string            `json:"recordedDate,omitempty"`
+}
+
+func (c Condition) Key() (string, string) { return c.ResourceType, c.ID }
+
+type AllergyIntolerance struct {
+	ResourceType string           `json:"resourceType"`
+	ID           string           `json:"id,omitempty"`
+	Code         *CodeableConcept `json:"code,omitempty"`
+	Patient      Reference        `json:"patient"`
+	Criticality  string           `json:"criticality,omitempty"` // low, high, unable-to-assess
+	Note         []Annotation     `json:"note,omitempty"`
+}
+
+func (a AllergyIntolerance) Key() (string, string) { return a.ResourceType, a.ID }
+
+type Observation struct {
+	ResourceType         string           `json:"resourceType"`
+	ID                   string           `json:"id,omitempty"`
+	Status               string           `json:"status,omitempty"`
+	Code                 CodeableConcept  `json:"code"`
+	Subject              Reference        `json:"subject"`
+	EffectiveDateTime    string           `json:"effectiveDateTime,omitempty"`
+	ValueQuantity        *Quantity        `json:"valueQuantity,omitempty"`
+	ValueCodeableConcept *CodeableConcept `json:"valueCodeableConcept,omitempty"`
+	ValueString          string           `json:"valueString,omitempty"`
+}
+
+func (o Observation) Key() (string, string) { return o.ResourceType, o.ID }
+
+type OperationOutcomeIssue struct {
+	Severity    string `json:"severity"` // fatal, error, warning, information
+	Code        string `json:"code"`     // e.g. not-found, invalid, forbidden
+	Diagnostics string `json:"diagnostics,omitempty"`
+}
+
+type OperationOutcome struct {
+	ResourceType string                  `json:"resourceType"`
+	Issue        []OperationOutcomeIssue `json:"issue"`
+}
+
+// NewOperationOutcome reports a single error issue.
+func NewOperationOutcome(code, diagnostics string) OperationOutcome {
+	return OperationOutcome{
+		ResourceType: "OperationOutcome",
+		Issue:        []OperationOutcomeIssue{{Severity: "error", Code: code, Diagnostics: diagnostics}},
+	}
+}
+
+type BundleLink struct {
+	Relation string `json:"relation"`
+	URL      string `json:"url"`
+}
+
+type BundleRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+}
+
+type BundleResponse struct {
+	Status   string            `json:"status"` // e.g. "201 Created"
+	Location string            `json:"location,omitempty"`
+	Outcome  *OperationOutcome `json:"outcome,omitempty"`
+}
+
+type BundleEntry struct {
+	FullURL  string          `json:"fullUrl,omitempty"`
+	Resource Resource        `json:"resource,omitempty"`
+	Request  *BundleRequest  `json:"request,omitempty"`
+	Response *BundleResponse `json:"response,omitempty"`
+}
+
+// RawBundle is an incoming bundle whose resources are decoded one at a time.
+type RawBundle struct {
+	ResourceType string           `json:"resourceType"`
+	Type         string           `json:"type"`
+	Entry        []RawBundleEntry `json:"entry"`
+}
+
+type RawBundleEntry struct {
+	FullURL  string          `json:"fullUrl,omitempty"`
+	Resource json.RawMessage `json:"resource"`
+	Request  *BundleRequest  `json:"request,omitempty"`
+}
+
+// ResourceTypeOf reads the resourceType of an encoded resource.
+func ResourceTypeOf(raw json.RawMessage) string {
+	var head struct {
+		ResourceType string `json:"resourceType"`
+	}
+	if err := json.Unmarshal(raw, &head); err != nil {
+		return ""
+	}
+	return head.ResourceType
+}
+
+type Bundle struct {
+	ResourceType string        `json:"resourceType"`
+	Type         string        `json:"type"` // collection, searchset, ...
+	Timestamp    string        `json:"timestamp,omitempty"`
+	Total        *int          `json:"total,omitempty"`
+	Link         []BundleLink  `json:"link,omitempty"`
+	Entry        []BundleEntry `json:"entry"`
+}
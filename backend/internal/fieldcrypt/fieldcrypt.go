@@ -0,0 +1,251 @@
+// Package fieldcrypt provides application-level envelope encryption for
+// sensitive columns. Every value is encrypted with its own random data key,
+// which is itself wrapped by a master key held by a KeyProvider.
+//
+// Columns opt in with the GORM tag `serializer:encrypted`. Values that were
+// written before encryption was enabled are read back as plaintext and
+// encrypted on the next write or rotation run.
+package fieldcrypt
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm/schema"
+)
+
+const prefix = "enc:v1:"
+
+var (
+	mu       sync.RWMutex
+	provider KeyProvider
+)
+
+func init() {
+	schema.RegisterSerializer("encrypted", Serializer{})
+}
+
+// Init configures the process-wide key provider from the environment:
+// FIELD_ENCRYPTION_KEYS (plus FIELD_ENCRYPTION_ACTIVE_KEY and FIELD_BLIND_INDEX_KEY)
+// selects static master keys, otherwise a LocalKMS key file is used at
+// FIELD_ENCRYPTION_KMS_FILE (default ./private/kms.json).
+func Init() error {
+	if spec := os.Getenv("FIELD_ENCRYPTION_KEYS"); spec != "" {
+		indexKey, err := base64.StdEncoding.DecodeString(os.Getenv("FIELD_BLIND_INDEX_KEY"))
+		if err != nil {
+			return fmt.Errorf("decode FIELD_BLIND_INDEX_KEY: %w", err)
+		}
+		keyring, err := NewStaticKeyring(spec, os.Getenv("FIELD_ENCRYPTION_ACTIVE_KEY"), indexKey)
+		if err != nil {
+			return err
+		}
+		SetProvider(keyring)
+		return nil
+	}
+
+	path := os.Getenv("FIELD_ENCRYPTION_KMS_FILE")
+	if path == "" {
+		path = "./private/kms.json"
+	}
+	kms, err := OpenLocalKMS(path)
+	if err != nil {
+		return err
+	}
+	SetProvider(kms)
+	return nil
+}
+
+// SetProvider replaces the process-wide key provider.
+func SetProvider(p KeyProvider) {
+	mu.Lock()
+	defer mu.Unlock()
+	provider = p
+}
+
+// Provider returns the configured key provider.
+func Provider() (KeyProvider, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if provider == nil {
+		return nil, errors.New("field encryption is not initialised")
+	}
+	return provider, nil
+}
+
+// IsEncrypted reports whether value is in the envelope format.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// Encrypt seals plaintext under a fresh data key. Empty strings stay empty.
+func Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	p, err := Provider()
+	if err != nil {
+		return "", err
+	}
+
+	dataKey := randomBytes(32)
+	ciphertext, err := seal(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	keyID, wrapped, err := p.WrapKey(dataKey)
+	if err != nil {
+		return "", fmt.Errorf("wrap data key: %w", err)
+	}
+	return envelope(keyID, wrapped, ciphertext), nil
+}
+
+// Decrypt opens an envelope. Values without the envelope prefix are returned unchanged.
+func Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	p, err := Provider()
+	if err != nil {
+		return "", err
+	}
+
+	keyID, wrapped, ciphertext, err := parseEnvelope(value)
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := p.UnwrapKey(keyID, wrapped)
+	if err != nil {
+		return "", fmt.Errorf("unwrap data key: %w", err)
+	}
+	plaintext, err := open(dataKey, ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("decrypt value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// Rewrap brings a stored value up to date with the active master key. Plaintext
+// is encrypted; envelopes under an older key get their data key re-wrapped, or
+// with full set are re-encrypted under a new data key. It reports whether the value changed.
+func Rewrap(value string, full bool) (string, bool, error) {
+	if value == "" {
+		return value, false, nil
+	}
+	if !IsEncrypted(value) {
+		encrypted, err := Encrypt(value)
+		return encrypted, err == nil, err
+	}
+
+	p, err := Provider()
+	if err != nil {
+		return "", false, err
+	}
+	keyID, wrapped, ciphertext, err := parseEnvelope(value)
+	if err != nil {
+		return "", false, err
+	}
+	if keyID == p.ActiveKeyID() && !full {
+		return value, false, nil
+	}
+
+	if full {
+		plaintext, err := Decrypt(value)
+		if err != nil {
+			return "", false, err
+		}
+		encrypted, err := Encrypt(plaintext)
+		return encrypted, err == nil, err
+	}
+
+	dataKey, err := p.UnwrapKey(keyID, wrapped)
+	if err != nil {
+		return "", false, fmt.Errorf("unwrap data key: %w", err)
+	}
+	newKeyID, rewrapped, err := p.WrapKey(dataKey)
+	if err != nil {
+		return "", false, fmt.Errorf("wrap data key: %w", err)
+	}
+	return envelope(newKeyID, rewrapped, ciphertext), true, nil
+}
+
+// BlindIndex returns a keyed hash of the normalised value so encrypted columns
+// can still be matched for equality. Empty values produce an empty index.
+func BlindIndex(value string) (string, error) {
+	normalized := strings.Join(strings.Fields(strings.ToLower(value)), " ")
+	if normalized == "" {
+		return "", nil
+	}
+	p, err := Provider()
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, p.IndexKey())
+	mac.Write([]byte(normalized))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func envelope(keyID string, wrapped, ciphertext []byte) string {
+	enc := base64.RawURLEncoding
+	return prefix + keyID + ":" + enc.EncodeToString(wrapped) + ":" + enc.EncodeToString(ciphertext)
+}
+
+func parseEnvelope(value string) (string, []byte, []byte, error) {
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return "", nil, nil, errors.New("malformed encrypted value")
+	}
+	enc := base64.RawURLEncoding
+	wrapped, err := enc.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("decode wrapped key: %w", err)
+	}
+	ciphertext, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("decode ciphertext: %w", err)
+	}
+	return parts[0], wrapped, ciphertext, nil
+}
+
+// Serializer is the GORM serializer registered as "encrypted" for string fields.
+//
+// GORM only runs serializers (and model hooks such as blind-index updates)
+// when a model struct is written. Update encrypted columns with
+// tx.Model(m).Select(fields...).Updates(m), never with a map or
+// UpdateColumn, which would store the plaintext.
+type Serializer struct{}
+
+func (Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var stored string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		stored = v
+	case []byte:
+		stored = string(v)
+	default:
+		return fmt.Errorf("fieldcrypt: unsupported column value %T", dbValue)
+	}
+
+	plaintext, err := Decrypt(stored)
+	if err != nil {
+		return fmt.Errorf("fieldcrypt: %s: %w", field.Name, err)
+	}
+	return field.Set(ctx, dst, plaintext)
+}
+
+func (Serializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	plaintext, ok := fieldValue.(string)
+	if !ok {
+		return nil, fmt.Errorf("fieldcrypt: %s must be a string", field.Name)
+	}
+	return Encrypt(plaintext)
+}
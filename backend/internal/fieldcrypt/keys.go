@@ -0,0 +1,292 @@
+package fieldcrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyProvider wraps and unwraps per-value data keys with master keys it never
+// hands out. It is the seam for plugging in a real KMS.
+type KeyProvider interface {
+	ActiveKeyID() string
+	WrapKey(dataKey []byte) (keyID string, wrapped []byte, err error)
+	UnwrapKey(keyID string, wrapped []byte) ([]byte, error)
+	// IndexKey is the HMAC key for blind indexes. It must survive master key rotation.
+	IndexKey() []byte
+}
+
+// StaticKeyring holds master keys supplied through configuration.
+type StaticKeyring struct {
+	active   string
+	keys     map[string][]byte
+	indexKey []byte
+}
+
+// NewStaticKeyring parses keys in the form "id1:base64key,id2:base64key".
+// Keys must be 32 bytes (AES-256) and ids may not contain ':' or ','.
+func NewStaticKeyring(spec, active string, indexKey []byte) (*StaticKeyring, error) {
+	keys := map[string][]byte{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(part, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("invalid key entry %q", part)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("decode key %s: %w", id, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("key %s must be 32 bytes", id)
+		}
+		keys[id] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no master keys configured")
+	}
+	if active == "" {
+		ids := make([]string, 0, len(keys))
+		for id := range keys {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		active = ids[len(ids)-1]
+	}
+	if _, ok := keys[active]; !ok {
+		return nil, fmt.Errorf("active key %s is not configured", active)
+	}
+	if len(indexKey) < 32 {
+		return nil, errors.New("blind index key must be at least 32 bytes")
+	}
+	return &StaticKeyring{active: active, keys: keys, indexKey: indexKey}, nil
+}
+
+func (k *StaticKeyring) ActiveKeyID() string { return k.active }
+
+func (k *StaticKeyring) WrapKey(dataKey []byte) (string, []byte, error) {
+	wrapped, err := seal(k.keys[k.active], dataKey)
+	return k.active, wrapped, err
+}
+
+func (k *StaticKeyring) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	key, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown master key %s", keyID)
+	}
+	return open(key, wrapped)
+}
+
+func (k *StaticKeyring) IndexKey() []byte { return k.indexKey }
+
+// LocalKMS is a file-backed stand-in for a key management service, meant for
+// development and single-node deployments. It creates its key file on first use.
+type LocalKMS struct {
+	mu      sync.RWMutex
+	path    string
+	state   localKMSState
+	modTime time.Time // of the key file when it was last read
+}
+
+type localKMSState struct {
+	Active   string            `json:"active"`
+	Keys     map[string][]byte `json:"keys"`
+	IndexKey []byte            `json:"indexKey"`
+}
+
+// OpenLocalKMS loads the key file at path, creating it with a fresh key if missing.
+func OpenLocalKMS(path string) (*LocalKMS, error) {
+	kms := &LocalKMS{path: path}
+	state, modTime, err := readLocalKMS(path)
+	if errors.Is(err, os.ErrNotExist) {
+		kms.state = localKMSState{Keys: map[string][]byte{}, IndexKey: randomBytes(32)}
+		if _, err := kms.Rotate(); err != nil {
+			return nil, err
+		}
+		return kms, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	kms.state, kms.modTime = state, modTime
+	return kms, nil
+}
+
+func readLocalKMS(path string) (localKMSState, time.Time, error) {
+	var state localKMSState
+	info, err := os.Stat(path)
+	if err != nil {
+		return state, time.Time{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return state, time.Time{}, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, time.Time{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if _, ok := state.Keys[state.Active]; !ok || len(state.IndexKey) == 0 {
+		return state, time.Time{}, fmt.Errorf("%s is missing its active or index key", path)
+	}
+	return state, info.ModTime(), nil
+}
+
+// Reload rereads the key file, picking up a rotation made by another process
+// such as cmd/rotatekeys. It reports whether the file had changed. A file
+// whose blind index key differs is refused, since every index would break.
+func (k *LocalKMS) Reload() (bool, error) {
+	info, err := os.Stat(k.path)
+	if err != nil {
+		return false, err
+	}
+	k.mu.RLock()
+	unchanged := info.ModTime().Equal(k.modTime)
+	k.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	state, modTime, err := readLocalKMS(k.path)
+	if err != nil {
+		return false, err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if !bytes.Equal(state.IndexKey, k.state.IndexKey) {
+		return false, fmt.Errorf("%s has a different blind index key", k.path)
+	}
+	k.state, k.modTime = state, modTime
+	return true, nil
+}
+
+// Rotate adds a new master key and makes it active. Existing keys are kept so
+// older values can still be unwrapped until they are re-encrypted.
+func (k *LocalKMS) Rotate() (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	id := fmt.Sprintf("local-%s-%x", time.Now().UTC().Format("20060102T150405"), randomBytes(2))
+	if _, exists := k.state.Keys[id]; exists {
+		return "", fmt.Errorf("key %s already exists", id)
+	}
+	previous := k.state.Active
+	k.state.Keys[id] = randomBytes(32)
+	k.state.Active = id
+	if err := k.save(); err != nil {
+		delete(k.state.Keys, id)
+		k.state.Active = previous
+		return "", err
+	}
+	if info, err := os.Stat(k.path); err == nil {
+		k.modTime = info.ModTime()
+	}
+	return id, nil
+}
+
+func (k *LocalKMS) ActiveKeyID() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.state.Active
+}
+
+func (k *LocalKMS) WrapKey(dataKey []byte) (string, []byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	wrapped, err := seal(k.state.Keys[k.state.Active], dataKey)
+	return k.state.Active, wrapped, err
+}
+
+// UnwrapKey rereads the key file once when keyID is unknown, so values
+// written after a rotation by another process can still be read.
+func (k *LocalKMS) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	key, ok := k.key(keyID)
+	if !ok {
+		if _, err := k.Reload(); err != nil {
+			return nil, fmt.Errorf("unknown master key %s: reload key file: %w", keyID, err)
+		}
+		if key, ok = k.key(keyID); !ok {
+			return nil, fmt.Errorf("unknown master key %s", keyID)
+		}
+	}
+	return open(key, wrapped)
+}
+
+func (k *LocalKMS) key(keyID string) ([]byte, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.state.Keys[keyID]
+	return key, ok
+}
+
+func (k *LocalKMS) IndexKey() []byte {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.state.IndexKey
+}
+
+func (k *LocalKMS) save() error {
+	if err := os.MkdirAll(filepath.Dir(k.path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(k.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := k.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, k.path)
+}
+
+// seal encrypts plaintext with AES-256-GCM, prefixing the random nonce.
+func seal(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := randomBytes(gcm.NonceSize())
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key, sealed []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func randomBytes(n int) []byte {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		panic(fmt.Sprintf("fieldcrypt: read random bytes: %v", err))
+	}
+	return buf
+}
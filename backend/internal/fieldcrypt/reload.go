@@ -0,0 +1,37 @@
+package fieldcrypt
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// ReloadOnHangup rereads the local KMS key file whenever the process receives
+// SIGHUP, so that values are wrapped under a newly rotated master key without
+// a restart. Static keyrings come from the environment and are not reloaded.
+func ReloadOnHangup() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for range signals {
+			p, err := Provider()
+			if err != nil {
+				log.Printf("fieldcrypt: reload: %v", err)
+				continue
+			}
+			kms, ok := p.(*LocalKMS)
+			if !ok {
+				log.Printf("fieldcrypt: SIGHUP ignored; static master keys are only read at start")
+				continue
+			}
+			changed, err := kms.Reload()
+			switch {
+			case err != nil:
+				log.Printf("fieldcrypt: failed to reload key file: %v", err)
+			case changed:
+				log.Printf("fieldcrypt: reloaded key file, active master key %s", kms.ActiveKeyID())
+			}
+		}
+	}()
+}
@@ -0,0 +1,72 @@
+package fieldcrypt
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Column names an encrypted column to bring up to date during rotation.
+type Column struct {
+	Table  string
+	Column string
+}
+
+// RotateResult counts the values touched per column.
+type RotateResult struct {
+	Column  Column `json:"column"`
+	Scanned int    `json:"scanned"`
+	Updated int    `json:"updated"`
+}
+
+// RotateColumns re-wraps (or with full, re-encrypts) every value in the given
+// columns under the active master key, in batches keyed by id. Rows are
+// updated with raw SQL so that model hooks and serializers are bypassed.
+func RotateColumns(db *gorm.DB, columns []Column, full bool) ([]RotateResult, error) {
+	const batchSize = 500
+
+	results := make([]RotateResult, 0, len(columns))
+	for _, col := range columns {
+		result := RotateResult{Column: col}
+		var lastID uint
+		for {
+			var rows []struct {
+				ID    uint
+				Value *string
+			}
+			if err := db.Table(col.Table).
+				Select(fmt.Sprintf("id, %s AS value", col.Column)).
+				Where("id > ?", lastID).
+				Order("id ASC").
+				Limit(batchSize).
+				Scan(&rows).Error; err != nil {
+				return results, fmt.Errorf("scan %s.%s: %w", col.Table, col.Column, err)
+			}
+
+			for _, row := range rows {
+				lastID = row.ID
+				result.Scanned++
+				if row.Value == nil {
+					continue
+				}
+				updated, changed, err := Rewrap(*row.Value, full)
+				if err != nil {
+					return results, fmt.Errorf("%s.%s id %d: %w", col.Table, col.Column, row.ID, err)
+				}
+				if !changed {
+					continue
+				}
+				if err := db.Exec(fmt.Sprintf("UPDATE %s SET %s = ? WHERE id = ?", col.Table, col.Column), updated, row.ID).Error; err != nil {
+					return results, fmt.Errorf("update %s.%s id %d: %w", col.Table, col.Column, row.ID, err)
+				}
+				result.Updated++
+			}
+
+			if len(rows) < batchSize {
+				break
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
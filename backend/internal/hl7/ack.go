@@ -0,0 +1,64 @@
+package hl7
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Acknowledgement codes for MSA-1.
+const (
+	AckAccept = "AA" // message processed
+	AckError  = "AE" // processing failed; the sender may retry
+	AckReject = "AR" // message rejected as invalid or unsupported
+)
+
+// Ack builds the acknowledgement for msg. When msg could not be parsed it may
+// be nil, and a generic header is used instead of echoing the sender's.
+func Ack(msg *Message, code, text string) []byte {
+	d := DefaultDelimiters
+	var header *Segment
+	event, controlID := "", ""
+	if msg != nil {
+		d = msg.Delimiters
+		header = msg.Header()
+		_, event = msg.Type()
+		controlID = msg.ControlID()
+	}
+
+	messageType := "ACK"
+	if event != "" {
+		messageType = "ACK" + string(d.Component) + event + string(d.Component) + "ACK"
+	}
+	version := header.Field(12)
+	if version == "" {
+		version = "2.5"
+	}
+	processingID := header.Field(11)
+	if processingID == "" {
+		processingID = "P"
+	}
+
+	now := time.Now()
+	sep := string(d.Field)
+	msh := strings.Join([]string{
+		"MSH",
+		string([]byte{d.Component, d.Repetition, d.Escape, d.Subcomponent}),
+		header.Field(5), header.Field(6), // we reply as the receiving application and facility
+		header.Field(3), header.Field(4),
+		now.Format("20060102150405"),
+		"",
+		messageType,
+		fmt.Sprintf("ACK%d", now.UnixNano()),
+		processingID,
+		version,
+	}, sep)
+
+	segments := []string{msh, strings.Join([]string{"MSA", code, controlID, d.EscapeText(text)}, sep)}
+	if code != AckAccept && text != "" {
+		// ERR-4 severity and ERR-8 user message; ERR-3 is left empty because the
+		// causes are application-specific rather than HL7 table 0357 codes.
+		segments = append(segments, strings.Join([]string{"ERR", "", "", "", "E", "", "", "", d.EscapeText(text)}, sep))
+	}
+	return []byte(strings.Join(segments, "\r") + "\r")
+}
@@ -0,0 +1,197 @@
+// Package hl7 parses HL7 v2 messages, builds acknowledgements and speaks the
+// MLLP framing used to exchange them over TCP.
+package hl7
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrEmptyMessage = errors.New("hl7: empty message")
+	ErrNoHeader     = errors.New("hl7: message does not start with an MSH segment")
+)
+
+// Delimiters are the separator characters declared in MSH-1 and MSH-2.
+type Delimiters struct {
+	Field        byte
+	Component    byte
+	Repetition   byte
+	Escape       byte
+	Subcomponent byte
+}
+
+// DefaultDelimiters are the conventional |^~\& separators.
+var DefaultDelimiters = Delimiters{Field: '|', Component: '^', Repetition: '~', Escape: '\\', Subcomponent: '&'}
+
+// Segment is one line of a message. Fields keep their escape sequences until
+// a component is read.
+type Segment struct {
+	Name   string
+	fields []string
+	delims Delimiters
+}
+
+// Message is a parsed HL7 v2 message.
+type Message struct {
+	Delimiters Delimiters
+	Segments   []*Segment
+}
+
+// Parse splits a message into segments. Segments may be terminated by CR, LF or CRLF.
+func Parse(data []byte) (*Message, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\r")
+	text = strings.ReplaceAll(text, "\n", "\r")
+	text = strings.Trim(text, "\r \t\x00")
+	if text == "" {
+		return nil, ErrEmptyMessage
+	}
+	if !strings.HasPrefix(text, "MSH") || len(text) < 8 {
+		return nil, ErrNoHeader
+	}
+
+	delims := Delimiters{
+		Field:        text[3],
+		Component:    text[4],
+		Repetition:   text[5],
+		Escape:       text[6],
+		Subcomponent: text[7],
+	}
+
+	message := &Message{Delimiters: delims}
+	for _, line := range strings.Split(text, "\r") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		parts := strings.Split(line, string(delims.Field))
+		segment := &Segment{Name: parts[0], delims: delims}
+		if segment.Name == "MSH" {
+			// MSH-1 is the field separator itself, so MSH field numbers are shifted by one.
+			segment.fields = append([]string{"MSH", string(delims.Field)}, parts[1:]...)
+		} else {
+			segment.fields = parts
+		}
+		message.Segments = append(message.Segments, segment)
+	}
+	return message, nil
+}
+
+// Segment returns the first segment with the given name, or nil.
+func (m *Message) Segment(name string) *Segment {
+	for _, segment := range m.Segments {
+		if segment.Name == name {
+			return segment
+		}
+	}
+	return nil
+}
+
+// Header returns the MSH segment.
+func (m *Message) Header() *Segment {
+	return m.Segment("MSH")
+}
+
+// Type returns the message code and trigger event from MSH-9, e.g. "ADT", "A04".
+func (m *Message) Type() (string, string) {
+	header := m.Header()
+	return header.Component(9, 1), header.Component(9, 2)
+}
+
+// ControlID returns MSH-10.
+func (m *Message) ControlID() string {
+	return m.Header().Component(10, 1)
+}
+
+// SendingApplication returns MSH-3.
+func (m *Message) SendingApplication() string {
+	return m.Header().Component(3, 1)
+}
+
+// SendingFacility returns MSH-4.
+func (m *Message) SendingFacility() string {
+	return m.Header().Component(4, 1)
+}
+
+// Field returns field n (1-based) with escape sequences intact, or "" if absent.
+func (s *Segment) Field(n int) string {
+	if s == nil || n < 1 || n >= len(s.fields) {
+		return ""
+	}
+	return s.fields[n]
+}
+
+// Repetitions splits field n into its repetitions.
+func (s *Segment) Repetitions(n int) []string {
+	value := s.Field(n)
+	if value == "" {
+		return nil
+	}
+	if s.Name == "MSH" && n <= 2 {
+		return []string{value}
+	}
+	return strings.Split(value, string(s.delims.Repetition))
+}
+
+// Component returns component c (1-based) of the first repetition of field n, unescaped.
+func (s *Segment) Component(n, c int) string {
+	reps := s.Repetitions(n)
+	if len(reps) == 0 {
+		return ""
+	}
+	return s.delims.ComponentOf(reps[0], c)
+}
+
+// ComponentOf returns component c (1-based) of a single field repetition, unescaped.
+func (d Delimiters) ComponentOf(value string, c int) string {
+	components := strings.Split(value, string(d.Component))
+	if c < 1 || c > len(components) {
+		return ""
+	}
+	return d.Unescape(components[c-1])
+}
+
+// SubcomponentOf returns subcomponent sc (1-based) of component c, unescaped.
+func (d Delimiters) SubcomponentOf(value string, c, sc int) string {
+	components := strings.Split(value, string(d.Component))
+	if c < 1 || c > len(components) {
+		return ""
+	}
+	subcomponents := strings.Split(components[c-1], string(d.Subcomponent))
+	if sc < 1 || sc > len(subcomponents) {
+		return ""
+	}
+	return d.Unescape(subcomponents[sc-1])
+}
+
+// Unescape replaces the standard escape sequences with the characters they stand for.
+func (d Delimiters) Unescape(value string) string {
+	escape := string(d.Escape)
+	if !strings.Contains(value, escape) {
+		return value
+	}
+	replacer := strings.NewReplacer(
+		escape+"F"+escape, string(d.Field),
+		escape+"S"+escape, string(d.Component),
+		escape+"T"+escape, string(d.Subcomponent),
+		escape+"R"+escape, string(d.Repetition),
+		escape+"E"+escape, escape,
+		escape+".br"+escape, "\n",
+	)
+	return replacer.Replace(value)
+}
+
+// EscapeText protects delimiter characters in free text.
+func (d Delimiters) EscapeText(value string) string {
+	escape := string(d.Escape)
+	replacer := strings.NewReplacer(
+		escape, escape+"E"+escape,
+		string(d.Field), escape+"F"+escape,
+		string(d.Component), escape+"S"+escape,
+		string(d.Subcomponent), escape+"T"+escape,
+		string(d.Repetition), escape+"R"+escape,
+		"\r", " ",
+		"\n", " ",
+	)
+	return replacer.Replace(value)
+}
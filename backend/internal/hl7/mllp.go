@@ -0,0 +1,163 @@
+package hl7
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// MLLP frame markers.
+const (
+	startBlock     = 0x0b
+	endBlock       = 0x1c
+	carriageReturn = 0x0d
+)
+
+const maxFrameSize = 1 << 20
+
+var ErrFrameTooLarge = errors.New("hl7: MLLP frame exceeds 1MB")
+
+// ReadFrame reads one MLLP-framed message, discarding anything before the start block.
+func ReadFrame(r *bufio.Reader) ([]byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b == startBlock {
+			break
+		}
+	}
+
+	var payload []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if b == endBlock {
+			// The trailing carriage return is required by the spec but tolerated if missing.
+			if next, err := r.Peek(1); err == nil && next[0] == carriageReturn {
+				r.ReadByte()
+			}
+			return payload, nil
+		}
+		if len(payload) >= maxFrameSize {
+			return nil, ErrFrameTooLarge
+		}
+		payload = append(payload, b)
+	}
+}
+
+// WriteFrame writes payload wrapped in MLLP start and end blocks.
+func WriteFrame(w io.Writer, payload []byte) error {
+	frame := make([]byte, 0, len(payload)+3)
+	frame = append(frame, startBlock)
+	frame = append(frame, payload...)
+	frame = append(frame, endBlock, carriageReturn)
+	_, err := w.Write(frame)
+	return err
+}
+
+// Handler processes one message and returns the acknowledgement to send back.
+type Handler func(message []byte, remoteAddr string) []byte
+
+// Server accepts MLLP connections and answers every message on the same connection.
+type Server struct {
+	Addr    string
+	Handler Handler
+	// IdleTimeout closes connections that send nothing for this long; zero means 5 minutes.
+	IdleTimeout time.Duration
+	// MaxConns caps the connections served at once; further ones are closed
+	// straight away. Zero means 32.
+	MaxConns int
+
+	mu       sync.Mutex
+	listener net.Listener
+}
+
+// ListenAndServe listens on Addr and serves connections until Close is called.
+func (s *Server) ListenAndServe() error {
+	listener, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+
+	maxConns := s.MaxConns
+	if maxConns == 0 {
+		maxConns = 32
+	}
+	slots := make(chan struct{}, maxConns)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		select {
+		case slots <- struct{}{}:
+			go func() {
+				defer func() { <-slots }()
+				s.serve(conn)
+			}()
+		default:
+			log.Printf("hl7: refused connection from %s: %d connections open", conn.RemoteAddr(), maxConns)
+			conn.Close()
+		}
+	}
+}
+
+// Close stops accepting connections.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer conn.Close()
+
+	idle := s.IdleTimeout
+	if idle == 0 {
+		idle = 5 * time.Minute
+	}
+	remote := conn.RemoteAddr().String()
+	reader := bufio.NewReader(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(idle))
+		payload, err := ReadFrame(reader)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("hl7: connection from %s closed: %v", remote, err)
+			}
+			return
+		}
+
+		reply := s.Handler(payload, remote)
+		conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
+		if err := WriteFrame(conn, reply); err != nil {
+			log.Printf("hl7: failed to acknowledge message from %s: %v", remote, err)
+			return
+		}
+	}
+}
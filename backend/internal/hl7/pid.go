@@ -0,0 +1,114 @@
+package hl7
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrNoPID = errors.New("hl7: message has no PID segment")
+
+// PatientIdentifier is one CX entry of PID-3.
+type PatientIdentifier struct {
+	Value     string
+	Authority string // assigning authority namespace or universal id
+	TypeCode  string // e.g. MR for medical record number
+}
+
+// PatientData is the demographics carried in a PID segment.
+type PatientData struct {
+	Identifiers []PatientIdentifier
+	FamilyName  string
+	GivenNames  []string
+	BirthDate   *time.Time
+	Gender      string // male, female, other or unknown; empty when not sent
+	Phone       string
+	Email       string
+}
+
+// FullName joins given and family names.
+func (p *PatientData) FullName() string {
+	parts := append(append([]string{}, p.GivenNames...), p.FamilyName)
+	return strings.TrimSpace(strings.Join(strings.Fields(strings.Join(parts, " ")), " "))
+}
+
+// ParsePID reads the patient demographics of msg.
+func ParsePID(msg *Message) (*PatientData, error) {
+	pid := msg.Segment("PID")
+	if pid == nil {
+		return nil, ErrNoPID
+	}
+	d := msg.Delimiters
+	data := &PatientData{}
+
+	for _, rep := range pid.Repetitions(3) {
+		value := d.ComponentOf(rep, 1)
+		if value == "" {
+			continue
+		}
+		authority := d.SubcomponentOf(rep, 4, 1)
+		if authority == "" {
+			authority = d.SubcomponentOf(rep, 4, 2)
+		}
+		data.Identifiers = append(data.Identifiers, PatientIdentifier{
+			Value:     value,
+			Authority: authority,
+			TypeCode:  d.ComponentOf(rep, 5),
+		})
+	}
+
+	if names := pid.Repetitions(5); len(names) > 0 {
+		name := names[0]
+		data.FamilyName = d.SubcomponentOf(name, 1, 1)
+		for _, c := range []int{2, 3} {
+			if given := d.ComponentOf(name, c); given != "" {
+				data.GivenNames = append(data.GivenNames, given)
+			}
+		}
+	}
+
+	if value := pid.Component(7, 1); value != "" {
+		birthDate, err := parseDate(value)
+		if err != nil {
+			return nil, err
+		}
+		data.BirthDate = &birthDate
+	}
+
+	switch strings.ToUpper(pid.Component(8, 1)) {
+	case "M":
+		data.Gender = "male"
+	case "F":
+		data.Gender = "female"
+	case "O", "A", "N":
+		data.Gender = "other"
+	case "U":
+		data.Gender = "unknown"
+	}
+
+	for _, rep := range pid.Repetitions(13) {
+		if email := d.ComponentOf(rep, 4); email != "" && data.Email == "" {
+			data.Email = email
+		}
+		phone := d.ComponentOf(rep, 1)
+		if phone == "" {
+			phone = strings.TrimSpace(d.ComponentOf(rep, 6) + " " + d.ComponentOf(rep, 7))
+		}
+		if phone != "" && data.Phone == "" && d.ComponentOf(rep, 3) != "Internet" {
+			data.Phone = phone
+		}
+	}
+	return data, nil
+}
+
+// parseDate reads the date part of an HL7 DTM/TS value such as 19800412 or 198004121530.
+func parseDate(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, errors.New("hl7: date of birth " + value + " is not YYYYMMDD")
+	}
+	parsed, err := time.Parse("20060102", value[:8])
+	if err != nil {
+		return time.Time{}, errors.New("hl7: date of birth " + value + " is not YYYYMMDD")
+	}
+	return parsed, nil
+}
@@ -0,0 +1,61 @@
+package i18n
+
+import (
+	"medapp/internal/db"
+	"medapp/internal/models"
+)
+
+// LocalizeDiseases replaces the English name and description of each disease
+// with its translation into lang. Each field falls back along Chain(lang)
+// separately, ending with the English catalog text.
+func LocalizeDiseases(lang string, diseases ...*models.Disease) error {
+	chain := Chain(lang)
+	languages := chain[:len(chain)-1] // English is the base row
+	if len(languages) == 0 || len(diseases) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(diseases))
+	for _, disease := range diseases {
+		ids = append(ids, disease.ID)
+	}
+	var translations []models.DiseaseTranslation
+	if err := db.DB.Where("disease_id IN ? AND lang IN ?", ids, languages).Find(&translations).Error; err != nil {
+		return err
+	}
+	if len(translations) == 0 {
+		return nil
+	}
+
+	byDisease := make(map[uint]map[string]*models.DiseaseTranslation, len(translations))
+	for i := range translations {
+		t := &translations[i]
+		if byDisease[t.DiseaseID] == nil {
+			byDisease[t.DiseaseID] = map[string]*models.DiseaseTranslation{}
+		}
+		byDisease[t.DiseaseID][t.Lang] = t
+	}
+
+	for _, disease := range diseases {
+		available := byDisease[disease.ID]
+		if available == nil {
+			continue
+		}
+		nameSet, descriptionSet := false, false
+		for _, l := range languages {
+			t := available[l]
+			if t == nil {
+				continue
+			}
+			if !nameSet && t.Name != "" {
+				disease.Name = t.Name
+				nameSet = true
+			}
+			if !descriptionSet && t.Description != "" {
+				disease.Description = t.Description
+				descriptionSet = true
+			}
+		}
+	}
+	return nil
+}
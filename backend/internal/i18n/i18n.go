@@ -0,0 +1,109 @@
+// Package i18n picks the response language from a request and localizes
+// catalog entries, falling back along a fixed chain when a translation is missing.
+package i18n
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Supported languages. English is the language of the base catalog columns.
+const (
+	English = "en"
+	Russian = "ru"
+	Kazakh  = "kk"
+)
+
+// Default is used when the request names no supported language.
+const Default = English
+
+var supported = map[string]bool{English: true, Russian: true, Kazakh: true}
+
+// fallbacks lists the languages tried after the requested one. Kazakh readers
+// are generally also fluent in Russian, so Russian comes before English.
+var fallbacks = map[string][]string{
+	Kazakh:  {Russian, English},
+	Russian: {English},
+	English: {},
+}
+
+// Supported lists the language codes translations may be stored in.
+func Supported() []string {
+	return []string{English, Russian, Kazakh}
+}
+
+// IsSupported reports whether lang is one of the supported language codes.
+func IsSupported(lang string) bool {
+	return supported[lang]
+}
+
+// Chain returns lang followed by its fallbacks, always ending in English.
+func Chain(lang string) []string {
+	if !supported[lang] {
+		lang = Default
+	}
+	return append([]string{lang}, fallbacks[lang]...)
+}
+
+// Normalize maps a language tag such as "ru-RU" or "KK" to a supported code, or "".
+func Normalize(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	if tag == "kz" { // common mistake for the Kazakh language code
+		tag = Kazakh
+	}
+	if supported[tag] {
+		return tag
+	}
+	return ""
+}
+
+// Parse picks the best supported language from an Accept-Language header.
+func Parse(header string) string {
+	type candidate struct {
+		lang string
+		q    float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		lang := Normalize(fields[0])
+		if lang == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.TrimSpace(key) == "q" {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{lang: lang, q: q})
+		}
+	}
+	if len(candidates) == 0 {
+		return Default
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].lang
+}
+
+// Language returns the language for the request: the lang query parameter
+// when it names a supported language, otherwise Accept-Language. It also
+// sets the Content-Language response header.
+func Language(c *gin.Context) string {
+	lang := Normalize(c.Query("lang"))
+	if lang == "" {
+		lang = Parse(c.GetHeader("Accept-Language"))
+	}
+	c.Header("Content-Language", lang)
+	return lang
+}
@@ -0,0 +1,237 @@
+package i18n
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"medapp/internal/icd10"
+	"medapp/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrUnsupportedLanguage = errors.New("translations are kept for ru and kk only")
+
+// csvHeader is the column layout of translation files. The source columns
+// carry the English text for the translator and are ignored on import.
+var csvHeader = []string{"disease_id", "code_system", "code", "lang", "source_name", "source_description", "name", "description"}
+
+// TranslationRow is one disease in a translation file.
+type TranslationRow struct {
+	DiseaseID         uint   `json:"diseaseId"`
+	CodeSystem        string `json:"codeSystem,omitempty"`
+	Code              string `json:"code,omitempty"`
+	Lang              string `json:"lang"`
+	SourceName        string `json:"sourceName,omitempty"`
+	SourceDescription string `json:"sourceDescription,omitempty"`
+	Name              string `json:"name"`
+	Description       string `json:"description"`
+}
+
+// ImportResult summarises a translation import.
+type ImportResult struct {
+	Saved   int      `json:"saved"`
+	Skipped int      `json:"skipped"` // rows left untranslated
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// ExportRows lists every active disease with its current translation into
+// lang, leaving name and description empty where none exists yet.
+func ExportRows(tx *gorm.DB, lang string) ([]TranslationRow, error) {
+	if !IsSupported(lang) || lang == English {
+		return nil, ErrUnsupportedLanguage
+	}
+	var diseases []models.Disease
+	if err := tx.Where("retired = ?", false).Order("code ASC, name ASC").Find(&diseases).Error; err != nil {
+		return nil, err
+	}
+	var translations []models.DiseaseTranslation
+	if err := tx.Where("lang = ?", lang).Find(&translations).Error; err != nil {
+		return nil, err
+	}
+	byDisease := make(map[uint]*models.DiseaseTranslation, len(translations))
+	for i := range translations {
+		byDisease[translations[i].DiseaseID] = &translations[i]
+	}
+
+	rows := make([]TranslationRow, 0, len(diseases))
+	for _, disease := range diseases {
+		row := TranslationRow{
+			DiseaseID:         disease.ID,
+			CodeSystem:        disease.CodeSystem,
+			Code:              disease.Code,
+			Lang:              lang,
+			SourceName:        disease.Name,
+			SourceDescription: disease.Description,
+		}
+		if t := byDisease[disease.ID]; t != nil {
+			row.Name, row.Description = t.Name, t.Description
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// WriteCSV writes rows in the translation file layout.
+func WriteCSV(w io.Writer, rows []TranslationRow) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := writer.Write([]string{
+			strconv.FormatUint(uint64(row.DiseaseID), 10),
+			row.CodeSystem,
+			row.Code,
+			row.Lang,
+			row.SourceName,
+			row.SourceDescription,
+			row.Name,
+			row.Description,
+		}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// ParseCSV reads a translation file. Columns are matched by header name, so
+// translators may drop or reorder the source columns.
+func ParseCSV(r io.Reader) ([]TranslationRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, errors.New("csv must have a name column")
+	}
+	if _, ok := columns["disease_id"]; !ok {
+		if _, ok := columns["code"]; !ok {
+			return nil, errors.New("csv must have a disease_id or code column")
+		}
+	}
+	column := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var rows []TranslationRow
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := TranslationRow{
+			CodeSystem:  column(record, "code_system"),
+			Code:        column(record, "code"),
+			Lang:        column(record, "lang"),
+			Name:        column(record, "name"),
+			Description: column(record, "description"),
+		}
+		if id := column(record, "disease_id"); id != "" {
+			parsed, err := strconv.ParseUint(id, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid disease_id %q", line, id)
+			}
+			row.DiseaseID = uint(parsed)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// ParseJSON reads a JSON array of translation rows.
+func ParseJSON(r io.Reader) ([]TranslationRow, error) {
+	var rows []TranslationRow
+	if err := json.NewDecoder(r).Decode(&rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// ImportRows saves the translations in rows, identifying diseases by id or by
+// code system and code. Rows without a name are skipped, and defaultLang is
+// used for rows that do not name a language. English rows are rejected since
+// the English text is edited on the disease itself.
+func ImportRows(tx *gorm.DB, rows []TranslationRow, defaultLang string) (*ImportResult, error) {
+	result := &ImportResult{}
+	for i, row := range rows {
+		lang := Normalize(row.Lang)
+		if row.Lang == "" {
+			lang = Normalize(defaultLang)
+		}
+		if lang == "" || lang == English {
+			result.Errors = append(result.Errors, fmt.Sprintf("row %d: %s", i+1, ErrUnsupportedLanguage))
+			continue
+		}
+		if strings.TrimSpace(row.Name) == "" {
+			result.Skipped++
+			continue
+		}
+
+		diseaseID := row.DiseaseID
+		if diseaseID == 0 {
+			system := row.CodeSystem
+			if system == "" {
+				system = models.CodeSystemICD10
+			}
+			var disease models.Disease
+			err := tx.Select("id").Where("code_system = ? AND code = ?", system, icd10.NormalizeCode(row.Code)).First(&disease).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				result.Errors = append(result.Errors, fmt.Sprintf("row %d: unknown code %s", i+1, row.Code))
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			diseaseID = disease.ID
+		} else {
+			var count int64
+			if err := tx.Model(&models.Disease{}).Where("id = ?", diseaseID).Count(&count).Error; err != nil {
+				return nil, err
+			}
+			if count == 0 {
+				result.Errors = append(result.Errors, fmt.Sprintf("row %d: unknown disease id %d", i+1, diseaseID))
+				continue
+			}
+		}
+
+		if err := Save(tx, &models.DiseaseTranslation{
+			DiseaseID:   diseaseID,
+			Lang:        lang,
+			Name:        strings.TrimSpace(row.Name),
+			Description: strings.TrimSpace(row.Description),
+		}); err != nil {
+			return nil, err
+		}
+		result.Saved++
+	}
+	return result, nil
+}
+
+// Save creates or replaces the translation of a disease into a language.
+func Save(tx *gorm.DB, translation *models.DiseaseTranslation) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "disease_id"}, {Name: "lang"}},
+		DoUpdates: clause.AssignmentColumns([]string{"name", "description", "updated_at"}),
+	}).Create(translation).Error
+}
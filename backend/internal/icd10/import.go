@@ -0,0 +1,214 @@
+package icd10
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"medapp/internal/models"
+	"medapp/internal/textutil"
+
+	"gorm.io/gorm"
+)
+
+const batchSize = 500
+
+// Options controls an import.
+type Options struct {
+	CodeSystem string // defaults to ICD-10
+	// RetireMissing retires catalog codes of the same system that are not in the file.
+	RetireMissing bool
+}
+
+// Result summarises an import.
+type Result struct {
+	Created        int      `json:"created"`
+	Updated        int      `json:"updated"`
+	Unchanged      int      `json:"unchanged"`
+	Retired        int      `json:"retired"`
+	Skipped        []string `json:"skipped,omitempty"`        // codes that could not be stored
+	MissingParents []string `json:"missingParents,omitempty"` // parent codes not found in the file or catalog
+}
+
+// Import creates or updates a disease for every entry, matching on code within
+// the code system, then links each disease to its parent code. Entries later
+// in the slice win over earlier duplicates.
+func Import(tx *gorm.DB, entries []Entry, opts Options) (*Result, error) {
+	system := opts.CodeSystem
+	if system == "" {
+		system = models.CodeSystemICD10
+	}
+	result := &Result{}
+
+	byCode := make(map[string]Entry, len(entries))
+	order := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if len(entry.Code) > 16 {
+			result.Skipped = append(result.Skipped, entry.Code)
+			continue
+		}
+		if _, seen := byCode[entry.Code]; !seen {
+			order = append(order, entry.Code)
+		}
+		byCode[entry.Code] = entry
+	}
+	inheritCategories(byCode)
+
+	var existing []models.Disease
+	if err := tx.Where("code_system = ? AND code <> ''", system).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	known := make(map[string]*models.Disease, len(existing))
+	for i := range existing {
+		known[existing[i].Code] = &existing[i]
+	}
+
+	var created []models.Disease
+	for _, code := range order {
+		entry := byCode[code]
+		disease, ok := known[code]
+		if !ok {
+			created = append(created, models.Disease{
+				Code:        code,
+				CodeSystem:  system,
+				Name:        textutil.Truncate(entry.Name, 255),
+				Category:    textutil.Truncate(entry.Category, 100),
+				Description: entry.Description,
+				SnomedCode:  textutil.Truncate(entry.SnomedCode, 32),
+			})
+			continue
+		}
+
+		updates := map[string]interface{}{}
+		if name := textutil.Truncate(entry.Name, 255); name != disease.Name {
+			updates["name"] = name
+		}
+		if category := textutil.Truncate(entry.Category, 100); category != "" && category != disease.Category {
+			updates["category"] = category
+		}
+		if entry.Description != "" && entry.Description != disease.Description {
+			updates["description"] = entry.Description
+		}
+		if snomed := textutil.Truncate(entry.SnomedCode, 32); snomed != "" && snomed != disease.SnomedCode {
+			updates["snomed_code"] = snomed
+		}
+		if disease.Retired {
+			updates["retired"] = false
+			updates["retired_at"] = nil
+		}
+		if len(updates) == 0 {
+			result.Unchanged++
+			continue
+		}
+		if err := tx.Model(disease).Updates(updates).Error; err != nil {
+			return nil, fmt.Errorf("update %s: %w", code, err)
+		}
+		result.Updated++
+	}
+
+	if len(created) > 0 {
+		if err := tx.CreateInBatches(&created, batchSize).Error; err != nil {
+			return nil, fmt.Errorf("create diseases: %w", err)
+		}
+		result.Created = len(created)
+		for i := range created {
+			known[created[i].Code] = &created[i]
+		}
+	}
+
+	if err := linkParents(tx, order, byCode, known, result); err != nil {
+		return nil, err
+	}
+
+	if opts.RetireMissing {
+		var ids []uint
+		for code, disease := range known {
+			if _, inFile := byCode[code]; !inFile && !disease.Retired {
+				ids = append(ids, disease.ID)
+			}
+		}
+		now := time.Now()
+		for start := 0; start < len(ids); start += batchSize {
+			end := min(start+batchSize, len(ids))
+			if err := tx.Model(&models.Disease{}).Where("id IN ?", ids[start:end]).
+				Updates(map[string]interface{}{"retired": true, "retired_at": now}).Error; err != nil {
+				return nil, fmt.Errorf("retire diseases: %w", err)
+			}
+		}
+		result.Retired = len(ids)
+	}
+	return result, nil
+}
+
+// linkParents points each imported disease at its parent code.
+func linkParents(tx *gorm.DB, order []string, byCode map[string]Entry, known map[string]*models.Disease, result *Result) error {
+	type link struct {
+		id       uint
+		parentID *uint
+	}
+	var links []link
+	missing := map[string]bool{}
+	for _, code := range order {
+		disease := known[code]
+		var parentID *uint
+		if parentCode := byCode[code].ParentCode; parentCode != "" && parentCode != code {
+			if parent, ok := known[parentCode]; ok {
+				parentID = &parent.ID
+			} else if !missing[parentCode] {
+				missing[parentCode] = true
+				result.MissingParents = append(result.MissingParents, parentCode)
+			}
+		}
+		if !sameID(disease.ParentID, parentID) {
+			links = append(links, link{id: disease.ID, parentID: parentID})
+		}
+	}
+
+	for start := 0; start < len(links); start += batchSize {
+		batch := links[start:min(start+batchSize, len(links))]
+		values := make([]string, 0, len(batch))
+		args := make([]interface{}, 0, len(batch)*2)
+		for _, l := range batch {
+			values = append(values, "(?::bigint, ?::bigint)")
+			args = append(args, l.id, l.parentID)
+		}
+		err := tx.Exec(`UPDATE diseases AS d SET parent_id = v.parent_id
+			FROM (VALUES `+strings.Join(values, ", ")+`) AS v(id, parent_id)
+			WHERE d.id = v.id`, args...).Error
+		if err != nil {
+			return fmt.Errorf("link parent codes: %w", err)
+		}
+	}
+	return nil
+}
+
+// inheritCategories gives entries without a category the category of their
+// nearest ancestor that has one.
+func inheritCategories(byCode map[string]Entry) {
+	for code, entry := range byCode {
+		if entry.Category != "" {
+			continue
+		}
+		seen := map[string]bool{code: true}
+		for parent := entry.ParentCode; parent != "" && !seen[parent]; {
+			seen[parent] = true
+			ancestor, ok := byCode[parent]
+			if !ok {
+				break
+			}
+			if ancestor.Category != "" {
+				entry.Category = ancestor.Category
+				byCode[code] = entry
+				break
+			}
+			parent = ancestor.ParentCode
+		}
+	}
+}
+
+func sameID(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
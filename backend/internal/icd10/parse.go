@@ -0,0 +1,257 @@
+// Package icd10 reads ICD-10 classifications from CSV or ClaML files and
+// loads them into the disease catalog with their parent/child hierarchy.
+package icd10
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+var (
+	ErrMissingColumns = errors.New("csv must have code and name columns")
+	ErrNoEntries      = errors.New("file contains no ICD-10 codes")
+)
+
+// Entry is one code of the classification.
+type Entry struct {
+	Code        string
+	Name        string
+	ParentCode  string // empty for top-level codes
+	Category    string // chapter or block title, used as the disease category
+	Description string
+	SnomedCode  string
+}
+
+// ParseCSV reads a header row followed by one code per row. Recognised
+// columns are code, name (or title), and optionally parent, category,
+// description and snomed. Without a parent column the parent is derived from
+// the code, e.g. E11.65 -> E11.6 -> E11.
+func ParseCSV(r io.Reader) ([]Entry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrNoEntries
+		}
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		switch name {
+		case "title", "label", "description_short":
+			name = "name"
+		case "parent_code", "parentcode":
+			name = "parent"
+		case "snomed_code", "snomedcode", "snomed_ct":
+			name = "snomed"
+		}
+		if _, seen := columns[name]; !seen {
+			columns[name] = i
+		}
+	}
+	if _, ok := columns["code"]; !ok {
+		return nil, ErrMissingColumns
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, ErrMissingColumns
+	}
+	_, hasParent := columns["parent"]
+
+	column := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var entries []Entry
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		entry := Entry{
+			Code:        NormalizeCode(column(record, "code")),
+			Name:        column(record, "name"),
+			Category:    column(record, "category"),
+			Description: column(record, "description"),
+			SnomedCode:  column(record, "snomed"),
+		}
+		if entry.Code == "" {
+			continue
+		}
+		if entry.Name == "" {
+			return nil, fmt.Errorf("line %d: code %s has no name", line, entry.Code)
+		}
+		if hasParent {
+			entry.ParentCode = NormalizeCode(column(record, "parent"))
+		} else {
+			entry.ParentCode = ParentCode(entry.Code)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return nil, ErrNoEntries
+	}
+	return entries, nil
+}
+
+// claMLClass is a Class element of a ClaML file.
+type claMLClass struct {
+	Code       string `xml:"code,attr"`
+	Kind       string `xml:"kind,attr"`
+	SuperClass []struct {
+		Code string `xml:"code,attr"`
+	} `xml:"SuperClass"`
+	Rubric []struct {
+		Kind  string `xml:"kind,attr"`
+		Label []struct {
+			Lang  string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+			Inner string `xml:",innerxml"`
+		} `xml:"Label"`
+	} `xml:"Rubric"`
+}
+
+func (c *claMLClass) rubric(kind, lang string) string {
+	fallback := ""
+	for _, rubric := range c.Rubric {
+		if rubric.Kind != kind {
+			continue
+		}
+		for _, label := range rubric.Label {
+			text := labelText(label.Inner)
+			if lang == "" || strings.EqualFold(label.Lang, lang) {
+				return text
+			}
+			if fallback == "" {
+				fallback = text
+			}
+		}
+	}
+	return fallback
+}
+
+// ParseClaML reads the WHO ClaML distribution of ICD-10. Chapters and blocks
+// are not imported as diseases; their titles become the category of the
+// codes below them. Labels in lang are preferred when several are present.
+func ParseClaML(r io.Reader, lang string) ([]Entry, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+
+	var classes []claMLClass
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "Class" {
+			continue
+		}
+		var class claMLClass
+		if err := decoder.DecodeElement(&class, &start); err != nil {
+			return nil, err
+		}
+		classes = append(classes, class)
+	}
+
+	byCode := make(map[string]*claMLClass, len(classes))
+	for i := range classes {
+		byCode[classes[i].Code] = &classes[i]
+	}
+	superClass := func(class *claMLClass) *claMLClass {
+		if len(class.SuperClass) == 0 {
+			return nil
+		}
+		return byCode[class.SuperClass[0].Code]
+	}
+
+	var entries []Entry
+	for i := range classes {
+		class := &classes[i]
+		if class.Kind != "category" {
+			continue
+		}
+		entry := Entry{
+			Code: NormalizeCode(class.Code),
+			Name: class.rubric("preferred", lang),
+		}
+		if entry.Code == "" || entry.Name == "" {
+			continue
+		}
+		if parent := superClass(class); parent != nil && parent.Kind == "category" {
+			entry.ParentCode = NormalizeCode(parent.Code)
+		}
+		// The category is the title of the nearest chapter above the code.
+		for ancestor := superClass(class); ancestor != nil; ancestor = superClass(ancestor) {
+			if ancestor.Kind == "chapter" {
+				entry.Category = ancestor.rubric("preferred", lang)
+				break
+			}
+		}
+		entry.Description = class.rubric("definition", lang)
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return nil, ErrNoEntries
+	}
+	return entries, nil
+}
+
+// labelText flattens the mixed content of a ClaML Label.
+func labelText(inner string) string {
+	var b strings.Builder
+	inTag := false
+	for _, r := range inner {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>':
+			inTag = false
+		case !inTag:
+			b.WriteRune(r)
+		}
+	}
+	return strings.Join(strings.Fields(html.UnescapeString(b.String())), " ")
+}
+
+// NormalizeCode upper-cases a code, drops the dagger/asterisk marks and
+// trailing dash used in printed classifications, and restores the dot of
+// codes written without one (E119 -> E11.9).
+func NormalizeCode(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	code = strings.TrimRight(code, "+*†-")
+	code = strings.TrimSuffix(code, ".")
+	if len(code) > 3 && !strings.ContainsAny(code, ".-") {
+		code = code[:3] + "." + code[3:]
+	}
+	return code
+}
+
+// ParentCode derives the parent of a subcategory code by dropping its last
+// character: E11.65 -> E11.6, E11.6 -> E11. Three-character categories have no
+// parent within the catalog.
+func ParentCode(code string) string {
+	dot := strings.IndexByte(code, '.')
+	if dot < 0 {
+		return ""
+	}
+	parent := code[:len(code)-1]
+	return strings.TrimSuffix(parent, ".")
+}
@@ -0,0 +1,228 @@
+// Package medicalhistory versions patient medical info. Every change bumps the
+// record's version and stores a revision with its author, so earlier diagnoses
+// are kept and two doctors editing at once get a conflict instead of silently
+// overwriting each other. Saving also brings the problem list in line with
+// the diseases recorded.
+package medicalhistory
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"medapp/internal/models"
+	"medapp/internal/problemlist"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrVersionConflict = errors.New("medical info was changed by someone else; reload and try again")
+
+// Save stores info with info.Diseases as its complete disease list and
+// records a revision by authorID. A new record (ID 0) starts at version 1;
+// an existing one is only written if its stored version still equals
+// info.Version, which is then incremented. Run it inside a transaction.
+func Save(tx *gorm.DB, info *models.PatientMedicalInfo, authorID uint) error {
+	diseases := info.Diseases
+	if info.ID == 0 {
+		info.Version = 1
+		// A concurrent first save for the same patient hits the unique patient_id index.
+		result := tx.Omit("Diseases").Clauses(clause.OnConflict{DoNothing: true}).Create(info)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			info.ID = 0
+			return ErrVersionConflict
+		}
+	} else {
+		expected := info.Version
+		info.Version = expected + 1
+		result := tx.Model(info).Where("version = ?", expected).
+			Select("DoctorID", "Gender", "AgeGroup", "GenderIndex", "AgeGroupIndex", "Version").
+			Updates(info)
+		if result.Error != nil {
+			info.Version = expected
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			info.Version = expected
+			return ErrVersionConflict
+		}
+	}
+
+	if err := tx.Model(info).Association("Diseases").Replace(diseases); err != nil {
+		return fmt.Errorf("update diseases: %w", err)
+	}
+	info.Diseases = diseases
+	if err := problemlist.Sync(tx, info.PatientID, diseases, authorID); err != nil {
+		return fmt.Errorf("update problem list: %w", err)
+	}
+
+	revision := NewRevision(info, authorID)
+	return tx.Create(&revision).Error
+}
+
+// SetDiseases replaces the diseases on a patient's medical info, creating the
+// record if needed, and saves a new version when the set changed.
+func SetDiseases(tx *gorm.DB, patientID uint, diseases []models.Disease, authorID uint) error {
+	var info models.PatientMedicalInfo
+	err := tx.Preload("Diseases").Where("patient_id = ?", patientID).First(&info).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if len(diseases) == 0 {
+			return nil
+		}
+		info = models.PatientMedicalInfo{PatientID: patientID}
+	} else if err != nil {
+		return err
+	}
+
+	if info.ID != 0 && len(info.Diseases) == len(diseases) {
+		current := make(map[uint]bool, len(info.Diseases))
+		for _, d := range info.Diseases {
+			current[d.ID] = true
+		}
+		same := true
+		for _, d := range diseases {
+			same = same && current[d.ID]
+		}
+		if same {
+			return nil
+		}
+	}
+	info.DoctorID = authorID
+	info.Diseases = diseases
+	return Save(tx, &info, authorID)
+}
+
+// NewRevision snapshots the current state of info.
+func NewRevision(info *models.PatientMedicalInfo, authorID uint) models.PatientMedicalInfoRevision {
+	revision := models.PatientMedicalInfoRevision{
+		MedicalInfoID: info.ID,
+		Version:       info.Version,
+		PatientID:     info.PatientID,
+		Gender:        info.Gender,
+		AgeGroup:      info.AgeGroup,
+		Diseases:      make([]models.RevisionDisease, 0, len(info.Diseases)),
+	}
+	if authorID != 0 {
+		revision.AuthorID = &authorID
+	}
+	for _, d := range info.Diseases {
+		revision.Diseases = append(revision.Diseases, models.RevisionDisease{ID: d.ID, Code: d.Code, Name: d.Name})
+	}
+	sort.Slice(revision.Diseases, func(i, j int) bool { return revision.Diseases[i].ID < revision.Diseases[j].ID })
+	return revision
+}
+
+// FieldChange is a changed scalar field.
+type FieldChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Changes is the difference between two revisions.
+type Changes struct {
+	FromVersion     int                      `json:"fromVersion"`
+	ToVersion       int                      `json:"toVersion"`
+	Gender          *FieldChange             `json:"gender,omitempty"`
+	AgeGroup        *FieldChange             `json:"ageGroup,omitempty"`
+	DiseasesAdded   []models.RevisionDisease `json:"diseasesAdded"`
+	DiseasesRemoved []models.RevisionDisease `json:"diseasesRemoved"`
+}
+
+// Diff compares two revisions; from may be nil to diff against an empty record.
+func Diff(from, to *models.PatientMedicalInfoRevision) Changes {
+	if from == nil {
+		from = &models.PatientMedicalInfoRevision{}
+	}
+	changes := Changes{
+		FromVersion:     from.Version,
+		ToVersion:       to.Version,
+		DiseasesAdded:   []models.RevisionDisease{},
+		DiseasesRemoved: []models.RevisionDisease{},
+	}
+	if from.Gender != to.Gender {
+		changes.Gender = &FieldChange{From: from.Gender, To: to.Gender}
+	}
+	if from.AgeGroup != to.AgeGroup {
+		changes.AgeGroup = &FieldChange{From: from.AgeGroup, To: to.AgeGroup}
+	}
+
+	before := make(map[uint]bool, len(from.Diseases))
+	for _, d := range from.Diseases {
+		before[d.ID] = true
+	}
+	after := make(map[uint]bool, len(to.Diseases))
+	for _, d := range to.Diseases {
+		after[d.ID] = true
+		if !before[d.ID] {
+			changes.DiseasesAdded = append(changes.DiseasesAdded, d)
+		}
+	}
+	for _, d := range from.Diseases {
+		if !after[d.ID] {
+			changes.DiseasesRemoved = append(changes.DiseasesRemoved, d)
+		}
+	}
+	return changes
+}
+
+// Diagnosis records when a disease still on the record was last added, and by whom.
+type Diagnosis struct {
+	Disease  models.RevisionDisease `json:"disease"`
+	Version  int                    `json:"version"`
+	AuthorID *uint                  `json:"authorId"`
+	AddedAt  time.Time              `json:"addedAt"`
+}
+
+// Diagnoses walks revisions in version order and attributes each disease in
+// the latest one to the revision that last added it.
+func Diagnoses(revisions []models.PatientMedicalInfoRevision) []Diagnosis {
+	ordered := append([]models.PatientMedicalInfoRevision(nil), revisions...)
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].Version < ordered[j].Version })
+
+	current := map[uint]Diagnosis{}
+	for _, revision := range ordered {
+		present := make(map[uint]bool, len(revision.Diseases))
+		for _, d := range revision.Diseases {
+			present[d.ID] = true
+			if _, known := current[d.ID]; !known {
+				current[d.ID] = Diagnosis{Disease: d, Version: revision.Version, AuthorID: revision.AuthorID, AddedAt: revision.CreatedAt}
+			}
+		}
+		for id := range current {
+			if !present[id] {
+				delete(current, id)
+			}
+		}
+	}
+
+	diagnoses := make([]Diagnosis, 0, len(current))
+	for _, d := range current {
+		diagnoses = append(diagnoses, d)
+	}
+	sort.Slice(diagnoses, func(i, j int) bool { return diagnoses[i].Version < diagnoses[j].Version })
+	return diagnoses
+}
+
+// Backfill records a revision for medical info written before revisions were
+// kept, attributed to the doctor last recorded on it.
+func Backfill(tx *gorm.DB) (int, error) {
+	var infos []models.PatientMedicalInfo
+	if err := tx.Preload("Diseases").
+		Where("NOT EXISTS (SELECT 1 FROM patient_medical_info_revisions r WHERE r.medical_info_id = patient_medical_infos.id)").
+		Find(&infos).Error; err != nil {
+		return 0, err
+	}
+	for i := range infos {
+		revision := NewRevision(&infos[i], infos[i].DoctorID)
+		revision.CreatedAt = infos[i].UpdatedAt
+		if err := tx.Create(&revision).Error; err != nil {
+			return i, err
+		}
+	}
+	return len(infos), nil
+}
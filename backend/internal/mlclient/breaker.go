@@ -0,0 +1,131 @@
+package mlclient
+
+import (
+	"sync"
+	"time"
+)
+
+// Breaker states
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half-open"
+)
+
+// breaker stops calls to the ML service after threshold consecutive failures.
+// Once cooldown has passed a single probe call is let through: success closes
+// the breaker, failure opens it for another cooldown.
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+	opened   int64 // times the breaker has opened
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown, now: time.Now, state: StateClosed}
+}
+
+// permit is handed out by allow and passed back with the call's outcome.
+type permit struct {
+	probe      bool  // the half-open probe call
+	generation int64 // times the breaker had opened when the call was allowed
+}
+
+// allow reports whether a call may go ahead. In the half-open state only one
+// call, the probe, is allowed at a time; its outcome decides the next state.
+func (b *breaker) allow() (permit, bool) {
+	if b.threshold <= 0 {
+		return permit{}, true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return permit{}, false
+		}
+		b.state = StateHalfOpen
+		b.probing = true
+		return permit{probe: true, generation: b.opened}, true
+	case StateHalfOpen:
+		if b.probing {
+			return permit{}, false
+		}
+		b.probing = true
+		return permit{probe: true, generation: b.opened}, true
+	}
+	return permit{generation: b.opened}, true
+}
+
+// done records the outcome of an allowed call. Only the probe moves a
+// half-open breaker; other calls count only while the breaker is still in the
+// closed state they were allowed in, so a slow call that finishes after the
+// breaker opened cannot close it.
+func (b *breaker) done(p permit, success bool) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if p.probe {
+		if b.state != StateHalfOpen || b.opened != p.generation {
+			return
+		}
+		b.probing = false
+		if success {
+			b.state = StateClosed
+			b.failures = 0
+			return
+		}
+		b.trip()
+		return
+	}
+	if b.state != StateClosed || b.opened != p.generation {
+		return
+	}
+	if success {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.trip()
+	}
+}
+
+// trip opens the breaker for a cooldown.
+func (b *breaker) trip() {
+	b.opened++
+	b.state = StateOpen
+	b.openedAt = b.now()
+}
+
+// release gives up an allowed call without an outcome, e.g. when the caller
+// went away, so a half-open breaker can probe again.
+func (b *breaker) release(p permit) {
+	if !p.probe {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == StateHalfOpen && b.opened == p.generation {
+		b.probing = false
+	}
+}
+
+func (b *breaker) snapshot() (state string, failures int, opened int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state = b.state
+	if state == StateOpen && b.now().Sub(b.openedAt) >= b.cooldown {
+		state = StateHalfOpen
+	}
+	return state, b.failures, b.opened
+}
@@ -0,0 +1,166 @@
+package mlclient
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestBreaker(threshold int, cooldown time.Duration) (*breaker, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)}
+	b := newBreaker(threshold, cooldown)
+	b.now = clock.Now
+	return b, clock
+}
+
+func mustAllow(t *testing.T, b *breaker) permit {
+	t.Helper()
+	p, ok := b.allow()
+	if !ok {
+		t.Fatalf("call refused in state %s", b.state)
+	}
+	return p
+}
+
+func fail(t *testing.T, b *breaker, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		b.done(mustAllow(t, b), false)
+	}
+}
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	b, _ := newTestBreaker(3, time.Minute)
+	fail(t, b, 2)
+	if b.state != StateClosed {
+		t.Fatalf("state = %s after 2 failures, want closed", b.state)
+	}
+	fail(t, b, 1)
+	if b.state != StateOpen {
+		t.Fatalf("state = %s after 3 failures, want open", b.state)
+	}
+	if _, ok := b.allow(); ok {
+		t.Fatal("open breaker allowed a call")
+	}
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	b, _ := newTestBreaker(3, time.Minute)
+	fail(t, b, 2)
+	b.done(mustAllow(t, b), true)
+	fail(t, b, 2)
+	if b.state != StateClosed {
+		t.Fatalf("state = %s, want closed: failures were not consecutive", b.state)
+	}
+}
+
+func TestBreakerHalfOpenProbeCloses(t *testing.T) {
+	b, clock := newTestBreaker(1, time.Minute)
+	fail(t, b, 1)
+
+	clock.Advance(59 * time.Second)
+	if _, ok := b.allow(); ok {
+		t.Fatal("call allowed before the cooldown passed")
+	}
+	clock.Advance(time.Second)
+	probe := mustAllow(t, b)
+	if !probe.probe || b.state != StateHalfOpen {
+		t.Fatalf("probe = %v in state %s, want the half-open probe", probe.probe, b.state)
+	}
+	if _, ok := b.allow(); ok {
+		t.Fatal("second call allowed while the probe is in flight")
+	}
+
+	b.done(probe, true)
+	if b.state != StateClosed {
+		t.Fatalf("state = %s after a successful probe, want closed", b.state)
+	}
+	if p := mustAllow(t, b); p.probe {
+		t.Fatal("call in the closed state marked as probe")
+	}
+}
+
+func TestBreakerHalfOpenProbeFailureReopens(t *testing.T) {
+	b, clock := newTestBreaker(1, time.Minute)
+	fail(t, b, 1)
+	clock.Advance(time.Minute)
+
+	b.done(mustAllow(t, b), false)
+	if b.state != StateOpen || b.opened != 2 {
+		t.Fatalf("state = %s, opened = %d; want open, 2", b.state, b.opened)
+	}
+	if _, ok := b.allow(); ok {
+		t.Fatal("call allowed right after the probe failed")
+	}
+	clock.Advance(time.Minute)
+	if p := mustAllow(t, b); !p.probe {
+		t.Fatal("call after the second cooldown is not a probe")
+	}
+}
+
+func TestBreakerIgnoresCallsFromBeforeItOpened(t *testing.T) {
+	b, clock := newTestBreaker(2, time.Minute)
+	slow := mustAllow(t, b)
+	fail(t, b, 2)
+	clock.Advance(time.Minute)
+	probe := mustAllow(t, b)
+
+	// The slow call finishing must neither close the breaker nor free the probe slot
+	b.done(slow, true)
+	if b.state != StateHalfOpen {
+		t.Fatalf("state = %s after a stale success, want half-open", b.state)
+	}
+	if _, ok := b.allow(); ok {
+		t.Fatal("second probe allowed after a stale call finished")
+	}
+	b.done(slow, false)
+	if b.state != StateHalfOpen {
+		t.Fatalf("state = %s after a stale failure, want half-open", b.state)
+	}
+
+	b.done(probe, false)
+	if b.state != StateOpen {
+		t.Fatalf("state = %s after the probe failed, want open", b.state)
+	}
+}
+
+func TestBreakerStaleProbeIgnored(t *testing.T) {
+	b, clock := newTestBreaker(1, time.Minute)
+	fail(t, b, 1)
+	clock.Advance(time.Minute)
+	probe := mustAllow(t, b)
+	b.done(probe, true)
+	fail(t, b, 1)
+
+	// Reporting the old probe again must not close the reopened breaker
+	b.done(probe, true)
+	if b.state != StateOpen {
+		t.Fatalf("state = %s, want open", b.state)
+	}
+}
+
+func TestBreakerReleaseFreesProbe(t *testing.T) {
+	b, clock := newTestBreaker(1, time.Minute)
+	fail(t, b, 1)
+	clock.Advance(time.Minute)
+
+	b.release(mustAllow(t, b))
+	if b.state != StateHalfOpen {
+		t.Fatalf("state = %s after release, want half-open", b.state)
+	}
+	if p := mustAllow(t, b); !p.probe {
+		t.Fatal("call after release is not a probe")
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	b, _ := newTestBreaker(-1, time.Minute)
+	fail(t, b, 100)
+	if _, ok := b.allow(); !ok {
+		t.Fatal("disabled breaker refused a call")
+	}
+}
@@ -1,19 +1,79 @@
+// Package mlclient calls the ML service. Calls are retried with jittered
+// backoff, a circuit breaker stops calling a service that keeps failing, and
+// rule-based fallbacks answer symptom checks while the service is down.
 package mlclient
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"math/rand"
 	"net/http"
 	"os"
+	"strconv"
+	"sync"
 	"time"
 )
 
+// SymptomsSchemaVersion is the symptom request schema sent to /v2/predict/.
+const SymptomsSchemaVersion = 2
+
+var (
+	// ErrCircuitOpen is returned without calling the service while the breaker is open.
+	ErrCircuitOpen = errors.New("ml service unavailable: circuit breaker open")
+	// ErrUnavailable wraps failures that may pass: network errors, timeouts,
+	// 429 and 5xx responses.
+	ErrUnavailable = errors.New("ml service unavailable")
+)
+
+// StatusError is a response the service gave that retrying will not change.
+type StatusError struct {
+	Status int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("ml service returned status %d", e.Status)
+}
+
+// Options configure a Client. Zero values fall back to the defaults below.
+type Options struct {
+	BaseURL          string
+	Timeout          time.Duration // per attempt
+	MaxRetries       int           // extra attempts for idempotent calls; negative disables retries
+	BackoffBase      time.Duration
+	BackoffMax       time.Duration
+	BreakerThreshold int           // consecutive failed calls that open the breaker; negative disables it
+	BreakerCooldown  time.Duration // how long the breaker stays open before probing
+	Fallback         *FallbackRules
+	HTTPClient       *http.Client
+}
+
+const (
+	defaultTimeout          = 5 * time.Second
+	defaultMaxRetries       = 2
+	defaultBackoffBase      = 200 * time.Millisecond
+	defaultBackoffMax       = 2 * time.Second
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 30 * time.Second
+)
+
 type Client struct {
-	baseURL string
-	http    *http.Client
+	baseURL     string
+	http        *http.Client
+	maxRetries  int
+	backoffBase time.Duration
+	backoffMax  time.Duration
+	breaker     *breaker
+	fallback    *FallbackRules
+	metrics     *metrics
+	sleep       func(ctx context.Context, d time.Duration) error
 }
 
+// SymptomsPayload is the original three-symptom request, still accepted by /predict/.
 type SymptomsPayload struct {
 	Fever    bool `json:"fever"`
 	Cough    bool `json:"cough"`
@@ -23,44 +83,276 @@ type SymptomsPayload struct {
 type PredictionResponse struct {
 	Prediction string  `json:"prediction"`
 	Confidence float64 `json:"confidence"`
+	Fallback   bool    `json:"fallback,omitempty"` // answered by the fallback rules
+}
+
+// Symptom is one reported symptom, identified by its catalog code.
+type Symptom struct {
+	Code         string `json:"code"`
+	Severity     string `json:"severity"` // mild, moderate or severe
+	DurationDays int    `json:"durationDays"`
+}
+
+// PatientContext is what the model may know about the person reporting
+// symptoms. Age is sent as a band only.
+type PatientContext struct {
+	AgeBand           string   `json:"ageBand,omitempty"`
+	Gender            string   `json:"gender,omitempty"`
+	ChronicConditions []string `json:"chronicConditions"` // ICD-10 codes
+}
+
+type SymptomsV2Payload struct {
+	Version  int            `json:"version"`
+	Symptoms []Symptom      `json:"symptoms"`
+	Patient  PatientContext `json:"patient"`
+}
+
+// Differential is one candidate condition, with the ICD-10 code when the model knows it.
+type Differential struct {
+	Condition   string  `json:"condition"`
+	Code        string  `json:"code,omitempty"`
+	Probability float64 `json:"probability"`
+}
+
+type DifferentialResponse struct {
+	Version      int            `json:"version"`
+	ModelVersion string         `json:"modelVersion"`
+	Differential []Differential `json:"differential"` // most likely first
+	Urgency      string         `json:"urgency"`      // self_care, routine, soon or emergency
+	Advice       string         `json:"advice"`
+	RedFlags     []string       `json:"redFlags"` // symptom codes that raised the urgency
+	Fallback     bool           `json:"fallback,omitempty"`
 }
 
+// New configures a client from the environment: ML_SERVICE_URL, ML_TIMEOUT,
+// ML_RETRIES, ML_BREAKER_THRESHOLD, ML_BREAKER_COOLDOWN, ML_FALLBACK and
+// ML_FALLBACK_RULES.
 func New() *Client {
+	opts := Options{
+		BaseURL:          getEnv("ML_SERVICE_URL", "http://localhost:8000"),
+		Timeout:          envDuration("ML_TIMEOUT", defaultTimeout),
+		MaxRetries:       envInt("ML_RETRIES", defaultMaxRetries),
+		BreakerThreshold: envInt("ML_BREAKER_THRESHOLD", defaultBreakerThreshold),
+		BreakerCooldown:  envDuration("ML_BREAKER_COOLDOWN", defaultBreakerCooldown),
+	}
+	if enabled, err := strconv.ParseBool(getEnv("ML_FALLBACK", "true")); err != nil {
+		log.Printf("mlclient: ignoring invalid ML_FALLBACK %q", os.Getenv("ML_FALLBACK"))
+		opts.Fallback = DefaultFallbackRules()
+	} else if enabled {
+		opts.Fallback = DefaultFallbackRules()
+		if path := os.Getenv("ML_FALLBACK_RULES"); path != "" {
+			if rules, err := LoadFallbackRules(path); err == nil {
+				opts.Fallback = rules
+			} else {
+				log.Printf("mlclient: using default fallback rules: %v", err)
+			}
+		}
+	}
+	return NewWithOptions(opts)
+}
+
+// NewWithOptions builds a client from explicit options, e.g. against an httptest server.
+func NewWithOptions(opts Options) *Client {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = defaultMaxRetries
+	} else if opts.MaxRetries < 0 {
+		opts.MaxRetries = 0
+	}
+	if opts.BackoffBase <= 0 {
+		opts.BackoffBase = defaultBackoffBase
+	}
+	if opts.BackoffMax <= 0 {
+		opts.BackoffMax = defaultBackoffMax
+	}
+	if opts.BreakerThreshold == 0 {
+		opts.BreakerThreshold = defaultBreakerThreshold
+	}
+	if opts.BreakerCooldown <= 0 {
+		opts.BreakerCooldown = defaultBreakerCooldown
+	}
+	httpClient := opts.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: opts.Timeout}
+	}
 	return &Client{
-		baseURL: getEnv("ML_SERVICE_URL", "http://localhost:8000"),
-		http:    &http.Client{Timeout: 10 * time.Second},
+		baseURL:     opts.BaseURL,
+		http:        httpClient,
+		maxRetries:  opts.MaxRetries,
+		backoffBase: opts.BackoffBase,
+		backoffMax:  opts.BackoffMax,
+		breaker:     newBreaker(opts.BreakerThreshold, opts.BreakerCooldown),
+		fallback:    opts.Fallback,
+		metrics:     newMetrics(),
+		sleep:       sleepContext,
 	}
 }
 
-func (c *Client) PredictSymptoms(payload SymptomsPayload) (*PredictionResponse, error) {
-	url := fmt.Sprintf("%s/predict/", c.baseURL)
-	body, err := json.Marshal(payload)
+var (
+	sharedOnce   sync.Once
+	sharedClient *Client
+)
+
+// Shared is the process-wide client, so every caller sees the same breaker and metrics.
+func Shared() *Client {
+	sharedOnce.Do(func() {
+		sharedClient = New()
+	})
+	return sharedClient
+}
+
+// PredictSymptoms answers a version 1 request, from the fallback rules when
+// the service is unavailable and fallback is enabled.
+func (c *Client) PredictSymptoms(ctx context.Context, payload SymptomsPayload) (*PredictionResponse, error) {
+	var prediction PredictionResponse
+	err := c.post(ctx, "/predict/", payload, &prediction, true)
+	if c.useFallback(ctx, "/predict/", err) {
+		return c.fallback.PredictV1(payload), nil
+	}
 	if err != nil {
 		return nil, err
 	}
+	return &prediction, nil
+}
 
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(body))
+// PredictSymptomsV2 asks for a ranked differential for catalog symptoms.
+func (c *Client) PredictSymptomsV2(ctx context.Context, payload SymptomsV2Payload) (*DifferentialResponse, error) {
+	payload.Version = SymptomsSchemaVersion
+	var prediction DifferentialResponse
+	err := c.post(ctx, "/v2/predict/", payload, &prediction, true)
+	if c.useFallback(ctx, "/v2/predict/", err) {
+		return c.fallback.PredictV2(payload), nil
+	}
 	if err != nil {
 		return nil, err
 	}
+	return &prediction, nil
+}
+
+// Metrics returns a copy of the client's call metrics and breaker state.
+func (c *Client) Metrics() Snapshot {
+	state, failures, opened := c.breaker.snapshot()
+	return Snapshot{
+		BaseURL:          c.baseURL,
+		BreakerState:     state,
+		BreakerFailures:  failures,
+		BreakerOpened:    opened,
+		FallbackEnabled:  c.fallback != nil,
+		LatencyBucketsMs: append([]int64(nil), latencyBuckets...),
+		Endpoints:        c.metrics.copy(),
+	}
+}
+
+// useFallback reports whether a failed call should be answered by the
+// fallback rules: the service is unavailable, not the request bad or the
+// caller gone.
+func (c *Client) useFallback(ctx context.Context, path string, err error) bool {
+	if err == nil || c.fallback == nil || ctx.Err() != nil {
+		return false
+	}
+	if !errors.Is(err, ErrUnavailable) && !errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	c.metrics.update(path, func(e *EndpointMetrics) { e.Fallbacks++ })
+	return true
+}
+
+// post sends payload to path and decodes the answer into out. Idempotent
+// calls are retried on failures that may pass.
+func (c *Client) post(ctx context.Context, path string, payload, out interface{}, idempotent bool) error {
+	permit, ok := c.breaker.allow()
+	if !ok {
+		c.metrics.update(path, func(e *EndpointMetrics) { e.Rejected++ })
+		return ErrCircuitOpen
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		c.breaker.release(permit)
+		return err
+	}
+
+	start := time.Now()
+	attempts := 1
+	if idempotent {
+		attempts += c.maxRetries
+	}
+	retries := 0
+	for attempt := 0; ; attempt++ {
+		err = c.do(ctx, path, body, out)
+		if err == nil || !errors.Is(err, ErrUnavailable) || attempt+1 >= attempts || ctx.Err() != nil {
+			break
+		}
+		if sleepErr := c.sleep(ctx, c.backoff(attempt)); sleepErr != nil {
+			break
+		}
+		retries++
+	}
+	c.metrics.observe(path, time.Since(start), retries, err)
+
+	switch {
+	case ctx.Err() != nil:
+		c.breaker.release(permit)
+		if err == nil {
+			return nil
+		}
+		return ctx.Err()
+	case err == nil:
+		c.breaker.done(permit, true)
+	default:
+		// A request the service rejected still shows the service is up
+		c.breaker.done(permit, !errors.Is(err, ErrUnavailable))
+	}
+	return err
+}
+
+func (c *Client) do(ctx context.Context, path string, body []byte, out interface{}) error {
+	url := fmt.Sprintf("%s%s", c.baseURL, path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.http.Do(req)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("%w: status %d", ErrUnavailable, resp.StatusCode)
+	}
 	if resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("ml service returned status %d", resp.StatusCode)
+		return &StatusError{Status: resp.StatusCode}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%w: invalid response: %v", ErrUnavailable, err)
 	}
+	return nil
+}
 
-	var prediction PredictionResponse
-	if err := json.NewDecoder(resp.Body).Decode(&prediction); err != nil {
-		return nil, err
+// backoff is the wait before retry attempt+1: a random duration up to
+// base*2^attempt, capped at the maximum ("full jitter").
+func (c *Client) backoff(attempt int) time.Duration {
+	limit := c.backoffBase << attempt
+	if limit <= 0 || limit > c.backoffMax {
+		limit = c.backoffMax
 	}
+	return time.Duration(rand.Int63n(int64(limit)) + 1)
+}
 
-	return &prediction, nil
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
 }
 
 func getEnv(key, fallback string) string {
@@ -69,3 +361,33 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+func envInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("mlclient: ignoring invalid %s %q", key, value)
+		return fallback
+	}
+	if parsed == 0 {
+		// Zero means "default" in Options, so spell out "off" for it
+		return -1
+	}
+	return parsed
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed <= 0 {
+		log.Printf("mlclient: ignoring invalid %s %q", key, value)
+		return fallback
+	}
+	return parsed
+}
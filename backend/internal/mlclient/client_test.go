@@ -0,0 +1,270 @@
+package mlclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stub is an httptest stand-in for the ML service that answers with the
+// status codes queued in order, repeating the last one.
+type stub struct {
+	mu       sync.Mutex
+	statuses []int
+	body     interface{}
+	calls    int64
+}
+
+func (s *stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&s.calls, 1)
+	s.mu.Lock()
+	status := s.statuses[0]
+	if len(s.statuses) > 1 {
+		s.statuses = s.statuses[1:]
+	}
+	s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if status < 300 {
+		json.NewEncoder(w).Encode(s.body)
+	}
+}
+
+func (s *stub) Calls() int64 { return atomic.LoadInt64(&s.calls) }
+
+func (s *stub) Respond(statuses ...int) {
+	s.mu.Lock()
+	s.statuses = statuses
+	s.mu.Unlock()
+}
+
+var okPrediction = PredictionResponse{Prediction: "Likely Sick", Confidence: 0.8}
+
+// newTestClient points a client at a stub. Backoff waits are recorded instead
+// of slept.
+func newTestClient(t *testing.T, s *stub, opts Options) (*Client, *[]time.Duration) {
+	t.Helper()
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	opts.BaseURL = server.URL
+	if opts.BackoffBase == 0 {
+		opts.BackoffBase = 100 * time.Millisecond
+	}
+	if opts.BackoffMax == 0 {
+		opts.BackoffMax = 150 * time.Millisecond
+	}
+	c := NewWithOptions(opts)
+	var waits []time.Duration
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return ctx.Err()
+	}
+	return c, &waits
+}
+
+func TestRetriesServerErrorsWithBackoff(t *testing.T) {
+	s := &stub{statuses: []int{503, 500, 200}, body: okPrediction}
+	c, waits := newTestClient(t, s, Options{MaxRetries: 3, BreakerThreshold: -1})
+
+	resp, err := c.PredictSymptoms(context.Background(), SymptomsPayload{Fever: true})
+	if err != nil {
+		t.Fatalf("PredictSymptoms: %v", err)
+	}
+	if resp.Prediction != okPrediction.Prediction || resp.Fallback {
+		t.Fatalf("response = %+v, want the service's answer", resp)
+	}
+	if s.Calls() != 3 {
+		t.Fatalf("service called %d times, want 3", s.Calls())
+	}
+	if len(*waits) != 2 {
+		t.Fatalf("waited %d times, want 2", len(*waits))
+	}
+	// Full jitter: up to base*2^attempt, capped at the maximum
+	for i, limit := range []time.Duration{100 * time.Millisecond, 150 * time.Millisecond} {
+		if w := (*waits)[i]; w < 0 || w > limit {
+			t.Errorf("wait %d = %v, want within [0, %v]", i, w, limit)
+		}
+	}
+}
+
+func TestGivesUpAfterMaxRetries(t *testing.T) {
+	s := &stub{statuses: []int{502}}
+	c, _ := newTestClient(t, s, Options{MaxRetries: 2, BreakerThreshold: -1})
+
+	_, err := c.PredictSymptoms(context.Background(), SymptomsPayload{})
+	if !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("err = %v, want ErrUnavailable", err)
+	}
+	if s.Calls() != 3 {
+		t.Fatalf("service called %d times, want 3", s.Calls())
+	}
+}
+
+func TestNoRetryForNonIdempotentCalls(t *testing.T) {
+	s := &stub{statuses: []int{503, 200}, body: okPrediction}
+	c, waits := newTestClient(t, s, Options{MaxRetries: 3, BreakerThreshold: -1})
+
+	var out PredictionResponse
+	err := c.post(context.Background(), "/predict/", SymptomsPayload{}, &out, false)
+	if !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("err = %v, want ErrUnavailable", err)
+	}
+	if s.Calls() != 1 || len(*waits) != 0 {
+		t.Fatalf("service called %d times after %d waits, want a single call", s.Calls(), len(*waits))
+	}
+}
+
+func TestNoRetryOnClientErrors(t *testing.T) {
+	s := &stub{statuses: []int{422}}
+	c, waits := newTestClient(t, s, Options{MaxRetries: 3, BreakerThreshold: 1, Fallback: DefaultFallbackRules()})
+
+	_, err := c.PredictSymptoms(context.Background(), SymptomsPayload{})
+	var status *StatusError
+	if !errors.As(err, &status) || status.Status != 422 {
+		t.Fatalf("err = %v, want a 422 StatusError", err)
+	}
+	if s.Calls() != 1 || len(*waits) != 0 {
+		t.Fatalf("service called %d times after %d waits, want a single call", s.Calls(), len(*waits))
+	}
+	// A rejected request shows the service is up: no fallback, breaker closed
+	if m := c.Metrics(); m.BreakerState != StateClosed || m.Endpoints["/predict/"].Fallbacks != 0 {
+		t.Fatalf("breaker %s with %d fallbacks, want closed and none", m.BreakerState, m.Endpoints["/predict/"].Fallbacks)
+	}
+}
+
+func TestBreakerLifecycleAgainstService(t *testing.T) {
+	s := &stub{statuses: []int{500}, body: okPrediction}
+	c, _ := newTestClient(t, s, Options{MaxRetries: -1, BreakerThreshold: 2, BreakerCooldown: time.Minute})
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)}
+	c.breaker.now = clock.Now
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.PredictSymptoms(ctx, SymptomsPayload{}); !errors.Is(err, ErrUnavailable) {
+			t.Fatalf("call %d: err = %v, want ErrUnavailable", i, err)
+		}
+	}
+	if state := c.Metrics().BreakerState; state != StateOpen {
+		t.Fatalf("state = %s after 2 failures, want open", state)
+	}
+	if _, err := c.PredictSymptoms(ctx, SymptomsPayload{}); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v while open, want ErrCircuitOpen", err)
+	}
+	if s.Calls() != 2 {
+		t.Fatalf("service called %d times, want the open breaker to stop the third call", s.Calls())
+	}
+
+	// A failed probe opens the breaker again
+	clock.Advance(time.Minute)
+	if state := c.Metrics().BreakerState; state != StateHalfOpen {
+		t.Fatalf("state = %s after the cooldown, want half-open", state)
+	}
+	if _, err := c.PredictSymptoms(ctx, SymptomsPayload{}); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("probe err = %v, want ErrUnavailable", err)
+	}
+	if m := c.Metrics(); m.BreakerState != StateOpen || m.BreakerOpened != 2 {
+		t.Fatalf("state = %s, opened = %d after a failed probe; want open, 2", m.BreakerState, m.BreakerOpened)
+	}
+
+	// A successful probe closes it
+	clock.Advance(time.Minute)
+	s.Respond(200)
+	if _, err := c.PredictSymptoms(ctx, SymptomsPayload{}); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if state := c.Metrics().BreakerState; state != StateClosed {
+		t.Fatalf("state = %s after a successful probe, want closed", state)
+	}
+}
+
+func TestFallbackWhileBreakerOpen(t *testing.T) {
+	s := &stub{statuses: []int{503}}
+	c, _ := newTestClient(t, s, Options{MaxRetries: -1, BreakerThreshold: 1, BreakerCooldown: time.Hour, Fallback: DefaultFallbackRules()})
+	ctx := context.Background()
+
+	// The failure that opens the breaker is already answered by the rules
+	v1, err := c.PredictSymptoms(ctx, SymptomsPayload{Fever: true, Cough: true})
+	if err != nil || !v1.Fallback {
+		t.Fatalf("PredictSymptoms = %+v, %v; want a fallback answer", v1, err)
+	}
+	if v1.Prediction != "Likely Sick" {
+		t.Errorf("prediction = %q, want Likely Sick for fever and cough", v1.Prediction)
+	}
+
+	v2, err := c.PredictSymptomsV2(ctx, SymptomsV2Payload{Symptoms: []Symptom{{Code: "chest_pain", Severity: "severe"}}})
+	if err != nil || !v2.Fallback {
+		t.Fatalf("PredictSymptomsV2 = %+v, %v; want a fallback answer", v2, err)
+	}
+	if v2.ModelVersion != FallbackModelVersion || v2.Urgency != "emergency" {
+		t.Errorf("model %s, urgency %s; want %s, emergency", v2.ModelVersion, v2.Urgency, FallbackModelVersion)
+	}
+	if s.Calls() != 1 {
+		t.Fatalf("service called %d times, want only the call that opened the breaker", s.Calls())
+	}
+	m := c.Metrics()
+	if got := m.Endpoints["/v2/predict/"]; got.Rejected != 1 || got.Fallbacks != 1 {
+		t.Fatalf("v2 metrics = %+v, want 1 rejected and 1 fallback", got)
+	}
+}
+
+func TestNoFallbackWhenDisabled(t *testing.T) {
+	s := &stub{statuses: []int{503}}
+	c, _ := newTestClient(t, s, Options{MaxRetries: -1, BreakerThreshold: -1})
+	if _, err := c.PredictSymptoms(context.Background(), SymptomsPayload{}); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("err = %v, want ErrUnavailable", err)
+	}
+}
+
+func TestMetricsCounters(t *testing.T) {
+	s := &stub{statuses: []int{500, 200, 500, 500, 400}, body: okPrediction}
+	c, _ := newTestClient(t, s, Options{MaxRetries: 1, BreakerThreshold: -1})
+	ctx := context.Background()
+
+	c.PredictSymptoms(ctx, SymptomsPayload{}) // 500, retried, 200
+	c.PredictSymptoms(ctx, SymptomsPayload{}) // 500, retried, 500
+	c.PredictSymptoms(ctx, SymptomsPayload{}) // 400
+
+	m := c.Metrics()
+	got := m.Endpoints["/predict/"]
+	if got.Calls != 3 || got.Failures != 2 || got.Retries != 2 || got.Rejected != 0 || got.Fallbacks != 0 {
+		t.Fatalf("metrics = %+v, want 3 calls, 2 failures, 2 retries", got)
+	}
+	var observed int64
+	for _, n := range got.LatencyBuckets {
+		observed += n
+	}
+	if observed != 3 || len(got.LatencyBuckets) != len(m.LatencyBucketsMs)+1 {
+		t.Fatalf("latency histogram %v over bounds %v, want 3 observations", got.LatencyBuckets, m.LatencyBucketsMs)
+	}
+	if s.Calls() != 5 {
+		t.Fatalf("service called %d times, want 5", s.Calls())
+	}
+}
+
+func TestStopsRetryingWhenCallerGoesAway(t *testing.T) {
+	s := &stub{statuses: []int{503}}
+	c, _ := newTestClient(t, s, Options{MaxRetries: 3, BreakerThreshold: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	c.sleep = func(context.Context, time.Duration) error {
+		cancel()
+		return context.Canceled
+	}
+
+	_, err := c.PredictSymptoms(ctx, SymptomsPayload{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if s.Calls() != 1 {
+		t.Fatalf("service called %d times, want 1", s.Calls())
+	}
+	// An abandoned call says nothing about the service
+	if state := c.Metrics().BreakerState; state != StateClosed {
+		t.Fatalf("state = %s, want closed", state)
+	}
+}
@@ -0,0 +1,157 @@
+package mlclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+)
+
+// FallbackModelVersion marks answers given by the fallback rules instead of the ML service.
+const FallbackModelVersion = "fallback-rules-1"
+
+// FallbackRules answer symptom checks while the ML service is unavailable.
+// They are deliberately cautious: the fallback never advises self care.
+type FallbackRules struct {
+	// Logistic model for version 1 requests, the same one the ML service used
+	Intercept float64            `json:"intercept"`
+	Weights   map[string]float64 `json:"weights"`
+	Threshold float64            `json:"threshold"`
+
+	// Version 2 requests are scored against each condition's symptom weights
+	Conditions []FallbackCondition `json:"conditions"`
+	// Symptoms that make a check an emergency when reported as severe
+	RedFlags []string `json:"redFlags"`
+}
+
+type FallbackCondition struct {
+	Condition string             `json:"condition"`
+	Code      string             `json:"code"`
+	Urgency   string             `json:"urgency"`
+	Weights   map[string]float64 `json:"weights"`
+}
+
+// DefaultFallbackRules are used unless ML_FALLBACK_RULES names a JSON file of rules.
+func DefaultFallbackRules() *FallbackRules {
+	return &FallbackRules{
+		Intercept: -1.2,
+		Weights:   map[string]float64{"fever": 1.5, "cough": 0.9, "headache": 0.6},
+		Threshold: 0.5,
+		Conditions: []FallbackCondition{
+			{Condition: "Respiratory infection", Code: "J06.9", Urgency: "routine", Weights: map[string]float64{"fever": 1, "cough": 1, "sore_throat": 1, "runny_nose": 1, "muscle_pain": 0.5, "chills": 0.5, "fatigue": 0.3}},
+			{Condition: "Pneumonia", Code: "J18.9", Urgency: "soon", Weights: map[string]float64{"fever": 1, "cough": 1, "shortness_of_breath": 1.5, "chest_pain": 0.5}},
+			{Condition: "Acute coronary syndrome", Code: "I24.9", Urgency: "emergency", Weights: map[string]float64{"chest_pain": 2, "shortness_of_breath": 0.8, "dizziness": 0.4, "palpitations": 0.4}},
+			{Condition: "Gastroenteritis", Code: "A09", Urgency: "routine", Weights: map[string]float64{"diarrhea": 1.5, "nausea": 1.2, "abdominal_pain": 1}},
+			{Condition: "Headache disorder", Code: "R51", Urgency: "routine", Weights: map[string]float64{"headache": 1.5, "dizziness": 0.4, "nausea": 0.4}},
+			{Condition: "Urinary tract infection", Code: "N39.0", Urgency: "routine", Weights: map[string]float64{"frequent_urination": 1.5, "abdominal_pain": 0.5}},
+			{Condition: "Hyperglycaemia", Code: "R73.9", Urgency: "soon", Weights: map[string]float64{"excessive_thirst": 1.5, "frequent_urination": 1, "fatigue": 0.4}},
+		},
+		RedFlags: []string{"chest_pain", "shortness_of_breath"},
+	}
+}
+
+// LoadFallbackRules reads rules from a JSON file.
+func LoadFallbackRules(path string) (*FallbackRules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules FallbackRules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if rules.Threshold <= 0 || rules.Threshold >= 1 {
+		rules.Threshold = 0.5
+	}
+	return &rules, nil
+}
+
+// PredictV1 answers a version 1 request.
+func (r *FallbackRules) PredictV1(payload SymptomsPayload) *PredictionResponse {
+	sum := r.Intercept
+	present := map[string]bool{"fever": payload.Fever, "cough": payload.Cough, "headache": payload.Headache}
+	for code, on := range present {
+		if on {
+			sum += r.Weights[code]
+		}
+	}
+	probability := 1 / (1 + math.Exp(-sum))
+	prediction := "Low Risk"
+	if probability >= r.Threshold {
+		prediction = "Likely Sick"
+	}
+	return &PredictionResponse{Prediction: prediction, Confidence: math.Round(probability*100) / 100, Fallback: true}
+}
+
+var fallbackSeverity = map[string]float64{"mild": 0.6, "moderate": 1, "severe": 1.4}
+
+var urgencyRank = map[string]int{"self_care": 0, "routine": 1, "soon": 2, "emergency": 3}
+
+// PredictV2 answers a version 2 request with the conditions the reported
+// symptoms point to, scaled to add up to one.
+func (r *FallbackRules) PredictV2(payload SymptomsV2Payload) *DifferentialResponse {
+	type scored struct {
+		condition FallbackCondition
+		score     float64
+	}
+	var scores []scored
+	total := 0.0
+	for _, condition := range r.Conditions {
+		score := 0.0
+		for _, s := range payload.Symptoms {
+			score += condition.Weights[s.Code] * fallbackSeverity[s.Severity]
+		}
+		if score > 0 {
+			scores = append(scores, scored{condition, score})
+			total += score
+		}
+	}
+	sort.SliceStable(scores, func(i, j int) bool { return scores[i].score > scores[j].score })
+	if len(scores) > 3 {
+		scores = scores[:3]
+	}
+
+	resp := &DifferentialResponse{
+		Version:      SymptomsSchemaVersion,
+		ModelVersion: FallbackModelVersion,
+		Differential: []Differential{},
+		Urgency:      "routine",
+		RedFlags:     []string{},
+		Fallback:     true,
+	}
+	for _, s := range scores {
+		resp.Differential = append(resp.Differential, Differential{
+			Condition:   s.condition.Condition,
+			Code:        s.condition.Code,
+			Probability: math.Round(s.score/total*1000) / 1000,
+		})
+		if urgencyRank[s.condition.Urgency] > urgencyRank[resp.Urgency] && s.score/total >= 0.3 {
+			resp.Urgency = s.condition.Urgency
+		}
+	}
+	for _, s := range payload.Symptoms {
+		if s.Severity != "severe" {
+			continue
+		}
+		for _, flag := range r.RedFlags {
+			if s.Code == flag {
+				resp.RedFlags = append(resp.RedFlags, s.Code)
+			}
+		}
+		if resp.Urgency == "routine" {
+			resp.Urgency = "soon"
+		}
+	}
+	if len(resp.RedFlags) > 0 {
+		resp.Urgency = "emergency"
+	}
+	resp.Advice = fallbackAdvice[resp.Urgency]
+	return resp
+}
+
+var fallbackAdvice = map[string]string{
+	"routine":   "Our full symptom checker is unavailable, so this is a simplified estimate. Book an appointment with your doctor.",
+	"soon":      "Our full symptom checker is unavailable, so this is a simplified estimate. See a doctor within the next day or two.",
+	"emergency": "Seek emergency care now or call emergency services.",
+}
@@ -0,0 +1,89 @@
+package mlclient
+
+import (
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds, in milliseconds, of the latency histogram.
+var latencyBuckets = []int64{50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// EndpointMetrics counts the calls to one ML service endpoint. Latency covers
+// whole calls including retries; LatencyBuckets[i] counts calls no slower
+// than LatencyBucketsMs[i], and the last entry the slower ones.
+type EndpointMetrics struct {
+	Calls          int64   `json:"calls"`
+	Failures       int64   `json:"failures"`  // calls that ended in an error
+	Retries        int64   `json:"retries"`   // extra attempts made
+	Rejected       int64   `json:"rejected"`  // calls refused by the open circuit breaker
+	Fallbacks      int64   `json:"fallbacks"` // calls answered by the fallback rules
+	LatencyMsSum   int64   `json:"latencyMsSum"`
+	LatencyMsMax   int64   `json:"latencyMsMax"`
+	LatencyBuckets []int64 `json:"latencyBuckets"`
+}
+
+// Snapshot is a copy of the client's metrics and breaker state.
+type Snapshot struct {
+	BaseURL          string                     `json:"baseUrl"`
+	BreakerState     string                     `json:"breakerState"`
+	BreakerFailures  int                        `json:"breakerFailures"`
+	BreakerOpened    int64                      `json:"breakerOpened"`
+	FallbackEnabled  bool                       `json:"fallbackEnabled"`
+	LatencyBucketsMs []int64                    `json:"latencyBucketsMs"`
+	Endpoints        map[string]EndpointMetrics `json:"endpoints"`
+}
+
+type metrics struct {
+	mu        sync.Mutex
+	endpoints map[string]*EndpointMetrics
+}
+
+func newMetrics() *metrics {
+	return &metrics{endpoints: map[string]*EndpointMetrics{}}
+}
+
+func (m *metrics) update(endpoint string, fn func(e *EndpointMetrics)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.endpoints[endpoint]
+	if !ok {
+		e = &EndpointMetrics{LatencyBuckets: make([]int64, len(latencyBuckets)+1)}
+		m.endpoints[endpoint] = e
+	}
+	fn(e)
+}
+
+func (m *metrics) observe(endpoint string, elapsed time.Duration, retries int, err error) {
+	ms := elapsed.Milliseconds()
+	m.update(endpoint, func(e *EndpointMetrics) {
+		e.Calls++
+		e.Retries += int64(retries)
+		if err != nil {
+			e.Failures++
+		}
+		e.LatencyMsSum += ms
+		if ms > e.LatencyMsMax {
+			e.LatencyMsMax = ms
+		}
+		bucket := len(latencyBuckets)
+		for i, bound := range latencyBuckets {
+			if ms <= bound {
+				bucket = i
+				break
+			}
+		}
+		e.LatencyBuckets[bucket]++
+	})
+}
+
+func (m *metrics) copy() map[string]EndpointMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]EndpointMetrics, len(m.endpoints))
+	for name, e := range m.endpoints {
+		c := *e
+		c.LatencyBuckets = append([]int64(nil), e.LatencyBuckets...)
+		out[name] = c
+	}
+	return out
+}
@@ -0,0 +1,57 @@
+package mlclient
+
+import "context"
+
+// RiskSchemaVersion is the risk request schema sent to /risk/.
+const RiskSchemaVersion = 1
+
+// RiskFeatures is the feature vector built from a patient's record.
+type RiskFeatures struct {
+	Age                 *int     `json:"age"` // nil when the date of birth is unknown
+	AgeBand             string   `json:"ageBand,omitempty"`
+	Gender              string   `json:"gender,omitempty"`
+	BloodType           string   `json:"bloodType,omitempty"`
+	Conditions          []string `json:"conditions"`          // ICD-10 codes of active problems
+	RemissionConditions []string `json:"remissionConditions"` // ICD-10 codes of problems in remission
+	ResolvedConditions  []string `json:"resolvedConditions"`  // ICD-10 codes of resolved problems
+}
+
+type RiskPayload struct {
+	Version  int          `json:"version"`
+	Features RiskFeatures `json:"features"`
+}
+
+// RiskFactor is one feature's share of a condition's risk. Contributions are
+// on the log-odds scale; negative ones lower the risk.
+type RiskFactor struct {
+	Feature      string  `json:"feature"`
+	Description  string  `json:"description"`
+	Contribution float64 `json:"contribution"`
+}
+
+// ConditionRisk is the estimated risk of developing or worsening a condition.
+type ConditionRisk struct {
+	Condition string       `json:"condition"`
+	Code      string       `json:"code"`
+	Risk      float64      `json:"risk"`  // probability, 0-1
+	Level     string       `json:"level"` // low, moderate or high
+	Factors   []RiskFactor `json:"factors"`
+}
+
+type RiskResponse struct {
+	Version      int             `json:"version"`
+	ModelVersion string          `json:"modelVersion"`
+	Risks        []ConditionRisk `json:"risks"` // highest risk first
+}
+
+// ScoreRisk asks for per-condition risk scores for a patient's features.
+// Unlike symptom checks there is no fallback: a doctor gets an error rather
+// than a guess.
+func (c *Client) ScoreRisk(ctx context.Context, payload RiskPayload) (*RiskResponse, error) {
+	payload.Version = RiskSchemaVersion
+	var response RiskResponse
+	if err := c.post(ctx, "/risk/", payload, &response, true); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
@@ -0,0 +1,397 @@
+// Package mljobs runs batch ML scoring in the background. A job is stored
+// with one item per patient; a fixed pool of workers claims pending items,
+// so no more than that many calls reach the ML service at once. Job state
+// lives in the database and survives restarts.
+package mljobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"medapp/internal/access"
+	"medapp/internal/db"
+	"medapp/internal/mlclient"
+	"medapp/internal/models"
+	"medapp/internal/notify"
+	"medapp/internal/risk"
+	"medapp/internal/textutil"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// MaxPatients caps the patients of one job
+	MaxPatients = 5000
+	// MaxActiveJobs caps the unfinished jobs one doctor may have
+	MaxActiveJobs = 3
+
+	defaultWorkers = 4
+	// How often idle workers look for items, e.g. ones due for a retry
+	pollInterval = 2 * time.Second
+	// Attempts per item while the ML service is unavailable
+	maxAttempts  = 5
+	retryBackoff = 15 * time.Second
+
+	// Size of MLJobItem.Error
+	maxErrorLength = 512
+)
+
+var (
+	ErrUnknownKind   = errors.New("unknown job kind")
+	ErrNoPatients    = errors.New("no patients to score")
+	ErrTooManyItems  = fmt.Errorf("a job can score at most %d patients", MaxPatients)
+	ErrTooManyActive = fmt.Errorf("at most %d jobs can run at once", MaxActiveJobs)
+	ErrFinished      = errors.New("job has already finished")
+)
+
+// scopes are the consents scoring reads; see the risk handler.
+var scopes = []string{models.ScopeDemographics, models.ScopeConditions}
+
+type pool struct {
+	wake chan struct{}
+
+	mu      sync.Mutex
+	running map[uint]map[uint]context.CancelFunc // job id -> item id -> cancel
+}
+
+var (
+	shared    *pool
+	startOnce sync.Once
+)
+
+// Start launches the worker pool; ML_JOB_WORKERS sets its size. Items left
+// running by a previous process are queued again, so only one server
+// process should run the pool.
+func Start() {
+	startOnce.Do(func() {
+		workers := defaultWorkers
+		if value := os.Getenv("ML_JOB_WORKERS"); value != "" {
+			if n, err := strconv.Atoi(value); err == nil && n > 0 {
+				workers = n
+			} else {
+				log.Printf("mljobs: invalid ML_JOB_WORKERS %q, using %d", value, workers)
+			}
+		}
+		if err := db.DB.Model(&models.MLJobItem{}).
+			Where("status = ?", models.MLJobItemRunning).
+			Update("status", models.MLJobItemPending).Error; err != nil {
+			log.Printf("mljobs: failed to requeue interrupted items: %v", err)
+		}
+
+		shared = &pool{
+			wake:    make(chan struct{}, workers),
+			running: map[uint]map[uint]context.CancelFunc{},
+		}
+		for i := 0; i < workers; i++ {
+			go shared.work()
+		}
+		log.Printf("mljobs: started %d workers", workers)
+	})
+}
+
+// notifyWorkers wakes idle workers after new items were queued.
+func notifyWorkers() {
+	if shared == nil {
+		return
+	}
+	for i := 0; i < cap(shared.wake); i++ {
+		select {
+		case shared.wake <- struct{}{}:
+		default:
+			return
+		}
+	}
+}
+
+// Submit queues a job scoring the given patients for the doctor.
+func Submit(doctor *models.User, kind string, patientIDs []uint, refresh bool) (*models.MLJob, error) {
+	if kind != models.MLJobKindRisk {
+		return nil, ErrUnknownKind
+	}
+	if len(patientIDs) == 0 {
+		return nil, ErrNoPatients
+	}
+	if len(patientIDs) > MaxPatients {
+		return nil, ErrTooManyItems
+	}
+
+	job := models.MLJob{
+		Kind:        kind,
+		Status:      models.MLJobQueued,
+		CreatedByID: doctor.ID,
+		Refresh:     refresh,
+		Total:       len(patientIDs),
+	}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var active int64
+		if err := tx.Model(&models.MLJob{}).
+			Where("created_by_id = ? AND finished_at IS NULL", doctor.ID).
+			Count(&active).Error; err != nil {
+			return err
+		}
+		if active >= MaxActiveJobs {
+			return ErrTooManyActive
+		}
+		if err := tx.Create(&job).Error; err != nil {
+			return err
+		}
+		items := make([]models.MLJobItem, 0, len(patientIDs))
+		for _, id := range patientIDs {
+			items = append(items, models.MLJobItem{JobID: job.ID, PatientID: id, Status: models.MLJobItemPending})
+		}
+		return tx.CreateInBatches(&items, 500).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	notifyWorkers()
+	return &job, nil
+}
+
+// Cancel stops a job: pending items are dropped and items being scored are
+// interrupted. Items that already finished keep their results.
+func Cancel(jobID uint) error {
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.MLJob{}).
+			Where("id = ? AND finished_at IS NULL", jobID).
+			Update("cancel_requested", true)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrFinished
+		}
+		dropped := tx.Model(&models.MLJobItem{}).
+			Where("job_id = ? AND status = ?", jobID, models.MLJobItemPending).
+			Updates(map[string]interface{}{"status": models.MLJobItemCancelled, "next_attempt_at": nil})
+		if dropped.Error != nil {
+			return dropped.Error
+		}
+		return tx.Model(&models.MLJob{}).Where("id = ?", jobID).
+			Update("cancelled", gorm.Expr("cancelled + ?", dropped.RowsAffected)).Error
+	})
+	if err != nil {
+		return err
+	}
+	if shared != nil {
+		shared.mu.Lock()
+		for _, cancel := range shared.running[jobID] {
+			cancel()
+		}
+		shared.mu.Unlock()
+	}
+	return finalize(jobID)
+}
+
+func (p *pool) work() {
+	for {
+		item, err := claim(time.Now())
+		if err != nil {
+			log.Printf("mljobs: failed to claim an item: %v", err)
+			time.Sleep(pollInterval)
+			continue
+		}
+		if item == nil {
+			select {
+			case <-p.wake:
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+		p.process(item)
+	}
+}
+
+// claim takes the oldest job's next due item and marks it running. Locked
+// rows are skipped, so workers never claim the same item.
+func claim(now time.Time) (*models.MLJobItem, error) {
+	var claimed *models.MLJobItem
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var items []models.MLJobItem
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?)", models.MLJobItemPending, now).
+			Order("job_id, id").Limit(1).
+			Find(&items).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		item := &items[0]
+		item.Status = models.MLJobItemRunning
+		item.Attempts++
+		if err := tx.Model(item).Updates(map[string]interface{}{"status": item.Status, "attempts": item.Attempts}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.MLJob{}).
+			Where("id = ? AND status = ?", item.JobID, models.MLJobQueued).
+			Updates(map[string]interface{}{"status": models.MLJobRunning, "started_at": now}).Error; err != nil {
+			return err
+		}
+		claimed = item
+		return nil
+	})
+	return claimed, err
+}
+
+func (p *pool) process(item *models.MLJobItem) {
+	var job models.MLJob
+	if err := db.DB.Preload("CreatedBy").First(&job, item.JobID).Error; err != nil {
+		log.Printf("mljobs: failed to load job %d: %v", item.JobID, err)
+		requeue(item, time.Now().Add(retryBackoff))
+		return
+	}
+	if job.CancelRequested {
+		finish(item, models.MLJobItemCancelled, nil, "")
+		return
+	}
+	if job.CreatedBy == nil || job.CreatedBy.Status != models.UserStatusActive {
+		finish(item, models.MLJobItemFailed, nil, "requesting doctor is no longer active")
+		return
+	}
+	// Consent may have changed since the job was queued
+	for _, scope := range scopes {
+		if err := access.Check(job.CreatedBy, item.PatientID, scope); err != nil {
+			if errors.Is(err, access.ErrNoConsent) || errors.Is(err, access.ErrForbidden) {
+				finish(item, models.MLJobItemFailed, nil, err.Error()+" ("+scope+")")
+				return
+			}
+			log.Printf("mljobs: access check for item %d failed: %v", item.ID, err)
+			requeue(item, time.Now().Add(retryBackoff))
+			return
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.track(item, cancel)
+	assessment, err := risk.Assess(ctx, db.DB, mlclient.Shared(), item.PatientID, &job.CreatedByID, job.Refresh)
+	p.untrack(item)
+	cancelled := ctx.Err() != nil
+	cancel()
+
+	switch {
+	case err == nil:
+		finish(item, models.MLJobItemSucceeded, &assessment.Record.ID, "")
+	case cancelled:
+		finish(item, models.MLJobItemCancelled, nil, "")
+	case transient(err) && item.Attempts < maxAttempts:
+		requeue(item, time.Now().Add(retryBackoff*time.Duration(item.Attempts)))
+	default:
+		finish(item, models.MLJobItemFailed, nil, err.Error())
+	}
+}
+
+// transient reports whether an ML failure may pass if the item waits.
+func transient(err error) bool {
+	return errors.Is(err, mlclient.ErrUnavailable) || errors.Is(err, mlclient.ErrCircuitOpen) || errors.Is(err, context.DeadlineExceeded)
+}
+
+func (p *pool) track(item *models.MLJobItem, cancel context.CancelFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.running[item.JobID] == nil {
+		p.running[item.JobID] = map[uint]context.CancelFunc{}
+	}
+	p.running[item.JobID][item.ID] = cancel
+}
+
+func (p *pool) untrack(item *models.MLJobItem) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.running[item.JobID], item.ID)
+	if len(p.running[item.JobID]) == 0 {
+		delete(p.running, item.JobID)
+	}
+}
+
+// requeue puts an item back to be tried again at the given time.
+func requeue(item *models.MLJobItem, at time.Time) {
+	if err := db.DB.Model(item).Updates(map[string]interface{}{
+		"status":          models.MLJobItemPending,
+		"next_attempt_at": at,
+	}).Error; err != nil {
+		log.Printf("mljobs: failed to requeue item %d: %v", item.ID, err)
+	}
+}
+
+// finish records an item's outcome on the item and its job's counters.
+func finish(item *models.MLJobItem, status models.MLJobItemStatus, assessmentID *uint, message string) {
+	counter := map[models.MLJobItemStatus]string{
+		models.MLJobItemSucceeded: "succeeded",
+		models.MLJobItemFailed:    "failed",
+		models.MLJobItemCancelled: "cancelled",
+	}[status]
+	message = textutil.Truncate(message, maxErrorLength)
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.MLJobItem{}).
+			Where("id = ? AND status = ?", item.ID, models.MLJobItemRunning).
+			Updates(map[string]interface{}{
+				"status":          status,
+				"assessment_id":   assessmentID,
+				"error":           message,
+				"next_attempt_at": nil,
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return tx.Model(&models.MLJob{}).Where("id = ?", item.JobID).
+			Update(counter, gorm.Expr(counter+" + 1")).Error
+	})
+	if err != nil {
+		log.Printf("mljobs: failed to record item %d: %v", item.ID, err)
+		return
+	}
+	if err := finalize(item.JobID); err != nil {
+		log.Printf("mljobs: failed to finish job %d: %v", item.JobID, err)
+	}
+}
+
+// finalize closes the job once none of its items are left to score and
+// tells the doctor how it went.
+func finalize(jobID uint) error {
+	var open int64
+	if err := db.DB.Model(&models.MLJobItem{}).
+		Where("job_id = ? AND status IN ?", jobID, []models.MLJobItemStatus{models.MLJobItemPending, models.MLJobItemRunning}).
+		Count(&open).Error; err != nil {
+		return err
+	}
+	if open > 0 {
+		return nil
+	}
+	var job models.MLJob
+	if err := db.DB.First(&job, jobID).Error; err != nil {
+		return err
+	}
+	status := models.MLJobCompleted
+	switch {
+	case job.CancelRequested:
+		status = models.MLJobCancelled
+	case job.Failed > 0 && job.Succeeded == 0:
+		status = models.MLJobFailed
+	case job.Failed > 0:
+		status = models.MLJobPartial
+	}
+	result := db.DB.Model(&models.MLJob{}).
+		Where("id = ? AND finished_at IS NULL", jobID).
+		Updates(map[string]interface{}{"status": status, "finished_at": time.Now()})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+
+	body := fmt.Sprintf("%d of %d patients were scored.", job.Succeeded, job.Total)
+	if job.Failed > 0 {
+		body += fmt.Sprintf(" %d could not be scored.", job.Failed)
+	}
+	if status == models.MLJobCancelled {
+		body = "The job was cancelled. " + body
+	}
+	notify.Send([]uint{job.CreatedByID}, "ml_job_finished", "Risk scoring finished", body)
+	return nil
+}
@@ -0,0 +1,101 @@
+package mljobs
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"medapp/internal/mlclient"
+	"medapp/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Result is one patient's outcome in a job.
+type Result struct {
+	PatientID    uint                     `json:"patientId"`
+	Status       models.MLJobItemStatus   `json:"status"`
+	Error        string                   `json:"error,omitempty"`
+	AssessmentID *uint                    `json:"assessmentId"`
+	ModelVersion string                   `json:"modelVersion,omitempty"`
+	AssessedAt   *time.Time               `json:"assessedAt,omitempty"`
+	Risks        []mlclient.ConditionRisk `json:"risks"`
+}
+
+// Results lists the outcome for every patient of the job, in the order they
+// were queued. Items still waiting are included with their current status.
+func Results(tx *gorm.DB, jobID uint) ([]Result, error) {
+	var items []models.MLJobItem
+	if err := tx.Preload("Assessment").Where("job_id = ?", jobID).Order("id").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	results := make([]Result, 0, len(items))
+	for _, item := range items {
+		result := Result{
+			PatientID:    item.PatientID,
+			Status:       item.Status,
+			Error:        item.Error,
+			AssessmentID: item.AssessmentID,
+			Risks:        []mlclient.ConditionRisk{},
+		}
+		if item.Assessment != nil {
+			var response mlclient.RiskResponse
+			if err := json.Unmarshal([]byte(item.Assessment.Result), &response); err != nil {
+				return nil, err
+			}
+			result.ModelVersion = item.Assessment.ModelVersion
+			result.AssessedAt = &item.Assessment.CreatedAt
+			if response.Risks != nil {
+				result.Risks = response.Risks
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+var csvHeader = []string{"patient_id", "status", "model_version", "assessed_at", "condition", "code", "risk", "level", "factors", "error"}
+
+// WriteCSV writes one row per patient and condition; patients without risks
+// get a single row with their status and error.
+func WriteCSV(w io.Writer, results []Result) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, r := range results {
+		assessedAt := ""
+		if r.AssessedAt != nil {
+			assessedAt = r.AssessedAt.UTC().Format(time.RFC3339)
+		}
+		prefix := []string{strconv.FormatUint(uint64(r.PatientID), 10), string(r.Status), r.ModelVersion, assessedAt}
+		if len(r.Risks) == 0 {
+			if err := out.Write(append(prefix, "", "", "", "", "", r.Error)); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, risk := range r.Risks {
+			factors := make([]string, 0, len(risk.Factors))
+			for _, f := range risk.Factors {
+				factors = append(factors, f.Feature+"="+strconv.FormatFloat(f.Contribution, 'f', 3, 64))
+			}
+			row := append(append([]string{}, prefix...),
+				risk.Condition,
+				risk.Code,
+				strconv.FormatFloat(risk.Risk, 'f', 3, 64),
+				risk.Level,
+				strings.Join(factors, "; "),
+				r.Error,
+			)
+			if err := out.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
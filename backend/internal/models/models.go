@@ -1,6 +1,14 @@
 package models
 
-import "time"
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"medapp/internal/fieldcrypt"
+
+	"gorm.io/gorm"
+)
 
 type Role string
 
@@ -19,6 +27,22 @@ const (
 	AppointmentCancelled AppointmentStatus = "cancelled"
 )
 
+type VerificationStatus string
+
+const (
+	VerificationUnverified VerificationStatus = "unverified"
+	VerificationPending    VerificationStatus = "pending"
+	VerificationVerified   VerificationStatus = "verified"
+	VerificationRejected   VerificationStatus = "rejected"
+)
+
+const (
+	UserStatusActive      = "active"
+	UserStatusDeactivated = "deactivated"
+	UserStatusErased      = "erased" // personal data removed after an erasure request
+	UserStatusMerged      = "merged" // duplicate record merged into another patient
+)
+
 type User struct {
 	ID           uint      `gorm:"primaryKey" json:"id"`
 	CreatedAt    time.Time `json:"createdAt"`
@@ -30,15 +54,18 @@ type User struct {
 	Role         Role      `gorm:"type:varchar(20);not null" json:"role"`
 	Status       string    `gorm:"size:50;default:'active'" json:"status"`
 
-	DoctorProfile         *DoctorProfile  `json:"doctorProfile,omitempty"`
-	PatientProfile        *PatientProfile `json:"patientProfile,omitempty"`
+	MustResetPassword bool       `gorm:"default:false" json:"mustResetPassword"`
+	PasswordChangedAt *time.Time `json:"-"` // tokens issued before this are rejected
+
+	DoctorProfile         *DoctorProfile       `json:"doctorProfile,omitempty"`
+	PatientProfile        *PatientProfile      `json:"patientProfile,omitempty"`
 	Videos                []Video              `json:"videos,omitempty" gorm:"foreignKey:UploaderID"`
 	AppointmentsAsDoctor  []Appointment        `gorm:"foreignKey:DoctorID" json:"-"`
 	AppointmentsAsPatient []Appointment        `gorm:"foreignKey:PatientID" json:"-"`
 	DoctorPatients        []DoctorPatient      `gorm:"foreignKey:DoctorID" json:"-"`
-	PatientDoctors         []DoctorPatient      `gorm:"foreignKey:PatientID" json:"-"`
-	MedicalInfo            *PatientMedicalInfo   `gorm:"foreignKey:PatientID" json:"medicalInfo,omitempty"`
-	MedicalInfoCreated    []PatientMedicalInfo  `gorm:"foreignKey:DoctorID" json:"-"`
+	PatientDoctors        []DoctorPatient      `gorm:"foreignKey:PatientID" json:"-"`
+	MedicalInfo           *PatientMedicalInfo  `gorm:"foreignKey:PatientID" json:"medicalInfo,omitempty"`
+	MedicalInfoCreated    []PatientMedicalInfo `gorm:"foreignKey:DoctorID" json:"-"`
 }
 
 type DoctorProfile struct {
@@ -55,6 +82,32 @@ type DoctorProfile struct {
 	AvatarURL       string    `gorm:"size:512" json:"avatarUrl"`
 	ConsultationFee int       `json:"consultationFee"`
 	User            *User     `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+
+	VerificationStatus VerificationStatus `gorm:"type:varchar(20);default:'unverified';index" json:"verificationStatus"`
+	VerifiedAt         *time.Time         `json:"verifiedAt,omitempty"`
+}
+
+// DoctorLicenseDocument is a license scan uploaded by a doctor for verification
+type DoctorLicenseDocument struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time `json:"createdAt"`
+	DoctorID    uint      `gorm:"index" json:"doctorId"`
+	FileName    string    `gorm:"size:255" json:"fileName"`
+	ContentType string    `gorm:"size:100" json:"contentType"`
+	Size        int64     `json:"size"`
+	StoragePath string    `gorm:"size:512;not null" json:"-"`
+	Doctor      *User     `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// DoctorVerificationEvent records every submission and admin decision on a doctor's license
+type DoctorVerificationEvent struct {
+	ID        uint               `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time          `json:"createdAt"`
+	DoctorID  uint               `gorm:"index" json:"doctorId"`
+	ActorID   uint               `json:"actorId"`
+	Status    VerificationStatus `gorm:"type:varchar(20)" json:"status"` // status after this event
+	Reason    string             `gorm:"type:text" json:"reason"`
+	Actor     *User              `json:"actor,omitempty" gorm:"constraint:OnDelete:SET NULL"`
 }
 
 type PatientProfile struct {
@@ -65,9 +118,9 @@ type PatientProfile struct {
 	DateOfBirth       *time.Time `json:"dateOfBirth"`
 	Gender            string     `gorm:"size:50" json:"gender"`
 	BloodType         string     `gorm:"size:10" json:"bloodType"`
-	Allergies         string     `gorm:"type:text" json:"allergies"`
-	ChronicConditions string     `gorm:"type:text" json:"chronicConditions"`
-	EmergencyContact  string     `gorm:"size:255" json:"emergencyContact"`
+	Allergies         string     `gorm:"type:text;serializer:encrypted" json:"allergies"`
+	ChronicConditions string     `gorm:"type:text;serializer:encrypted" json:"chronicConditions"`
+	EmergencyContact  string     `gorm:"type:text;serializer:encrypted" json:"emergencyContact"`
 	User              *User      `gorm:"constraint:OnDelete:CASCADE" json:"-"`
 }
 
@@ -94,41 +147,615 @@ type Appointment struct {
 	DurationMin int               `json:"durationMin"`
 	Status      AppointmentStatus `gorm:"type:varchar(20);default:'pending'" json:"status"`
 	Reason      string            `gorm:"type:text" json:"reason"`
-	Notes       string            `gorm:"type:text" json:"notes"`
+	Notes       string            `gorm:"type:text;serializer:encrypted" json:"notes"`
+	TriageID    *uint             `gorm:"index" json:"triageId"` // symptom check the patient attached
 	Doctor      *User             `json:"doctor,omitempty"`
 	Patient     *User             `json:"patient,omitempty"`
+	Triage      *TriageRecord     `json:"-" gorm:"constraint:OnDelete:SET NULL"`
 }
 
-// DoctorPatient represents the many-to-many relationship between doctors and patients
+// Roles a doctor can have in a patient's care team
+const (
+	CareRoleAttending  = "attending"
+	CareRoleSpecialist = "specialist"
+	CareRoleConsultant = "consultant"
+	CareRoleReferring  = "referring"
+	CareRoleOther      = "other"
+)
+
+var CareRoles = []string{CareRoleAttending, CareRoleSpecialist, CareRoleConsultant, CareRoleReferring, CareRoleOther}
+
+// DoctorPatient represents the many-to-many relationship between doctors and
+// patients. The doctors assigned to a patient form their care team, in which
+// at most one is the primary physician.
 type DoctorPatient struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
 	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
 	DoctorID  uint      `gorm:"index" json:"doctorId"`
-	PatientID uint      `gorm:"index" json:"patientId"`
+	PatientID uint      `gorm:"index;uniqueIndex:idx_doctor_patient_primary,where:is_primary" json:"patientId"`
+	IsPrimary bool      `gorm:"default:false" json:"isPrimary"`
+	CareRole  string    `gorm:"size:20;default:'attending'" json:"careRole"`
 	Doctor    *User     `json:"doctor,omitempty" gorm:"constraint:OnDelete:CASCADE"`
 	Patient   *User     `json:"patient,omitempty" gorm:"constraint:OnDelete:CASCADE"`
 }
 
 // PatientMedicalInfo stores medical information filled by doctors
 type PatientMedicalInfo struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+	PatientID     uint      `gorm:"uniqueIndex" json:"patientId"`
+	DoctorID      uint      `gorm:"index" json:"doctorId"` // Doctor who filled this info
+	Gender        string    `gorm:"type:text;serializer:encrypted" json:"gender"`
+	AgeGroup      string    `gorm:"type:text;serializer:encrypted" json:"ageGroup"` // e.g., "0-18", "19-35", "36-50", "51-65", "65+"
+	GenderIndex   string    `gorm:"size:64;index" json:"-"`                         // blind index of Gender
+	AgeGroupIndex string    `gorm:"size:64;index" json:"-"`                         // blind index of AgeGroup
+	DiseaseIDs    string    `gorm:"type:text" json:"-"`                             // JSON array of disease IDs
+	Version       int       `gorm:"not null;default:1" json:"version"`              // bumped on every change; see PatientMedicalInfoRevision
+	Diseases      []Disease `gorm:"many2many:patient_medical_info_diseases;" json:"diseases"`
+	Patient       *User     `json:"patient,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+	Doctor        *User     `json:"doctor,omitempty" gorm:"constraint:OnDelete:SET NULL"`
+}
+
+// BeforeSave keeps the blind indexes in step with the encrypted values.
+func (m *PatientMedicalInfo) BeforeSave(tx *gorm.DB) (err error) {
+	if m.GenderIndex, err = fieldcrypt.BlindIndex(m.Gender); err != nil {
+		return err
+	}
+	m.AgeGroupIndex, err = fieldcrypt.BlindIndex(m.AgeGroup)
+	return err
+}
+
+// RevisionDisease is a disease as it was recorded in a revision, kept by
+// value so history survives later catalog edits.
+type RevisionDisease struct {
+	ID   uint   `json:"id"`
+	Code string `json:"code,omitempty"`
+	Name string `json:"name"`
+}
+
+// PatientMedicalInfoRevision is the state of a patient's medical info after
+// one change, with the doctor who made it.
+type PatientMedicalInfoRevision struct {
+	ID            uint                `gorm:"primaryKey" json:"id"`
+	CreatedAt     time.Time           `json:"createdAt"`
+	MedicalInfoID uint                `gorm:"uniqueIndex:idx_medical_info_revision_version" json:"medicalInfoId"`
+	Version       int                 `gorm:"uniqueIndex:idx_medical_info_revision_version" json:"version"`
+	PatientID     uint                `gorm:"index" json:"patientId"`
+	AuthorID      *uint               `gorm:"index" json:"authorId"`
+	Gender        string              `gorm:"type:text;serializer:encrypted" json:"gender"`
+	AgeGroup      string              `gorm:"type:text;serializer:encrypted" json:"ageGroup"`
+	Diseases      []RevisionDisease   `gorm:"type:text;serializer:json" json:"diseases"`
+	Author        *User               `json:"author,omitempty" gorm:"constraint:OnDelete:SET NULL"`
+	MedicalInfo   *PatientMedicalInfo `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// ProblemStatus is the clinical status of a problem list entry
+type ProblemStatus string
+
+const (
+	ProblemActive    ProblemStatus = "active"
+	ProblemRemission ProblemStatus = "remission"
+	ProblemResolved  ProblemStatus = "resolved"
+)
+
+// ProblemSeverity grades a problem; it is optional
+type ProblemSeverity string
+
+const (
+	SeverityMild     ProblemSeverity = "mild"
+	SeverityModerate ProblemSeverity = "moderate"
+	SeveritySevere   ProblemSeverity = "severe"
+)
+
+// PatientProblem is one diagnosis on a patient's problem list. Active and
+// in-remission problems are the diseases listed on the patient's medical info;
+// resolved ones stay on the list as history. A disease has at most one
+// unresolved problem per patient.
+type PatientProblem struct {
+	ID            uint            `gorm:"primaryKey" json:"id"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	UpdatedAt     time.Time       `json:"updatedAt"`
+	PatientID     uint            `gorm:"index;uniqueIndex:idx_patient_problem_open,where:status <> 'resolved'" json:"patientId"`
+	DiseaseID     uint            `gorm:"index;uniqueIndex:idx_patient_problem_open,where:status <> 'resolved'" json:"diseaseId"`
+	Status        ProblemStatus   `gorm:"type:varchar(20);default:'active';index" json:"status"`
+	Severity      ProblemSeverity `gorm:"type:varchar(20);default:''" json:"severity"`
+	OnsetDate     *time.Time      `gorm:"type:date" json:"onsetDate"`
+	ResolvedDate  *time.Time      `gorm:"type:date" json:"resolvedDate"`
+	DiagnosedByID *uint           `gorm:"index" json:"diagnosedById"`
+	Notes         string          `gorm:"type:text;serializer:encrypted" json:"notes"`
+	Disease       *Disease        `json:"disease,omitempty"`
+	DiagnosedBy   *User           `json:"diagnosedBy,omitempty" gorm:"constraint:OnDelete:SET NULL"`
+	Patient       *User           `json:"patient,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// Problem event types
+const (
+	ProblemEventRecorded      = "recorded"
+	ProblemEventStatusChanged = "status_changed"
+	ProblemEventUpdated       = "updated"
+)
+
+// PatientProblemEvent is one change to a problem, for the problem timeline.
+type PatientProblemEvent struct {
+	ID         uint            `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	ProblemID  uint            `gorm:"index" json:"problemId"`
+	PatientID  uint            `gorm:"index" json:"patientId"`
+	AuthorID   *uint           `gorm:"index" json:"authorId"`
+	Type       string          `gorm:"type:varchar(20)" json:"type"`
+	FromStatus ProblemStatus   `gorm:"type:varchar(20)" json:"fromStatus,omitempty"`
+	ToStatus   ProblemStatus   `gorm:"type:varchar(20)" json:"toStatus,omitempty"`
+	Detail     string          `gorm:"type:text" json:"detail,omitempty"` // which fields changed, never their values
+	Author     *User           `json:"author,omitempty" gorm:"constraint:OnDelete:SET NULL"`
+	Problem    *PatientProblem `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// Code systems used for Disease.CodeSystem
+const (
+	CodeSystemICD10   = "ICD-10"
+	CodeSystemICD10CM = "ICD-10-CM"
+)
+
+// Disease is an entry of the diagnosis catalog. Codes are unique within their
+// code system; retired entries stay on existing records but cannot be newly chosen.
+type Disease struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	Name        string     `gorm:"size:255;not null;index:idx_diseases_name_lookup" json:"name"`
+	Category    string     `gorm:"size:100" json:"category"` // e.g., "Chronic", "Infectious", "Genetic"
+	Description string     `gorm:"type:text" json:"description"`
+	Code        string     `gorm:"size:16;default:'';uniqueIndex:idx_diseases_system_code,where:code <> ''" json:"code"` // e.g. "E11.9"
+	CodeSystem  string     `gorm:"size:32;default:'ICD-10';uniqueIndex:idx_diseases_system_code,where:code <> ''" json:"codeSystem"`
+	SnomedCode  string     `gorm:"size:32;default:'';index" json:"snomedCode,omitempty"`
+	ParentID    *uint      `gorm:"index" json:"parentId"`
+	Retired     bool       `gorm:"default:false;index" json:"retired"`
+	RetiredAt   *time.Time `json:"retiredAt,omitempty"`
+	Parent      *Disease   `json:"-" gorm:"constraint:OnDelete:SET NULL"`
+}
+
+// DiseaseTranslation holds a disease's name and description in another
+// language; the Disease row itself is English.
+type DiseaseTranslation struct {
 	ID          uint      `gorm:"primaryKey" json:"id"`
 	CreatedAt   time.Time `json:"createdAt"`
 	UpdatedAt   time.Time `json:"updatedAt"`
-	PatientID   uint      `gorm:"uniqueIndex" json:"patientId"`
-	DoctorID    uint      `gorm:"index" json:"doctorId"` // Doctor who filled this info
-	Gender      string    `gorm:"size:50" json:"gender"`
-	AgeGroup    string    `gorm:"size:50" json:"ageGroup"` // e.g., "0-18", "19-35", "36-50", "51-65", "65+"
-	DiseaseIDs  string    `gorm:"type:text" json:"-"`      // JSON array of disease IDs
-	Diseases    []Disease `gorm:"many2many:patient_medical_info_diseases;" json:"diseases"`
-	Patient     *User     `json:"patient,omitempty" gorm:"constraint:OnDelete:CASCADE"`
-	Doctor      *User     `json:"doctor,omitempty" gorm:"constraint:OnDelete:SET NULL"`
+	DiseaseID   uint      `gorm:"uniqueIndex:idx_disease_translation_lang" json:"diseaseId"`
+	Lang        string    `gorm:"size:8;uniqueIndex:idx_disease_translation_lang" json:"lang"`
+	Name        string    `gorm:"size:255;not null" json:"name"`
+	Description string    `gorm:"type:text" json:"description"`
+	Disease     *Disease  `json:"-" gorm:"constraint:OnDelete:CASCADE"`
 }
 
-// Disease represents a chronic or other disease
-type Disease struct {
+// Symptom is an entry of the catalog the symptom checker offers. Codes are
+// what the ML service understands; retired symptoms are no longer offered.
+type Symptom struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	Code        string     `gorm:"size:64;not null;uniqueIndex" json:"code"` // e.g. "sore_throat"
+	Name        string     `gorm:"size:255;not null" json:"name"`
+	Category    string     `gorm:"size:100" json:"category"` // body system, e.g. "Respiratory"
+	Description string     `gorm:"type:text" json:"description"`
+	SortOrder   int        `gorm:"default:0" json:"sortOrder"`
+	Retired     bool       `gorm:"default:false;index" json:"retired"`
+	RetiredAt   *time.Time `json:"retiredAt,omitempty"`
+}
+
+// TriageRecord is one run of the symptom checker. Input and Output hold the
+// request sent to and the answer from the ML service as JSON; Prediction and
+// Confidence are the top result. A doctor reviewing the check records whether
+// the prediction matched the final diagnosis, which is kept as labeled data
+// for the model.
+type TriageRecord struct {
+	ID                  uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt           time.Time  `json:"createdAt"`
+	UpdatedAt           time.Time  `json:"updatedAt"`
+	UserID              uint       `gorm:"index" json:"userId"`    // who ran the check
+	PatientID           *uint      `gorm:"index" json:"patientId"` // set when a patient checked their own symptoms
+	SchemaVersion       int        `gorm:"not null;default:1" json:"schemaVersion"`
+	ModelVersion        string     `gorm:"size:64" json:"modelVersion"`
+	Input               string     `gorm:"type:text;serializer:encrypted" json:"-"`
+	Output              string     `gorm:"type:text;serializer:encrypted" json:"-"`
+	Prediction          string     `gorm:"type:text;serializer:encrypted" json:"prediction"`
+	Confidence          float64    `json:"confidence"`
+	Urgency             string     `gorm:"size:20" json:"urgency"`
+	FeedbackMatched     *bool      `json:"feedbackMatched"`
+	FeedbackDiagnosisID *uint      `gorm:"index" json:"feedbackDiagnosisId"`
+	FeedbackNote        string     `gorm:"type:text;serializer:encrypted" json:"feedbackNote"`
+	FeedbackByID        *uint      `gorm:"index" json:"feedbackById"`
+	FeedbackAt          *time.Time `json:"feedbackAt"`
+	User                *User      `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	FeedbackDiagnosis   *Disease   `json:"feedbackDiagnosis,omitempty" gorm:"constraint:OnDelete:SET NULL"`
+	FeedbackBy          *User      `json:"-" gorm:"constraint:OnDelete:SET NULL"`
+}
+
+// RiskAssessment is a chronic disease risk score computed by the ML service.
+// FeatureHash is a blind index of the features sent, so a later request for
+// an unchanged record is answered from the latest assessment.
+type RiskAssessment struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt     time.Time `json:"createdAt"`
+	PatientID     uint      `gorm:"index:idx_risk_assessment_lookup" json:"patientId"`
+	FeatureHash   string    `gorm:"size:64;index:idx_risk_assessment_lookup" json:"-"`
+	ModelVersion  string    `gorm:"size:64" json:"modelVersion"`
+	Features      string    `gorm:"type:text;serializer:encrypted" json:"-"` // JSON
+	Result        string    `gorm:"type:text;serializer:encrypted" json:"-"` // JSON
+	RequestedByID *uint     `gorm:"index" json:"requestedById"`
+	Patient       *User     `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	RequestedBy   *User     `json:"-" gorm:"constraint:OnDelete:SET NULL"`
+}
+
+// MLJobStatus is the state of a batch scoring job
+type MLJobStatus string
+
+const (
+	MLJobQueued    MLJobStatus = "queued"
+	MLJobRunning   MLJobStatus = "running"
+	MLJobCompleted MLJobStatus = "completed"
+	MLJobPartial   MLJobStatus = "partial" // finished, but some patients could not be scored
+	MLJobFailed    MLJobStatus = "failed"  // finished without scoring any patient
+	MLJobCancelled MLJobStatus = "cancelled"
+)
+
+// MLJobItemStatus is the state of one patient in a batch scoring job
+type MLJobItemStatus string
+
+const (
+	MLJobItemPending   MLJobItemStatus = "pending"
+	MLJobItemRunning   MLJobItemStatus = "running"
+	MLJobItemSucceeded MLJobItemStatus = "succeeded"
+	MLJobItemFailed    MLJobItemStatus = "failed"
+	MLJobItemCancelled MLJobItemStatus = "cancelled"
+)
+
+// Batch scoring job kinds
+const (
+	MLJobKindRisk = "risk"
+)
+
+// MLJob scores many patients in the background. The counters are kept up to
+// date as items finish, so polling a job does not count its items.
+type MLJob struct {
+	ID              uint        `gorm:"primaryKey" json:"id"`
+	CreatedAt       time.Time   `json:"createdAt"`
+	UpdatedAt       time.Time   `json:"updatedAt"`
+	Kind            string      `gorm:"size:32" json:"kind"`
+	Status          MLJobStatus `gorm:"type:varchar(20);default:'queued';index" json:"status"`
+	CreatedByID     uint        `gorm:"index" json:"createdById"`
+	Refresh         bool        `json:"refresh"` // score again even when the record is unchanged
+	Total           int         `json:"total"`
+	Succeeded       int         `json:"succeeded"`
+	Failed          int         `json:"failed"`
+	Cancelled       int         `json:"cancelled"`
+	CancelRequested bool        `json:"cancelRequested"`
+	StartedAt       *time.Time  `json:"startedAt"`
+	FinishedAt      *time.Time  `json:"finishedAt"`
+	CreatedBy       *User       `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// MLJobItem is one patient of a batch scoring job.
+type MLJobItem struct {
+	ID            uint            `gorm:"primaryKey" json:"id"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	UpdatedAt     time.Time       `json:"updatedAt"`
+	JobID         uint            `gorm:"index" json:"jobId"`
+	PatientID     uint            `gorm:"index" json:"patientId"`
+	Status        MLJobItemStatus `gorm:"type:varchar(20);default:'pending';index" json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt *time.Time      `json:"-"` // when a pending item that hit an ML outage may be retried
+	AssessmentID  *uint           `json:"assessmentId"`
+	Error         string          `gorm:"size:512" json:"error,omitempty"`
+	Job           *MLJob          `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Patient       *User           `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Assessment    *RiskAssessment `json:"-" gorm:"constraint:OnDelete:SET NULL"`
+}
+
+type ConsentStatus string
+
+const (
+	ConsentPending ConsentStatus = "pending"
+	ConsentActive  ConsentStatus = "active"
+	ConsentDenied  ConsentStatus = "denied"
+	ConsentRevoked ConsentStatus = "revoked"
+)
+
+// Consent scopes name the parts of a patient record a doctor may access
+const (
+	ScopeDemographics = "demographics"
+	ScopeConditions   = "conditions"
+	ScopeDocuments    = "documents"
+)
+
+var ConsentScopes = []string{ScopeDemographics, ScopeConditions, ScopeDocuments}
+
+// PatientConsent is a doctor's request for, and a patient's grant of, access to their record
+type PatientConsent struct {
+	ID           uint          `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time     `json:"createdAt"`
+	UpdatedAt    time.Time     `json:"updatedAt"`
+	PatientID    uint          `gorm:"index" json:"patientId"`
+	DoctorID     uint          `gorm:"index" json:"doctorId"`
+	Scopes       string        `gorm:"size:255" json:"-"` // comma-separated consent scopes
+	Status       ConsentStatus `gorm:"type:varchar(20);default:'pending';index" json:"status"`
+	Reason       string        `gorm:"type:text" json:"reason"`       // doctor's justification
+	ResponseNote string        `gorm:"type:text" json:"responseNote"` // patient's note when answering
+	DurationDays int           `json:"durationDays"`                  // requested validity, 0 = no expiry
+	RespondedAt  *time.Time    `json:"respondedAt"`
+	ExpiresAt    *time.Time    `json:"expiresAt"`
+	RevokedAt    *time.Time    `json:"revokedAt"`
+	Patient      *User         `json:"patient,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+	Doctor       *User         `json:"doctor,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+func (c *PatientConsent) ScopeList() []string {
+	if c.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(c.Scopes, ",")
+}
+
+func (c *PatientConsent) HasScope(scope string) bool {
+	for _, s := range c.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsActive reports whether the consent currently grants access.
+func (c *PatientConsent) IsActive(now time.Time) bool {
+	return c.Status == ConsentActive && (c.ExpiresAt == nil || c.ExpiresAt.After(now))
+}
+
+// PatientDocument is a file attached to a patient's record
+type PatientDocument struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time `json:"createdAt"`
+	PatientID    uint      `gorm:"index" json:"patientId"`
+	UploadedByID uint      `json:"uploadedById"`
+	Title        string    `gorm:"size:255" json:"title"`
+	FileName     string    `gorm:"size:255" json:"fileName"`
+	ContentType  string    `gorm:"size:100" json:"contentType"`
+	Size         int64     `json:"size"`
+	StoragePath  string    `gorm:"size:512;not null" json:"-"`
+	Patient      *User     `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+type EmergencyReviewStatus string
+
+const (
+	EmergencyReviewPending  EmergencyReviewStatus = "pending"
+	EmergencyReviewApproved EmergencyReviewStatus = "approved"
+	EmergencyReviewFlagged  EmergencyReviewStatus = "flagged"
+)
+
+// EmergencyAccess is a time-limited break-the-glass override giving a doctor
+// access to a patient outside their assignments and consents
+type EmergencyAccess struct {
+	ID           uint                  `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time             `json:"createdAt"`
+	UpdatedAt    time.Time             `json:"updatedAt"`
+	DoctorID     uint                  `gorm:"index" json:"doctorId"`
+	PatientID    uint                  `gorm:"index" json:"patientId"`
+	Reason       string                `gorm:"type:text;not null" json:"reason"`
+	ExpiresAt    time.Time             `json:"expiresAt"`
+	EndedAt      *time.Time            `json:"endedAt"`
+	ReviewStatus EmergencyReviewStatus `gorm:"type:varchar(20);default:'pending';index" json:"reviewStatus"`
+	ReviewedByID *uint                 `json:"reviewedById"`
+	ReviewNote   string                `gorm:"type:text" json:"reviewNote"`
+	ReviewedAt   *time.Time            `json:"reviewedAt"`
+	Doctor       *User                 `json:"doctor,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+	Patient      *User                 `json:"patient,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// IsActive reports whether the override still grants access.
+func (e *EmergencyAccess) IsActive(now time.Time) bool {
+	return e.EndedAt == nil && e.ExpiresAt.After(now)
+}
+
+// Notification is an in-app message for a user
+type Notification struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UserID    uint       `gorm:"index" json:"userId"`
+	Kind      string     `gorm:"size:50" json:"kind"`
+	Title     string     `gorm:"size:255" json:"title"`
+	Body      string     `gorm:"type:text" json:"body"`
+	ReadAt    *time.Time `json:"readAt"`
+	User      *User      `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// AuditLog is an append-only, hash-chained record of access to patient data
+type AuditLog struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time `gorm:"index" json:"createdAt"`
+	ActorID    uint      `gorm:"index" json:"actorId"`
+	ActorRole  string    `gorm:"size:20" json:"actorRole"`
+	Action     string    `gorm:"size:20;index" json:"action"`   // read, create, update, delete
+	Resource   string    `gorm:"size:50;index" json:"resource"` // e.g. "medical_info", "appointment"
+	ResourceID string    `gorm:"size:100" json:"resourceId"`
+	PatientID  *uint     `gorm:"index" json:"patientId"`
+	IP         string    `gorm:"size:64" json:"ip"`
+	UserAgent  string    `gorm:"size:512" json:"userAgent"`
+	Outcome    string    `gorm:"size:20" json:"outcome"` // success, denied, error
+	Detail     string    `gorm:"type:text" json:"detail"`
+	PrevHash   string    `gorm:"size:64" json:"prevHash"`
+	Hash       string    `gorm:"size:64;uniqueIndex" json:"hash"`
+
+	EmergencyAccessID *uint `gorm:"index" json:"emergencyAccessId,omitempty"` // set when made under a break-the-glass override
+}
+
+// BeforeUpdate keeps audit entries immutable at the ORM level; the database
+// trigger installed at migration time covers everything else.
+func (a *AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return errors.New("audit log entries are immutable")
+}
+
+func (a *AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return errors.New("audit log entries are immutable")
+}
+
+// VideoView records that a signed-in user opened a video
+type VideoView struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"viewedAt"`
+	UserID    uint      `gorm:"index" json:"userId"`
+	VideoID   uint      `gorm:"index" json:"videoId"`
+	Video     *Video    `json:"video,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+	User      *User     `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+type ErasureStatus string
+
+const (
+	ErasurePending   ErasureStatus = "pending"
+	ErasureRejected  ErasureStatus = "rejected"
+	ErasureCancelled ErasureStatus = "cancelled"
+	ErasureCompleted ErasureStatus = "completed"
+)
+
+// ErasureRequest is a patient's request to close their account and remove
+// their personal data. Clinical records are kept until RetainUntil.
+type ErasureRequest struct {
+	ID           uint          `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time     `json:"createdAt"`
+	UpdatedAt    time.Time     `json:"updatedAt"`
+	UserID       uint          `gorm:"index" json:"userId"`
+	Reason       string        `gorm:"type:text" json:"reason"`
+	Status       ErasureStatus `gorm:"type:varchar(20);default:'pending';index" json:"status"`
+	ReviewedByID *uint         `json:"reviewedById"`
+	ReviewNote   string        `gorm:"type:text" json:"reviewNote"`
+	ReviewedAt   *time.Time    `json:"reviewedAt"`
+	CompletedAt  *time.Time    `json:"completedAt"`
+	RetainUntil  *time.Time    `gorm:"index" json:"retainUntil"` // clinical records are purged after this
+	PurgedAt     *time.Time    `json:"purgedAt"`
+	User         *User         `json:"user,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// PatientIdentifier links a patient to their id in another system, such as a
+// hospital medical record number received in a FHIR import
+type PatientIdentifier struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	PatientID uint      `gorm:"index" json:"patientId"`
+	System    string    `gorm:"size:255;uniqueIndex:idx_patient_identifier_system_value" json:"system"`
+	Value     string    `gorm:"size:255;uniqueIndex:idx_patient_identifier_system_value" json:"value"`
+	Patient   *User     `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// HL7MessageStatus tracks whether an inbound HL7 message was applied.
+type HL7MessageStatus string
+
+const (
+	HL7MessageAccepted HL7MessageStatus = "accepted"
+	HL7MessageRejected HL7MessageStatus = "rejected"
+)
+
+// HL7Message is an ADT message received over MLLP. The raw text is kept so
+// that rejected messages can be replayed once the cause is fixed.
+type HL7Message struct {
+	ID              uint             `gorm:"primaryKey" json:"id"`
+	CreatedAt       time.Time        `json:"createdAt"`
+	UpdatedAt       time.Time        `json:"updatedAt"`
+	ControlID       string           `gorm:"size:64;index" json:"controlId"`
+	MessageType     string           `gorm:"size:16" json:"messageType"`
+	Event           string           `gorm:"size:16;index" json:"event"`
+	SendingApp      string           `gorm:"size:255" json:"sendingApp"`
+	SendingFacility string           `gorm:"size:255" json:"sendingFacility"`
+	RemoteAddr      string           `gorm:"size:64" json:"remoteAddr"`
+	Status          HL7MessageStatus `gorm:"type:varchar(20);index" json:"status"`
+	Error           string           `gorm:"type:text" json:"error"`
+	PatientID       *uint            `gorm:"index" json:"patientId"`
+	Attempts        int              `gorm:"default:1" json:"attempts"`
+	LastAttemptAt   time.Time        `json:"lastAttemptAt"`
+	Raw             string           `gorm:"type:text;serializer:encrypted" json:"raw,omitempty"`
+	Patient         *User            `json:"-" gorm:"constraint:OnDelete:SET NULL"`
+}
+
+type DuplicateStatus string
+
+const (
+	DuplicatePending   DuplicateStatus = "pending"
+	DuplicateMerged    DuplicateStatus = "merged"
+	DuplicateDismissed DuplicateStatus = "dismissed"
+)
+
+// PatientDuplicate is a pair of patient records that may belong to the same
+// person, found by the duplicate scan and waiting for an admin's review.
+// PatientID is always the lower of the two ids.
+type PatientDuplicate struct {
+	ID             uint            `gorm:"primaryKey" json:"id"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	UpdatedAt      time.Time       `json:"updatedAt"`
+	PatientID      uint            `gorm:"uniqueIndex:idx_patient_duplicate_pair" json:"patientId"`
+	OtherID        uint            `gorm:"uniqueIndex:idx_patient_duplicate_pair;index" json:"otherId"`
+	Score          float64         `gorm:"index" json:"score"` // 0-1, higher is more likely the same person
+	NameSimilarity float64         `json:"nameSimilarity"`     // trigram similarity of the full names
+	SameBirthDate  *bool           `json:"sameBirthDate"`      // nil when either date is unknown
+	SamePhone      bool            `json:"samePhone"`
+	Status         DuplicateStatus `gorm:"type:varchar(20);default:'pending';index" json:"status"`
+	ReviewedByID   *uint           `json:"reviewedById"`
+	ReviewNote     string          `gorm:"type:text" json:"reviewNote"`
+	ReviewedAt     *time.Time      `json:"reviewedAt"`
+	Patient        *User           `json:"patient,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+	Other          *User           `json:"other,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// PatientMergeRedirect records that a patient record was merged into another,
+// so links and identifiers using the old id still reach the patient.
+type PatientMergeRedirect struct {
 	ID          uint      `gorm:"primaryKey" json:"id"`
 	CreatedAt   time.Time `json:"createdAt"`
-	Name        string    `gorm:"size:255;uniqueIndex;not null" json:"name"`
-	Category    string    `gorm:"size:100" json:"category"` // e.g., "Chronic", "Infectious", "Genetic"
-	Description string    `gorm:"type:text" json:"description"`
+	FromID      uint      `gorm:"uniqueIndex" json:"fromId"`
+	ToID        uint      `gorm:"index" json:"toId"`
+	MergedByID  uint      `json:"mergedById"`
+	DuplicateID *uint     `json:"duplicateId"`
+}
+
+type ReferralStatus string
+
+const (
+	ReferralPending   ReferralStatus = "pending"
+	ReferralAccepted  ReferralStatus = "accepted"
+	ReferralDeclined  ReferralStatus = "declined"
+	ReferralCancelled ReferralStatus = "cancelled"
+	ReferralCompleted ReferralStatus = "completed"
+)
+
+type ReferralUrgency string
+
+const (
+	UrgencyRoutine ReferralUrgency = "routine"
+	UrgencySoon    ReferralUrgency = "soon"
+	UrgencyUrgent  ReferralUrgency = "urgent"
+)
+
+// Referral hands a patient from one doctor to another, either a named doctor
+// or any doctor of a speciality. Accepting it adds the receiving doctor to
+// the patient's care team.
+type Referral struct {
+	ID            uint              `gorm:"primaryKey" json:"id"`
+	CreatedAt     time.Time         `json:"createdAt"`
+	UpdatedAt     time.Time         `json:"updatedAt"`
+	PatientID     uint              `gorm:"index" json:"patientId"`
+	FromDoctorID  uint              `gorm:"index" json:"fromDoctorId"`
+	ToDoctorID    *uint             `gorm:"index" json:"toDoctorId"`          // nil until a doctor of Speciality accepts
+	Speciality    string            `gorm:"size:255;index" json:"speciality"` // set when referred to a speciality rather than a doctor
+	Reason        string            `gorm:"type:text;serializer:encrypted" json:"reason"`
+	Notes         string            `gorm:"type:text;serializer:encrypted" json:"notes"`
+	Urgency       ReferralUrgency   `gorm:"type:varchar(20);default:'routine';index" json:"urgency"`
+	Status        ReferralStatus    `gorm:"type:varchar(20);default:'pending';index" json:"status"`
+	AutoBook      bool              `gorm:"default:false" json:"autoBook"` // book the first free slot on acceptance
+	AppointmentID *uint             `json:"appointmentId"`
+	ResponseNote  string            `gorm:"type:text" json:"responseNote"`
+	RespondedAt   *time.Time        `json:"respondedAt"`
+	Documents     []PatientDocument `gorm:"many2many:referral_documents;constraint:OnDelete:CASCADE" json:"documents,omitempty"`
+	Patient       *User             `json:"patient,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+	FromDoctor    *User             `json:"fromDoctor,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+	ToDoctor      *User             `json:"toDoctor,omitempty" gorm:"constraint:OnDelete:SET NULL"`
+	Appointment   *Appointment      `json:"appointment,omitempty" gorm:"constraint:OnDelete:SET NULL"`
 }
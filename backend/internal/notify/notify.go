@@ -0,0 +1,41 @@
+// Package notify delivers in-app notifications to users.
+package notify
+
+import (
+	"log"
+
+	"medapp/internal/db"
+	"medapp/internal/models"
+)
+
+// Send stores a notification for each user. Delivery failures are logged, not returned,
+// so that a notification problem never blocks the action that triggered it.
+func Send(userIDs []uint, kind, title, body string) {
+	if len(userIDs) == 0 {
+		return
+	}
+	notifications := make([]models.Notification, 0, len(userIDs))
+	for _, id := range userIDs {
+		notifications = append(notifications, models.Notification{
+			UserID: id,
+			Kind:   kind,
+			Title:  title,
+			Body:   body,
+		})
+	}
+	if err := db.DB.Create(&notifications).Error; err != nil {
+		log.Printf("notify: failed to send %q to %d users: %v", kind, len(userIDs), err)
+	}
+}
+
+// Admins notifies every active admin.
+func Admins(kind, title, body string) {
+	var ids []uint
+	if err := db.DB.Model(&models.User{}).
+		Where("role = ? AND status = ?", models.RoleAdmin, models.UserStatusActive).
+		Pluck("id", &ids).Error; err != nil {
+		log.Printf("notify: failed to load admins for %q: %v", kind, err)
+		return
+	}
+	Send(ids, kind, title, body)
+}
@@ -0,0 +1,454 @@
+// Package patientsearch finds patients for doctors and admins: free-text
+// matching on names (trigram and full-text when Postgres has pg_trgm), filters
+// on contact details, demographics, diseases and derived age, sorting, and
+// cursor pagination that stays stable while records are added.
+package patientsearch
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"medapp/internal/access"
+	"medapp/internal/ageband"
+	"medapp/internal/fieldcrypt"
+	"medapp/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// Sort orders.
+const (
+	SortName        = "name"
+	SortNameDesc    = "-name"
+	SortCreated     = "created"
+	SortCreatedDesc = "-created"
+	SortRelevance   = "relevance" // best name match first; needs q or name
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidSort   = errors.New("sort must be name, -name, created, -created or relevance")
+)
+
+// trigram is set by Setup when the pg_trgm extension is available.
+var trigram bool
+
+// Setup enables pg_trgm and creates the name search indexes. Without the
+// extension, name search falls back to full-text and substring matching.
+func Setup(db *gorm.DB) {
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
+		log.Printf("patientsearch: pg_trgm unavailable, using full-text name search only: %v", err)
+	} else if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_users_full_name_trgm ON users USING gin (full_name gin_trgm_ops)").Error; err != nil {
+		log.Printf("patientsearch: failed to create trigram index: %v", err)
+	} else {
+		trigram = true
+	}
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_users_full_name_fts ON users USING gin (to_tsvector('simple', full_name))").Error; err != nil {
+		log.Printf("patientsearch: failed to create full-text index: %v", err)
+	}
+}
+
+// Query is a patient search. Empty fields do not filter.
+type Query struct {
+	Text      string // name, email or phone
+	Name      string
+	Email     string
+	Phone     string
+	Disease   string // disease id, catalog code or part of its name
+	Gender    string
+	BloodType string
+	AgeGroup  string // label of a configured age band
+	MinAge    int
+	MaxAge    int // -1 when unbounded
+	Sort      string
+	Limit     int
+	Cursor    string
+}
+
+// Visibility limits a search to the patients a user may see.
+type Visibility struct {
+	All          bool   // admins see every patient
+	PatientIDs   []uint // patients whose demographics may be seen
+	ConditionIDs []uint // patients whose conditions may be seen, for disease and medical info filters
+	AssignedTo   uint   // when set, only patients assigned to this doctor
+}
+
+// VisibilityFor returns what user may search: admins see every patient and
+// doctors the patients who consented to share their demographics, narrowed to
+// their own assignments when assignedOnly is set.
+func VisibilityFor(user *models.User, assignedOnly bool) (Visibility, error) {
+	if user.Role == models.RoleAdmin {
+		return Visibility{All: true}, nil
+	}
+	var v Visibility
+	var err error
+	if v.PatientIDs, err = access.ConsentedPatientIDs(user.ID, models.ScopeDemographics); err != nil {
+		return v, err
+	}
+	if v.ConditionIDs, err = access.ConsentedPatientIDs(user.ID, models.ScopeConditions); err != nil {
+		return v, err
+	}
+	if assignedOnly {
+		v.AssignedTo = user.ID
+	}
+	return v, nil
+}
+
+// Page is one page of results.
+type Page struct {
+	Patients   []models.User // with PatientProfile loaded
+	Scores     map[uint]float64
+	NextCursor string
+}
+
+// FromRequest reads a query from the q, name, email, phone, disease, gender,
+// bloodType, ageGroup, minAge, maxAge, sort, limit and cursor parameters.
+func FromRequest(c *gin.Context) (Query, error) {
+	q := Query{
+		Text:      strings.TrimSpace(c.Query("q")),
+		Name:      strings.TrimSpace(c.Query("name")),
+		Email:     strings.TrimSpace(c.Query("email")),
+		Phone:     strings.TrimSpace(c.Query("phone")),
+		Disease:   strings.TrimSpace(c.Query("disease")),
+		Gender:    strings.TrimSpace(c.Query("gender")),
+		BloodType: strings.TrimSpace(c.Query("bloodType")),
+		AgeGroup:  c.Query("ageGroup"),
+		MaxAge:    -1,
+		Sort:      c.Query("sort"),
+		Limit:     DefaultLimit,
+		Cursor:    c.Query("cursor"),
+	}
+	for _, param := range []struct {
+		name   string
+		target *int
+	}{{"minAge", &q.MinAge}, {"maxAge", &q.MaxAge}, {"limit", &q.Limit}} {
+		if value := c.Query(param.name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return q, fmt.Errorf("invalid %s", param.name)
+			}
+			*param.target = n
+		}
+	}
+	if q.Limit < 1 || q.Limit > MaxLimit {
+		q.Limit = DefaultLimit
+	}
+	if q.MaxAge >= 0 && q.MaxAge < q.MinAge {
+		return q, errors.New("maxAge must not be less than minAge")
+	}
+	if q.Phone != "" && digits(q.Phone) == "" {
+		return q, errors.New("phone must contain digits")
+	}
+	if q.AgeGroup != "" {
+		if _, ok := ageband.Lookup(q.AgeGroup); !ok {
+			return q, errors.New("unknown age group")
+		}
+	}
+	if q.Sort == "" {
+		q.Sort = SortName
+		if q.searchesName() {
+			q.Sort = SortRelevance
+		}
+	}
+	switch q.Sort {
+	case SortName, SortNameDesc, SortCreated, SortCreatedDesc:
+	case SortRelevance:
+		if !q.searchesName() {
+			return q, errors.New("sort=relevance needs q or name")
+		}
+	default:
+		return q, ErrInvalidSort
+	}
+	return q, nil
+}
+
+func (q *Query) searchesName() bool {
+	return q.Text != "" || q.Name != ""
+}
+
+// cursor marks the last patient of a page.
+type cursor struct {
+	Sort    string     `json:"s"`
+	ID      uint       `json:"id"`
+	Name    string     `json:"n,omitempty"`
+	Created *time.Time `json:"c,omitempty"`
+	Score   *float64   `json:"r,omitempty"`
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value, sort string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != sort {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// likePattern escapes LIKE wildcards in s and wraps it for a substring match.
+func likePattern(s string) string {
+	return "%" + likeEscaper.Replace(s) + "%"
+}
+
+func digits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// nameMatch matches users.full_name against text.
+func nameMatch(text string) clause.Expr {
+	sql := "(users.full_name ILIKE ? OR to_tsvector('simple', users.full_name) @@ plainto_tsquery('simple', ?)"
+	vars := []interface{}{likePattern(text), text}
+	if trigram {
+		// % is pg_trgm's similarity operator and can use the trigram index
+		sql += " OR users.full_name % ?"
+		vars = append(vars, text)
+	}
+	return gorm.Expr(sql+")", vars...)
+}
+
+// nameScore rates how well users.full_name matches text: a prefix match beats
+// a substring match, and trigram similarity and full-text rank add to both.
+func nameScore(text string) clause.Expr {
+	sql := "(CASE WHEN users.full_name ILIKE ? THEN 1 WHEN users.full_name ILIKE ? THEN 0.5 ELSE 0 END" +
+		" + ts_rank(to_tsvector('simple', users.full_name), plainto_tsquery('simple', ?))"
+	vars := []interface{}{likeEscaper.Replace(text) + "%", likePattern(text), text}
+	if trigram {
+		sql += " + similarity(users.full_name, ?)"
+		vars = append(vars, text)
+	}
+	return gorm.Expr("("+sql+")::float8)", vars...)
+}
+
+// Search runs q within the patients v allows.
+func Search(tx *gorm.DB, q Query, v Visibility) (*Page, error) {
+	tx = tx.Session(&gorm.Session{NewDB: true})
+	query := tx.Model(&models.User{}).
+		Joins("LEFT JOIN patient_profiles ON patient_profiles.user_id = users.id").
+		Where("users.role = ? AND users.status NOT IN ?", models.RolePatient, []string{models.UserStatusErased, models.UserStatusMerged})
+
+	if !v.All {
+		if len(v.PatientIDs) == 0 {
+			return &Page{Scores: map[uint]float64{}}, nil
+		}
+		query = query.Where("users.id IN ?", v.PatientIDs)
+	}
+	if v.AssignedTo != 0 {
+		query = query.Where("users.id IN (SELECT patient_id FROM doctor_patients WHERE doctor_id = ?)", v.AssignedTo)
+	}
+	// Filters on medical info only consider patients whose conditions are visible
+	conditions := func() *gorm.DB {
+		if v.All {
+			return query
+		}
+		return query.Where("users.id IN ?", v.ConditionIDs)
+	}
+
+	if q.Text != "" {
+		text := q.Text
+		match := nameMatch(text)
+		if d := digits(text); len(d) >= 4 {
+			query = query.Where("(? OR users.email ILIKE ? OR regexp_replace(users.phone, '[^0-9]', '', 'g') LIKE ?)", match, likePattern(text), likePattern(d))
+		} else {
+			query = query.Where("(? OR users.email ILIKE ?)", match, likePattern(text))
+		}
+	}
+	if q.Name != "" {
+		query = query.Where("?", nameMatch(q.Name))
+	}
+	if q.Email != "" {
+		query = query.Where("users.email ILIKE ?", likePattern(q.Email))
+	}
+	if q.Phone != "" {
+		query = query.Where("regexp_replace(users.phone, '[^0-9]', '', 'g') LIKE ?", likePattern(digits(q.Phone)))
+	}
+	if q.BloodType != "" {
+		query = query.Where("UPPER(patient_profiles.blood_type) = ?", strings.ToUpper(q.BloodType))
+	}
+	if q.Disease != "" {
+		query = conditions()
+		diseases := tx.Table("diseases").Select("id")
+		if id, err := strconv.ParseUint(q.Disease, 10, 64); err == nil {
+			diseases = diseases.Where("id = ?", id)
+		} else {
+			diseases = diseases.Where("code ILIKE ? OR name ILIKE ?", likeEscaper.Replace(q.Disease)+"%", likePattern(q.Disease))
+		}
+		query = query.Where("users.id IN (?)", tx.Table("patient_medical_infos AS pmi").
+			Select("pmi.patient_id").
+			Joins("JOIN patient_medical_info_diseases AS j ON j.patient_medical_info_id = pmi.id").
+			Where("j.disease_id IN (?)", diseases))
+	}
+	if q.Gender != "" {
+		index, err := fieldcrypt.BlindIndex(q.Gender)
+		if err != nil {
+			return nil, err
+		}
+		// The profile gender is demographics; the one on medical info needs the conditions scope
+		medical := tx.Table("patient_medical_infos").Select("patient_id").Where("gender_index = ?", index)
+		if !v.All {
+			medical = medical.Where("patient_id IN ?", v.ConditionIDs)
+		}
+		query = query.Where("(LOWER(patient_profiles.gender) = LOWER(?) OR users.id IN (?))", q.Gender, medical)
+	}
+	if q.AgeGroup != "" || q.MinAge > 0 || q.MaxAge >= 0 {
+		min, max := q.MinAge, q.MaxAge
+		if q.AgeGroup != "" {
+			band, _ := ageband.Lookup(q.AgeGroup)
+			if band.Min > min {
+				min = band.Min
+			}
+			if band.Max >= 0 && (max < 0 || band.Max < max) {
+				max = band.Max
+			}
+		}
+		latest, earliest := ageband.BirthDates(min, max, time.Now())
+		byBirth := tx.Where("patient_profiles.date_of_birth <= ?", latest)
+		if earliest != nil {
+			byBirth = byBirth.Where("patient_profiles.date_of_birth > ?", *earliest)
+		}
+		// Without a date of birth, a recorded age group matches when the whole band is in range
+		labels := ageband.Labels(min, max)
+		indexes := make([]string, 0, len(labels))
+		for _, label := range labels {
+			index, err := fieldcrypt.BlindIndex(label)
+			if err != nil {
+				return nil, err
+			}
+			indexes = append(indexes, index)
+		}
+		manual := tx.Table("patient_medical_infos").Select("patient_id").Where("age_group_index IN ?", indexes)
+		if !v.All {
+			manual = manual.Where("patient_id IN ?", v.ConditionIDs)
+		}
+		query = query.Where(tx.Where(byBirth).Or(tx.Where("patient_profiles.date_of_birth IS NULL AND users.id IN (?)", manual)))
+	}
+
+	var order clause.Expr
+	var score clause.Expr
+	switch q.Sort {
+	case SortName:
+		order = gorm.Expr("LOWER(users.full_name) ASC, users.id ASC")
+	case SortNameDesc:
+		order = gorm.Expr("LOWER(users.full_name) DESC, users.id DESC")
+	case SortCreated:
+		order = gorm.Expr("users.created_at ASC, users.id ASC")
+	case SortCreatedDesc:
+		order = gorm.Expr("users.created_at DESC, users.id DESC")
+	case SortRelevance:
+		text := q.Text
+		if text == "" {
+			text = q.Name
+		}
+		score = nameScore(text)
+		order = gorm.Expr("? DESC, users.id ASC", score)
+	}
+
+	if q.Cursor != "" {
+		after, err := decodeCursor(q.Cursor, q.Sort)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case q.Sort == SortName:
+			query = query.Where("(LOWER(users.full_name), users.id) > (?, ?)", after.Name, after.ID)
+		case q.Sort == SortNameDesc:
+			query = query.Where("(LOWER(users.full_name), users.id) < (?, ?)", after.Name, after.ID)
+		case q.Sort == SortCreated && after.Created != nil:
+			query = query.Where("(users.created_at, users.id) > (?, ?)", *after.Created, after.ID)
+		case q.Sort == SortCreatedDesc && after.Created != nil:
+			query = query.Where("(users.created_at, users.id) < (?, ?)", *after.Created, after.ID)
+		case q.Sort == SortRelevance && after.Score != nil:
+			query = query.Where("(? < ? OR (? = ? AND users.id > ?))", score, *after.Score, score, *after.Score, after.ID)
+		default:
+			return nil, ErrInvalidCursor
+		}
+	}
+
+	type hit struct {
+		ID        uint
+		Name      string
+		CreatedAt time.Time
+		Score     float64
+	}
+	columns := "users.id, LOWER(users.full_name) AS name, users.created_at"
+	selectVars := []interface{}{}
+	if q.Sort == SortRelevance {
+		columns += ", ? AS score"
+		selectVars = append(selectVars, score)
+	}
+	var hits []hit
+	if err := query.Select(columns, selectVars...).
+		Clauses(clause.OrderBy{Expression: order}).
+		Limit(q.Limit + 1).
+		Scan(&hits).Error; err != nil {
+		return nil, err
+	}
+
+	page := &Page{Scores: make(map[uint]float64, len(hits))}
+	if len(hits) > q.Limit {
+		hits = hits[:q.Limit]
+		last := hits[len(hits)-1]
+		next := cursor{Sort: q.Sort, ID: last.ID}
+		switch q.Sort {
+		case SortName, SortNameDesc:
+			next.Name = last.Name
+		case SortCreated, SortCreatedDesc:
+			next.Created = &last.CreatedAt
+		case SortRelevance:
+			next.Score = &last.Score
+		}
+		page.NextCursor = encodeCursor(next)
+	}
+	if len(hits) == 0 {
+		return page, nil
+	}
+
+	ids := make([]uint, 0, len(hits))
+	for _, h := range hits {
+		ids = append(ids, h.ID)
+		if q.Sort == SortRelevance {
+			page.Scores[h.ID] = h.Score
+		}
+	}
+	var patients []models.User
+	if err := tx.Preload("PatientProfile").Where("id IN ?", ids).Find(&patients).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.User, len(patients))
+	for _, p := range patients {
+		byID[p.ID] = p
+	}
+	page.Patients = make([]models.User, 0, len(ids))
+	for _, id := range ids {
+		if p, ok := byID[id]; ok {
+			page.Patients = append(page.Patients, p)
+		}
+	}
+	return page, nil
+}
@@ -0,0 +1,307 @@
+// Package problemlist keeps each patient's problem list: every diagnosis with
+// its onset, status, severity and resolution, and an event for each change.
+// The unresolved problems are kept in step with the diseases on the patient's
+// medical info, which remains the quick "what does this patient have" view.
+package problemlist
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"medapp/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAlreadyOpen     = errors.New("patient already has an unresolved problem for this disease")
+	ErrInvalidStatus   = errors.New("status must be active, remission or resolved")
+	ErrInvalidSeverity = errors.New("severity must be mild, moderate or severe")
+	ErrInvalidDates    = errors.New("resolution date cannot be before onset date")
+)
+
+// ValidStatus reports whether s is a known problem status.
+func ValidStatus(s models.ProblemStatus) bool {
+	switch s {
+	case models.ProblemActive, models.ProblemRemission, models.ProblemResolved:
+		return true
+	}
+	return false
+}
+
+// ValidSeverity reports whether s is a known severity; empty means ungraded.
+func ValidSeverity(s models.ProblemSeverity) bool {
+	switch s {
+	case "", models.SeverityMild, models.SeverityModerate, models.SeveritySevere:
+		return true
+	}
+	return false
+}
+
+// Open reports whether a problem with status s still counts as a current diagnosis.
+func Open(s models.ProblemStatus) bool {
+	return s != models.ProblemResolved
+}
+
+func today() *time.Time {
+	now := time.Now().UTC()
+	d := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return &d
+}
+
+func authorRef(authorID uint) *uint {
+	if authorID == 0 {
+		return nil
+	}
+	return &authorID
+}
+
+// validate checks the fields of p and fills the resolution date of a resolved
+// problem, or clears it on an unresolved one.
+func validate(p *models.PatientProblem) error {
+	if p.Status == "" {
+		p.Status = models.ProblemActive
+	}
+	if !ValidStatus(p.Status) {
+		return ErrInvalidStatus
+	}
+	if !ValidSeverity(p.Severity) {
+		return ErrInvalidSeverity
+	}
+	if p.Status == models.ProblemResolved {
+		if p.ResolvedDate == nil {
+			p.ResolvedDate = today()
+		}
+	} else {
+		p.ResolvedDate = nil
+	}
+	if p.OnsetDate != nil && p.ResolvedDate != nil && p.ResolvedDate.Before(*p.OnsetDate) {
+		return ErrInvalidDates
+	}
+	return nil
+}
+
+func openProblemExists(tx *gorm.DB, patientID, diseaseID, exceptID uint) (bool, error) {
+	var count int64
+	err := tx.Model(&models.PatientProblem{}).
+		Where("patient_id = ? AND disease_id = ? AND status <> ? AND id <> ?", patientID, diseaseID, models.ProblemResolved, exceptID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Record adds p to the problem list. The diagnosing doctor defaults to the author.
+func Record(tx *gorm.DB, p *models.PatientProblem, authorID uint, detail string) error {
+	if err := validate(p); err != nil {
+		return err
+	}
+	if Open(p.Status) {
+		exists, err := openProblemExists(tx, p.PatientID, p.DiseaseID, 0)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrAlreadyOpen
+		}
+	}
+	if p.DiagnosedByID == nil {
+		p.DiagnosedByID = authorRef(authorID)
+	}
+	if err := tx.Omit("Disease", "DiagnosedBy", "Patient").Create(p).Error; err != nil {
+		return err
+	}
+	return tx.Create(&models.PatientProblemEvent{
+		ProblemID: p.ID,
+		PatientID: p.PatientID,
+		AuthorID:  authorRef(authorID),
+		Type:      models.ProblemEventRecorded,
+		ToStatus:  p.Status,
+		Detail:    detail,
+	}).Error
+}
+
+// Update saves the editable fields of after, which must be a changed copy of
+// before, and records what changed.
+func Update(tx *gorm.DB, before, after *models.PatientProblem, authorID uint) error {
+	if err := validate(after); err != nil {
+		return err
+	}
+	if !Open(before.Status) && Open(after.Status) {
+		exists, err := openProblemExists(tx, after.PatientID, after.DiseaseID, after.ID)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrAlreadyOpen
+		}
+	}
+
+	var changed []string
+	if after.Severity != before.Severity {
+		changed = append(changed, "severity")
+	}
+	if !sameDate(after.OnsetDate, before.OnsetDate) {
+		changed = append(changed, "onsetDate")
+	}
+	if !sameDate(after.ResolvedDate, before.ResolvedDate) && after.Status == before.Status {
+		changed = append(changed, "resolvedDate")
+	}
+	if after.Notes != before.Notes {
+		changed = append(changed, "notes")
+	}
+	if after.Status == before.Status && len(changed) == 0 {
+		return nil
+	}
+
+	if err := tx.Model(after).
+		Select("Status", "Severity", "OnsetDate", "ResolvedDate", "Notes").
+		Updates(after).Error; err != nil {
+		return err
+	}
+
+	event := models.PatientProblemEvent{
+		ProblemID: after.ID,
+		PatientID: after.PatientID,
+		AuthorID:  authorRef(authorID),
+		Type:      models.ProblemEventUpdated,
+		Detail:    strings.Join(changed, ", "),
+	}
+	if after.Status != before.Status {
+		event.Type = models.ProblemEventStatusChanged
+		event.FromStatus = before.Status
+		event.ToStatus = after.Status
+	}
+	return tx.Create(&event).Error
+}
+
+func sameDate(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Format("2006-01-02") == b.Format("2006-01-02")
+}
+
+// OpenDiseases returns the diseases of the patient's unresolved problems.
+func OpenDiseases(tx *gorm.DB, patientID uint) ([]models.Disease, error) {
+	var diseases []models.Disease
+	err := tx.Where("id IN (?)", tx.Model(&models.PatientProblem{}).Select("disease_id").
+		Where("patient_id = ? AND status <> ?", patientID, models.ProblemResolved)).
+		Order("id ASC").Find(&diseases).Error
+	return diseases, err
+}
+
+// Sync brings the problem list in line with the diseases now on the patient's
+// medical info: a disease without an unresolved problem gets an active one,
+// and unresolved problems for diseases no longer listed are resolved today.
+func Sync(tx *gorm.DB, patientID uint, diseases []models.Disease, authorID uint) error {
+	var open []models.PatientProblem
+	if err := tx.Where("patient_id = ? AND status <> ?", patientID, models.ProblemResolved).Find(&open).Error; err != nil {
+		return err
+	}
+	listed := make(map[uint]bool, len(diseases))
+	for _, d := range diseases {
+		listed[d.ID] = true
+	}
+	tracked := make(map[uint]bool, len(open))
+	for i := range open {
+		tracked[open[i].DiseaseID] = true
+		if listed[open[i].DiseaseID] {
+			continue
+		}
+		after := open[i]
+		after.Status = models.ProblemResolved
+		after.ResolvedDate = nil
+		if err := Update(tx, &open[i], &after, authorID); err != nil {
+			return err
+		}
+	}
+	for _, d := range diseases {
+		if tracked[d.ID] {
+			continue
+		}
+		problem := models.PatientProblem{PatientID: patientID, DiseaseID: d.ID, Status: models.ProblemActive}
+		if err := Record(tx, &problem, authorID, "added to medical info"); err != nil {
+			return err
+		}
+		tracked[d.ID] = true
+	}
+	return nil
+}
+
+// Backfill starts the problem list of patients whose medical info predates it,
+// with an active problem for each listed disease.
+func Backfill(tx *gorm.DB) (int, error) {
+	var infos []models.PatientMedicalInfo
+	if err := tx.Preload("Diseases").
+		Where("NOT EXISTS (SELECT 1 FROM patient_problems p WHERE p.patient_id = patient_medical_infos.patient_id)").
+		Find(&infos).Error; err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, info := range infos {
+		if len(info.Diseases) == 0 {
+			continue
+		}
+		if err := Sync(tx, info.PatientID, info.Diseases, info.DoctorID); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Timeline entry kinds. Onset and resolution are clinical dates; the others
+// are when the problem list was changed.
+const (
+	EntryOnset    = "onset"
+	EntryResolved = "resolved"
+)
+
+// Entry is one dated point on a patient's problem timeline.
+type Entry struct {
+	Date       time.Time            `json:"date"`
+	Kind       string               `json:"kind"`
+	ProblemID  uint                 `json:"problemId"`
+	Disease    *models.Disease      `json:"disease,omitempty"`
+	FromStatus models.ProblemStatus `json:"fromStatus,omitempty"`
+	ToStatus   models.ProblemStatus `json:"toStatus,omitempty"`
+	Detail     string               `json:"detail,omitempty"`
+	Author     *models.User         `json:"-"`
+}
+
+// Timeline merges the onset and resolution dates of problems with the events
+// that changed them, oldest first. Problems should have Disease preloaded and
+// events Author.
+func Timeline(problems []models.PatientProblem, events []models.PatientProblemEvent) []Entry {
+	byID := make(map[uint]*models.PatientProblem, len(problems))
+	entries := make([]Entry, 0, len(events)+len(problems))
+	for i := range problems {
+		p := &problems[i]
+		byID[p.ID] = p
+		if p.OnsetDate != nil {
+			entries = append(entries, Entry{Date: *p.OnsetDate, Kind: EntryOnset, ProblemID: p.ID, Disease: p.Disease})
+		}
+		if p.ResolvedDate != nil {
+			entries = append(entries, Entry{Date: *p.ResolvedDate, Kind: EntryResolved, ProblemID: p.ID, Disease: p.Disease, ToStatus: p.Status})
+		}
+	}
+	for _, e := range events {
+		p := byID[e.ProblemID]
+		if p == nil {
+			continue
+		}
+		entries = append(entries, Entry{
+			Date:       e.CreatedAt,
+			Kind:       e.Type,
+			ProblemID:  e.ProblemID,
+			Disease:    p.Disease,
+			FromStatus: e.FromStatus,
+			ToStatus:   e.ToStatus,
+			Detail:     e.Detail,
+			Author:     e.Author,
+		})
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Date.Before(entries[j].Date) })
+	return entries
+}
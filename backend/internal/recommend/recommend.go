@@ -0,0 +1,275 @@
+// Package recommend suggests doctors to book after a symptom check. Each
+// condition in the differential is mapped to the specialities that treat it,
+// and verified doctors are ranked by how well their speciality covers the
+// likely conditions, then by city, fee and how soon they can see the patient.
+package recommend
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"medapp/internal/mlclient"
+	"medapp/internal/models"
+	"medapp/internal/scheduling"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultLimit = 5
+	MaxLimit     = 20
+
+	// How far ahead free slots are looked for
+	slotWindow = 14 * 24 * time.Hour
+	// Only this many of the best speciality matches get their calendar checked
+	slotCandidates = 4
+
+	weightSpeciality = 0.55
+	weightSlot       = 0.2
+	weightCity       = 0.15
+	weightFee        = 0.1
+)
+
+// Area is a speciality as doctors write it in their profile; a profile
+// matches when its speciality contains one of the keywords.
+type Area struct {
+	Name     string
+	Keywords []string
+}
+
+var (
+	generalPractice  = Area{"General practice", []string{"general", "family", "therap", "internal medicine", "internist", "gp"}}
+	cardiology       = Area{"Cardiology", []string{"cardio"}}
+	pulmonology      = Area{"Pulmonology", []string{"pulmon", "respirat"}}
+	ent              = Area{"Otolaryngology", []string{"otolaryng", "ent", "ear, nose"}}
+	neurology        = Area{"Neurology", []string{"neuro"}}
+	gastroenterology = Area{"Gastroenterology", []string{"gastro"}}
+	urology          = Area{"Urology", []string{"urolog"}}
+	nephrology       = Area{"Nephrology", []string{"nephro"}}
+	endocrinology    = Area{"Endocrinology", []string{"endocrin", "diabet"}}
+	psychiatry       = Area{"Psychiatry", []string{"psychi", "psychol"}}
+	rheumatology     = Area{"Rheumatology", []string{"rheumat"}}
+	orthopedics      = Area{"Orthopedics", []string{"ortho", "traumat"}}
+	dermatology      = Area{"Dermatology", []string{"dermat"}}
+	allergology      = Area{"Allergy and immunology", []string{"allerg", "immunol"}}
+	infectious       = Area{"Infectious diseases", []string{"infect"}}
+	hematology       = Area{"Hematology", []string{"hemat", "haemat"}}
+	oncology         = Area{"Oncology", []string{"oncol"}}
+)
+
+// byPrefix maps ICD-10 code prefixes to specialities, most specific prefix
+// first. General practice is always added as the last resort.
+var byPrefix = []struct {
+	prefix string
+	areas  []Area
+}{
+	{"J00", []Area{ent}}, {"J01", []Area{ent}}, {"J02", []Area{ent}}, {"J03", []Area{ent}}, {"J06", []Area{ent}},
+	{"J45", []Area{pulmonology, allergology}},
+	{"U07", []Area{infectious, pulmonology}},
+	{"A09", []Area{gastroenterology, infectious}},
+	{"A", []Area{infectious}}, {"B", []Area{infectious}},
+	{"C", []Area{oncology}},
+	{"D", []Area{hematology}},
+	{"E", []Area{endocrinology}},
+	{"F", []Area{psychiatry}},
+	{"G", []Area{neurology}},
+	{"I", []Area{cardiology}},
+	{"J", []Area{pulmonology}},
+	{"K", []Area{gastroenterology}},
+	{"L", []Area{dermatology}},
+	{"M", []Area{rheumatology, orthopedics}},
+	{"N39", []Area{urology, nephrology}},
+	{"N", []Area{nephrology, urology}},
+	{"R51", []Area{neurology}},
+	{"R73", []Area{endocrinology}},
+	{"T78", []Area{allergology, dermatology}},
+}
+
+// Areas returns the specialities that treat the condition with the given
+// ICD-10 code, best suited first.
+func Areas(code string) []Area {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	var areas []Area
+	for _, entry := range byPrefix {
+		if code != "" && strings.HasPrefix(code, entry.prefix) {
+			areas = append(areas, entry.areas...)
+			break
+		}
+	}
+	return append(areas, generalPractice)
+}
+
+// Matches reports whether a doctor's speciality belongs to the area.
+func (a Area) Matches(speciality string) bool {
+	speciality = strings.ToLower(speciality)
+	for _, keyword := range a.Keywords {
+		// Short keywords such as "gp" and "ent" must be whole words
+		if len(keyword) <= 3 {
+			for _, word := range strings.FieldsFunc(speciality, func(r rune) bool { return r == ' ' || r == ',' || r == '/' || r == '-' }) {
+				if word == keyword {
+					return true
+				}
+			}
+			continue
+		}
+		if strings.Contains(speciality, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// Input is a symptom check's result and the patient's preferences.
+type Input struct {
+	Differential []mlclient.Differential
+	City         string
+	Limit        int
+	Now          time.Time
+}
+
+// Recommendation is a doctor worth booking, with why.
+type Recommendation struct {
+	Doctor       *models.User `json:"-"`
+	Score        float64      `json:"score"`
+	Speciality   string       `json:"speciality"` // the matched area
+	Condition    string       `json:"condition"`  // the condition that matched best
+	CityMatch    bool         `json:"cityMatch"`
+	NextFreeSlot *time.Time   `json:"nextFreeSlot"`
+}
+
+// Recommend ranks verified doctors for the differential.
+func Recommend(tx *gorm.DB, in Input) ([]Recommendation, error) {
+	if in.Limit <= 0 {
+		in.Limit = DefaultLimit
+	}
+	if in.Limit > MaxLimit {
+		in.Limit = MaxLimit
+	}
+	if in.Now.IsZero() {
+		in.Now = time.Now()
+	}
+	differential := in.Differential
+	if len(differential) == 0 {
+		// Without a differential only general practice can be suggested
+		differential = []mlclient.Differential{{Condition: "General check-up", Probability: 1}}
+	}
+
+	var doctors []models.User
+	if err := tx.
+		Preload("DoctorProfile").
+		Joins("JOIN doctor_profiles ON doctor_profiles.user_id = users.id").
+		Where("users.role = ? AND users.status = ?", models.RoleDoctor, models.UserStatusActive).
+		Where("doctor_profiles.verification_status = ?", models.VerificationVerified).
+		Find(&doctors).Error; err != nil {
+		return nil, err
+	}
+
+	var candidates []Recommendation
+	minFee, maxFee := -1, 0
+	for i := range doctors {
+		profile := doctors[i].DoctorProfile
+		if profile == nil {
+			continue
+		}
+		best, area, condition := specialityScore(profile.Speciality, differential)
+		if best <= 0 {
+			continue
+		}
+		candidates = append(candidates, Recommendation{
+			Doctor:     &doctors[i],
+			Score:      best,
+			Speciality: area,
+			Condition:  condition,
+			CityMatch:  in.City != "" && strings.EqualFold(strings.TrimSpace(profile.City), strings.TrimSpace(in.City)),
+		})
+		if minFee < 0 || profile.ConsultationFee < minFee {
+			minFee = profile.ConsultationFee
+		}
+		if profile.ConsultationFee > maxFee {
+			maxFee = profile.ConsultationFee
+		}
+	}
+
+	// Rank without calendars first, then look up slots for the front of the list
+	for i := range candidates {
+		c := &candidates[i]
+		score := c.Score * weightSpeciality
+		if c.CityMatch {
+			score += weightCity
+		}
+		if maxFee > minFee {
+			score += weightFee * float64(maxFee-c.Doctor.DoctorProfile.ConsultationFee) / float64(maxFee-minFee)
+		} else {
+			score += weightFee
+		}
+		c.Score = score
+	}
+	sortByScore(candidates)
+	if limit := in.Limit * slotCandidates; len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	for i := range candidates {
+		c := &candidates[i]
+		slot, err := scheduling.NextFreeSlot(tx, c.Doctor.ID, in.Now, scheduling.SlotLength(), slotWindow)
+		if err != nil {
+			if errors.Is(err, scheduling.ErrNoFreeSlot) {
+				continue
+			}
+			return nil, err
+		}
+		c.NextFreeSlot = &slot
+		wait := slot.Sub(in.Now)
+		c.Score += weightSlot * (1 - float64(wait)/float64(slotWindow))
+	}
+	sortByScore(candidates)
+	if len(candidates) > in.Limit {
+		candidates = candidates[:in.Limit]
+	}
+	for i := range candidates {
+		candidates[i].Score = float64(int(candidates[i].Score*1000+0.5)) / 1000
+	}
+	return candidates, nil
+}
+
+// specialityScore is how well the speciality covers the differential: the
+// probability of each condition it treats, discounted for specialities
+// further down that condition's list. It returns the best-matching area and
+// condition too.
+func specialityScore(speciality string, differential []mlclient.Differential) (float64, string, string) {
+	total, best := 0.0, 0.0
+	area, condition := "", ""
+	for _, d := range differential {
+		areas := Areas(d.Code)
+		for rank, a := range areas {
+			if !a.Matches(speciality) {
+				continue
+			}
+			weight := 1.0 - 0.3*float64(rank)
+			if a.Name == generalPractice.Name {
+				weight = 0.4
+			}
+			score := d.Probability * weight
+			total += score
+			if score > best {
+				best, area, condition = score, a.Name, d.Condition
+			}
+			break
+		}
+	}
+	if total > 1 {
+		total = 1
+	}
+	return total, area, condition
+}
+
+func sortByScore(recommendations []Recommendation) {
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		if recommendations[i].Score != recommendations[j].Score {
+			return recommendations[i].Score > recommendations[j].Score
+		}
+		return recommendations[i].Doctor.ID < recommendations[j].Doctor.ID
+	})
+}
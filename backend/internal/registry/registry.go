@@ -0,0 +1,257 @@
+// Package registry finds existing patients from demographic details received
+// from other systems, creating new patient accounts when nothing matches.
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"medapp/internal/auth"
+	"medapp/internal/duplicates"
+	"medapp/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAmbiguousMatch     = errors.New("more than one patient matches these details")
+	ErrNotPatient         = errors.New("matched account is not a patient")
+	ErrIdentifierConflict = errors.New("identifier already belongs to another patient")
+	ErrInsufficientData   = errors.New("a name is required to register a new patient")
+)
+
+// LocalSystem identifies medapp's own user ids in identifiers.
+const LocalSystem = "urn:medapp:user"
+
+// Identifier is an id assigned to the patient by some system.
+type Identifier struct {
+	System string
+	Value  string
+}
+
+// Candidate holds the demographics used to find or register a patient.
+type Candidate struct {
+	FullName         string
+	Email            string
+	Phone            string
+	BirthDate        *time.Time
+	Gender           string
+	EmergencyContact string
+	Identifiers      []Identifier
+}
+
+// Match looks for an existing patient, trying in order: a medapp user id,
+// a known external identifier, the email address, and finally name plus
+// date of birth. It returns nil when nothing matches.
+func Match(tx *gorm.DB, candidate *Candidate) (*models.User, error) {
+	for _, identifier := range candidate.Identifiers {
+		var userID uint
+		if identifier.System == LocalSystem {
+			if _, err := fmt.Sscan(identifier.Value, &userID); err != nil {
+				continue
+			}
+		} else {
+			var known models.PatientIdentifier
+			err := tx.Where("system = ? AND value = ?", identifier.System, identifier.Value).First(&known).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			userID = known.PatientID
+		}
+		user, err := loadPatient(tx, tx.Where("id = ?", userID))
+		if err != nil || user != nil {
+			return user, err
+		}
+	}
+
+	if email := strings.ToLower(strings.TrimSpace(candidate.Email)); email != "" {
+		user, err := loadPatient(tx, tx.Where("email = ?", email))
+		if err != nil || user != nil {
+			return user, err
+		}
+	}
+
+	if candidate.FullName != "" && candidate.BirthDate != nil {
+		var users []models.User
+		if err := tx.Preload("PatientProfile").
+			Joins("JOIN patient_profiles ON patient_profiles.user_id = users.id").
+			Where("users.role = ? AND users.status = ?", models.RolePatient, models.UserStatusActive).
+			Where("LOWER(users.full_name) = ? AND patient_profiles.date_of_birth::date = ?",
+				strings.ToLower(strings.TrimSpace(candidate.FullName)), candidate.BirthDate.Format("2006-01-02")).
+			Limit(2).Find(&users).Error; err != nil {
+			return nil, err
+		}
+		switch len(users) {
+		case 1:
+			return &users[0], nil
+		case 2:
+			return nil, ErrAmbiguousMatch
+		}
+	}
+	return nil, nil
+}
+
+// loadPatient loads the patient query finds, following the redirect when
+// the record was merged into another.
+func loadPatient(tx, query *gorm.DB) (*models.User, error) {
+	var user models.User
+	if err := query.Preload("PatientProfile").First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if user.Role != models.RolePatient {
+		return nil, ErrNotPatient
+	}
+	if user.Status == models.UserStatusMerged {
+		survivorID, merged, err := duplicates.Resolve(tx, user.ID)
+		if err != nil || !merged {
+			return nil, err
+		}
+		return loadPatient(tx, tx.Where("id = ?", survivorID))
+	}
+	return &user, nil
+}
+
+// MatchOrCreatePatient returns the matching patient, filling in profile
+// details that are still empty, or registers a new patient who must set a
+// password before signing in. Candidate identifiers are recorded either way.
+func MatchOrCreatePatient(tx *gorm.DB, candidate *Candidate) (*models.User, bool, error) {
+	user, err := Match(tx, candidate)
+	if err != nil {
+		return nil, false, err
+	}
+
+	created := false
+	if user == nil {
+		if user, err = create(tx, candidate); err != nil {
+			return nil, false, err
+		}
+		created = true
+	} else if err := fillProfile(tx, user, candidate); err != nil {
+		return nil, false, err
+	}
+
+	if err := AddIdentifiers(tx, user.ID, candidate.Identifiers); err != nil {
+		return nil, false, err
+	}
+	return user, created, nil
+}
+
+func create(tx *gorm.DB, candidate *Candidate) (*models.User, error) {
+	name := strings.TrimSpace(candidate.FullName)
+	if name == "" {
+		return nil, ErrInsufficientData
+	}
+
+	// Imported patients get an unusable password and must go through a reset
+	// before their first sign-in.
+	placeholder, err := auth.GenerateTemporaryPassword()
+	if err != nil {
+		return nil, err
+	}
+	hash, err := auth.HashPassword(placeholder)
+	if err != nil {
+		return nil, err
+	}
+
+	email := strings.ToLower(strings.TrimSpace(candidate.Email))
+	user := &models.User{
+		FullName:          name,
+		Email:             email,
+		Phone:             candidate.Phone,
+		PasswordHash:      hash,
+		Role:              models.RolePatient,
+		Status:            models.UserStatusActive,
+		MustResetPassword: true,
+	}
+	if email == "" {
+		// Email is required and unique; a placeholder keeps the row valid until the patient provides one.
+		user.Email = fmt.Sprintf("imported-%d@import.invalid", time.Now().UnixNano())
+	}
+	if err := tx.Create(user).Error; err != nil {
+		return nil, fmt.Errorf("create patient: %w", err)
+	}
+
+	user.PatientProfile = &models.PatientProfile{
+		UserID:           user.ID,
+		DateOfBirth:      candidate.BirthDate,
+		Gender:           candidate.Gender,
+		EmergencyContact: candidate.EmergencyContact,
+	}
+	if err := tx.Create(user.PatientProfile).Error; err != nil {
+		return nil, fmt.Errorf("create patient profile: %w", err)
+	}
+	return user, nil
+}
+
+func fillProfile(tx *gorm.DB, user *models.User, candidate *Candidate) error {
+	if user.Phone == "" && candidate.Phone != "" {
+		if err := tx.Model(user).Update("phone", candidate.Phone).Error; err != nil {
+			return err
+		}
+		user.Phone = candidate.Phone
+	}
+
+	profile := user.PatientProfile
+	if profile == nil {
+		profile = &models.PatientProfile{UserID: user.ID}
+		if err := tx.Create(profile).Error; err != nil {
+			return err
+		}
+		user.PatientProfile = profile
+	}
+
+	var fields []string
+	if profile.DateOfBirth == nil && candidate.BirthDate != nil {
+		profile.DateOfBirth = candidate.BirthDate
+		fields = append(fields, "DateOfBirth")
+	}
+	if profile.Gender == "" && candidate.Gender != "" {
+		profile.Gender = candidate.Gender
+		fields = append(fields, "Gender")
+	}
+	if profile.EmergencyContact == "" && candidate.EmergencyContact != "" {
+		profile.EmergencyContact = candidate.EmergencyContact
+		fields = append(fields, "EmergencyContact")
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return tx.Model(profile).Select(fields).Updates(profile).Error
+}
+
+// AddIdentifiers records external identifiers for the patient, skipping ones
+// already linked to them and medapp's own ids.
+func AddIdentifiers(tx *gorm.DB, patientID uint, identifiers []Identifier) error {
+	for _, identifier := range identifiers {
+		if identifier.System == LocalSystem || identifier.System == "" || identifier.Value == "" {
+			continue
+		}
+		var existing models.PatientIdentifier
+		err := tx.Where("system = ? AND value = ?", identifier.System, identifier.Value).First(&existing).Error
+		if err == nil {
+			if existing.PatientID != patientID {
+				return fmt.Errorf("%w: %s|%s", ErrIdentifierConflict, identifier.System, identifier.Value)
+			}
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err := tx.Create(&models.PatientIdentifier{
+			PatientID: patientID,
+			System:    identifier.System,
+			Value:     identifier.Value,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+// Package risk scores a patient's risk of chronic disease with the ML
+// service. The feature vector is built from the patient's record, and each
+// assessment is stored with a blind index of its features so the same record
+// is only scored once.
+package risk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"medapp/internal/ageband"
+	"medapp/internal/fieldcrypt"
+	"medapp/internal/mlclient"
+	"medapp/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Features builds the feature vector for a patient from their profile,
+// medical info and problem list. Anything not on record is left empty.
+func Features(tx *gorm.DB, patientID uint, now time.Time) (mlclient.RiskFeatures, error) {
+	features := mlclient.RiskFeatures{
+		Conditions:          []string{},
+		RemissionConditions: []string{},
+		ResolvedConditions:  []string{},
+	}
+
+	var profile models.PatientProfile
+	hasProfile := true
+	if err := tx.Where("user_id = ?", patientID).First(&profile).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return features, err
+		}
+		hasProfile = false
+	}
+	var info models.PatientMedicalInfo
+	hasInfo := true
+	if err := tx.Preload("Diseases").Where("patient_id = ?", patientID).First(&info).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return features, err
+		}
+		hasInfo = false
+	}
+	var problems []models.PatientProblem
+	if err := tx.Preload("Disease").Where("patient_id = ?", patientID).Find(&problems).Error; err != nil {
+		return features, err
+	}
+
+	var dob *time.Time
+	if hasProfile {
+		dob = profile.DateOfBirth
+		features.Gender = strings.ToLower(strings.TrimSpace(profile.Gender))
+		features.BloodType = strings.ToUpper(strings.TrimSpace(profile.BloodType))
+	}
+	manualBand := ""
+	active := map[string]bool{}
+	if hasInfo {
+		manualBand = info.AgeGroup
+		if features.Gender == "" {
+			features.Gender = strings.ToLower(strings.TrimSpace(info.Gender))
+		}
+		for _, disease := range info.Diseases {
+			if disease.Code != "" {
+				active[disease.Code] = true
+			}
+		}
+	}
+	age := ageband.Resolve(dob, manualBand, now)
+	features.Age = age.Age
+	features.AgeBand = age.Band
+
+	// A problem's status decides where its code goes; a code with several
+	// entries counts under its most current status
+	remission, resolved := map[string]bool{}, map[string]bool{}
+	for _, p := range problems {
+		if p.Disease == nil || p.Disease.Code == "" {
+			continue
+		}
+		switch p.Status {
+		case models.ProblemRemission:
+			remission[p.Disease.Code] = true
+		case models.ProblemResolved:
+			resolved[p.Disease.Code] = true
+		default:
+			active[p.Disease.Code] = true
+		}
+	}
+	for code := range active {
+		features.Conditions = append(features.Conditions, code)
+	}
+	for code := range remission {
+		if !active[code] {
+			features.RemissionConditions = append(features.RemissionConditions, code)
+		}
+	}
+	for code := range resolved {
+		if !active[code] && !remission[code] {
+			features.ResolvedConditions = append(features.ResolvedConditions, code)
+		}
+	}
+	sort.Strings(features.Conditions)
+	sort.Strings(features.RemissionConditions)
+	sort.Strings(features.ResolvedConditions)
+	return features, nil
+}
+
+// Assessment is a stored risk assessment with its decoded features and result.
+type Assessment struct {
+	Record   models.RiskAssessment
+	Features mlclient.RiskFeatures
+	Result   mlclient.RiskResponse
+	Cached   bool // answered from an earlier assessment of the same record
+}
+
+// Assess scores the patient's current record. The latest assessment of an
+// identical feature vector is reused unless refresh is set.
+func Assess(ctx context.Context, tx *gorm.DB, client *mlclient.Client, patientID uint, requestedByID *uint, refresh bool) (*Assessment, error) {
+	features, err := Features(tx, patientID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(features)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := fieldcrypt.BlindIndex(string(encoded))
+	if err != nil {
+		return nil, err
+	}
+
+	if !refresh {
+		var previous models.RiskAssessment
+		err := tx.Where("patient_id = ? AND feature_hash = ?", patientID, hash).Order("created_at DESC, id DESC").First(&previous).Error
+		switch {
+		case err == nil:
+			var result mlclient.RiskResponse
+			if err := json.Unmarshal([]byte(previous.Result), &result); err == nil {
+				return &Assessment{Record: previous, Features: features, Result: result, Cached: true}, nil
+			}
+			// An unreadable result is scored again
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, err
+		}
+	}
+
+	result, err := client.ScoreRisk(ctx, mlclient.RiskPayload{Features: features})
+	if err != nil {
+		return nil, err
+	}
+	if result.Risks == nil {
+		result.Risks = []mlclient.ConditionRisk{}
+	}
+	sort.SliceStable(result.Risks, func(i, j int) bool { return result.Risks[i].Risk > result.Risks[j].Risk })
+	output, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	record := models.RiskAssessment{
+		PatientID:     patientID,
+		FeatureHash:   hash,
+		ModelVersion:  result.ModelVersion,
+		Features:      string(encoded),
+		Result:        string(output),
+		RequestedByID: requestedByID,
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return nil, err
+	}
+	return &Assessment{Record: record, Features: features, Result: *result}, nil
+}
@@ -0,0 +1,164 @@
+// Package scheduling finds free appointment slots in a doctor's calendar.
+// Slots fall on weekdays within the clinic hours set by CLINIC_HOURS and are
+// SLOT_MINUTES long; a doctor is busy during their pending and confirmed
+// appointments.
+package scheduling
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"medapp/internal/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultHours       = "09:00-17:00"
+	defaultSlotMinutes = 30
+)
+
+var ErrNoFreeSlot = errors.New("no free slot in the doctor's calendar")
+
+// Hours are the daily opening hours as minutes after midnight.
+type Hours struct {
+	Open  int
+	Close int
+}
+
+// ParseHours reads "HH:MM-HH:MM".
+func ParseHours(spec string) (Hours, error) {
+	openText, closeText, ok := strings.Cut(spec, "-")
+	if !ok {
+		return Hours{}, fmt.Errorf("clinic hours %q must be HH:MM-HH:MM", spec)
+	}
+	var h Hours
+	var err error
+	if h.Open, err = parseClock(openText); err != nil {
+		return Hours{}, err
+	}
+	if h.Close, err = parseClock(closeText); err != nil {
+		return Hours{}, err
+	}
+	if h.Close <= h.Open {
+		return Hours{}, fmt.Errorf("clinic hours %q close before they open", spec)
+	}
+	return h, nil
+}
+
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+var (
+	configOnce  sync.Once
+	clinicHours Hours
+	slotLength  time.Duration
+)
+
+func config() (Hours, time.Duration) {
+	configOnce.Do(func() {
+		clinicHours, _ = ParseHours(defaultHours)
+		if spec := os.Getenv("CLINIC_HOURS"); spec != "" {
+			if h, err := ParseHours(spec); err == nil {
+				clinicHours = h
+			} else {
+				log.Printf("scheduling: ignoring invalid CLINIC_HOURS: %v", err)
+			}
+		}
+		minutes := defaultSlotMinutes
+		if value := os.Getenv("SLOT_MINUTES"); value != "" {
+			if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+				minutes = parsed
+			} else {
+				log.Printf("scheduling: ignoring invalid SLOT_MINUTES %q", value)
+			}
+		}
+		slotLength = time.Duration(minutes) * time.Minute
+	})
+	return clinicHours, slotLength
+}
+
+// SlotLength is the length of an appointment slot.
+func SlotLength() time.Duration {
+	_, slot := config()
+	return slot
+}
+
+type interval struct {
+	start, end time.Time
+}
+
+// busy loads the doctor's booked intervals overlapping [from, until).
+func busy(tx *gorm.DB, doctorID uint, from, until time.Time) ([]interval, error) {
+	var appointments []models.Appointment
+	// Looking back a day catches long appointments that started before from
+	if err := tx.Select("scheduled_at", "duration_min").
+		Where("doctor_id = ? AND status IN ? AND scheduled_at >= ? AND scheduled_at < ?", doctorID,
+			[]models.AppointmentStatus{models.AppointmentPending, models.AppointmentConfirmed},
+			from.Add(-24*time.Hour), until).
+		Find(&appointments).Error; err != nil {
+		return nil, err
+	}
+	intervals := make([]interval, 0, len(appointments))
+	for _, a := range appointments {
+		length := time.Duration(a.DurationMin) * time.Minute
+		if length <= 0 {
+			length = SlotLength()
+		}
+		intervals = append(intervals, interval{a.ScheduledAt, a.ScheduledAt.Add(length)})
+	}
+	return intervals, nil
+}
+
+// NextFreeSlot returns the start of the first slot of length duration after
+// from and before from+within in which the doctor has no appointment.
+func NextFreeSlot(tx *gorm.DB, doctorID uint, from time.Time, duration, within time.Duration) (time.Time, error) {
+	hours, slot := config()
+	if duration <= 0 {
+		duration = slot
+	}
+	until := from.Add(within)
+	booked, err := busy(tx, doctorID, from, until)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	for ; day.Before(until); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+		closing := day.Add(time.Duration(hours.Close) * time.Minute)
+		for start := day.Add(time.Duration(hours.Open) * time.Minute); !start.Add(duration).After(closing); start = start.Add(slot) {
+			if start.Before(from) {
+				continue
+			}
+			if !start.Before(until) {
+				return time.Time{}, ErrNoFreeSlot
+			}
+			end := start.Add(duration)
+			free := true
+			for _, b := range booked {
+				if start.Before(b.end) && b.start.Before(end) {
+					free = false
+					break
+				}
+			}
+			if free {
+				return start, nil
+			}
+		}
+	}
+	return time.Time{}, ErrNoFreeSlot
+}
@@ -0,0 +1,179 @@
+// Package symptomcheck validates symptom checker requests against the
+// symptom catalog and adds what the patient's record says about them before
+// the request goes to the ML service.
+package symptomcheck
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"medapp/internal/ageband"
+	"medapp/internal/mlclient"
+	"medapp/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Symptom severities
+const (
+	SeverityMild     = "mild"
+	SeverityModerate = "moderate"
+	SeveritySevere   = "severe"
+)
+
+// Severities lists the accepted severities, mildest first.
+var Severities = []string{SeverityMild, SeverityModerate, SeveritySevere}
+
+const (
+	MaxSymptoms     = 25
+	MaxDurationDays = 365
+)
+
+// Request is a version 2 symptom checker request.
+type Request struct {
+	Version  int               `json:"version"`
+	Symptoms []ReportedSymptom `json:"symptoms"`
+	City     string            `json:"city"` // preferred city for recommended doctors; not sent to the model
+}
+
+type ReportedSymptom struct {
+	Code         string `json:"code"`
+	Severity     string `json:"severity"` // defaults to moderate
+	DurationDays int    `json:"durationDays"`
+}
+
+// ValidationError lists everything wrong with a request.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return strings.Join(e.Problems, "; ")
+}
+
+// Validate checks req against the active symptom catalog and returns the
+// symptoms to send, with codes and severities normalised. Problems with the
+// request are reported as a *ValidationError.
+func Validate(tx *gorm.DB, req *Request) ([]mlclient.Symptom, error) {
+	verr := &ValidationError{}
+	if req.Version != mlclient.SymptomsSchemaVersion {
+		verr.Problems = append(verr.Problems, fmt.Sprintf("unsupported schema version %d", req.Version))
+		return nil, verr
+	}
+	if len(req.Symptoms) == 0 {
+		verr.Problems = append(verr.Problems, "at least one symptom is required")
+		return nil, verr
+	}
+	if len(req.Symptoms) > MaxSymptoms {
+		verr.Problems = append(verr.Problems, fmt.Sprintf("at most %d symptoms can be checked at once", MaxSymptoms))
+		return nil, verr
+	}
+
+	codes := make([]string, 0, len(req.Symptoms))
+	for _, s := range req.Symptoms {
+		codes = append(codes, normalizeCode(s.Code))
+	}
+	var known []string
+	if err := tx.Model(&models.Symptom{}).Where("code IN ? AND retired = ?", codes, false).Pluck("code", &known).Error; err != nil {
+		return nil, err
+	}
+	active := make(map[string]bool, len(known))
+	for _, code := range known {
+		active[code] = true
+	}
+
+	symptoms := make([]mlclient.Symptom, 0, len(req.Symptoms))
+	seen := make(map[string]bool, len(req.Symptoms))
+	for i, s := range req.Symptoms {
+		code := codes[i]
+		switch {
+		case code == "":
+			verr.Problems = append(verr.Problems, fmt.Sprintf("symptoms[%d]: code is required", i))
+			continue
+		case !active[code]:
+			verr.Problems = append(verr.Problems, fmt.Sprintf("symptoms[%d]: unknown symptom %q", i, code))
+			continue
+		case seen[code]:
+			verr.Problems = append(verr.Problems, fmt.Sprintf("symptoms[%d]: %q is listed twice", i, code))
+			continue
+		}
+		seen[code] = true
+
+		severity := strings.ToLower(strings.TrimSpace(s.Severity))
+		if severity == "" {
+			severity = SeverityModerate
+		}
+		if !validSeverity(severity) {
+			verr.Problems = append(verr.Problems, fmt.Sprintf("symptoms[%d]: severity must be mild, moderate or severe", i))
+		}
+		if s.DurationDays < 0 || s.DurationDays > MaxDurationDays {
+			verr.Problems = append(verr.Problems, fmt.Sprintf("symptoms[%d]: durationDays must be between 0 and %d", i, MaxDurationDays))
+		}
+		symptoms = append(symptoms, mlclient.Symptom{Code: code, Severity: severity, DurationDays: s.DurationDays})
+	}
+	if len(verr.Problems) > 0 {
+		return nil, verr
+	}
+	return symptoms, nil
+}
+
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.TrimSpace(code))
+}
+
+func validSeverity(severity string) bool {
+	for _, s := range Severities {
+		if s == severity {
+			return true
+		}
+	}
+	return false
+}
+
+// PatientContext describes the patient from their profile and medical info:
+// the age band, the gender and the ICD-10 codes of their recorded diseases.
+// Anything not on record is left empty.
+func PatientContext(tx *gorm.DB, patientID uint, now time.Time) (mlclient.PatientContext, error) {
+	ctx := mlclient.PatientContext{ChronicConditions: []string{}}
+
+	var profile models.PatientProfile
+	hasProfile := true
+	if err := tx.Where("user_id = ?", patientID).First(&profile).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx, err
+		}
+		hasProfile = false
+	}
+	var info models.PatientMedicalInfo
+	hasInfo := true
+	if err := tx.Preload("Diseases").Where("patient_id = ?", patientID).First(&info).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx, err
+		}
+		hasInfo = false
+	}
+
+	var dob *time.Time
+	if hasProfile {
+		dob = profile.DateOfBirth
+		ctx.Gender = strings.ToLower(strings.TrimSpace(profile.Gender))
+	}
+	manualBand := ""
+	if hasInfo {
+		manualBand = info.AgeGroup
+		if ctx.Gender == "" {
+			ctx.Gender = strings.ToLower(strings.TrimSpace(info.Gender))
+		}
+		for _, disease := range info.Diseases {
+			if disease.Code != "" {
+				ctx.ChronicConditions = append(ctx.ChronicConditions, disease.Code)
+			}
+		}
+		sort.Strings(ctx.ChronicConditions)
+	}
+	ctx.AgeBand = ageband.Resolve(dob, manualBand, now).Band
+	return ctx, nil
+}
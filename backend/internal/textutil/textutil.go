@@ -0,0 +1,18 @@
+// Package textutil holds small string helpers shared across packages.
+package textutil
+
+// Truncate shortens value to at most max characters, the unit Postgres
+// varchar(n) columns are sized in. It never splits a UTF-8 character.
+func Truncate(value string, max int) string {
+	if len(value) <= max {
+		return value
+	}
+	n := 0
+	for i := range value {
+		if n == max {
+			return value[:i]
+		}
+		n++
+	}
+	return value
+}
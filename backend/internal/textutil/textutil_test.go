@@ -0,0 +1,23 @@
+package textutil
+
+import "testing"
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		value string
+		max   int
+		want  string
+	}{
+		{"short", 10, "short"},
+		{"exactly", 7, "exactly"},
+		{"truncated", 5, "trunc"},
+		{"Zürich", 2, "Zü"},
+		{"日本語テキスト", 3, "日本語"},
+		{"naïve", 0, ""},
+	}
+	for _, tt := range tests {
+		if got := Truncate(tt.value, tt.max); got != tt.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tt.value, tt.max, got, tt.want)
+		}
+	}
+}
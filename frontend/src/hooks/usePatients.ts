@@ -14,7 +14,10 @@ export interface PatientMedicalInfo {
   doctorId: number;
   gender: string;
   ageGroup: string;
+  ageGroupSource?: "dateOfBirth" | "manual";
+  age?: number | null;
   diseases: Disease[];
+  version: number;
   updatedAt: string;
   patient?: { id: number; fullName: string };
   doctor?: { id: number; fullName: string };
@@ -25,6 +28,7 @@ export interface Patient {
   fullName: string;
   email: string;
   phone?: string;
+  age?: number | null;
   medicalInfo?: PatientMedicalInfo;
 }
 
@@ -69,16 +73,19 @@ export const useUpdateMedicalInfo = () => {
       gender,
       ageGroup,
       diseaseIds,
+      version,
     }: {
       patientId: number;
       gender: string;
       ageGroup: string;
       diseaseIds: number[];
+      version?: number;
     }) => {
       const { data } = await api.post(`/patients/${patientId}/medical-info`, {
         gender,
         ageGroup,
         diseaseIds,
+        version,
       });
       return data;
     },
@@ -51,6 +51,9 @@ const PatientInfoPage = () => {
     },
   });
 
+  const hasDateOfBirth = () =>
+    patients.find((p) => String(p.id) === selectedPatientId)?.age != null;
+
   const form = useForm({
     initialValues: {
       gender: "",
@@ -59,7 +62,8 @@ const PatientInfoPage = () => {
     },
     validate: {
       gender: (value) => (!value ? "Gender is required" : null),
-      ageGroup: (value) => (!value ? "Age group is required" : null),
+      // The age group is derived from the date of birth when the patient has one
+      ageGroup: (value) => (!value && !hasDateOfBirth() ? "Age group is required" : null),
     },
     transformValues: (values) => ({
       ...values,
@@ -70,7 +74,7 @@ const PatientInfoPage = () => {
   const queryClient = useQueryClient();
   
   const mutation = useMutation({
-    mutationFn: async (values: { gender: string; ageGroup: string; diseaseIds: number[] }) => {
+    mutationFn: async (values: { gender: string; ageGroup: string; diseaseIds: number[]; version?: number }) => {
       const { data } = await api.post(`/patients/${selectedPatientId}/medical-info`, values);
       return data;
     },
@@ -86,6 +90,10 @@ const PatientInfoPage = () => {
       queryClient.invalidateQueries({ queryKey: ["patients"] });
     },
     onError: (error: any) => {
+      if (error?.response?.status === 409) {
+        // Reload so the doctor sees the other edit before trying again
+        queryClient.invalidateQueries({ queryKey: ["patients"] });
+      }
       notifications.show({
         title: "Save failed",
         message: error?.response?.data?.error || "Failed to save information",
@@ -103,10 +111,13 @@ const PatientInfoPage = () => {
       });
       return;
     }
+    // Send the version the form was loaded from so a concurrent edit is rejected, not overwritten
+    const current = patients.find((p) => String(p.id) === selectedPatientId);
     mutation.mutate({
       gender: values.gender,
       ageGroup: values.ageGroup,
       diseaseIds: values.diseaseIds.map(Number),
+      version: current?.medicalInfo?.version,
     });
   });
 
@@ -180,13 +191,19 @@ const PatientInfoPage = () => {
                 {...form.getInputProps("gender")}
               />
 
-              <Select
-                label="Age Group"
-                placeholder="Select age group"
-                data={AGE_GROUP_OPTIONS}
-                required
-                {...form.getInputProps("ageGroup")}
-              />
+              {selectedPatient?.age != null ? (
+                <Text size="sm">
+                  Age: {selectedPatient.age} (age group {selectedPatient.medicalInfo?.ageGroup || "from date of birth"})
+                </Text>
+              ) : (
+                <Select
+                  label="Age Group"
+                  placeholder="Select age group"
+                  data={AGE_GROUP_OPTIONS}
+                  required
+                  {...form.getInputProps("ageGroup")}
+                />
+              )}
 
               {diseasesLoading ? (
                 <Text c="dimmed" size="sm">Loading diseases...</Text>
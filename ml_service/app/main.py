@@ -1,5 +1,5 @@
 from fastapi import FastAPI
-from app.routers import predict
+from app.routers import predict, predict_v2, risk
 
 app = FastAPI(title="MedApp ML Service")
 
@@ -7,4 +7,6 @@ app = FastAPI(title="MedApp ML Service")
 def root():
     return {"message": "ML service is running"}
 
-app.include_router(predict.router)
\ No newline at end of file
+app.include_router(predict.router)
+app.include_router(predict_v2.router)
+app.include_router(risk.router)
\ No newline at end of file
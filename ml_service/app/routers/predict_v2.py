@@ -0,0 +1,179 @@
+from math import exp
+from typing import Dict, List
+
+from fastapi import APIRouter
+
+from app.schemas import Differential, SymptomsV2Request, SymptomsV2Response
+
+router = APIRouter(prefix="/v2/predict", tags=["Predict"])
+
+MODEL_VERSION = "rules-2.0"
+
+SEVERITY_FACTOR = {"mild": 0.6, "moderate": 1.0, "severe": 1.4}
+
+URGENCY_ORDER = ["self_care", "routine", "soon", "emergency"]
+
+ADVICE = {
+    "self_care": "Rest, drink fluids and monitor your symptoms. Book a visit if they get worse or last longer than a week.",
+    "routine": "Book a routine appointment with your doctor.",
+    "soon": "See a doctor within the next day or two.",
+    "emergency": "Seek emergency care now or call emergency services.",
+}
+
+# Each condition has a prior log-odds, symptom weights and the urgency it
+# usually calls for. Duration hints move the score for short or long illnesses.
+CONDITIONS: List[Dict] = [
+    {
+        "condition": "Common cold", "code": "J00", "prior": 0.8, "urgency": "self_care",
+        "weights": {"runny_nose": 1.6, "sore_throat": 1.1, "cough": 0.8, "headache": 0.3, "fatigue": 0.3, "fever": -0.4},
+        "maxDays": 10,
+    },
+    {
+        "condition": "Influenza", "code": "J11", "prior": 0.2, "urgency": "routine",
+        "weights": {"fever": 1.6, "muscle_pain": 1.3, "chills": 1.1, "fatigue": 0.9, "headache": 0.7, "cough": 0.7, "sore_throat": 0.4},
+        "maxDays": 14,
+    },
+    {
+        "condition": "COVID-19", "code": "U07.1", "prior": 0.0, "urgency": "routine",
+        "weights": {"loss_of_smell": 2.2, "fever": 1.0, "cough": 0.9, "fatigue": 0.8, "shortness_of_breath": 0.6, "sore_throat": 0.3},
+        "maxDays": 21,
+    },
+    {
+        "condition": "Pneumonia", "code": "J18.9", "prior": -1.2, "urgency": "soon",
+        "weights": {"fever": 1.1, "cough": 1.2, "shortness_of_breath": 1.5, "chest_pain": 0.7, "chills": 0.6, "fatigue": 0.4},
+        "minDays": 3,
+        "chronic": {"J44": 0.8, "J45": 0.4},
+    },
+    {
+        "condition": "Acute bronchitis", "code": "J20.9", "prior": -0.3, "urgency": "routine",
+        "weights": {"cough": 1.7, "fatigue": 0.3, "shortness_of_breath": 0.4, "sore_throat": 0.3},
+        "chronic": {"J44": 0.5},
+    },
+    {
+        "condition": "Asthma exacerbation", "code": "J45", "prior": -2.0, "urgency": "soon",
+        "weights": {"shortness_of_breath": 2.0, "cough": 0.8, "chest_pain": 0.3},
+        "chronic": {"J45": 2.5},
+    },
+    {
+        "condition": "Migraine", "code": "G43", "prior": -0.5, "urgency": "routine",
+        "weights": {"headache": 2.0, "nausea": 0.9, "dizziness": 0.5, "fever": -0.8},
+        "chronic": {"G43": 1.5},
+    },
+    {
+        "condition": "Tension headache", "code": "G44.2", "prior": 0.0, "urgency": "self_care",
+        "weights": {"headache": 1.6, "fatigue": 0.3, "fever": -0.8, "nausea": -0.3},
+    },
+    {
+        "condition": "Gastroenteritis", "code": "A09", "prior": 0.0, "urgency": "self_care",
+        "weights": {"diarrhea": 2.0, "nausea": 1.5, "abdominal_pain": 1.0, "fever": 0.4},
+        "maxDays": 7,
+    },
+    {
+        "condition": "Urinary tract infection", "code": "N39.0", "prior": -0.8, "urgency": "routine",
+        "weights": {"frequent_urination": 2.2, "abdominal_pain": 0.6, "fever": 0.4},
+        "gender": {"female": 0.8},
+    },
+    {
+        "condition": "Diabetes, poorly controlled", "code": "E11", "prior": -2.0, "urgency": "routine",
+        "weights": {"excessive_thirst": 2.0, "frequent_urination": 1.4, "fatigue": 0.6, "dizziness": 0.3},
+        "minDays": 7,
+        "chronic": {"E11": 1.5, "E10": 1.5, "E66": 0.6},
+    },
+    {
+        "condition": "Acute coronary syndrome", "code": "I24.9", "prior": -3.0, "urgency": "emergency",
+        "weights": {"chest_pain": 2.8, "shortness_of_breath": 1.0, "dizziness": 0.6, "nausea": 0.5, "palpitations": 0.4},
+        "chronic": {"I10": 0.8, "I51.9": 1.2, "E11": 0.6, "E10": 0.6},
+        "ageBands": {"51-65": 0.8, "65+": 1.2, "0-18": -2.0},
+    },
+    {
+        "condition": "Cardiac arrhythmia", "code": "I49.9", "prior": -2.2, "urgency": "soon",
+        "weights": {"palpitations": 2.6, "dizziness": 0.8, "shortness_of_breath": 0.5, "chest_pain": 0.4},
+        "chronic": {"I51.9": 1.0, "E07.9": 0.5},
+    },
+    {
+        "condition": "Anxiety", "code": "F41", "prior": -1.5, "urgency": "routine",
+        "weights": {"palpitations": 1.0, "dizziness": 0.7, "chest_pain": 0.4, "shortness_of_breath": 0.4, "fatigue": 0.3},
+        "chronic": {"F41": 1.5},
+    },
+    {
+        "condition": "Allergic reaction", "code": "T78.4", "prior": -1.2, "urgency": "routine",
+        "weights": {"rash": 2.2, "runny_nose": 0.6, "shortness_of_breath": 0.5},
+    },
+    {
+        "condition": "Viral arthralgia", "code": "M25.5", "prior": -1.0, "urgency": "self_care",
+        "weights": {"joint_pain": 1.8, "muscle_pain": 0.8, "fever": 0.5, "rash": 0.4},
+        "chronic": {"M13.9": 1.0},
+    },
+]
+
+TOP_N = 5
+MIN_PROBABILITY = 0.02
+
+
+def _red_flags(symptoms: Dict[str, str]) -> List[str]:
+    flags = []
+    if "chest_pain" in symptoms and (symptoms["chest_pain"] == "severe" or "shortness_of_breath" in symptoms):
+        flags.append("chest_pain")
+    if symptoms.get("shortness_of_breath") == "severe":
+        flags.append("shortness_of_breath")
+    return flags
+
+
+def _score(condition: Dict, request: SymptomsV2Request) -> float:
+    score = condition["prior"]
+    weights = condition["weights"]
+    for symptom in request.symptoms:
+        weight = weights.get(symptom.code)
+        if weight is None:
+            # A symptom the condition does not explain makes it a little less likely
+            score -= 0.2
+            continue
+        score += weight * SEVERITY_FACTOR[symptom.severity]
+        if "maxDays" in condition and symptom.durationDays > condition["maxDays"]:
+            score -= 0.5
+        if "minDays" in condition and symptom.durationDays >= condition["minDays"]:
+            score += 0.3
+
+    patient = request.patient
+    for code in patient.chronicConditions:
+        score += condition.get("chronic", {}).get(code, 0.0)
+    if patient.ageBand:
+        score += condition.get("ageBands", {}).get(patient.ageBand, 0.0)
+    if patient.gender:
+        score += condition.get("gender", {}).get(patient.gender, 0.0)
+    return score
+
+
+@router.post("/", response_model=SymptomsV2Response)
+def predict(request: SymptomsV2Request):
+    scores = [(condition, _score(condition, request)) for condition in CONDITIONS]
+    top = max(score for _, score in scores)
+    weights = [(condition, exp(score - top)) for condition, score in scores]
+    total = sum(weight for _, weight in weights)
+    ranked = sorted(weights, key=lambda item: item[1], reverse=True)
+
+    differential = []
+    urgency = "self_care"
+    for condition, weight in ranked[:TOP_N]:
+        probability = weight / total
+        if probability < MIN_PROBABILITY:
+            break
+        differential.append(Differential(condition=condition["condition"], code=condition["code"], probability=round(probability, 3)))
+        # Unlikely conditions should not raise the urgency on their own
+        if probability >= 0.15 and URGENCY_ORDER.index(condition["urgency"]) > URGENCY_ORDER.index(urgency):
+            urgency = condition["urgency"]
+
+    flags = _red_flags({s.code: s.severity for s in request.symptoms})
+    if flags:
+        urgency = "emergency"
+    elif urgency == "self_care" and any(s.severity == "severe" for s in request.symptoms):
+        urgency = "routine"
+
+    return SymptomsV2Response(
+        version=2,
+        modelVersion=MODEL_VERSION,
+        differential=differential,
+        urgency=urgency,
+        advice=ADVICE[urgency],
+        redFlags=flags,
+    )
@@ -0,0 +1,145 @@
+import re
+from math import exp
+from typing import Dict, List, Optional, Tuple
+
+from fastapi import APIRouter
+
+from app.schemas import ConditionRisk, RiskFactor, RiskFeatures, RiskRequest, RiskResponse
+
+router = APIRouter(prefix="/risk", tags=["Risk"])
+
+MODEL_VERSION = "risk-rules-1.0"
+
+# Conditions in remission count for this share of their weight; resolved ones
+# for a smaller share.
+REMISSION_FACTOR = 0.5
+RESOLVED_FACTOR = 0.2
+
+# Each condition has a baseline log-odds at age 40, a per-year age slope, and
+# weights for recorded conditions keyed by ICD-10 code prefix. A condition the
+# patient already has is not scored.
+CONDITIONS: List[Dict] = [
+    {
+        "condition": "Type 2 diabetes", "code": "E11", "intercept": -3.2, "ageSlope": 0.045,
+        "gender": {"male": 0.2},
+        "factors": {"E66": 1.4, "R73": 1.6, "I10": 0.6, "E78": 0.5, "O24": 1.2, "E28.2": 0.8},
+    },
+    {
+        "condition": "Hypertension", "code": "I10", "intercept": -2.0, "ageSlope": 0.055,
+        "gender": {"male": 0.25},
+        "factors": {"E66": 1.0, "E11": 0.8, "E10": 0.5, "N18": 1.2, "E78": 0.4, "G47.3": 0.7},
+    },
+    {
+        "condition": "Coronary heart disease", "code": "I25", "intercept": -4.2, "ageSlope": 0.07,
+        "gender": {"male": 0.6},
+        "factors": {"I10": 0.9, "E11": 0.9, "E10": 0.7, "E78": 0.8, "E66": 0.4, "N18": 0.7, "F17": 0.9, "I24": 1.5, "I21": 1.8},
+    },
+    {
+        "condition": "Stroke", "code": "I63", "intercept": -5.0, "ageSlope": 0.075,
+        "factors": {"I10": 1.1, "I48": 1.4, "E11": 0.6, "E78": 0.4, "F17": 0.7, "G45": 1.6},
+    },
+    {
+        "condition": "Chronic kidney disease", "code": "N18", "intercept": -4.0, "ageSlope": 0.06,
+        "factors": {"E11": 1.3, "E10": 1.2, "I10": 1.0, "I50": 0.8, "N20": 0.5, "N39.0": 0.2},
+    },
+    {
+        "condition": "Chronic obstructive pulmonary disease", "code": "J44", "intercept": -4.3, "ageSlope": 0.06,
+        "factors": {"F17": 2.0, "J45": 1.0, "J42": 1.2, "J18": 0.4},
+    },
+    {
+        "condition": "Heart failure", "code": "I50", "intercept": -5.2, "ageSlope": 0.08,
+        "gender": {"male": 0.3},
+        "factors": {"I25": 1.4, "I21": 1.6, "I10": 0.9, "I48": 1.0, "E11": 0.7, "N18": 0.6, "E66": 0.5},
+    },
+]
+
+REFERENCE_AGE = 40
+
+# Cut-offs for the risk levels shown to doctors
+LEVELS = [(0.25, "high"), (0.1, "moderate"), (0.0, "low")]
+
+BAND_PATTERN = re.compile(r"^(\d+)\s*(?:-\s*(\d+)|\+)$")
+
+
+def _band_age(band: Optional[str]) -> Optional[float]:
+    """Estimates an age from a band label such as 36-50 or 65+."""
+    if not band:
+        return None
+    match = BAND_PATTERN.match(band.strip())
+    if not match:
+        return None
+    low = int(match.group(1))
+    if match.group(2):
+        return (low + int(match.group(2))) / 2
+    return low + 5
+
+
+def _matches(code: str, prefix: str) -> bool:
+    return code.upper().startswith(prefix.upper())
+
+
+def _has(codes: List[str], prefix: str) -> bool:
+    return any(_matches(code, prefix) for code in codes)
+
+
+def _score(condition: Dict, features: RiskFeatures) -> Tuple[float, List[RiskFactor]]:
+    score = condition["intercept"]
+    factors: List[RiskFactor] = []
+
+    if features.age is not None:
+        age, source = float(features.age), f"Age {features.age}"
+    else:
+        age, source = _band_age(features.ageBand), f"Age band {features.ageBand}"
+    if age is not None:
+        contribution = condition["ageSlope"] * (age - REFERENCE_AGE)
+        score += contribution
+        factors.append(RiskFactor(feature="age", description=source, contribution=contribution))
+
+    gender = (features.gender or "").lower()
+    if gender in condition.get("gender", {}):
+        contribution = condition["gender"][gender]
+        score += contribution
+        factors.append(RiskFactor(feature="gender", description=f"Gender {gender}", contribution=contribution))
+
+    for prefix, weight in condition["factors"].items():
+        for codes, share, status in (
+            (features.conditions, 1.0, "active"),
+            (features.remissionConditions, REMISSION_FACTOR, "in remission"),
+            (features.resolvedConditions, RESOLVED_FACTOR, "resolved"),
+        ):
+            if _has(codes, prefix):
+                contribution = weight * share
+                score += contribution
+                factors.append(RiskFactor(feature=f"condition:{prefix}", description=f"{prefix} ({status})", contribution=contribution))
+                break
+    return score, factors
+
+
+def _level(risk: float) -> str:
+    for cutoff, level in LEVELS:
+        if risk >= cutoff:
+            return level
+    return "low"
+
+
+@router.post("/", response_model=RiskResponse)
+def score(request: RiskRequest):
+    features = request.features
+    risks = []
+    for condition in CONDITIONS:
+        if _has(features.conditions, condition["code"]):
+            continue
+        log_odds, factors = _score(condition, features)
+        risk = 1 / (1 + exp(-log_odds))
+        factors = sorted(factors, key=lambda f: abs(f.contribution), reverse=True)
+        for factor in factors:
+            factor.contribution = round(factor.contribution, 3)
+        risks.append(ConditionRisk(
+            condition=condition["condition"],
+            code=condition["code"],
+            risk=round(risk, 3),
+            level=_level(risk),
+            factors=factors,
+        ))
+    risks.sort(key=lambda r: r.risk, reverse=True)
+    return RiskResponse(version=1, modelVersion=MODEL_VERSION, risks=risks)
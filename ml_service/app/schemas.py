@@ -0,0 +1,71 @@
+from typing import List, Literal, Optional
+
+from pydantic import BaseModel, Field
+
+
+class SymptomInput(BaseModel):
+    code: str
+    severity: Literal["mild", "moderate", "severe"] = "moderate"
+    durationDays: int = Field(0, ge=0, le=365)
+
+
+class PatientContext(BaseModel):
+    ageBand: Optional[str] = None
+    gender: Optional[str] = None
+    chronicConditions: List[str] = []
+
+
+class SymptomsV2Request(BaseModel):
+    version: Literal[2]
+    symptoms: List[SymptomInput] = Field(..., min_length=1)
+    patient: PatientContext = PatientContext()
+
+
+class Differential(BaseModel):
+    condition: str
+    code: Optional[str] = None
+    probability: float
+
+
+class SymptomsV2Response(BaseModel):
+    version: int
+    modelVersion: str
+    differential: List[Differential]
+    urgency: Literal["self_care", "routine", "soon", "emergency"]
+    advice: str
+    redFlags: List[str]
+
+
+class RiskFeatures(BaseModel):
+    age: Optional[int] = Field(None, ge=0, le=130)
+    ageBand: Optional[str] = None
+    gender: Optional[str] = None
+    bloodType: Optional[str] = None
+    conditions: List[str] = []
+    remissionConditions: List[str] = []
+    resolvedConditions: List[str] = []
+
+
+class RiskRequest(BaseModel):
+    version: Literal[1]
+    features: RiskFeatures
+
+
+class RiskFactor(BaseModel):
+    feature: str
+    description: str
+    contribution: float
+
+
+class ConditionRisk(BaseModel):
+    condition: str
+    code: str
+    risk: float
+    level: Literal["low", "moderate", "high"]
+    factors: List[RiskFactor]
+
+
+class RiskResponse(BaseModel):
+    version: int
+    modelVersion: str
+    risks: List[ConditionRisk]